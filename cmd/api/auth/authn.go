@@ -50,7 +50,7 @@ func Authentication(tri clientAuthnv1.TokenReviewInterface, cache *cache.Cache,
 	return func(c *gin.Context) {
 		token, err := extractBearerToken(c.GetHeader("Authorization"))
 		if err != nil {
-			abort.Abort(c, err, http.StatusBadRequest)
+			abort.Abort(c, err, http.StatusUnauthorized)
 			return
 		}
 
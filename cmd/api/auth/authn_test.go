@@ -72,7 +72,7 @@ func TestInvalidAuthHeader(t *testing.T) {
 
 			Authentication(ftr, cacheStorage, cacheExpirationDuration, cacheExpirationDuration)(c)
 
-			assert.Equal(t, http.StatusBadRequest, res.Code)
+			assert.Equal(t, http.StatusUnauthorized, res.Code)
 			assert.Equal(t, nil, cacheStorage.Get("fakeusername"), "Cache shouldn't be populated at this point in the code.")
 
 			_, usrExists := c.Get("user")
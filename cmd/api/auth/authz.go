@@ -6,10 +6,12 @@ package auth
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/kubearchive/kubearchive/cmd/api/discovery"
 	"github.com/kubearchive/kubearchive/pkg/abort"
 	"github.com/kubearchive/kubearchive/pkg/cache"
 	"k8s.io/apiserver/pkg/authentication/user"
@@ -17,6 +19,8 @@ import (
 	"github.com/gin-gonic/gin"
 
 	apiAuthzv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	clientAuthzv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 )
 
@@ -26,17 +30,6 @@ func RBACAuthorization(
 	cacheExpirationAuthorized,
 	cacheExpirationUnauthorized time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		usr, ok := c.Get("user")
-		if !ok {
-			abort.Abort(c, errors.New("user not found in context"), http.StatusInternalServerError)
-			return
-		}
-		userInfo, ok := usr.(user.Info)
-		if !ok {
-			abort.Abort(c, fmt.Errorf("unexpected user type in context: %T", usr), http.StatusInternalServerError)
-			return
-		}
-
 		verb := "list"
 		if c.Param("name") != "" {
 			verb = "get"
@@ -62,22 +55,138 @@ func RBACAuthorization(
 			})
 		}
 
-		errSar := doSarRequests(
-			c.Request.Context(),
-			sari,
-			userInfo,
-			resourceAttributes,
-			cache,
-			cacheExpirationAuthorized,
-			cacheExpirationUnauthorized,
-		)
-
-		if errSar != nil {
-			if errors.Is(errSar, errUnauth) {
-				abort.Abort(c, errSar, http.StatusUnauthorized)
-				return
-			}
-			abort.Abort(c, errSar, http.StatusInternalServerError)
+		authorize(c, sari, cache, cacheExpirationAuthorized, cacheExpirationUnauthorized, resourceAttributes)
+	}
+}
+
+// StatsAuthorization checks that the user can get the `stats` resource of the kubearchive.org
+// group, in the namespace of the request or cluster wide when the request has no namespace
+func StatsAuthorization(
+	sari clientAuthzv1.SubjectAccessReviewInterface,
+	cache *cache.Cache,
+	cacheExpirationAuthorized,
+	cacheExpirationUnauthorized time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authorize(c, sari, cache, cacheExpirationAuthorized, cacheExpirationUnauthorized,
+			[]*apiAuthzv1.ResourceAttributes{
+				{
+					Namespace: c.Param("namespace"),
+					Group:     "kubearchive.org",
+					Version:   "v1",
+					Resource:  "stats",
+					Verb:      "get",
+				},
+			})
+	}
+}
+
+// KindAuthorizer checks the user of a request can list the resources of a kind, for the routes that return
+// archived resources of other kinds than the one in their path
+type KindAuthorizer struct {
+	sari                        clientAuthzv1.SubjectAccessReviewInterface
+	resources                   *discovery.ArchivedAPI
+	cache                       *cache.Cache
+	cacheExpirationAuthorized   time.Duration
+	cacheExpirationUnauthorized time.Duration
+}
+
+func NewKindAuthorizer(
+	sari clientAuthzv1.SubjectAccessReviewInterface,
+	resources *discovery.ArchivedAPI,
+	cache *cache.Cache,
+	cacheExpirationAuthorized,
+	cacheExpirationUnauthorized time.Duration) *KindAuthorizer {
+	return &KindAuthorizer{
+		sari:                        sari,
+		resources:                   resources,
+		cache:                       cache,
+		cacheExpirationAuthorized:   cacheExpirationAuthorized,
+		cacheExpirationUnauthorized: cacheExpirationUnauthorized,
+	}
+}
+
+// CanList returns whether the user in the context can list the resources of the kind in the namespace. Kinds
+// that are not served by the cluster nor defined by a stored definition, or that can not be discovered, can not
+// be listed
+func (k *KindAuthorizer) CanList(c *gin.Context, apiVersion, kind, namespace string) (bool, error) {
+	userInfo, err := userFromContext(c)
+	if err != nil {
+		return false, err
+	}
+	groupVersion, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return false, err
+	}
+	resource, err := k.resources.ResourceForKind(c.Request.Context(), groupVersion, kind)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "unable to discover the kind, it can not be listed",
+			"apiVersion", apiVersion, "kind", kind, "error", err.Error())
+		return false, nil
+	}
+	if resource == nil {
+		return false, nil
+	}
+	if !resource.Namespaced {
+		namespace = ""
+	}
+
+	err = doSarRequests(c.Request.Context(), k.sari, userInfo, []*apiAuthzv1.ResourceAttributes{
+		{
+			Namespace: namespace,
+			Group:     groupVersion.Group,
+			Version:   groupVersion.Version,
+			Resource:  resource.Name,
+			Verb:      "list",
+		},
+	}, k.cache, k.cacheExpirationAuthorized, k.cacheExpirationUnauthorized)
+	if apierrors.IsForbidden(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// userFromContext returns the user set in the context by the authentication
+func userFromContext(c *gin.Context) (user.Info, error) {
+	usr, ok := c.Get("user")
+	if !ok {
+		return nil, errors.New("user not found in context")
+	}
+	userInfo, ok := usr.(user.Info)
+	if !ok {
+		return nil, fmt.Errorf("unexpected user type in context: %T", usr)
+	}
+	return userInfo, nil
+}
+
+// authorize aborts the request unless the user in the context is allowed to perform all the resourceAttributes
+func authorize(
+	c *gin.Context,
+	sari clientAuthzv1.SubjectAccessReviewInterface,
+	cache *cache.Cache,
+	cacheExpirationAuthorized,
+	cacheExpirationUnauthorized time.Duration,
+	resourceAttributes []*apiAuthzv1.ResourceAttributes) {
+	userInfo, err := userFromContext(c)
+	if err != nil {
+		abort.Abort(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	errSar := doSarRequests(
+		c.Request.Context(),
+		sari,
+		userInfo,
+		resourceAttributes,
+		cache,
+		cacheExpirationAuthorized,
+		cacheExpirationUnauthorized,
+	)
+
+	if errSar != nil {
+		if apierrors.IsForbidden(errSar) {
+			abort.Abort(c, errSar, http.StatusForbidden)
+			return
 		}
+		abort.Abort(c, errSar, http.StatusInternalServerError)
 	}
 }
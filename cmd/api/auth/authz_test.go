@@ -5,17 +5,25 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/cmd/api/discovery"
 	"github.com/kubearchive/kubearchive/pkg/cache"
+	"github.com/kubearchive/kubearchive/pkg/database/fake"
 	"github.com/stretchr/testify/assert"
 	apiAuthnv1 "k8s.io/api/authentication/v1"
 	apiAuthzv1 "k8s.io/api/authorization/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeRest "k8s.io/client-go/rest/fake"
 )
 
 const (
@@ -71,7 +79,7 @@ func TestAuthZMiddleware(t *testing.T) {
 			resourceName: "",
 			namespace:    "",
 			verb:         "list",
-			expected:     http.StatusUnauthorized,
+			expected:     http.StatusForbidden,
 		},
 		{
 			name:         "Authorized list core resource request",
@@ -87,7 +95,7 @@ func TestAuthZMiddleware(t *testing.T) {
 			resourceName: "",
 			namespace:    "ns",
 			verb:         "list",
-			expected:     http.StatusUnauthorized,
+			expected:     http.StatusForbidden,
 		},
 		{
 			name:         "Authorized list namespaced resource request",
@@ -158,7 +166,7 @@ func TestAuthorizationCache(t *testing.T) {
 		{
 			name:     "Cache unauthorizes although the action is authorized",
 			allowed:  true,
-			expected: http.StatusUnauthorized,
+			expected: http.StatusForbidden,
 		},
 	}
 
@@ -194,7 +202,7 @@ func TestDifferentAuthorizationExpirations(t *testing.T) {
 		{
 			name:     "Unauthorized requests aren't cached",
 			allowed:  false,
-			expected: http.StatusUnauthorized,
+			expected: http.StatusForbidden,
 		},
 		{
 			name:     "Authorized requests are cached",
@@ -249,21 +257,21 @@ func TestTwoSARForLogRequest(t *testing.T) {
 			logsAllowed:     true,
 			resourceAllowed: false,
 			sarRequests:     1,
-			expected:        http.StatusUnauthorized,
+			expected:        http.StatusForbidden,
 		},
 		{
 			name:            "Resource allowed but logs get not",
 			logsAllowed:     false,
 			resourceAllowed: true,
 			sarRequests:     2,
-			expected:        http.StatusUnauthorized,
+			expected:        http.StatusForbidden,
 		},
 		{
 			name:            "Nothing is allowed",
 			logsAllowed:     false,
 			resourceAllowed: false,
 			sarRequests:     1,
-			expected:        http.StatusUnauthorized,
+			expected:        http.StatusForbidden,
 		},
 	}
 	for _, tc := range tests {
@@ -303,3 +311,116 @@ func TestTwoSARForLogRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestStatsAuthorization(t *testing.T) {
+	tests := []struct {
+		name       string
+		authorized bool
+		namespace  string
+		expected   int
+	}{
+		{
+			name:       "Unauthorized cluster stats request",
+			authorized: false,
+			expected:   http.StatusForbidden,
+		},
+		{
+			name:       "Authorized cluster stats request",
+			authorized: true,
+			expected:   http.StatusOK,
+		},
+		{
+			name:       "Authorized namespaced stats request",
+			authorized: true,
+			namespace:  "ns",
+			expected:   http.StatusOK,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fsar := &fakeSubjectAccessReviews{allowed: []bool{tc.authorized}}
+			res := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(res)
+			c.Set("user", newDefaultInfoFromAuthN(apiAuthnv1.UserInfo{Username: username, UID: uid, Groups: []string{usergroup}}))
+			c.Params = gin.Params{gin.Param{Key: "namespace", Value: tc.namespace}}
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+			StatsAuthorization(fsar, cache.New(), cacheExpirationDuration, cacheExpirationDuration)(c)
+			assert.Equal(t, tc.expected, res.Code)
+			ra := fsar.sar[0].Spec.ResourceAttributes
+			assert.Equal(t, "kubearchive.org", ra.Group)
+			assert.Equal(t, "v1", ra.Version)
+			assert.Equal(t, "stats", ra.Resource)
+			assert.Equal(t, "get", ra.Verb)
+			assert.Equal(t, tc.namespace, ra.Namespace)
+		})
+	}
+}
+
+func TestKindAuthorizer(t *testing.T) {
+	restClient := &fakeRest.RESTClient{
+		Client: fakeRest.CreateHTTPClient(func(request *http.Request) (*http.Response, error) {
+			if request.URL.Path == "/apis/broken.example.com/v1" {
+				return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader(""))}, nil
+			}
+			if request.URL.Path != "/api/v1" {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+			}
+			body, err := json.Marshal(metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+				{Name: "pods", Namespaced: true, Kind: "Pod"},
+				{Name: "nodes", Namespaced: false, Kind: "Node"},
+			}})
+			assert.NoError(t, err)
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(string(body)))}, nil
+		}),
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+	}
+	resources := discovery.NewArchivedAPI(restClient, fake.NewFakeDatabase(nil, nil, ""), cache.New())
+
+	tests := []struct {
+		name              string
+		kind              string
+		authorized        bool
+		expected          bool
+		expectedNamespace string
+	}{
+		{name: "allowed", kind: "Pod", authorized: true, expected: true, expectedNamespace: "ns"},
+		{name: "denied", kind: "Pod", authorized: false, expected: false, expectedNamespace: "ns"},
+		{name: "cluster scoped", kind: "Node", authorized: true, expected: true, expectedNamespace: ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fsar := &fakeSubjectAccessReviews{allowed: []bool{tc.authorized}}
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Set("user", newDefaultInfoFromAuthN(apiAuthnv1.UserInfo{Username: username, UID: uid, Groups: []string{usergroup}}))
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+			authorizer := NewKindAuthorizer(fsar, resources, cache.New(), cacheExpirationDuration, cacheExpirationDuration)
+			allowed, err := authorizer.CanList(c, "v1", tc.kind, "ns")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, allowed)
+			ra := fsar.sar[0].Spec.ResourceAttributes
+			assert.Equal(t, "list", ra.Verb)
+			assert.Equal(t, tc.expectedNamespace, ra.Namespace)
+		})
+	}
+
+	// Kinds that are not served by the cluster are not allowed
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("user", newDefaultInfoFromAuthN(apiAuthnv1.UserInfo{Username: username}))
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	fsar := &fakeSubjectAccessReviews{}
+	authorizer := NewKindAuthorizer(fsar, resources, cache.New(), cacheExpirationDuration, cacheExpirationDuration)
+	allowed, err := authorizer.CanList(c, "v1", "Unknown", "ns")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Empty(t, fsar.sar)
+
+	// Kinds that can not be discovered are not allowed either
+	allowed, err = authorizer.CanList(c, "broken.example.com/v1", "Broken", "ns")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Empty(t, fsar.sar)
+}
@@ -16,10 +16,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/kubearchive/kubearchive/pkg/abort"
 	"github.com/kubearchive/kubearchive/pkg/cache"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 	apiAuthnv1 "k8s.io/api/authentication/v1"
 	apiAuthzv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apiserver/pkg/authentication/serviceaccount"
 	"k8s.io/apiserver/pkg/authentication/user"
 	clientAuthzv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
@@ -217,11 +216,12 @@ func Impersonation(
 			cacheExpirationAuthorized,
 			cacheExpirationUnauthorized,
 		)
+		if apierrors.IsForbidden(err) {
+			abort.Abort(c, err, http.StatusForbidden)
+			return
+		}
 		if err != nil {
-			abort.Abort(c,
-				status.Error(codes.Unauthenticated, "the user don't have permission to impersonate"),
-				http.StatusUnauthorized,
-			)
+			abort.Abort(c, err, http.StatusInternalServerError)
 			return
 		}
 		// The context user is updated with the impersonated user info
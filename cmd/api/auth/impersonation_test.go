@@ -273,7 +273,7 @@ func TestImpersonationAuthz(t *testing.T) {
 			},
 			fakeSarOutcomes:       []bool{false, false, false},
 			expectedNumberOfSar:   1,
-			expectedStatusCode:    http.StatusUnauthorized,
+			expectedStatusCode:    http.StatusForbidden,
 			expectedUserInContext: requesterUser,
 		},
 		{
@@ -284,7 +284,7 @@ func TestImpersonationAuthz(t *testing.T) {
 			},
 			fakeSarOutcomes:       []bool{true, false, false},
 			expectedNumberOfSar:   2,
-			expectedStatusCode:    http.StatusUnauthorized,
+			expectedStatusCode:    http.StatusForbidden,
 			expectedUserInContext: requesterUser,
 		},
 	}
@@ -350,7 +350,7 @@ func TestImpersonationCache(t *testing.T) {
 		{
 			name:                  "Cache unauthorizes although the action is authorized",
 			allowed:               true,
-			expected:              http.StatusUnauthorized,
+			expected:              http.StatusForbidden,
 			expectedUserInContext: requesterUser,
 		},
 	}
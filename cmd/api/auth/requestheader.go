@@ -0,0 +1,201 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/pkg/abort"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	clientCorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// AggregatedAPIEnvVar enables the authentication of the requests proxied by the Kubernetes API server
+	// when KubeArchive is registered as an aggregated API
+	AggregatedAPIEnvVar = "AGGREGATED_API"
+
+	requestHeaderConfigMapNamespace = "kube-system"
+	requestHeaderConfigMapName      = "extension-apiserver-authentication"
+
+	requestHeaderClientCAKey        = "requestheader-client-ca-file"
+	requestHeaderAllowedNamesKey    = "requestheader-allowed-names"
+	requestHeaderUsernameKey        = "requestheader-username-headers"
+	requestHeaderGroupKey           = "requestheader-group-headers"
+	requestHeaderExtraPrefixKey     = "requestheader-extra-headers-prefix"
+	defaultRequestHeaderUsername    = "X-Remote-User"
+	defaultRequestHeaderGroup       = "X-Remote-Group"
+	defaultRequestHeaderExtraPrefix = "X-Remote-Extra-"
+)
+
+// RequestHeaderConfig is the front-proxy configuration the Kubernetes API server uses to forward
+// the requests it already authenticated to the aggregated APIs
+type RequestHeaderConfig struct {
+	// ClientCA verifies the client certificate of the Kubernetes API server
+	ClientCA *x509.CertPool
+	// AllowedNames are the common names accepted in the client certificate, any name when empty
+	AllowedNames        []string
+	UsernameHeaders     []string
+	GroupHeaders        []string
+	ExtraHeaderPrefixes []string
+}
+
+// NewRequestHeaderConfig reads the front-proxy configuration from the extension-apiserver-authentication
+// ConfigMap the Kubernetes API server publishes for the aggregated APIs
+func NewRequestHeaderConfig(ctx context.Context, client clientCorev1.ConfigMapsGetter) (*RequestHeaderConfig, error) {
+	configMap, err := client.ConfigMaps(requestHeaderConfigMapNamespace).Get(ctx, requestHeaderConfigMapName,
+		metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve ConfigMap %s/%s: %w", requestHeaderConfigMapNamespace,
+			requestHeaderConfigMapName, err)
+	}
+
+	caData := configMap.Data[requestHeaderClientCAKey]
+	if caData == "" {
+		return nil, fmt.Errorf("ConfigMap %s/%s does not contain %s", requestHeaderConfigMapNamespace,
+			requestHeaderConfigMapName, requestHeaderClientCAKey)
+	}
+	clientCA := x509.NewCertPool()
+	if !clientCA.AppendCertsFromPEM([]byte(caData)) {
+		return nil, fmt.Errorf("unable to parse %s certificates", requestHeaderClientCAKey)
+	}
+
+	config := &RequestHeaderConfig{ClientCA: clientCA}
+	for key, value := range map[string]*[]string{
+		requestHeaderAllowedNamesKey: &config.AllowedNames,
+		requestHeaderUsernameKey:     &config.UsernameHeaders,
+		requestHeaderGroupKey:        &config.GroupHeaders,
+		requestHeaderExtraPrefixKey:  &config.ExtraHeaderPrefixes,
+	} {
+		if configMap.Data[key] == "" {
+			continue
+		}
+		if err = json.Unmarshal([]byte(configMap.Data[key]), value); err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", key, err)
+		}
+	}
+
+	if len(config.UsernameHeaders) == 0 {
+		config.UsernameHeaders = []string{defaultRequestHeaderUsername}
+	}
+	if len(config.GroupHeaders) == 0 {
+		config.GroupHeaders = []string{defaultRequestHeaderGroup}
+	}
+	if len(config.ExtraHeaderPrefixes) == 0 {
+		config.ExtraHeaderPrefixes = []string{defaultRequestHeaderExtraPrefix}
+	}
+	return config, nil
+}
+
+// TLSConfig requests a client certificate, so the requests proxied by the Kubernetes API server
+// can be told apart from the rest, and verifies it when it is sent
+func (r *RequestHeaderConfig) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  r.ClientCA,
+	}
+}
+
+// verifyClientCertificate returns true when the request has a client certificate signed by the
+// front-proxy CA with one of the allowed common names, and an error when the certificate is not valid
+func (r *RequestHeaderConfig) verifyClientCertificate(req *http.Request) (bool, error) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return false, nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range req.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	certificate := req.TLS.PeerCertificates[0]
+	_, err := certificate.Verify(x509.VerifyOptions{
+		Roots:         r.ClientCA,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return false, fmt.Errorf("invalid front-proxy client certificate: %w", err)
+	}
+
+	if len(r.AllowedNames) > 0 && !slices.Contains(r.AllowedNames, certificate.Subject.CommonName) {
+		return false, fmt.Errorf("front-proxy client certificate %s is not allowed", certificate.Subject.CommonName)
+	}
+	return true, nil
+}
+
+// userFromHeaders returns the user the Kubernetes API server authenticated from the front-proxy headers
+func (r *RequestHeaderConfig) userFromHeaders(headers http.Header) (*user.DefaultInfo, error) {
+	userInfo := &user.DefaultInfo{}
+	for _, header := range r.UsernameHeaders {
+		if name := headers.Get(header); name != "" {
+			userInfo.Name = name
+			break
+		}
+	}
+	if userInfo.Name == "" {
+		return nil, errors.New("no user found in the front-proxy headers")
+	}
+
+	for _, header := range r.GroupHeaders {
+		userInfo.Groups = append(userInfo.Groups, headers.Values(header)...)
+	}
+
+	for headerKey, headerValues := range headers {
+		for _, prefix := range r.ExtraHeaderPrefixes {
+			if !strings.HasPrefix(strings.ToLower(headerKey), strings.ToLower(prefix)) {
+				continue
+			}
+			encodedKey := strings.ToLower(headerKey[len(prefix):])
+			key, err := url.PathUnescape(encodedKey)
+			if err != nil {
+				key = encodedKey
+			}
+			if userInfo.Extra == nil {
+				userInfo.Extra = make(map[string][]string)
+			}
+			userInfo.Extra[key] = append(userInfo.Extra[key], headerValues...)
+		}
+	}
+	return userInfo, nil
+}
+
+// RequestHeaderAuthentication authenticates the requests proxied by the Kubernetes API server using
+// the front-proxy headers. The rest of the requests, or all of them when config is nil, are
+// authenticated by tokenAuthentication
+func RequestHeaderAuthentication(config *RequestHeaderConfig, tokenAuthentication gin.HandlerFunc) gin.HandlerFunc {
+	if config == nil {
+		return tokenAuthentication
+	}
+
+	return func(c *gin.Context) {
+		proxied, err := config.verifyClientCertificate(c.Request)
+		if err != nil {
+			abort.Abort(c, err, http.StatusUnauthorized)
+			return
+		}
+		if !proxied {
+			tokenAuthentication(c)
+			return
+		}
+
+		userInfo, err := config.userFromHeaders(c.Request.Header)
+		if err != nil {
+			abort.Abort(c, err, http.StatusUnauthorized)
+			return
+		}
+		c.Set("user", userInfo)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	fakeK8s "k8s.io/client-go/kubernetes/fake"
+)
+
+type testCA struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "front-proxy-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return &testCA{certificate: certificate, key: key}
+}
+
+func (ca *testCA) pem() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw}))
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.certificate)
+	return pool
+}
+
+func (ca *testCA) clientCertificate(t *testing.T, commonName string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.certificate, &key.PublicKey, ca.key)
+	assert.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return certificate
+}
+
+func TestNewRequestHeaderConfig(t *testing.T) {
+	ca := newTestCA(t)
+	tests := []struct {
+		name                  string
+		data                  map[string]string
+		expectedError         bool
+		expectedAllowedNames  []string
+		expectedUsername      []string
+		expectedExtraPrefixes []string
+	}{
+		{
+			name: "complete configuration",
+			data: map[string]string{
+				requestHeaderClientCAKey:     ca.pem(),
+				requestHeaderAllowedNamesKey: `["front-proxy-client"]`,
+				requestHeaderUsernameKey:     `["X-Proxy-User"]`,
+				requestHeaderGroupKey:        `["X-Proxy-Group"]`,
+				requestHeaderExtraPrefixKey:  `["X-Proxy-Extra-"]`,
+			},
+			expectedAllowedNames:  []string{"front-proxy-client"},
+			expectedUsername:      []string{"X-Proxy-User"},
+			expectedExtraPrefixes: []string{"X-Proxy-Extra-"},
+		},
+		{
+			name:                  "default headers",
+			data:                  map[string]string{requestHeaderClientCAKey: ca.pem()},
+			expectedUsername:      []string{defaultRequestHeaderUsername},
+			expectedExtraPrefixes: []string{defaultRequestHeaderExtraPrefix},
+		},
+		{
+			name:          "no client CA",
+			data:          map[string]string{requestHeaderAllowedNamesKey: `["front-proxy-client"]`},
+			expectedError: true,
+		},
+		{
+			name:          "invalid client CA",
+			data:          map[string]string{requestHeaderClientCAKey: "not a certificate"},
+			expectedError: true,
+		},
+		{
+			name: "invalid allowed names",
+			data: map[string]string{
+				requestHeaderClientCAKey:     ca.pem(),
+				requestHeaderAllowedNamesKey: "front-proxy-client",
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fakeK8s.NewClientset(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: requestHeaderConfigMapName, Namespace: requestHeaderConfigMapNamespace},
+				Data:       tc.data,
+			})
+
+			config, err := NewRequestHeaderConfig(context.Background(), client.CoreV1())
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedAllowedNames, config.AllowedNames)
+			assert.Equal(t, tc.expectedUsername, config.UsernameHeaders)
+			assert.Equal(t, tc.expectedExtraPrefixes, config.ExtraHeaderPrefixes)
+		})
+	}
+}
+
+func TestNewRequestHeaderConfigNoConfigMap(t *testing.T) {
+	_, err := NewRequestHeaderConfig(context.Background(), fakeK8s.NewClientset().CoreV1())
+	assert.Error(t, err)
+}
+
+func TestRequestHeaderAuthentication(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+	config := &RequestHeaderConfig{
+		ClientCA:            ca.pool(),
+		AllowedNames:        []string{"front-proxy-client"},
+		UsernameHeaders:     []string{defaultRequestHeaderUsername},
+		GroupHeaders:        []string{defaultRequestHeaderGroup},
+		ExtraHeaderPrefixes: []string{defaultRequestHeaderExtraPrefix},
+	}
+	headers := http.Header{}
+	headers.Set("X-Remote-User", "jane")
+	headers.Add("X-Remote-Group", "developers")
+	headers.Add("X-Remote-Group", "system:authenticated")
+	headers.Set("X-Remote-Extra-Scopes", "view")
+
+	tests := []struct {
+		name          string
+		certificate   *x509.Certificate
+		headers       http.Header
+		expectedCode  int
+		expectedToken bool
+		expectedUser  *user.DefaultInfo
+	}{
+		{
+			name:          "no client certificate",
+			headers:       headers,
+			expectedCode:  http.StatusOK,
+			expectedToken: true,
+		},
+		{
+			name:         "proxied request",
+			certificate:  ca.clientCertificate(t, "front-proxy-client"),
+			headers:      headers,
+			expectedCode: http.StatusOK,
+			expectedUser: &user.DefaultInfo{
+				Name:   "jane",
+				Groups: []string{"developers", "system:authenticated"},
+				Extra:  map[string][]string{"scopes": {"view"}},
+			},
+		},
+		{
+			name:         "proxied request without user",
+			certificate:  ca.clientCertificate(t, "front-proxy-client"),
+			headers:      http.Header{},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "certificate name not allowed",
+			certificate:  ca.clientCertificate(t, "someone-else"),
+			headers:      headers,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "certificate from another CA",
+			certificate:  otherCA.clientCertificate(t, "front-proxy-client"),
+			headers:      headers,
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenAuthenticated := false
+			tokenAuthentication := func(c *gin.Context) {
+				tokenAuthenticated = true
+			}
+
+			res := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(res)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.Header = tc.headers
+			if tc.certificate != nil {
+				c.Request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tc.certificate}}
+			}
+
+			RequestHeaderAuthentication(config, tokenAuthentication)(c)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+			assert.Equal(t, tc.expectedToken, tokenAuthenticated)
+			userInfo, exists := c.Get("user")
+			if tc.expectedUser == nil {
+				assert.False(t, exists)
+				return
+			}
+			assert.Equal(t, tc.expectedUser, userInfo)
+		})
+	}
+}
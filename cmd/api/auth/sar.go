@@ -5,18 +5,18 @@ package auth
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"time"
 
 	"github.com/kubearchive/kubearchive/pkg/cache"
 	apiAuthzv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apiserver/pkg/authentication/user"
 	clientAuthzv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 )
 
-var errUnauth = errors.New("unauthorized")
-
 // doSarRequests checks if the current user can impersonate the user in impersonatedData through SAR or cache
 func doSarRequests(
 	ctx context.Context,
@@ -48,7 +48,7 @@ func doSarRequests(
 		allowed := cache.Get(sarSpec.String())
 		if allowed != nil {
 			if allowed != true {
-				return errUnauth
+				return forbiddenError(user, resourceAttribute)
 			}
 			continue
 		}
@@ -60,8 +60,25 @@ func doSarRequests(
 		cache.Set(sarSpec.String(), sar.Status.Allowed, cacheExpirationAuthorized)
 		if !sar.Status.Allowed {
 			cache.Set(sarSpec.String(), sar.Status.Allowed, cacheExpirationUnauthorized)
-			return errUnauth
+			return forbiddenError(user, resourceAttribute)
 		}
 	}
 	return nil
 }
+
+// forbiddenError returns the error the Kubernetes API server returns when user is not allowed to perform
+// attributes, like `pods is forbidden: User "alice" cannot list resource "pods" in API group "" in the
+// namespace "test"`
+func forbiddenError(user user.Info, attributes *apiAuthzv1.ResourceAttributes) error {
+	resource := attributes.Resource
+	if attributes.Subresource != "" {
+		resource = resource + "/" + attributes.Subresource
+	}
+	scope := "at the cluster scope"
+	if attributes.Namespace != "" {
+		scope = fmt.Sprintf("in the namespace %q", attributes.Namespace)
+	}
+	return apierrors.NewForbidden(schema.GroupResource{Group: attributes.Group, Resource: resource}, attributes.Name,
+		fmt.Errorf("User %q cannot %s resource %q in API group %q %s", user.GetName(), attributes.Verb, resource,
+			attributes.Group, scope))
+}
@@ -0,0 +1,66 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// AggregatedGroup and AggregatedVersion are the group and version KubeArchive is registered
+	// with when it runs as an aggregated API of the Kubernetes API server
+	AggregatedGroup   = "archive.kubearchive.org"
+	AggregatedVersion = "v1"
+)
+
+var (
+	aggregatedGroupVersion = fmt.Sprintf("%s/%s", AggregatedGroup, AggregatedVersion)
+	// AggregatedGroupPath and AggregatedPathPrefix are the paths the Kubernetes API server proxies to KubeArchive
+	AggregatedGroupPath  = fmt.Sprintf("/apis/%s", AggregatedGroup)
+	AggregatedPathPrefix = fmt.Sprintf("/apis/%s", aggregatedGroupVersion)
+)
+
+// GetAggregatedAPIGroup returns the discovery document of the aggregated API group
+func GetAggregatedAPIGroup(c *gin.Context) {
+	groupVersion := metav1.GroupVersionForDiscovery{GroupVersion: aggregatedGroupVersion, Version: AggregatedVersion}
+	c.JSON(http.StatusOK, metav1.APIGroup{
+		TypeMeta:         metav1.TypeMeta{Kind: "APIGroup", APIVersion: "v1"},
+		Name:             AggregatedGroup,
+		Versions:         []metav1.GroupVersionForDiscovery{groupVersion},
+		PreferredVersion: groupVersion,
+	})
+}
+
+// GetAggregatedAPIResourceList returns the discovery document of the aggregated API version. The archived
+// resources are served under it with the same paths as the Kubernetes API, so it does not list resources
+func GetAggregatedAPIResourceList(c *gin.Context) {
+	c.JSON(http.StatusOK, metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: aggregatedGroupVersion,
+		APIResources: []metav1.APIResource{},
+	})
+}
+
+// StripAggregatedPathPrefix removes AggregatedPathPrefix from the requests proxied by the Kubernetes
+// API server, so `/apis/archive.kubearchive.org/v1/api/v1/namespaces/default/pods` is served as
+// `/api/v1/namespaces/default/pods`
+func StripAggregatedPathPrefix(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, found := strings.CutPrefix(r.URL.Path, AggregatedPathPrefix+"/")
+		if found {
+			request := r.Clone(r.Context())
+			request.URL.Path = "/" + path
+			if rawPath, rawFound := strings.CutPrefix(r.URL.RawPath, AggregatedPathPrefix+"/"); rawFound {
+				request.URL.RawPath = "/" + rawPath
+			}
+			r = request
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetAggregatedAPIGroup(t *testing.T) {
+	res := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(res)
+	GetAggregatedAPIGroup(c)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	group := metav1.APIGroup{}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &group))
+	assert.Equal(t, "APIGroup", group.Kind)
+	assert.Equal(t, AggregatedGroup, group.Name)
+	assert.Equal(t, "archive.kubearchive.org/v1", group.PreferredVersion.GroupVersion)
+	assert.Len(t, group.Versions, 1)
+}
+
+func TestGetAggregatedAPIResourceList(t *testing.T) {
+	res := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(res)
+	GetAggregatedAPIResourceList(c)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	resourceList := metav1.APIResourceList{}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &resourceList))
+	assert.Equal(t, "APIResourceList", resourceList.Kind)
+	assert.Equal(t, "archive.kubearchive.org/v1", resourceList.GroupVersion)
+}
+
+func TestStripAggregatedPathPrefix(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		expectedPath string
+	}{
+		{
+			name:         "core resources",
+			path:         "/apis/archive.kubearchive.org/v1/api/v1/namespaces/default/pods",
+			expectedPath: "/api/v1/namespaces/default/pods",
+		},
+		{
+			name:         "non core resources",
+			path:         "/apis/archive.kubearchive.org/v1/apis/batch/v1/jobs?labelSelector=app%3Dfrontend",
+			expectedPath: "/apis/batch/v1/jobs",
+		},
+		{
+			name:         "discovery",
+			path:         "/apis/archive.kubearchive.org/v1",
+			expectedPath: "/apis/archive.kubearchive.org/v1",
+		},
+		{
+			name:         "not aggregated",
+			path:         "/api/v1/namespaces/default/pods",
+			expectedPath: "/api/v1/namespaces/default/pods",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var path string
+			handler := StripAggregatedPathPrefix(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.expectedPath, path)
+		})
+	}
+}
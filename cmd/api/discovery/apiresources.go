@@ -6,6 +6,7 @@ package discovery
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 
 	"github.com/kubearchive/kubearchive/pkg/abort"
 	"github.com/kubearchive/kubearchive/pkg/cache"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
 )
 
 // Arbitrary time for cache, probably enough to not overload the Kubernetes API
@@ -26,7 +28,10 @@ var cacheExpirationTime = 10 * time.Minute
 // so we had to implement the same call to be able to pass the context so telemetry traces are tied together
 // @rh-hemartin opened a ticket to allow for a context on that function, see https://github.com/kubernetes/client-go/issues/1370
 // The client-go discovery package offers cache implementations however our cache implementation is simpler.
-func GetAPIResource(client rest.Interface, cache *cache.Cache) gin.HandlerFunc {
+//
+// When the Kubernetes cluster does not serve the resource, because its CustomResourceDefinition was removed,
+// the definitions stored by the sink are used instead so the archived resources can still be queried.
+func GetAPIResource(client rest.Interface, database interfaces.DBReader, cache *cache.Cache) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		resourceName := c.Param("resourceType")
 		kind := cache.Get(resourceName)
@@ -41,6 +46,9 @@ func GetAPIResource(client rest.Interface, cache *cache.Cache) gin.HandlerFunc {
 		if result.Error() != nil {
 			status := 0
 			result.StatusCode(&status)
+			if status == http.StatusNotFound && setStoredAPIResourceKind(c, database, cache) {
+				return
+			}
 			abort.Abort(c, fmt.Errorf("unable to retrieve information from '%s', error: %w", discoveryURL, result.Error()), status)
 			return
 		}
@@ -59,12 +67,43 @@ func GetAPIResource(client rest.Interface, cache *cache.Cache) gin.HandlerFunc {
 				return
 			}
 		}
+		if setStoredAPIResourceKind(c, database, cache) {
+			return
+		}
 		abort.Abort(c,
 			fmt.Errorf("unable to find the API resource %s in the Kubernetes cluster", resourceName),
 			http.StatusNotFound)
 	}
 }
 
+// setStoredAPIResourceKind sets apiResourceKind attribute in the context from the definitions stored by the sink
+// and returns true if the requested resource is defined by one of them
+func setStoredAPIResourceKind(c *gin.Context, database interfaces.DBReader, cache *cache.Cache) bool {
+	group := c.Param("group")
+	if group == "" || database == nil {
+		return false
+	}
+
+	crds, err := storedDefinitions(c.Request.Context(), database, cache, group)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "unable to use the stored definitions", "error", err.Error())
+		return false
+	}
+	for _, crd := range crds {
+		if crd.Spec.Names.Plural != c.Param("resourceType") {
+			continue
+		}
+		resource, ok := storedAPIResource(crd, c.Param("version"))
+		if !ok {
+			return false
+		}
+		cache.Set(resource.Name, resource.Kind, cacheExpirationTime)
+		c.Set("apiResourceKind", resource.Kind)
+		return true
+	}
+	return false
+}
+
 func GetAPIResourceKind(context *gin.Context) (string, error) {
 	kind := context.GetString("apiResourceKind")
 	if kind == "" {
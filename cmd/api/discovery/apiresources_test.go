@@ -125,7 +125,7 @@ func TestGetAPIResource(t *testing.T) {
 			c.AddParam("version", tc.version)
 			c.AddParam("resourceType", tc.resource)
 
-			GetAPIResource(restClient, cache)(c)
+			GetAPIResource(restClient, nil, cache)(c)
 
 			apiResourceKind := c.GetString("apiResourceKind")
 			assert.Equal(t, tc.expectedCode, res.Code)
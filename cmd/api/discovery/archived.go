@@ -0,0 +1,445 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/pkg/abort"
+	"github.com/kubearchive/kubearchive/pkg/cache"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	archivedKindsKey    = "archivedKinds"
+	clusterResourcesKey = "clusterResources"
+	clusterOpenAPIKey   = "clusterOpenAPI"
+	openAPIV3Path       = "/openapi/v3"
+)
+
+// archivedKindsExpirationTime is shorter than cacheExpirationTime, so the kinds archived for the first time
+// are published soon
+var archivedKindsExpirationTime = time.Minute
+
+// archivedVerbs are the verbs the KubeArchive API supports on every archived resource
+var archivedVerbs = metav1.Verbs{"get", "list", "watch"}
+
+// ArchivedAPI serves the discovery and OpenAPI documents of the kinds with resources in the archive.
+// The resource names, scope and schemas are taken from the Kubernetes cluster, falling back to the
+// CustomResourceDefinitions stored by the sink for the custom resources the cluster does not serve anymore
+type ArchivedAPI struct {
+	client   rest.Interface
+	database interfaces.DBReader
+	cache    *cache.Cache
+}
+
+func NewArchivedAPI(client rest.Interface, database interfaces.DBReader, cache *cache.Cache) *ArchivedAPI {
+	return &ArchivedAPI{client: client, database: database, cache: cache}
+}
+
+// GetAPIVersions returns the versions of the core group with archived resources
+func (a *ArchivedAPI) GetAPIVersions(c *gin.Context) {
+	resources, err := a.archivedResources(c.Request.Context())
+	if err != nil {
+		abort.Abort(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	versions := []string{}
+	for groupVersion := range resources {
+		if groupVersion.Group == "" {
+			versions = append(versions, groupVersion.Version)
+		}
+	}
+	slices.SortFunc(versions, func(a, b string) int {
+		return version.CompareKubeAwareVersionStrings(b, a)
+	})
+	c.JSON(http.StatusOK, metav1.APIVersions{
+		TypeMeta:                   metav1.TypeMeta{Kind: "APIVersions"},
+		Versions:                   versions,
+		ServerAddressByClientCIDRs: []metav1.ServerAddressByClientCIDR{},
+	})
+}
+
+// GetAPIGroupList returns the groups with archived resources
+func (a *ArchivedAPI) GetAPIGroupList(c *gin.Context) {
+	resources, err := a.archivedResources(c.Request.Context())
+	if err != nil {
+		abort.Abort(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	groups := []metav1.APIGroup{}
+	for _, name := range archivedGroups(resources) {
+		groups = append(groups, newAPIGroup(name, resources))
+	}
+	c.JSON(http.StatusOK, metav1.APIGroupList{
+		TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"},
+		Groups:   groups,
+	})
+}
+
+// GetAPIGroup returns the versions of the group in the path with archived resources
+func (a *ArchivedAPI) GetAPIGroup(c *gin.Context) {
+	resources, err := a.archivedResources(c.Request.Context())
+	if err != nil {
+		abort.Abort(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	group := c.Param("group")
+	if !slices.Contains(archivedGroups(resources), group) {
+		abort.Abort(c, fmt.Errorf("no archived resources in group %s", group), http.StatusNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, newAPIGroup(group, resources))
+}
+
+// GetAPIResourceList returns the archived resources of the group and version in the path
+func (a *ArchivedAPI) GetAPIResourceList(c *gin.Context) {
+	resources, err := a.archivedResources(c.Request.Context())
+	if err != nil {
+		abort.Abort(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	groupVersion := schema.GroupVersion{Group: c.Param("group"), Version: c.Param("version")}
+	apiResources, ok := resources[groupVersion]
+	if !ok {
+		abort.Abort(c, fmt.Errorf("no archived resources in %s", groupVersion.String()), http.StatusNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: groupVersion.String(),
+		APIResources: apiResources,
+	})
+}
+
+// GetOpenAPIIndex returns the OpenAPI v3 discovery document with the group versions with archived resources
+func (a *ArchivedAPI) GetOpenAPIIndex(c *gin.Context) {
+	resources, err := a.archivedResources(c.Request.Context())
+	if err != nil {
+		abort.Abort(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	paths := map[string]openAPIGroupVersion{}
+	for groupVersion := range resources {
+		path := strings.TrimPrefix(getDiscoveryURL(groupVersion.Group, groupVersion.Version), "/")
+		paths[path] = openAPIGroupVersion{ServerRelativeURL: fmt.Sprintf("%s/%s", openAPIV3Path, path)}
+	}
+	c.JSON(http.StatusOK, openAPIIndex{Paths: paths})
+}
+
+// GetOpenAPI returns the OpenAPI v3 document of the group and version in the path. It is the document
+// the Kubernetes cluster publishes with only the read operations of the archived resources. When the cluster
+// does not serve the group version anymore, the document is built from the stored CustomResourceDefinitions
+func (a *ArchivedAPI) GetOpenAPI(c *gin.Context) {
+	resources, err := a.archivedResources(c.Request.Context())
+	if err != nil {
+		abort.Abort(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	groupVersion := schema.GroupVersion{Group: c.Param("group"), Version: c.Param("version")}
+	apiResources, ok := resources[groupVersion]
+	if !ok {
+		abort.Abort(c, fmt.Errorf("no archived resources in %s", groupVersion.String()), http.StatusNotFound)
+		return
+	}
+
+	document, err := a.clusterOpenAPI(c.Request.Context(), groupVersion)
+	if err != nil {
+		abort.Abort(c, err, http.StatusBadGateway)
+		return
+	}
+	if document == nil {
+		document, err = a.storedOpenAPI(c.Request.Context(), groupVersion, apiResources)
+		if err != nil {
+			abort.Abort(c, err, http.StatusInternalServerError)
+			return
+		}
+		if document == nil {
+			abort.Abort(c, fmt.Errorf("no OpenAPI document for %s", groupVersion.String()), http.StatusNotFound)
+			return
+		}
+	}
+
+	names := make([]string, 0, len(apiResources))
+	for _, resource := range apiResources {
+		names = append(names, resource.Name)
+	}
+	c.JSON(http.StatusOK, filterOpenAPI(document, getDiscoveryURL(groupVersion.Group, groupVersion.Version), names))
+}
+
+type openAPIGroupVersion struct {
+	ServerRelativeURL string `json:"serverRelativeURL"`
+}
+
+type openAPIIndex struct {
+	Paths map[string]openAPIGroupVersion `json:"paths"`
+}
+
+// archivedResources returns the API resources of the kinds with archived resources by group version
+func (a *ArchivedAPI) archivedResources(ctx context.Context) (map[schema.GroupVersion][]metav1.APIResource, error) {
+	kinds, err := a.archivedKinds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := map[schema.GroupVersion][]metav1.APIResource{}
+	for _, kind := range kinds {
+		groupVersion, parseErr := schema.ParseGroupVersion(kind.APIVersion)
+		if parseErr != nil {
+			slog.WarnContext(ctx, "ignoring archived resources with invalid apiVersion",
+				"apiVersion", kind.APIVersion, "error", parseErr.Error())
+			continue
+		}
+
+		resource, resourceErr := a.ResourceForKind(ctx, groupVersion, kind.Kind)
+		if resourceErr != nil {
+			return nil, resourceErr
+		}
+		if resource != nil {
+			resources[groupVersion] = append(resources[groupVersion], *resource)
+		}
+	}
+	return resources, nil
+}
+
+// archivedKinds returns the kinds with archived resources. They are cached, as listing them goes through
+// the whole resource table and every discovery request needs them
+func (a *ArchivedAPI) archivedKinds(ctx context.Context) ([]models.ArchivedKind, error) {
+	if kinds, ok := a.cache.Get(archivedKindsKey).([]models.ArchivedKind); ok {
+		return kinds, nil
+	}
+
+	kinds, err := a.database.QueryArchivedKinds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.cache.Set(archivedKindsKey, kinds, archivedKindsExpirationTime)
+	return kinds, nil
+}
+
+// ResourceForKind returns the API resource of the kind in the group version, from the Kubernetes cluster or the
+// definitions stored by the sink, nil when neither of them define it
+func (a *ArchivedAPI) ResourceForKind(ctx context.Context, groupVersion schema.GroupVersion, kind string,
+) (*metav1.APIResource, error) {
+	clusterResources, err := a.clusterResources(ctx, groupVersion)
+	if err != nil {
+		return nil, err
+	}
+	for _, resource := range clusterResources {
+		// Subresources, like pods/log, are not archived
+		if resource.Kind != kind || strings.Contains(resource.Name, "/") {
+			continue
+		}
+		return &metav1.APIResource{
+			Name:         resource.Name,
+			SingularName: resource.SingularName,
+			Namespaced:   resource.Namespaced,
+			Kind:         resource.Kind,
+			ShortNames:   resource.ShortNames,
+			Categories:   resource.Categories,
+			Verbs:        archivedVerbs,
+		}, nil
+	}
+	if groupVersion.Group == "" {
+		return nil, nil
+	}
+	return a.storedResource(ctx, groupVersion, kind)
+}
+
+// storedResource returns the API resource of the kind from the definitions stored by the sink,
+// nil when the kind has no stored definition for the group version
+func (a *ArchivedAPI) storedResource(ctx context.Context, groupVersion schema.GroupVersion, kind string,
+) (*metav1.APIResource, error) {
+	crds, err := storedDefinitions(ctx, a.database, a.cache, groupVersion.Group)
+	if err != nil {
+		return nil, err
+	}
+	for _, crd := range crds {
+		if crd.Spec.Names.Kind != kind {
+			continue
+		}
+		if resource, ok := storedAPIResource(crd, groupVersion.Version); ok {
+			return &resource, nil
+		}
+	}
+	return nil, nil
+}
+
+// clusterResources returns the API resources the Kubernetes cluster serves for the group version,
+// none when the cluster does not serve it anymore
+func (a *ArchivedAPI) clusterResources(ctx context.Context, groupVersion schema.GroupVersion) ([]metav1.APIResource, error) {
+	cacheKey := fmt.Sprintf("%s/%s", clusterResourcesKey, groupVersion.String())
+	if resources, ok := a.cache.Get(cacheKey).([]metav1.APIResource); ok {
+		return resources, nil
+	}
+
+	discoveryURL := getDiscoveryURL(groupVersion.Group, groupVersion.Version)
+	result := a.client.Get().AbsPath(discoveryURL).Do(ctx)
+	if result.Error() != nil {
+		status := 0
+		result.StatusCode(&status)
+		if status == http.StatusNotFound {
+			a.cache.Set(cacheKey, []metav1.APIResource{}, cacheExpirationTime)
+			return []metav1.APIResource{}, nil
+		}
+		return nil, fmt.Errorf("unable to retrieve information from '%s', error: %w", discoveryURL, result.Error())
+	}
+
+	resourceList := &metav1.APIResourceList{}
+	if err := result.Into(resourceList); err != nil {
+		return nil, fmt.Errorf("unable to deserialize result from '%s', error: %w", discoveryURL, err)
+	}
+	a.cache.Set(cacheKey, resourceList.APIResources, cacheExpirationTime)
+	return resourceList.APIResources, nil
+}
+
+// clusterOpenAPI returns the OpenAPI v3 document the Kubernetes cluster publishes for the group version,
+// nil when the cluster does not publish it
+func (a *ArchivedAPI) clusterOpenAPI(ctx context.Context, groupVersion schema.GroupVersion) (map[string]any, error) {
+	cacheKey := fmt.Sprintf("%s/%s", clusterOpenAPIKey, groupVersion.String())
+	if document, ok := a.cache.Get(cacheKey).(map[string]any); ok {
+		return document, nil
+	}
+
+	openAPIURL := openAPIV3Path + getDiscoveryURL(groupVersion.Group, groupVersion.Version)
+	raw, err := a.client.Get().AbsPath(openAPIURL).DoRaw(ctx)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve information from '%s', error: %w", openAPIURL, err)
+	}
+
+	document := map[string]any{}
+	if err = json.Unmarshal(raw, &document); err != nil {
+		return nil, fmt.Errorf("unable to deserialize result from '%s', error: %w", openAPIURL, err)
+	}
+	if _, ok := document["paths"].(map[string]any); !ok {
+		return nil, errors.New("the OpenAPI document of the cluster does not contain paths")
+	}
+	a.cache.Set(cacheKey, document, cacheExpirationTime)
+	return document, nil
+}
+
+// storedOpenAPI returns the OpenAPI v3 document of the archived resources of the group version built from
+// their stored CustomResourceDefinitions, nil when none of them has a stored schema
+func (a *ArchivedAPI) storedOpenAPI(ctx context.Context, groupVersion schema.GroupVersion,
+	resources []metav1.APIResource) (map[string]any, error) {
+	if groupVersion.Group == "" {
+		return nil, nil
+	}
+	crds, err := storedDefinitions(ctx, a.database, a.cache, groupVersion.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	var archived []apiextensionsv1.CustomResourceDefinition
+	for _, crd := range crds {
+		if slices.ContainsFunc(resources, func(resource metav1.APIResource) bool {
+			return resource.Kind == crd.Spec.Names.Kind
+		}) {
+			archived = append(archived, crd)
+		}
+	}
+	return storedOpenAPIDocument(archived, groupVersion), nil
+}
+
+// filterOpenAPI returns a copy of the document with only the get operations of the collection
+// and the individual paths of the resources. The schemas are kept as they are
+func filterOpenAPI(document map[string]any, prefix string, resources []string) map[string]any {
+	filtered := make(map[string]any, len(document))
+	for key, value := range document {
+		filtered[key] = value
+	}
+
+	paths := map[string]any{}
+	for path, item := range document["paths"].(map[string]any) {
+		operations, ok := item.(map[string]any)
+		if !ok || !isArchivedPath(path, prefix, resources) || operations["get"] == nil {
+			continue
+		}
+		archivedItem := map[string]any{"get": operations["get"]}
+		if parameters, found := operations["parameters"]; found {
+			archivedItem["parameters"] = parameters
+		}
+		paths[path] = archivedItem
+	}
+	filtered["paths"] = paths
+	return filtered
+}
+
+// isArchivedPath returns true when the path is the collection or an individual resource of one
+// of the resources, cluster wide or in a namespace
+func isArchivedPath(path, prefix string, resources []string) bool {
+	rest, found := strings.CutPrefix(path, prefix+"/")
+	if !found {
+		return false
+	}
+	if namespaced, isNamespaced := strings.CutPrefix(rest, "namespaces/{namespace}/"); isNamespaced {
+		rest = namespaced
+	}
+
+	parts := strings.Split(rest, "/")
+	if !slices.Contains(resources, parts[0]) {
+		return false
+	}
+	return len(parts) == 1 || (len(parts) == 2 && parts[1] == "{name}")
+}
+
+// archivedGroups returns the names of the groups with archived resources, the core group excluded
+func archivedGroups(resources map[schema.GroupVersion][]metav1.APIResource) []string {
+	groups := []string{}
+	for groupVersion := range resources {
+		if groupVersion.Group != "" && !slices.Contains(groups, groupVersion.Group) {
+			groups = append(groups, groupVersion.Group)
+		}
+	}
+	slices.Sort(groups)
+	return groups
+}
+
+// newAPIGroup returns the discovery document of the group, the preferred version is the most stable
+func newAPIGroup(name string, resources map[schema.GroupVersion][]metav1.APIResource) metav1.APIGroup {
+	versions := []metav1.GroupVersionForDiscovery{}
+	for groupVersion := range resources {
+		if groupVersion.Group == name {
+			versions = append(versions, metav1.GroupVersionForDiscovery{
+				GroupVersion: groupVersion.String(),
+				Version:      groupVersion.Version,
+			})
+		}
+	}
+	slices.SortFunc(versions, func(a, b metav1.GroupVersionForDiscovery) int {
+		return version.CompareKubeAwareVersionStrings(b.Version, a.Version)
+	})
+
+	return metav1.APIGroup{
+		TypeMeta:         metav1.TypeMeta{Kind: "APIGroup", APIVersion: "v1"},
+		Name:             name,
+		Versions:         versions,
+		PreferredVersion: versions[0],
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/pkg/cache"
+	"github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeRest "k8s.io/client-go/rest/fake"
+)
+
+const testOpenAPIDocument = `{
+	"openapi": "3.0.0",
+	"paths": {
+		"/apis/batch/v1/": {"get": {"operationId": "getBatchV1APIResources"}},
+		"/apis/batch/v1/jobs": {"get": {"operationId": "listJobForAllNamespaces"}},
+		"/apis/batch/v1/namespaces/{namespace}/jobs": {
+			"get": {"operationId": "listNamespacedJob"},
+			"post": {"operationId": "createNamespacedJob"},
+			"parameters": [{"name": "namespace"}]
+		},
+		"/apis/batch/v1/namespaces/{namespace}/jobs/{name}": {
+			"get": {"operationId": "readNamespacedJob"},
+			"delete": {"operationId": "deleteNamespacedJob"}
+		},
+		"/apis/batch/v1/namespaces/{namespace}/jobs/{name}/status": {"get": {"operationId": "readNamespacedJobStatus"}},
+		"/apis/batch/v1/namespaces/{namespace}/cronjobs": {"get": {"operationId": "listNamespacedCronJob"}},
+		"/apis/batch/v1/watch/namespaces/{namespace}/jobs": {"get": {"operationId": "watchNamespacedJobList"}}
+	},
+	"components": {"schemas": {"io.k8s.api.batch.v1.Job": {"type": "object"}}}
+}`
+
+func newTestResource(kind, apiVersion string) *unstructured.Unstructured {
+	resource := &unstructured.Unstructured{}
+	resource.SetKind(kind)
+	resource.SetAPIVersion(apiVersion)
+	resource.SetNamespace("test")
+	resource.SetName("test")
+	return resource
+}
+
+func newTestArchivedAPI(t *testing.T) *ArchivedAPI {
+	responses := map[string]any{
+		"/api/v1": metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods", SingularName: "pod", Namespaced: true, Kind: "Pod", ShortNames: []string{"po"},
+				Verbs: metav1.Verbs{"create", "delete", "get", "list"}},
+			{Name: "pods/log", Namespaced: true, Kind: "Pod", Verbs: metav1.Verbs{"get"}},
+			{Name: "services", SingularName: "service", Namespaced: true, Kind: "Service"},
+		}},
+		"/apis/batch/v1": metav1.APIResourceList{GroupVersion: "batch/v1", APIResources: []metav1.APIResource{
+			{Name: "jobs", SingularName: "job", Namespaced: true, Kind: "Job"},
+			{Name: "cronjobs", SingularName: "cronjob", Namespaced: true, Kind: "CronJob"},
+		}},
+		"/apis/batch/v1beta1": metav1.APIResourceList{GroupVersion: "batch/v1beta1", APIResources: []metav1.APIResource{
+			{Name: "jobs", SingularName: "job", Namespaced: true, Kind: "Job"},
+		}},
+	}
+
+	restClient := &fakeRest.RESTClient{
+		Client: fakeRest.CreateHTTPClient(func(request *http.Request) (*http.Response, error) {
+			if request.URL.Path == "/openapi/v3/apis/batch/v1" {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(testOpenAPIDocument))}, nil
+			}
+			if request.URL.Path == "/openapi/v3/apis/batch/v1beta1" {
+				return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader(""))}, nil
+			}
+			response, ok := responses[request.URL.Path]
+			if !ok {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+			}
+			body, err := json.Marshal(response)
+			assert.NoError(t, err)
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(string(body)))}, nil
+		}),
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+	}
+
+	database := fake.NewFakeDatabase([]*unstructured.Unstructured{
+		newTestResource("Pod", "v1"),
+		newTestResource("Job", "batch/v1"),
+		newTestResource("Job", "batch/v1beta1"),
+		// The CRD of this kind was removed from the cluster
+		newTestResource("Crontab", "stable.example.com/v1"),
+	}, nil, "")
+	return NewArchivedAPI(restClient, database, cache.New())
+}
+
+func serveArchivedAPI(handler gin.HandlerFunc, params gin.Params) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(res)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Params = params
+	handler(c)
+	return res
+}
+
+func TestGetAPIVersions(t *testing.T) {
+	res := serveArchivedAPI(newTestArchivedAPI(t).GetAPIVersions, nil)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	versions := metav1.APIVersions{}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &versions))
+	assert.Equal(t, []string{"v1"}, versions.Versions)
+}
+
+func TestGetAPIGroupList(t *testing.T) {
+	res := serveArchivedAPI(newTestArchivedAPI(t).GetAPIGroupList, nil)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	groupList := metav1.APIGroupList{}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &groupList))
+	if assert.Len(t, groupList.Groups, 1) {
+		group := groupList.Groups[0]
+		assert.Equal(t, "batch", group.Name)
+		assert.Equal(t, "batch/v1", group.PreferredVersion.GroupVersion)
+		assert.Equal(t, []metav1.GroupVersionForDiscovery{
+			{GroupVersion: "batch/v1", Version: "v1"},
+			{GroupVersion: "batch/v1beta1", Version: "v1beta1"},
+		}, group.Versions)
+	}
+}
+
+func TestGetAPIGroup(t *testing.T) {
+	api := newTestArchivedAPI(t)
+
+	res := serveArchivedAPI(api.GetAPIGroup, gin.Params{{Key: "group", Value: "batch"}})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = serveArchivedAPI(api.GetAPIGroup, gin.Params{{Key: "group", Value: "stable.example.com"}})
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestGetAPIResourceList(t *testing.T) {
+	tests := []struct {
+		name              string
+		params            gin.Params
+		expectedCode      int
+		expectedResources []metav1.APIResource
+	}{
+		{
+			name:         "core",
+			params:       gin.Params{{Key: "version", Value: "v1"}},
+			expectedCode: http.StatusOK,
+			expectedResources: []metav1.APIResource{{Name: "pods", SingularName: "pod", Namespaced: true, Kind: "Pod",
+				ShortNames: []string{"po"}, Verbs: archivedVerbs}},
+		},
+		{
+			name:         "non core",
+			params:       gin.Params{{Key: "group", Value: "batch"}, {Key: "version", Value: "v1"}},
+			expectedCode: http.StatusOK,
+			expectedResources: []metav1.APIResource{{Name: "jobs", SingularName: "job", Namespaced: true, Kind: "Job",
+				Verbs: archivedVerbs}},
+		},
+		{
+			name:         "not in the cluster",
+			params:       gin.Params{{Key: "group", Value: "stable.example.com"}, {Key: "version", Value: "v1"}},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "not archived",
+			params:       gin.Params{{Key: "group", Value: "apps"}, {Key: "version", Value: "v1"}},
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := serveArchivedAPI(newTestArchivedAPI(t).GetAPIResourceList, tc.params)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			resourceList := metav1.APIResourceList{}
+			assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &resourceList))
+			assert.Equal(t, tc.expectedResources, resourceList.APIResources)
+		})
+	}
+}
+
+func TestGetOpenAPIIndex(t *testing.T) {
+	res := serveArchivedAPI(newTestArchivedAPI(t).GetOpenAPIIndex, nil)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	index := openAPIIndex{}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &index))
+	assert.Equal(t, map[string]openAPIGroupVersion{
+		"api/v1":             {ServerRelativeURL: "/openapi/v3/api/v1"},
+		"apis/batch/v1":      {ServerRelativeURL: "/openapi/v3/apis/batch/v1"},
+		"apis/batch/v1beta1": {ServerRelativeURL: "/openapi/v3/apis/batch/v1beta1"},
+	}, index.Paths)
+}
+
+func TestGetOpenAPI(t *testing.T) {
+	api := newTestArchivedAPI(t)
+	res := serveArchivedAPI(api.GetOpenAPI, gin.Params{{Key: "group", Value: "batch"}, {Key: "version", Value: "v1"}})
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	document := map[string]any{}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &document))
+	assert.Equal(t, "3.0.0", document["openapi"])
+	assert.Contains(t, document["components"].(map[string]any)["schemas"], "io.k8s.api.batch.v1.Job")
+
+	paths := document["paths"].(map[string]any)
+	assert.Len(t, paths, 3)
+	assert.Contains(t, paths, "/apis/batch/v1/jobs")
+	assert.Equal(t, map[string]any{
+		"get":        map[string]any{"operationId": "listNamespacedJob"},
+		"parameters": []any{map[string]any{"name": "namespace"}},
+	}, paths["/apis/batch/v1/namespaces/{namespace}/jobs"])
+	assert.Equal(t, map[string]any{"get": map[string]any{"operationId": "readNamespacedJob"}},
+		paths["/apis/batch/v1/namespaces/{namespace}/jobs/{name}"])
+
+	// The cluster does not publish the document and there is no stored definition
+	res = serveArchivedAPI(api.GetOpenAPI, gin.Params{{Key: "version", Value: "v1"}})
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	res = serveArchivedAPI(api.GetOpenAPI, gin.Params{{Key: "group", Value: "batch"}, {Key: "version", Value: "v1beta1"}})
+	assert.Equal(t, http.StatusBadGateway, res.Code)
+
+	res = serveArchivedAPI(api.GetOpenAPI, gin.Params{{Key: "group", Value: "apps"}, {Key: "version", Value: "v1"}})
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestArchivedAPIDatabaseError(t *testing.T) {
+	api := NewArchivedAPI(nil, fake.NewFakeDatabaseWithError(errors.New("test error")), cache.New())
+	for _, handler := range []gin.HandlerFunc{api.GetAPIVersions, api.GetAPIGroupList, api.GetAPIGroup,
+		api.GetAPIResourceList, api.GetOpenAPIIndex, api.GetOpenAPI} {
+		res := serveArchivedAPI(handler, nil)
+		assert.Equal(t, http.StatusInternalServerError, res.Code)
+	}
+}
+
+// countingDatabase counts the queries of the archived kinds
+type countingDatabase struct {
+	interfaces.DBReader
+	queries int
+}
+
+func (c *countingDatabase) QueryArchivedKinds(ctx context.Context) ([]models.ArchivedKind, error) {
+	c.queries++
+	return c.DBReader.QueryArchivedKinds(ctx)
+}
+
+func TestArchivedKindsCache(t *testing.T) {
+	api := newTestArchivedAPI(t)
+	database := &countingDatabase{DBReader: api.database}
+	api.database = database
+
+	for range 3 {
+		res := serveArchivedAPI(api.GetAPIGroupList, nil)
+		assert.Equal(t, http.StatusOK, res.Code)
+	}
+	assert.Equal(t, 1, database.queries)
+
+	// Expire the kinds
+	api.cache.Set(archivedKindsKey, []models.ArchivedKind{}, -2*time.Second)
+	res := serveArchivedAPI(api.GetAPIGroupList, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, 2, database.queries)
+}
+
+func TestResourceForKind(t *testing.T) {
+	api := newTestArchivedAPI(t)
+	tests := []struct {
+		name         string
+		groupVersion schema.GroupVersion
+		kind         string
+		expected     string
+	}{
+		{name: "core", groupVersion: schema.GroupVersion{Version: "v1"}, kind: "Pod", expected: "pods"},
+		{name: "group", groupVersion: schema.GroupVersion{Group: "batch", Version: "v1"}, kind: "Job", expected: "jobs"},
+		{name: "unknown kind", groupVersion: schema.GroupVersion{Version: "v1"}, kind: "Unknown"},
+		{name: "unknown group", groupVersion: schema.GroupVersion{Group: "example.com", Version: "v1"}, kind: "Job"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource, err := api.ResourceForKind(context.Background(), tt.groupVersion, tt.kind)
+			assert.NoError(t, err)
+			if tt.expected == "" {
+				assert.Nil(t, resource)
+				return
+			}
+			if assert.NotNil(t, resource) {
+				assert.Equal(t, tt.expected, resource.Name)
+				assert.Equal(t, archivedVerbs, resource.Verbs)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/kubearchive/kubearchive/pkg/cache"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const storedDefinitionsKey = "storedDefinitions"
+
+// storedDefinitions returns the CustomResourceDefinitions the sink stored for the group. They are used
+// when the Kubernetes cluster does not serve the archived custom resources anymore
+func storedDefinitions(ctx context.Context, database interfaces.DBReader, cache *cache.Cache, group string,
+) ([]apiextensionsv1.CustomResourceDefinition, error) {
+	cacheKey := fmt.Sprintf("%s/%s", storedDefinitionsKey, group)
+	if crds, ok := cache.Get(cacheKey).([]apiextensionsv1.CustomResourceDefinition); ok {
+		return crds, nil
+	}
+
+	definitions, err := database.QueryResourceDefinitions(ctx, group)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve the stored definitions of group %s: %w", group, err)
+	}
+
+	crds := make([]apiextensionsv1.CustomResourceDefinition, 0, len(definitions))
+	for _, definition := range definitions {
+		crd := apiextensionsv1.CustomResourceDefinition{}
+		if err = json.Unmarshal([]byte(definition.Data), &crd); err != nil {
+			slog.WarnContext(ctx, "ignoring invalid stored CustomResourceDefinition",
+				"group", definition.Group, "plural", definition.Plural, "error", err.Error())
+			continue
+		}
+		crds = append(crds, crd)
+	}
+	cache.Set(cacheKey, crds, cacheExpirationTime)
+	return crds, nil
+}
+
+// storedAPIResource returns the API resource the CustomResourceDefinition defined for the version, if any
+func storedAPIResource(crd apiextensionsv1.CustomResourceDefinition, version string) (metav1.APIResource, bool) {
+	for _, crdVersion := range crd.Spec.Versions {
+		if crdVersion.Name != version {
+			continue
+		}
+		return metav1.APIResource{
+			Name:         crd.Spec.Names.Plural,
+			SingularName: crd.Spec.Names.Singular,
+			Namespaced:   crd.Spec.Scope == apiextensionsv1.NamespaceScoped,
+			Kind:         crd.Spec.Names.Kind,
+			ShortNames:   crd.Spec.Names.ShortNames,
+			Categories:   crd.Spec.Names.Categories,
+			Verbs:        archivedVerbs,
+		}, true
+	}
+	return metav1.APIResource{}, false
+}
+
+// storedPrinterColumns returns the additionalPrinterColumns the CustomResourceDefinition defined for the version
+func storedPrinterColumns(crd apiextensionsv1.CustomResourceDefinition, version string,
+) []apiextensionsv1.CustomResourceColumnDefinition {
+	columns := []apiextensionsv1.CustomResourceColumnDefinition{}
+	for _, crdVersion := range crd.Spec.Versions {
+		if crdVersion.Name == version {
+			columns = append(columns, crdVersion.AdditionalPrinterColumns...)
+		}
+	}
+	return columns
+}
+
+// storedOpenAPIDocument returns the OpenAPI v3 document with the read operations of the custom resources of
+// the group version, with the schemas of their CustomResourceDefinitions. The schemas and operations are
+// named as the Kubernetes API server names them. It returns nil when none of them has a schema for the version
+func storedOpenAPIDocument(crds []apiextensionsv1.CustomResourceDefinition, groupVersion schema.GroupVersion,
+) map[string]any {
+	prefix := getDiscoveryURL(groupVersion.Group, groupVersion.Version)
+	paths := map[string]any{}
+	schemas := map[string]any{}
+	for _, crd := range crds {
+		index := slices.IndexFunc(crd.Spec.Versions, func(version apiextensionsv1.CustomResourceDefinitionVersion) bool {
+			return version.Name == groupVersion.Version
+		})
+		if index < 0 || crd.Spec.Versions[index].Schema == nil || crd.Spec.Versions[index].Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+
+		var crdSchema map[string]any
+		data, err := json.Marshal(crd.Spec.Versions[index].Schema.OpenAPIV3Schema)
+		if err != nil || json.Unmarshal(data, &crdSchema) != nil {
+			continue
+		}
+		kind := crd.Spec.Names.Kind
+		name := openAPISchemaName(groupVersion, kind)
+		crdSchema["x-kubernetes-group-version-kind"] = []any{
+			map[string]any{"group": groupVersion.Group, "version": groupVersion.Version, "kind": kind},
+		}
+		schemas[name] = crdSchema
+		schemas[name+"List"] = map[string]any{
+			"type":     "object",
+			"required": []any{"items"},
+			"properties": map[string]any{
+				"apiVersion": map[string]any{"type": "string"},
+				"kind":       map[string]any{"type": "string"},
+				"metadata":   map[string]any{"type": "object"},
+				"items":      map[string]any{"type": "array", "items": openAPIRef(name)},
+			},
+			"x-kubernetes-group-version-kind": []any{
+				map[string]any{"group": groupVersion.Group, "version": groupVersion.Version, "kind": kind + "List"},
+			},
+		}
+
+		group := openAPIOperationGroup(groupVersion)
+		plural := crd.Spec.Names.Plural
+		if crd.Spec.Scope == apiextensionsv1.NamespaceScoped {
+			namespaced := prefix + "/namespaces/{namespace}/" + plural
+			paths[prefix+"/"+plural] = openAPIPath("list"+group+kind+"ForAllNamespaces", name+"List")
+			paths[namespaced] = openAPIPath("list"+group+"Namespaced"+kind, name+"List", "namespace")
+			paths[namespaced+"/{name}"] = openAPIPath("read"+group+"Namespaced"+kind, name, "namespace", "name")
+		} else {
+			paths[prefix+"/"+plural] = openAPIPath("list"+group+kind, name+"List")
+			paths[prefix+"/"+plural+"/{name}"] = openAPIPath("read"+group+kind, name, "name")
+		}
+	}
+	if len(schemas) == 0 {
+		return nil
+	}
+
+	return map[string]any{
+		"openapi":    "3.0.0",
+		"info":       map[string]any{"title": "Kubernetes CRD Swagger", "version": "v0.1.0"},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+// openAPISchemaName returns the name of the schema of the kind, the group reversed followed by the version
+// and the kind, like com.example.stable.v1.CronTab
+func openAPISchemaName(groupVersion schema.GroupVersion, kind string) string {
+	parts := strings.Split(groupVersion.Group, ".")
+	slices.Reverse(parts)
+	return strings.Join(append(parts, groupVersion.Version, kind), ".")
+}
+
+// openAPIOperationGroup returns the group version as it is in the operation ids, like StableExampleComV1
+func openAPIOperationGroup(groupVersion schema.GroupVersion) string {
+	var name strings.Builder
+	for _, part := range strings.FieldsFunc(groupVersion.Group+"."+groupVersion.Version, func(r rune) bool {
+		return r == '.' || r == '-'
+	}) {
+		name.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return name.String()
+}
+
+func openAPIRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// openAPIPath returns the path item with the get operation returning the schema, and the path parameters
+func openAPIPath(operationID, schemaName string, parameters ...string) map[string]any {
+	item := map[string]any{"get": map[string]any{
+		"operationId": operationID,
+		"responses": map[string]any{"200": map[string]any{
+			"description": "OK",
+			"content":     map[string]any{"application/json": map[string]any{"schema": openAPIRef(schemaName)}},
+		}},
+	}}
+	if len(parameters) > 0 {
+		pathParameters := []any{}
+		for _, parameter := range parameters {
+			pathParameters = append(pathParameters, map[string]any{
+				"name": parameter, "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+			})
+		}
+		item["parameters"] = pathParameters
+	}
+	return item
+}
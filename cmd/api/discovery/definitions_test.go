@@ -0,0 +1,173 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/pkg/cache"
+	"github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeRest "k8s.io/client-go/rest/fake"
+)
+
+var storedCRD = apiextensionsv1.CustomResourceDefinition{
+	Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+		Group: "stable.example.com",
+		Names: apiextensionsv1.CustomResourceDefinitionNames{
+			Plural: "crontabs", Singular: "crontab", Kind: "Crontab", ShortNames: []string{"ct"},
+		},
+		Scope: apiextensionsv1.NamespaceScoped,
+		Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+			{Name: "v1", AdditionalPrinterColumns: testPrinterColumns, Schema: &apiextensionsv1.CustomResourceValidation{
+				OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+					Type: "object",
+					Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"spec": {Type: "object", Properties: map[string]apiextensionsv1.JSONSchemaProps{
+							"cronSpec": {Type: "string"},
+						}},
+					},
+				},
+			}},
+		},
+	},
+}
+
+// newStoredDefinitionsDatabase returns a database with archived Crontabs and their stored definition
+func newStoredDefinitionsDatabase(t *testing.T) interfaces.DBReader {
+	data, err := json.Marshal(storedCRD)
+	assert.NoError(t, err)
+	database := fake.NewFakeDatabase([]*unstructured.Unstructured{
+		newTestResource("Crontab", "stable.example.com/v1"),
+	}, nil, "")
+	assert.NoError(t, database.WriteResourceDefinition(context.Background(), models.ResourceDefinition{
+		Group: "stable.example.com", Plural: "crontabs", Kind: "Crontab", Data: string(data),
+	}))
+	return database
+}
+
+// newNotFoundRESTClient returns a client of a Kubernetes cluster without the stable.example.com group
+func newNotFoundRESTClient() *fakeRest.RESTClient {
+	return &fakeRest.RESTClient{
+		Client: fakeRest.CreateHTTPClient(func(request *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+		}),
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+	}
+}
+
+func TestGetAPIResourceStoredDefinition(t *testing.T) {
+	tests := []struct {
+		name         string
+		version      string
+		resource     string
+		expectedCode int
+		expectedKind string
+	}{
+		{
+			name:         "stored resource",
+			version:      "v1",
+			resource:     "crontabs",
+			expectedCode: http.StatusOK,
+			expectedKind: "Crontab",
+		},
+		{
+			name:         "version not stored",
+			version:      "v2",
+			resource:     "crontabs",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "resource not stored",
+			version:      "v1",
+			resource:     "invalid",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(res)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.AddParam("group", "stable.example.com")
+			c.AddParam("version", tc.version)
+			c.AddParam("resourceType", tc.resource)
+
+			GetAPIResource(newNotFoundRESTClient(), newStoredDefinitionsDatabase(t), cache.New())(c)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+			assert.Equal(t, tc.expectedKind, c.GetString("apiResourceKind"))
+		})
+	}
+}
+
+func TestGetPrinterColumnsStoredDefinition(t *testing.T) {
+	res := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(res)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.Header.Set("Accept", tableAccept)
+	c.AddParam("group", "stable.example.com")
+	c.AddParam("version", "v1")
+	c.AddParam("resourceType", "crontabs")
+
+	GetPrinterColumns(newNotFoundRESTClient(), newStoredDefinitionsDatabase(t), cache.New())(c)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, testPrinterColumns, GetPrinterColumnsFromContext(c))
+}
+
+func TestGetAPIResourceListStoredDefinition(t *testing.T) {
+	api := NewArchivedAPI(newNotFoundRESTClient(), newStoredDefinitionsDatabase(t), cache.New())
+	res := serveArchivedAPI(api.GetAPIResourceList,
+		gin.Params{{Key: "group", Value: "stable.example.com"}, {Key: "version", Value: "v1"}})
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	resourceList := metav1.APIResourceList{}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &resourceList))
+	assert.Equal(t, []metav1.APIResource{{Name: "crontabs", SingularName: "crontab", Namespaced: true,
+		Kind: "Crontab", ShortNames: []string{"ct"}, Verbs: archivedVerbs}}, resourceList.APIResources)
+}
+
+func TestGetOpenAPIStoredDefinition(t *testing.T) {
+	api := NewArchivedAPI(newNotFoundRESTClient(), newStoredDefinitionsDatabase(t), cache.New())
+	res := serveArchivedAPI(api.GetOpenAPI,
+		gin.Params{{Key: "group", Value: "stable.example.com"}, {Key: "version", Value: "v1"}})
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	document := map[string]any{}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &document))
+	assert.Equal(t, "3.0.0", document["openapi"])
+
+	schemas := document["components"].(map[string]any)["schemas"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string"},
+		schemas["com.example.stable.v1.Crontab"].(map[string]any)["properties"].(map[string]any)["spec"].(map[string]any)["properties"].(map[string]any)["cronSpec"])
+	assert.Contains(t, schemas, "com.example.stable.v1.CrontabList")
+
+	paths := document["paths"].(map[string]any)
+	assert.Len(t, paths, 3)
+	operations := map[string]any{}
+	for path, item := range paths {
+		operations[path] = item.(map[string]any)["get"].(map[string]any)["operationId"]
+	}
+	assert.Equal(t, map[string]any{
+		"/apis/stable.example.com/v1/crontabs":                               "listStableExampleComV1CrontabForAllNamespaces",
+		"/apis/stable.example.com/v1/namespaces/{namespace}/crontabs":        "listStableExampleComV1NamespacedCrontab",
+		"/apis/stable.example.com/v1/namespaces/{namespace}/crontabs/{name}": "readStableExampleComV1NamespacedCrontab",
+	}, operations)
+}
@@ -0,0 +1,103 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/kubearchive/kubearchive/cmd/api/tables"
+	"github.com/kubearchive/kubearchive/pkg/cache"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+)
+
+const (
+	printerColumnsKey = "printerColumns"
+	crdsURL           = "/apis/apiextensions.k8s.io/v1/customresourcedefinitions"
+)
+
+// GetPrinterColumns sets printerColumns attribute in the context with the additionalPrinterColumns of the
+// CustomResourceDefinition of the requested resource. The Kubernetes API is only queried when the client asks
+// for a Table, and failures are not fatal as the table falls back to the built-in columns. When the
+// CustomResourceDefinition was removed from the cluster, the definition stored by the sink is used.
+func GetPrinterColumns(client rest.Interface, database interfaces.DBReader, cache *cache.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group := c.Param("group")
+		if group == "" || !tables.IsTableRequest(c.GetHeader("Accept")) {
+			return
+		}
+
+		crdName := fmt.Sprintf("%s.%s", c.Param("resourceType"), group)
+		cacheKey := fmt.Sprintf("%s/%s/%s", printerColumnsKey, crdName, c.Param("version"))
+		if columns, ok := cache.Get(cacheKey).([]apiextensionsv1.CustomResourceColumnDefinition); ok {
+			c.Set(printerColumnsKey, columns)
+			return
+		}
+
+		result := client.Get().AbsPath(crdsURL, crdName).Do(c.Request.Context())
+		if result.Error() != nil {
+			status := 0
+			result.StatusCode(&status)
+			if status == http.StatusNotFound {
+				// Not defined by a CRD in the cluster, cache it to avoid querying the Kubernetes API again
+				columns := storedDefinitionPrinterColumns(c, database, cache)
+				cache.Set(cacheKey, columns, cacheExpirationTime)
+				c.Set(printerColumnsKey, columns)
+				return
+			}
+			slog.WarnContext(c.Request.Context(), "unable to retrieve the CustomResourceDefinition",
+				"crd", crdName, "error", result.Error().Error())
+			return
+		}
+
+		raw, err := result.Raw()
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "unable to read the CustomResourceDefinition",
+				"crd", crdName, "error", err.Error())
+			return
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err = json.Unmarshal(raw, crd); err != nil {
+			slog.WarnContext(c.Request.Context(), "unable to deserialize the CustomResourceDefinition",
+				"crd", crdName, "error", err.Error())
+			return
+		}
+
+		columns := storedPrinterColumns(*crd, c.Param("version"))
+		cache.Set(cacheKey, columns, cacheExpirationTime)
+		c.Set(printerColumnsKey, columns)
+	}
+}
+
+// storedDefinitionPrinterColumns returns the additionalPrinterColumns of the requested resource from the
+// definitions stored by the sink, none when there is no stored definition
+func storedDefinitionPrinterColumns(c *gin.Context, database interfaces.DBReader, cache *cache.Cache,
+) []apiextensionsv1.CustomResourceColumnDefinition {
+	if database == nil {
+		return []apiextensionsv1.CustomResourceColumnDefinition{}
+	}
+	crds, err := storedDefinitions(c.Request.Context(), database, cache, c.Param("group"))
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "unable to use the stored definitions", "error", err.Error())
+		return []apiextensionsv1.CustomResourceColumnDefinition{}
+	}
+	for _, crd := range crds {
+		if crd.Spec.Names.Plural == c.Param("resourceType") {
+			return storedPrinterColumns(crd, c.Param("version"))
+		}
+	}
+	return []apiextensionsv1.CustomResourceColumnDefinition{}
+}
+
+// GetPrinterColumnsFromContext returns the additionalPrinterColumns set by GetPrinterColumns, if any
+func GetPrinterColumnsFromContext(context *gin.Context) []apiextensionsv1.CustomResourceColumnDefinition {
+	columns, _ := context.Value(printerColumnsKey).([]apiextensionsv1.CustomResourceColumnDefinition)
+	return columns
+}
@@ -0,0 +1,142 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+package discovery
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeRest "k8s.io/client-go/rest/fake"
+)
+
+const tableAccept = "application/json;as=Table;v=v1;g=meta.k8s.io"
+
+var testPrinterColumns = []apiextensionsv1.CustomResourceColumnDefinition{
+	{Name: "Spec", Type: "string", JSONPath: ".spec.cronSpec"},
+}
+
+var testCRD = apiextensionsv1.CustomResourceDefinition{
+	Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+		Group: "stable.example.com",
+		Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+			{Name: "v1", AdditionalPrinterColumns: testPrinterColumns},
+			{Name: "v2"},
+		},
+	},
+}
+
+func TestGetPrinterColumns(t *testing.T) {
+	crdBytes, err := json.Marshal(testCRD)
+	if err != nil {
+		t.Fatalf("Error while serializing the CRD: %s", err)
+	}
+
+	tests := []struct {
+		name          string
+		group         string
+		version       string
+		accept        string
+		status        int
+		expected      []apiextensionsv1.CustomResourceColumnDefinition
+		expectedCalls int
+	}{
+		{
+			name:          "not a table request",
+			group:         "stable.example.com",
+			version:       "v1",
+			status:        http.StatusOK,
+			expectedCalls: 0,
+		},
+		{
+			name:          "core resource",
+			version:       "v1",
+			accept:        tableAccept,
+			status:        http.StatusOK,
+			expectedCalls: 0,
+		},
+		{
+			name:          "version with printer columns",
+			group:         "stable.example.com",
+			version:       "v1",
+			accept:        tableAccept,
+			status:        http.StatusOK,
+			expected:      testPrinterColumns,
+			expectedCalls: 1,
+		},
+		{
+			name:          "version without printer columns",
+			group:         "stable.example.com",
+			version:       "v2",
+			accept:        tableAccept,
+			status:        http.StatusOK,
+			expectedCalls: 1,
+		},
+		{
+			name:          "not a custom resource",
+			group:         "apps",
+			version:       "v1",
+			accept:        tableAccept,
+			status:        http.StatusNotFound,
+			expectedCalls: 1,
+		},
+		{
+			name:          "error retrieving the CRD",
+			group:         "stable.example.com",
+			version:       "v1",
+			accept:        tableAccept,
+			status:        http.StatusForbidden,
+			expectedCalls: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			restClient := &fakeRest.RESTClient{
+				Client: fakeRest.CreateHTTPClient(func(request *http.Request) (*http.Response, error) {
+					calls++
+					assert.Equal(t, crdsURL+"/crontabs."+tc.group, request.URL.Path)
+					body := string(crdBytes)
+					if tc.status != http.StatusOK {
+						body = "{}"
+					}
+					return &http.Response{StatusCode: tc.status, Body: io.NopCloser(strings.NewReader(body))}, nil
+				}),
+				NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+				GroupVersion:         schema.GroupVersion{Version: "v1"},
+			}
+
+			memCache := cache.New()
+			// The second request uses the cache unless retrieving the CRD failed
+			for range 2 {
+				res := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(res)
+				c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+				c.Request.Header.Set("Accept", tc.accept)
+				c.AddParam("group", tc.group)
+				c.AddParam("version", tc.version)
+				c.AddParam("resourceType", "crontabs")
+
+				GetPrinterColumns(restClient, nil, memCache)(c)
+
+				assert.Equal(t, http.StatusOK, res.Code)
+				if len(tc.expected) == 0 {
+					assert.Empty(t, GetPrinterColumnsFromContext(c))
+				} else {
+					assert.Equal(t, tc.expected, GetPrinterColumnsFromContext(c))
+				}
+			}
+			assert.Equal(t, tc.expectedCalls, calls)
+		})
+	}
+}
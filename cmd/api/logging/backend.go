@@ -0,0 +1,161 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kubearchive/kubearchive/pkg/blobstore"
+	"github.com/ohler55/ojg/jp"
+	"github.com/ohler55/ojg/oj"
+)
+
+// LogBackend retrieves the log lines of a container from a logging backend
+type LogBackend interface {
+	// Stream calls add with the log lines returned by logURL until there are no more lines or add returns
+	// false. Backends that split the lines in pages retrieve all the pages, so the lines are not cut off
+	// at the page size of the backend.
+	Stream(ctx context.Context, logURL *url.URL, jsonPath jp.Expr, options LogOptions, add addLineFunc) error
+}
+
+// addLineFunc is called with every log line retrieved, it returns false when no more lines are needed
+type addLineFunc func(line logLine) (bool, error)
+
+// statusError is returned when the logging backend responds with an error
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("error response: %d - %s", e.code, e.body)
+}
+
+// newLogBackend returns the LogBackend for logURL, based on the API of the logging backend it targets.
+// Unknown APIs are queried with a single GET request whose body is parsed line by line. The logs the sink
+// stored are read from store.
+func newLogBackend(client *http.Client, headers map[string]string, store blobstore.Store,
+	logURL *url.URL) LogBackend {
+	backendClient := backendClient{client: client, headers: headers}
+	switch {
+	case logURL.Scheme == blobstore.URLScheme:
+		return &blobBackend{backendClient: backendClient, store: store}
+	case strings.HasSuffix(logURL.Path, lokiQueryRangePath):
+		return &lokiBackend{backendClient: backendClient}
+	case strings.HasSuffix(logURL.Path, elasticsearchSearchPath):
+		return &elasticsearchBackend{backendClient: backendClient}
+	case strings.Contains(logURL.Path, splunkJobsPath):
+		return &splunkBackend{backendClient: backendClient, pollInterval: splunkPollInterval}
+	default:
+		return &httpBackend{backendClient: backendClient}
+	}
+}
+
+// backendClient sends the requests to the logging backend with the headers of the kubearchive-logging secret
+type backendClient struct {
+	client  *http.Client
+	headers map[string]string
+}
+
+// do sends the request and returns its response, or a statusError when the response is not successful
+func (b backendClient) do(ctx context.Context, method, requestURL string, body io.Reader,
+	contentType string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, requestURL, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range b.headers {
+		request.Header.Set(key, value)
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	response, err := b.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		defer response.Body.Close()
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, &statusError{code: response.StatusCode, body: string(responseBody)}
+	}
+	return response, nil
+}
+
+// doJSON sends the request and returns its response parsed as JSON
+func (b backendClient) doJSON(ctx context.Context, method, requestURL string, body io.Reader,
+	contentType string) (any, error) {
+	response, err := b.do(ctx, method, requestURL, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	return oj.Parse(responseBody)
+}
+
+// httpBackend retrieves the logs with a GET request to the log URL, parsing each line of the response
+// with the jsonPath
+type httpBackend struct {
+	backendClient
+}
+
+func (b *httpBackend) Stream(ctx context.Context, logURL *url.URL, jsonPath jp.Expr, _ LogOptions,
+	add addLineFunc) error {
+	response, err := b.do(ctx, http.MethodGet, logURL.String(), nil, "")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	reader := bufio.NewReader(response.Body)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		parsedLines, errParseLine := parseLine(line, jsonPath)
+		if errParseLine != nil {
+			return errParseLine
+		}
+		for _, parsedLine := range parsedLines {
+			next, errAdd := add(parsedLine)
+			if errAdd != nil || !next {
+				return errAdd
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+// addLines calls add with the lines selected by jsonPath in value, logged at timestamp when it is not zero.
+// It returns false when add did.
+func addLines(value any, jsonPath jp.Expr, timestamp time.Time, add addLineFunc) (bool, error) {
+	for _, res := range jsonPath.Get(value) {
+		text, ok := res.(string)
+		if !ok {
+			return false, fmt.Errorf("unexpected log entry of type %T", res)
+		}
+		next, err := add(logLine{timestamp: timestamp, text: text})
+		if err != nil || !next {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,92 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/kubearchive/kubearchive/pkg/blobstore"
+	"github.com/ohler55/ojg/jp"
+)
+
+// blobBackend retrieves the logs the sink stored in the log store. When they are not there it retrieves
+// them from the logging backend of the fallback URL.
+type blobBackend struct {
+	backendClient
+	// store is nil when the API has no log store configured
+	store blobstore.Store
+}
+
+func (b *blobBackend) Stream(ctx context.Context, logURL *url.URL, jsonPath jp.Expr, options LogOptions,
+	add addLineFunc) error {
+	key, fallback, ok := blobstore.ParseURL(logURL)
+	if !ok {
+		return fmt.Errorf("invalid stored log URL '%s'", logURL.Redacted())
+	}
+
+	errGet := errors.New("no log store configured")
+	if b.store != nil {
+		var content io.ReadCloser
+		content, errGet = b.store.Get(ctx, key)
+		if errGet == nil {
+			defer content.Close()
+			return addStoredLines(content, add)
+		}
+	}
+
+	if fallback == "" {
+		if b.store == nil || errors.Is(errGet, blobstore.ErrNotFound) {
+			return &statusError{code: http.StatusNotFound, body: "log not found in the log store"}
+		}
+		return errGet
+	}
+	fallbackURL, errURL := url.Parse(fallback)
+	if errURL != nil {
+		return fmt.Errorf("invalid fallback log URL: %w", errURL)
+	}
+	if fallbackURL.Scheme == blobstore.URLScheme {
+		return errors.New("the fallback log URL can not be a stored log URL")
+	}
+	slog.WarnContext(ctx, "Could not read the log from the log store, using the logging backend",
+		"key", key, "error", errGet)
+	return newLogBackend(b.client, b.headers, nil, fallbackURL).Stream(ctx, fallbackURL, jsonPath, options, add)
+}
+
+// addStoredLines calls add with the lines read from content, which the sink stored as the kubelet returns
+// them, prefixed with their RFC3339 timestamp
+func addStoredLines(content io.Reader, add addLineFunc) error {
+	reader := bufio.NewReader(content)
+	for {
+		line, errRead := reader.ReadBytes('\n')
+		if errRead != nil && !errors.Is(errRead, io.EOF) {
+			return errRead
+		}
+		if len(line) > 0 {
+			line = bytes.TrimSuffix(line, []byte("\n"))
+			var timestamp time.Time
+			if rawTimestamp, text, found := bytes.Cut(line, []byte(" ")); found {
+				if parsed, err := time.Parse(time.RFC3339Nano, string(rawTimestamp)); err == nil {
+					timestamp = parsed
+					line = text
+				}
+			}
+			next, err := add(logLine{timestamp: timestamp, text: string(line)})
+			if err != nil || !next {
+				return err
+			}
+		}
+		if errRead != nil {
+			return nil
+		}
+	}
+}
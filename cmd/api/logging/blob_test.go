@@ -0,0 +1,99 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubearchive/kubearchive/pkg/blobstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlobBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("fallback line\n"))
+	}))
+	defer server.Close()
+
+	store, err := blobstore.NewFilesystemStore(t.TempDir())
+	assert.NoError(t, err)
+	storedKey := blobstore.ContainerLogKey("test", "abc-123", "main")
+	assert.NoError(t, store.Put(context.Background(), storedKey,
+		strings.NewReader("2025-01-01T10:00:00.123456789Z line 1\n2025-01-01T10:00:01Z line 2\nwithout timestamp\n")))
+	missingKey := blobstore.ContainerLogKey("test", "abc-123", "init")
+
+	tests := []struct {
+		name          string
+		store         blobstore.Store
+		url           string
+		expected      []logLine
+		expectedError int
+	}{
+		{
+			name:  "stored",
+			store: store,
+			url:   blobstore.URL(storedKey, server.URL),
+			expected: []logLine{
+				{timestamp: time.Date(2025, 1, 1, 10, 0, 0, 123456789, time.UTC), text: "line 1"},
+				{timestamp: time.Date(2025, 1, 1, 10, 0, 1, 0, time.UTC), text: "line 2"},
+				{text: "without timestamp"},
+			},
+		},
+		{
+			name:     "not stored",
+			store:    store,
+			url:      blobstore.URL(missingKey, server.URL),
+			expected: []logLine{{text: "fallback line"}},
+		},
+		{
+			name:     "no log store",
+			url:      blobstore.URL(storedKey, server.URL),
+			expected: []logLine{{text: "fallback line"}},
+		},
+		{
+			name:          "not stored without fallback",
+			store:         store,
+			url:           blobstore.URL(missingKey, ""),
+			expectedError: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logURL, _ := url.Parse(tt.url)
+			var lines []logLine
+			backend := newLogBackend(server.Client(), nil, tt.store, logURL)
+			err := backend.Stream(context.Background(), logURL, nil, LogOptions{}, func(line logLine) (bool, error) {
+				lines = append(lines, line)
+				return true, nil
+			})
+
+			if tt.expectedError != 0 {
+				var errStatus *statusError
+				if assert.ErrorAs(t, err, &errStatus) {
+					assert.Equal(t, tt.expectedError, errStatus.code)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, lines)
+		})
+	}
+}
+
+func TestBlobBackendStoredFallback(t *testing.T) {
+	logURL, _ := url.Parse(blobstore.URL("logs/a.log", blobstore.URL("logs/b.log", "")))
+	backend := newLogBackend(http.DefaultClient, nil, nil, logURL)
+	err := backend.Stream(context.Background(), logURL, nil, LogOptions{}, func(line logLine) (bool, error) {
+		return true, nil
+	})
+
+	assert.ErrorContains(t, err, "can not be a stored log URL")
+}
@@ -0,0 +1,225 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/kubearchive/kubearchive/pkg/files"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// Keys of the kubearchive-logging secret that configure the connection to the logging backend. The rest
+// of the keys are sent as headers.
+const (
+	// caBundleKey is the PEM bundle of the CAs that sign the certificate of the logging backend, added to
+	// the CAs of the system
+	caBundleKey = "CA_BUNDLE"
+	// clientCertKey and clientKeyKey are the PEM certificate and key to authenticate to the logging backend
+	clientCertKey = "TLS_CLIENT_CERT"
+	clientKeyKey  = "TLS_CLIENT_KEY"
+	// insecureSkipVerifyKey disables the verification of the certificate of the logging backend when "true"
+	insecureSkipVerifyKey = "TLS_INSECURE_SKIP_VERIFY"
+	// oauth2TokenURLKey enables the OAuth2 client credentials flow, the token is sent as a bearer token
+	oauth2TokenURLKey     = "OAUTH2_TOKEN_URL"
+	oauth2ClientIDKey     = "OAUTH2_CLIENT_ID"
+	oauth2ClientSecretKey = "OAUTH2_CLIENT_SECRET"
+	// oauth2ScopesKey are the scopes requested for the token, separated by commas or spaces
+	oauth2ScopesKey = "OAUTH2_SCOPES"
+)
+
+var configKeys = []string{caBundleKey, clientCertKey, clientKeyKey, insecureSkipVerifyKey, oauth2TokenURLKey,
+	oauth2ClientIDKey, oauth2ClientSecretKey, oauth2ScopesKey}
+
+const loggingClientTimeout = 60 * time.Second
+
+// loggingConfig are the headers and the client used to query the logging backend
+type loggingConfig struct {
+	headers map[string]string
+	client  *http.Client
+}
+
+// newLoggingConfig returns the loggingConfig of the data of the kubearchive-logging secret
+func newLoggingConfig(data map[string]string) (*loggingConfig, error) {
+	headers := maps.Clone(data)
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	for _, key := range configKeys {
+		delete(headers, key)
+	}
+
+	tlsConfig, err := newTLSConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Transport: otelhttp.NewTransport(&http.Transport{TLSClientConfig: tlsConfig}),
+		Timeout:   loggingClientTimeout,
+	}
+
+	if tokenURL := data[oauth2TokenURLKey]; tokenURL != "" {
+		if data[oauth2ClientIDKey] == "" || data[oauth2ClientSecretKey] == "" {
+			return nil, fmt.Errorf("%s and %s are required with %s", oauth2ClientIDKey, oauth2ClientSecretKey,
+				oauth2TokenURLKey)
+		}
+		credentials := clientcredentials.Config{
+			ClientID:     data[oauth2ClientIDKey],
+			ClientSecret: data[oauth2ClientSecretKey],
+			TokenURL:     tokenURL,
+			Scopes: strings.FieldsFunc(data[oauth2ScopesKey], func(r rune) bool {
+				return r == ',' || r == ' '
+			}),
+		}
+		// The tokens are requested with the same TLS configuration, and refreshed when they expire
+		tokenContext := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+		client = &http.Client{
+			Transport: &oauth2.Transport{Source: credentials.TokenSource(tokenContext), Base: client.Transport},
+			Timeout:   loggingClientTimeout,
+		}
+	}
+
+	return &loggingConfig{headers: headers, client: client}, nil
+}
+
+// newTLSConfig returns the TLS configuration of the data of the kubearchive-logging secret
+func newTLSConfig(data map[string]string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if value := data[insecureSkipVerifyKey]; value != "" {
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a boolean, got '%s'", insecureSkipVerifyKey, value)
+		}
+		tlsConfig.InsecureSkipVerify = insecure // #nosec G402 -- explicitly enabled by the administrator
+	}
+
+	if caBundle := data[caBundleKey]; caBundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(caBundle)) {
+			return nil, fmt.Errorf("%s does not contain any PEM certificate", caBundleKey)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	cert, key := data[clientCertKey], data[clientKeyKey]
+	if (cert == "") != (key == "") {
+		return nil, fmt.Errorf("%s and %s must be set together", clientCertKey, clientKeyKey)
+	}
+	if cert != "" {
+		certificate, err := tls.X509KeyPair([]byte(cert), []byte(key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// LoggingSecret keeps the configuration read from the kubearchive-logging secret mounted in the directory
+// of the KUBEARCHIVE_LOGGING_DIR environment variable
+type LoggingSecret struct {
+	dir    string
+	config atomic.Pointer[loggingConfig]
+}
+
+// NewLoggingSecret returns the LoggingSecret with the current content of the kubearchive-logging secret.
+// When the secret is not available or not valid, no headers are sent and the certificate of the logging
+// backend is verified with the CAs of the system.
+func NewLoggingSecret() *LoggingSecret {
+	secret := &LoggingSecret{dir: os.Getenv(files.LoggingDirEnvVar)}
+	if secret.dir == "" {
+		slog.Warn(fmt.Sprintf("environment variable not set: %s", files.LoggingDirEnvVar))
+	}
+	if err := secret.reload(); err != nil {
+		slog.Warn("could not read kubearchive-logging secret", "error", err.Error())
+	}
+	if secret.config.Load() == nil {
+		config, _ := newLoggingConfig(nil)
+		secret.config.Store(config)
+	}
+	return secret
+}
+
+// reload reads the secret again. The previous configuration is kept if the new one is not valid.
+func (s *LoggingSecret) reload() error {
+	if s.dir == "" {
+		return nil
+	}
+	configFiles, err := files.FilesInDir(s.dir)
+	if err != nil {
+		return err
+	}
+	if len(configFiles) == 0 {
+		return errors.New("logging configuration not specified")
+	}
+	data, err := files.LoggingConfigFromFiles(configFiles)
+	if err != nil {
+		return err
+	}
+	config, err := newLoggingConfig(data)
+	if err != nil {
+		return err
+	}
+	s.config.Store(config)
+	return nil
+}
+
+// Watch reloads the secret every time Kubernetes updates the files in its directory, until ctx is done
+func (s *LoggingSecret) Watch(ctx context.Context) error {
+	if s.dir == "" {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	// Kubernetes updates the files by replacing the ..data symlink of the directory
+	if err = watcher.Add(s.dir); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if errReload := s.reload(); errReload != nil {
+				slog.Error("could not reload kubearchive-logging secret", "error", errReload.Error())
+				continue
+			}
+			slog.Info("kubearchive-logging secret reloaded")
+		case errWatch, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Error("error watching kubearchive-logging secret", "error", errWatch.Error())
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohler55/ojg/jp"
+)
+
+const (
+	elasticsearchSearchPath = "/_search"
+	elasticsearchPITPath    = "/_pit"
+	// elasticsearchPageSize is the number of hits requested per page when the query does not set a size
+	elasticsearchPageSize = 1000
+	// elasticsearchKeepAlive is how long the point in time is kept between two pages
+	elasticsearchKeepAlive = "1m"
+	// elasticsearchTimestampField is the field with the time the line was logged, filtered with sinceTime
+	// and returned with timestamps
+	elasticsearchTimestampField = "@timestamp"
+	// elasticsearchMaxResultWindow is the default index.max_result_window of Elasticsearch, the largest size
+	// requested for the last lines of a log
+	elasticsearchMaxResultWindow = 10000
+)
+
+var (
+	elasticsearchHits          = jp.MustParseString("$.hits.hits")
+	elasticsearchTimedOut      = jp.MustParseString("$.timed_out")
+	elasticsearchPITID         = jp.MustParseString("$.pit_id")
+	elasticsearchDefaultSource = jp.MustParseString("$.hits.hits[*]._source.message")
+)
+
+// elasticsearchBackend retrieves the logs with the Elasticsearch search API. Elasticsearch returns at most
+// size hits per search and can not go past 10000 hits with from, so the next pages are retrieved with
+// search_after using the sort values of the last hit until a page has less hits than the size. The pages
+// are searched in a point in time of the index, so the hits indexed while paging do not move the pages.
+// When only the last lines are needed, they are requested with a single search sorted by time descending.
+// The jsonPath is applied to every hit wrapped as a search response, {"hits": {"hits": [...]}}.
+type elasticsearchBackend struct {
+	backendClient
+}
+
+// elasticsearchSearch is the search of the log URL split in the parts sent on each page
+type elasticsearchSearch struct {
+	// baseURL is the URL of the Elasticsearch API, without the index
+	baseURL url.URL
+	index   string
+	// params are the query parameters of the log URL sent on each page, except q
+	params url.Values
+	size   int
+	// query is the q parameter of the log URL along with the range of sinceTime
+	query map[string]any
+}
+
+// newElasticsearchSearch returns the search of logURL prepared to be paged with search_after
+func newElasticsearchSearch(logURL *url.URL, options LogOptions) (*elasticsearchSearch, error) {
+	search := &elasticsearchSearch{baseURL: *logURL, params: logURL.Query()}
+	search.baseURL.RawQuery = ""
+	path := strings.TrimSuffix(logURL.Path, elasticsearchSearchPath)
+	slash := strings.LastIndex(path, "/")
+	// A point in time is opened on an index
+	if slash < 0 || slash == len(path)-1 {
+		return nil, errors.New("the Elasticsearch log URL must search an index, like /<index>/_search")
+	}
+	search.index = path[slash+1:]
+	search.baseURL.Path = path[:slash]
+
+	// from can not be used with search_after
+	search.params.Del("from")
+	// search_after needs the hits sorted, _doc is the most efficient order
+	if search.params.Get("sort") == "" {
+		search.params.Set("sort", "_doc")
+	}
+	// The timestamp of the hits is read from their source
+	if includes := search.params.Get("_source_includes"); includes != "" &&
+		!slices.Contains(strings.Split(includes, ","), elasticsearchTimestampField) {
+		search.params.Set("_source_includes", includes+","+elasticsearchTimestampField)
+	}
+
+	search.size = elasticsearchPageSize
+	if value := search.params.Get("size"); value != "" {
+		var err error
+		search.size, err = strconv.Atoi(value)
+		if err != nil || search.size < 1 {
+			return nil, fmt.Errorf("invalid Elasticsearch size '%s'", value)
+		}
+	}
+	search.params.Set("size", strconv.Itoa(search.size))
+
+	// q is moved to the body, where it is combined with the range of sinceTime
+	clauses := map[string]any{}
+	if q := search.params.Get("q"); q != "" {
+		clauses["must"] = map[string]any{"query_string": map[string]any{"query": q}}
+	}
+	search.params.Del("q")
+	if options.SinceTime != nil {
+		clauses["filter"] = map[string]any{"range": map[string]any{
+			elasticsearchTimestampField: map[string]any{"gte": options.SinceTime.UTC().Format(time.RFC3339Nano)},
+		}}
+	}
+	if len(clauses) > 0 {
+		search.query = map[string]any{"bool": clauses}
+	}
+	return search, nil
+}
+
+func (b *elasticsearchBackend) Stream(ctx context.Context, logURL *url.URL, jsonPath jp.Expr, options LogOptions,
+	add addLineFunc) error {
+	search, err := newElasticsearchSearch(logURL, options)
+	if err != nil {
+		return err
+	}
+	if jsonPath == nil {
+		jsonPath = elasticsearchDefaultSource
+	}
+	if tailLines, ok := options.tailLimit(); ok && tailLines <= elasticsearchMaxResultWindow {
+		return b.tail(ctx, search, tailLines, jsonPath, add)
+	}
+
+	pitID, err := b.openPIT(ctx, search)
+	if err != nil {
+		return err
+	}
+	defer func() { b.closePIT(ctx, search, pitID) }()
+
+	searchURL := search.baseURL
+	searchURL.Path += elasticsearchSearchPath
+	searchURL.RawQuery = search.params.Encode()
+	var searchAfter any
+	for {
+		body := map[string]any{"pit": map[string]any{"id": pitID, "keep_alive": elasticsearchKeepAlive}}
+		if search.query != nil {
+			body["query"] = search.query
+		}
+		if searchAfter != nil {
+			body["search_after"] = searchAfter
+		}
+		encoded, errEncode := json.Marshal(body)
+		if errEncode != nil {
+			return errEncode
+		}
+		page, errPage := b.doJSON(ctx, http.MethodPost, searchURL.String(), bytes.NewReader(encoded),
+			"application/json")
+		if errPage != nil {
+			return errPage
+		}
+		// A search that timed out returns the hits found so far, so the log would be cut off
+		if timedOut := elasticsearchTimedOut.First(page); timedOut == true {
+			return errors.New("elasticsearch search timed out, the log is incomplete")
+		}
+		// The id of the point in time can change between searches
+		if id, ok := elasticsearchPITID.First(page).(string); ok && id != "" {
+			pitID = id
+		}
+
+		hits, _ := elasticsearchHits.First(page).([]any)
+		for _, hit := range hits {
+			wrapped := map[string]any{"hits": map[string]any{"hits": []any{hit}}}
+			next, errAdd := addLines(wrapped, jsonPath, elasticsearchHitTimestamp(hit), add)
+			if errAdd != nil || !next {
+				return errAdd
+			}
+		}
+
+		if len(hits) < search.size {
+			return nil
+		}
+		lastHit, _ := hits[len(hits)-1].(map[string]any)
+		searchAfter = lastHit["sort"]
+		if searchAfter == nil {
+			return errors.New("elasticsearch hits without sort values can not be paged")
+		}
+	}
+}
+
+// tail calls add with the lines of the last tailLines hits of the search. The hits are searched from the
+// newest to the oldest, so they are added in reverse. One hit is requested at least, the writer drops it when
+// no lines are needed, so a missing log is still reported.
+func (b *elasticsearchBackend) tail(ctx context.Context, search *elasticsearchSearch, tailLines int,
+	jsonPath jp.Expr, add addLineFunc) error {
+	search.params.Set("sort", elasticsearchTimestampField+":desc")
+	search.params.Set("size", strconv.Itoa(max(tailLines, 1)))
+	searchURL := search.baseURL
+	searchURL.Path += "/" + search.index + elasticsearchSearchPath
+	searchURL.RawQuery = search.params.Encode()
+
+	body := map[string]any{}
+	if search.query != nil {
+		body["query"] = search.query
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	page, err := b.doJSON(ctx, http.MethodPost, searchURL.String(), bytes.NewReader(encoded), "application/json")
+	if err != nil {
+		return err
+	}
+	if timedOut := elasticsearchTimedOut.First(page); timedOut == true {
+		return errors.New("elasticsearch search timed out, the log is incomplete")
+	}
+
+	hits, _ := elasticsearchHits.First(page).([]any)
+	for i := len(hits) - 1; i >= 0; i-- {
+		wrapped := map[string]any{"hits": map[string]any{"hits": []any{hits[i]}}}
+		next, errAdd := addLines(wrapped, jsonPath, elasticsearchHitTimestamp(hits[i]), add)
+		if errAdd != nil || !next {
+			return errAdd
+		}
+	}
+	return nil
+}
+
+// elasticsearchHitTimestamp returns the time the hit was logged, the zero time when it is not in its source
+func elasticsearchHitTimestamp(hit any) time.Time {
+	fields, _ := hit.(map[string]any)
+	source, _ := fields["_source"].(map[string]any)
+	value, _ := source[elasticsearchTimestampField].(string)
+	timestamp, _ := time.Parse(time.RFC3339Nano, value)
+	return timestamp
+}
+
+// openPIT opens a point in time of the index of the search and returns its id
+func (b *elasticsearchBackend) openPIT(ctx context.Context, search *elasticsearchSearch) (string, error) {
+	pitURL := search.baseURL
+	pitURL.Path += "/" + search.index + elasticsearchPITPath
+	pitURL.RawQuery = url.Values{"keep_alive": {elasticsearchKeepAlive}}.Encode()
+	response, err := b.doJSON(ctx, http.MethodPost, pitURL.String(), nil, "")
+	if err != nil {
+		return "", err
+	}
+	fields, _ := response.(map[string]any)
+	id, _ := fields["id"].(string)
+	if id == "" {
+		return "", errors.New("elasticsearch did not return the id of the point in time")
+	}
+	return id, nil
+}
+
+// closePIT closes the point in time, so Elasticsearch does not keep its resources until it expires
+func (b *elasticsearchBackend) closePIT(ctx context.Context, search *elasticsearchSearch, pitID string) {
+	pitURL := search.baseURL
+	pitURL.Path += elasticsearchPITPath
+	body, _ := json.Marshal(map[string]string{"id": pitID})
+	response, err := b.do(context.WithoutCancel(ctx), http.MethodDelete, pitURL.String(), bytes.NewReader(body),
+		"application/json")
+	if err != nil {
+		slog.WarnContext(ctx, "could not close the Elasticsearch point in time", "error", err)
+		return
+	}
+	response.Body.Close()
+}
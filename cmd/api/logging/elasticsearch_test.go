@@ -0,0 +1,201 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newElasticsearchServer returns a server with total hits logged a second apart from start, that opens and
+// closes points in time and sends the search bodies it receives to searches
+func newElasticsearchServer(t *testing.T, total int, start time.Time, timedOut bool,
+	searches *[]map[string]any, closed *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/fluentd"+elasticsearchPITPath:
+			assert.Equal(t, elasticsearchKeepAlive, r.URL.Query().Get("keep_alive"))
+			_, _ = fmt.Fprintln(w, `{"id":"pit-0"}`)
+			return
+		case r.Method == http.MethodDelete && r.URL.Path == elasticsearchPITPath:
+			body := map[string]string{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			*closed = append(*closed, body["id"])
+			return
+		}
+
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, elasticsearchSearchPath, r.URL.Path)
+		assert.Equal(t, "_doc", r.URL.Query().Get("sort"))
+		assert.Empty(t, r.URL.Query().Get("from"))
+		assert.Empty(t, r.URL.Query().Get("q"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+
+		body := map[string]any{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		*searches = append(*searches, body)
+		first := 0
+		if searchAfter, ok := body["search_after"].([]any); ok {
+			first = int(searchAfter[0].(float64)) + 1
+		}
+
+		hits := []map[string]any{}
+		for i := first; i < total && len(hits) < size; i++ {
+			hits = append(hits, map[string]any{
+				"_source": map[string]any{
+					"message":                   fmt.Sprintf("line %d", i+1),
+					elasticsearchTimestampField: start.Add(time.Duration(i) * time.Second).Format(time.RFC3339),
+				},
+				"sort": []int{i},
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"pit_id":    fmt.Sprintf("pit-%d", len(*searches)),
+			"timed_out": timedOut,
+			"hits":      map[string]any{"hits": hits},
+		})
+	}))
+}
+
+func TestElasticsearchBackendPages(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var searches []map[string]any
+	var closed []string
+	server := newElasticsearchServer(t, 5, start, false, &searches, &closed)
+	defer server.Close()
+
+	logURL, _ := url.Parse(server.URL + "/fluentd" + elasticsearchSearchPath + "?q=kubernetes.pod_id:a&size=2&from=0")
+	var lines []logLine
+	backend := newLogBackend(server.Client(), nil, nil, logURL)
+	err := backend.Stream(context.Background(), logURL, nil, LogOptions{}, func(line logLine) (bool, error) {
+		lines = append(lines, line)
+		return true, nil
+	})
+
+	assert.NoError(t, err)
+	texts := []string{}
+	for i, line := range lines {
+		texts = append(texts, line.text)
+		assert.Equal(t, start.Add(time.Duration(i)*time.Second), line.timestamp)
+	}
+	assert.Equal(t, []string{"line 1", "line 2", "line 3", "line 4", "line 5"}, texts)
+
+	if assert.Len(t, searches, 3) {
+		pitIDs := []any{}
+		searchAfters := []any{}
+		for _, search := range searches {
+			pitIDs = append(pitIDs, search["pit"].(map[string]any)["id"])
+			searchAfters = append(searchAfters, search["search_after"])
+			assert.Equal(t, map[string]any{"bool": map[string]any{
+				"must": map[string]any{"query_string": map[string]any{"query": "kubernetes.pod_id:a"}},
+			}}, search["query"])
+		}
+		assert.Equal(t, []any{"pit-0", "pit-1", "pit-2"}, pitIDs)
+		assert.Equal(t, []any{nil, []any{float64(1)}, []any{float64(3)}}, searchAfters)
+	}
+	assert.Equal(t, []string{"pit-3"}, closed)
+}
+
+func TestElasticsearchBackendSinceTime(t *testing.T) {
+	since := time.Date(2025, 1, 1, 0, 0, 2, 0, time.UTC)
+	var searches []map[string]any
+	var closed []string
+	server := newElasticsearchServer(t, 1, since, false, &searches, &closed)
+	defer server.Close()
+
+	logURL, _ := url.Parse(server.URL + "/fluentd" + elasticsearchSearchPath + "?_source_includes=message")
+	backend := newLogBackend(server.Client(), nil, nil, logURL)
+	err := backend.Stream(context.Background(), logURL, nil, LogOptions{SinceTime: &since},
+		func(line logLine) (bool, error) {
+			return true, nil
+		})
+
+	assert.NoError(t, err)
+	if assert.Len(t, searches, 1) {
+		assert.Equal(t, map[string]any{"bool": map[string]any{
+			"filter": map[string]any{"range": map[string]any{
+				elasticsearchTimestampField: map[string]any{"gte": "2025-01-01T00:00:02Z"},
+			}},
+		}}, searches[0]["query"])
+	}
+}
+
+func TestNewElasticsearchSearch(t *testing.T) {
+	logURL, _ := url.Parse("https://es:9200/logs/fluentd" + elasticsearchSearchPath + "?_source_includes=message")
+	search, err := newElasticsearchSearch(logURL, LogOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "fluentd", search.index)
+	assert.Equal(t, "https://es:9200/logs", search.baseURL.String())
+	assert.Equal(t, "message,"+elasticsearchTimestampField, search.params.Get("_source_includes"))
+	assert.Nil(t, search.query)
+
+	logURL, _ = url.Parse("https://es:9200" + elasticsearchSearchPath)
+	_, err = newElasticsearchSearch(logURL, LogOptions{})
+	assert.ErrorContains(t, err, "must search an index")
+}
+
+func TestElasticsearchBackendTimedOut(t *testing.T) {
+	var searches []map[string]any
+	var closed []string
+	server := newElasticsearchServer(t, 1, time.Now(), true, &searches, &closed)
+	defer server.Close()
+
+	logURL, _ := url.Parse(server.URL + "/fluentd" + elasticsearchSearchPath)
+	backend := newLogBackend(server.Client(), nil, nil, logURL)
+	err := backend.Stream(context.Background(), logURL, nil, LogOptions{}, func(line logLine) (bool, error) {
+		return true, nil
+	})
+
+	assert.ErrorContains(t, err, "timed out")
+	assert.Equal(t, []string{"pit-0"}, closed)
+}
+
+func TestElasticsearchBackendTailLines(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var searches []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		searches = append(searches, r)
+		body := map[string]any{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Nil(t, body["pit"])
+
+		// The newest hits are returned first
+		hits := []map[string]any{}
+		for i := 4; i > 4-2; i-- {
+			hits = append(hits, map[string]any{"_source": map[string]any{
+				"message":                   fmt.Sprintf("line %d", i+1),
+				elasticsearchTimestampField: start.Add(time.Duration(i) * time.Second).Format(time.RFC3339),
+			}})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"hits": map[string]any{"hits": hits}})
+	}))
+	defer server.Close()
+
+	logURL, _ := url.Parse(server.URL + "/fluentd" + elasticsearchSearchPath + "?q=kubernetes.pod_id:a")
+	var lines []string
+	backend := newLogBackend(server.Client(), nil, nil, logURL)
+	err := backend.Stream(context.Background(), logURL, nil, LogOptions{TailLines: ptr(int64(2))},
+		func(line logLine) (bool, error) {
+			lines = append(lines, line.text)
+			return true, nil
+		})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line 4", "line 5"}, lines)
+	// No point in time is opened for a single search
+	if assert.Len(t, searches, 1) {
+		assert.Equal(t, "/fluentd"+elasticsearchSearchPath, searches[0].URL.Path)
+		assert.Equal(t, elasticsearchTimestampField+":desc", searches[0].URL.Query().Get("sort"))
+		assert.Equal(t, "2", searches[0].URL.Query().Get("size"))
+	}
+}
@@ -4,189 +4,222 @@
 package logging
 
 import (
-	"bufio"
-	"crypto/tls"
+	"bytes"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
-	"os"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kubearchive/kubearchive/pkg/abort"
-	"github.com/kubearchive/kubearchive/pkg/files"
+	"github.com/kubearchive/kubearchive/pkg/blobstore"
+	"github.com/kubearchive/kubearchive/pkg/models"
 	"github.com/ohler55/ojg/jp"
 	"github.com/ohler55/ojg/oj"
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 const (
-	loggingKey string = "headers"
+	loggingKey       string = "headers"
+	loggingClientKey string = "loggingClient"
 )
 
-// getKubeArchiveLoggingHeaders retrieves the kubearchive-logging secret data if it's available
-func getKubeArchiveLoggingHeaders() map[string]string {
-	loggingDir, exists := os.LookupEnv(files.LoggingDirEnvVar)
-	if !exists {
-		errMsg := fmt.Sprintf("environment variable not set: %s", files.LoggingDirEnvVar)
-		slog.Warn(errMsg)
-		return nil
-	}
-	configFiles, err := files.FilesInDir(loggingDir)
-	if err != nil {
-		errMsg := fmt.Sprintf("could not read kubearchive-logging secret files: %s", err.Error())
-		slog.Warn(errMsg)
-		return nil
-	}
-	if len(configFiles) == 0 {
-		errMsg := "logging configuration not specified"
-		slog.Warn(errMsg)
-		return nil
+// SetLoggingHeaders sets the headers to be sent to the logging backend, and the client to send them with,
+// from the current configuration of the kubearchive-logging secret
+func SetLoggingHeaders(secret *LoggingSecret) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config := secret.config.Load()
+		c.Set(loggingKey, config.headers)
+		c.Set(loggingClientKey, config.client)
 	}
+}
 
-	loggingConf, err := files.LoggingConfigFromFiles(configFiles)
-	if err != nil {
-		errMsg := fmt.Sprintf("could not read kubearchive-logging secret files: %s", err.Error())
-		slog.Warn(errMsg)
-		return nil
-	}
-	return loggingConf
+// logTarget is a log URL to retrieve logs from and the prefix to add to each of its lines
+type logTarget struct {
+	url      string
+	jsonPath string
+	prefix   string
 }
 
-// SetLoggingHeaders sets the headers to be sent to the logging backend
-func SetLoggingHeaders() gin.HandlerFunc {
-	loggingHeaders := getKubeArchiveLoggingHeaders()
-	return func(c *gin.Context) {
-		c.Set(loggingKey, loggingHeaders)
+// getLogTargets returns the log URLs set in the context, either the logURL of a single container or the
+// logURLs of all the containers of the Pod when allContainers is set. The lines of the latter are prefixed
+// with [pod/<pod>/<container>] like kubectl logs --prefix does.
+func getLogTargets(c *gin.Context) []logTarget {
+	if value, exists := c.Get("logURLs"); exists {
+		logURLs, _ := value.([]models.ContainerLogURL)
+		targets := make([]logTarget, 0, len(logURLs))
+		for _, logURL := range logURLs {
+			if logURL.Url == "" {
+				continue
+			}
+			targets = append(targets, logTarget{
+				url:      logURL.Url,
+				jsonPath: logURL.JsonPath,
+				prefix:   fmt.Sprintf("[pod/%s/%s] ", logURL.PodName, logURL.ContainerName),
+			})
+		}
+		return targets
 	}
+
+	logUrl := c.GetString("logURL")
+	if logUrl == "" {
+		return nil
+	}
+	return []logTarget{{url: logUrl, jsonPath: c.GetString("jsonPath")}}
 }
 
 // LogRetrieval retrieves a middleware function that checks the logging config and when set
 // expects to find a log url in the context from where retrieve and parse logs
-// It should be called when user, password and logURL are set in the context.
-func LogRetrieval() gin.HandlerFunc {
-	// FIXME For now the queries to the logging backend server are done insecurely. Needed for the current test env.
-	client := http.Client{
-		Transport: otelhttp.NewTransport(&http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}), // #nosec G402
-		Timeout: 60 * time.Second,
-	}
+// It should be called when user, password and logURL are set in the context. The logs the sink stored
+// are read from store, which is nil when no log store is configured.
+func LogRetrieval(store blobstore.Store) gin.HandlerFunc {
+	// defaultConfig is used when SetLoggingHeaders did not set a client
+	defaultConfig, _ := newLoggingConfig(nil)
 	return func(c *gin.Context) {
 
+		client := defaultConfig.client
+		if value, ok := c.Get(loggingClientKey); ok {
+			client = value.(*http.Client)
+		}
 		headers := c.GetStringMapString(loggingKey)
-		logUrl := c.GetString("logURL")
-		jsonPath := c.GetString("jsonPath")
+		targets := getLogTargets(c)
 
-		if logUrl == "" {
+		if len(targets) == 0 {
 			abort.Abort(c, fmt.Errorf("no log URL found"), http.StatusNotFound)
 			return
 		}
-		slog.InfoContext(c.Request.Context(), "Retrieving logs", "logURL", logUrl)
 
-		var jsonPathParser jp.Expr
-		var errJsonPath error
+		options, errOptions := parseLogOptions(c)
+		if errOptions != nil {
+			abort.Abort(c, errOptions, http.StatusBadRequest)
+			return
+		}
 
-		if jsonPath != "" {
-			jsonPathParser, errJsonPath = jp.ParseString(jsonPath)
-			if errJsonPath != nil {
-				abort.Abort(c, fmt.Errorf("invalid jsonPath %s for url %s", jsonPath, logUrl),
-					http.StatusInternalServerError)
+		writer := newLogWriter(c, options)
+		for _, target := range targets {
+			writer.reset(target.prefix)
+			if !retrieveLogs(c, client, headers, store, target, writer) {
+				return
+			}
+			if errFlush := writer.flush(); errFlush != nil {
+				slog.ErrorContext(c.Request.Context(), "error writing log line", "error", errFlush)
 				return
 			}
 		}
 
-		request, errReq := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, logUrl, nil)
-		if errReq != nil {
-			abort.Abort(c, errReq, http.StatusInternalServerError)
+		if !writer.found {
+			abort.Abort(c, errors.New("no logs found for the requested resource"), http.StatusNotFound)
 			return
 		}
+		writer.start()
+	}
+}
 
-		for key, value := range headers {
-			request.Header.Set(key, value)
+// retrieveLogs streams the logs of target into writer using the LogBackend of its URL. It returns false
+// when the request was aborted or the logs could not be streamed, so no more targets should be retrieved.
+func retrieveLogs(c *gin.Context, client *http.Client, headers map[string]string, store blobstore.Store,
+	target logTarget, writer *logWriter) bool {
+	// abortOrLog aborts the request if the response did not start, once started the error can only be logged.
+	// Searches across Pods log the error and continue with the next target.
+	abortOrLog := func(msg string, err error, code int) bool {
+		if c.GetBool("logSearch") {
+			slog.WarnContext(c.Request.Context(), msg+", skipping it in the log search", "url", target.url,
+				"error", err)
+			return true
 		}
-
-		response, errReq := client.Do(request)
-		if errReq != nil {
-			abort.Abort(c, errReq, http.StatusInternalServerError)
-			return
+		if writer.started {
+			slog.ErrorContext(c.Request.Context(), msg+" after streaming started", "error", err)
+		} else {
+			abort.Abort(c, err, code)
 		}
+		return false
+	}
 
-		defer response.Body.Close()
+	logUrl, errURL := url.Parse(target.url)
+	if errURL != nil {
+		return abortOrLog("error parsing log URL", fmt.Errorf("invalid log URL: %w", errURL),
+			http.StatusInternalServerError)
+	}
 
-		if response.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
-			abort.Abort(c, fmt.Errorf("error response: %d - %s", response.StatusCode, body), response.StatusCode)
-			return
+	var jsonPathParser jp.Expr
+	if target.jsonPath != "" {
+		var errJsonPath error
+		jsonPathParser, errJsonPath = jp.ParseString(target.jsonPath)
+		if errJsonPath != nil {
+			return abortOrLog("error parsing jsonPath",
+				fmt.Errorf("invalid jsonPath %s for url %s", target.jsonPath, target.url), http.StatusInternalServerError)
 		}
+	}
 
-		reader := bufio.NewReader(response.Body)
-		var logsReturned bool
-
-		for {
-			line, readErr := reader.ReadBytes('\n')
-			if readErr != nil && readErr != io.EOF {
-				if logsReturned {
-					slog.ErrorContext(c.Request.Context(), "error reading log line after streaming started", "error", readErr)
-				} else {
-					abort.Abort(c, readErr, http.StatusInternalServerError)
-				}
-				return
-			}
-			parsedLine, errParseLine := parseLine(line, jsonPathParser)
-			if errParseLine != nil {
-				if logsReturned {
-					slog.ErrorContext(c.Request.Context(), "error parsing log line after streaming started", "error", errParseLine)
-				} else {
-					abort.Abort(c, errParseLine, http.StatusInternalServerError)
-				}
-				return
-			}
-			if parsedLine != "" {
-				if !logsReturned {
-					c.Header("Content-Type", "text/plain; charset=utf-8")
-					c.Status(http.StatusOK)
-					logsReturned = true
-				}
-				if _, err := fmt.Fprintln(c.Writer, parsedLine); err != nil { // #nosec G705 -- Content-Type is text/plain
-					slog.ErrorContext(c.Request.Context(), "error writing log line", "error", err)
-					return
-				}
-				c.Writer.Flush()
-			}
-
-			if readErr == io.EOF {
-				break
-			}
+	slog.InfoContext(c.Request.Context(), "Retrieving logs", "logURL", target.url)
+	found := false
+	backend := newLogBackend(client, headers, store, logUrl)
+	errStream := backend.Stream(c.Request.Context(), logUrl, jsonPathParser, writer.options, func(line logLine) (bool, error) {
+		found = true
+		return writer.add(line)
+	})
+	if errStream != nil {
+		var errStatus *statusError
+		if errors.As(errStream, &errStatus) {
+			return abortOrLog("error response from the logging backend", errStream, errStatus.code)
 		}
+		return abortOrLog("error retrieving logs", errStream, http.StatusInternalServerError)
+	}
 
-		if !logsReturned {
-			slog.Error("no logs on loki", "jsonPath", jsonPathParser.String(), "url", logUrl)
-			abort.Abort(c, errors.New("no logs found for the requested resource"), http.StatusNotFound)
-		}
+	if !found {
+		slog.Error("no logs on the logging backend", "jsonPath", target.jsonPath, "url", target.url)
 	}
+	return true
 }
 
-func parseLine(line []byte, jsonPathParser jp.Expr) (string, error) {
+// parseLine returns the log lines in the line retrieved from the logging backend. When the jsonPath
+// returns [timestamp, line] pairs, as Loki does, the timestamp in nanoseconds is kept with the line
+func parseLine(line []byte, jsonPathParser jp.Expr) ([]logLine, error) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil, nil
+	}
 	if jsonPathParser == nil {
-		return string(line), nil
+		return []logLine{{text: strings.TrimSuffix(string(line), "\n")}}, nil
 	}
 
-	var jsonLine any
-	var errJson error
-	var result string
-
-	jsonLine, errJson = oj.Parse(line)
+	jsonLine, errJson := oj.Parse(line)
 	if errJson != nil {
-		return "", errJson
+		return nil, errJson
 	}
+
+	var result []logLine
 	for _, res := range jsonPathParser.Get(jsonLine) {
-		if result == "" {
-			result = res.(string)
-		} else {
-			result = fmt.Sprintf("%s\n%s", result, res.(string))
+		switch value := res.(type) {
+		case string:
+			result = append(result, newLogLine("", value))
+		case []any:
+			if len(value) != 2 {
+				return nil, fmt.Errorf("unexpected log entry with %d values", len(value))
+			}
+			timestamp, _ := value[0].(string)
+			text, _ := value[1].(string)
+			result = append(result, newLogLine(timestamp, text))
+		default:
+			return nil, fmt.Errorf("unexpected log entry of type %T", res)
+		}
+	}
+	// Entries without any text, like {"message":""}, do not count as logs
+	for _, parsedLine := range result {
+		if parsedLine.text != "" {
+			return result, nil
 		}
 	}
-	return result, nil
+	return nil, nil
+}
+
+// newLogLine returns the logLine of text, with the timestamp in nanoseconds when it is valid
+func newLogLine(timestamp, text string) logLine {
+	line := logLine{text: text}
+	if nanoseconds, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+		line.timestamp = time.Unix(0, nanoseconds)
+	}
+	return line
 }
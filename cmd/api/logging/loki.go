@@ -0,0 +1,216 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ohler55/ojg/jp"
+)
+
+const (
+	lokiQueryRangePath = "/loki/api/v1/query_range"
+	// lokiDefaultLimit is the number of entries Loki returns when the query does not set a limit
+	lokiDefaultLimit = 100
+	// lokiMaxLimit is the default max_entries_limit_per_query of Loki, the largest limit requested when
+	// a page has only entries logged at the same time
+	lokiMaxLimit = 5000
+)
+
+// lokiBackend retrieves the logs with the Loki query_range API. Loki returns at most limit entries
+// per query, so the query is repeated from the timestamp of the last entry until a page has less
+// entries than the limit. When only the last lines are needed, they are requested with a single
+// backward query. The jsonPath is applied to every entry wrapped as a query_range response,
+// {"data": {"result": [{"stream": {...}, "values": [[timestamp, line]]}]}}.
+type lokiBackend struct {
+	backendClient
+}
+
+type lokiResponse struct {
+	Data struct {
+		Result []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][]any           `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+type lokiEntry struct {
+	timestamp int64
+	// key identifies the entry to skip it when it is returned again in the next page
+	key    string
+	stream map[string]string
+	text   string
+}
+
+// lokiQuery returns the query of logURL with the sinceTime of the options as start of the range and the
+// entries sorted from the oldest to the newest, so the pages can be retrieved by moving the start
+func lokiQuery(logURL *url.URL, options LogOptions) (url.Values, int, error) {
+	query := logURL.Query()
+	query.Set("direction", "forward")
+
+	if options.SinceTime != nil {
+		since := options.SinceTime.UnixNano()
+		start, err := strconv.ParseInt(query.Get("start"), 10, 64)
+		if err != nil || start < since {
+			query.Set("start", strconv.FormatInt(since, 10))
+		}
+	}
+
+	limit := lokiDefaultLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return nil, 0, fmt.Errorf("invalid Loki limit '%s'", value)
+		}
+	}
+	return query, limit, nil
+}
+
+func (b *lokiBackend) Stream(ctx context.Context, logURL *url.URL, jsonPath jp.Expr, options LogOptions,
+	add addLineFunc) error {
+	query, limit, err := lokiQuery(logURL, options)
+	if err != nil {
+		return err
+	}
+	if tailLines, ok := options.tailLimit(); ok && tailLines <= lokiMaxLimit {
+		return b.tail(ctx, logURL, query, tailLines, jsonPath, add)
+	}
+
+	pageURL := *logURL
+	pageLimit := limit
+	// seen has the entries already returned that were logged at the start of the next page
+	seen := map[string]bool{}
+	for {
+		query.Set("limit", strconv.Itoa(pageLimit))
+		pageURL.RawQuery = query.Encode()
+		entries, errPage := b.page(ctx, pageURL.String(), false)
+		if errPage != nil {
+			return errPage
+		}
+
+		for _, entry := range entries {
+			if seen[entry.key] {
+				continue
+			}
+			next, errAdd := b.add(entry, jsonPath, add)
+			if errAdd != nil || !next {
+				return errAdd
+			}
+		}
+
+		if len(entries) < pageLimit {
+			return nil
+		}
+
+		// The start of the range is inclusive, so the next page starts with the entries logged at the
+		// same time as the last one. If the whole page was logged at the same time, moving the start would
+		// skip the entries of that time not returned yet, so the page is requested again with a larger limit.
+		last := entries[len(entries)-1].timestamp
+		pageLimit = limit
+		if entries[0].timestamp == last {
+			if len(entries) >= lokiMaxLimit {
+				return fmt.Errorf("more than %d Loki entries logged at %d, the log is incomplete", lokiMaxLimit, last)
+			}
+			pageLimit = min(len(entries)*2, lokiMaxLimit)
+		} else {
+			seen = map[string]bool{}
+		}
+		for _, entry := range entries {
+			if entry.timestamp == last {
+				seen[entry.key] = true
+			}
+		}
+		query.Set("start", strconv.FormatInt(last, 10))
+	}
+}
+
+// tail calls add with the last tailLines entries of the query. Loki returns the newest entries first with the
+// backward direction, so they are sorted again from the oldest to the newest. One entry is requested at least,
+// the writer drops it when no lines are needed, so a missing log is still reported.
+func (b *lokiBackend) tail(ctx context.Context, logURL *url.URL, query url.Values, tailLines int, jsonPath jp.Expr,
+	add addLineFunc) error {
+	query.Set("direction", "backward")
+	query.Set("limit", strconv.Itoa(max(tailLines, 1)))
+	tailURL := *logURL
+	tailURL.RawQuery = query.Encode()
+	entries, err := b.page(ctx, tailURL.String(), true)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		next, errAdd := b.add(entry, jsonPath, add)
+		if errAdd != nil || !next {
+			return errAdd
+		}
+	}
+	return nil
+}
+
+// add calls add with the entry, applying jsonPath to it wrapped as a query_range response when it is set
+func (b *lokiBackend) add(entry lokiEntry, jsonPath jp.Expr, add addLineFunc) (bool, error) {
+	timestamp := time.Unix(0, entry.timestamp)
+	if jsonPath == nil {
+		return add(logLine{timestamp: timestamp, text: entry.text})
+	}
+	wrapped := map[string]any{"data": map[string]any{"result": []any{map[string]any{
+		"stream": entry.stream,
+		"values": []any{[]any{strconv.FormatInt(entry.timestamp, 10), entry.text}},
+	}}}}
+	return addLines(wrapped, jsonPath, timestamp, add)
+}
+
+// page returns the entries of all the streams in the response of pageURL sorted by timestamp. The values of
+// each stream are reversed when they were requested backward, so the entries logged at the same time keep
+// their order.
+func (b *lokiBackend) page(ctx context.Context, pageURL string, backward bool) ([]lokiEntry, error) {
+	response, err := b.do(ctx, http.MethodGet, pageURL, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	lokiResp := lokiResponse{}
+	if err = json.NewDecoder(response.Body).Decode(&lokiResp); err != nil {
+		return nil, fmt.Errorf("invalid Loki response: %w", err)
+	}
+
+	var entries []lokiEntry
+	for _, result := range lokiResp.Data.Result {
+		stream, _ := json.Marshal(result.Stream)
+		if backward {
+			slices.Reverse(result.Values)
+		}
+		for _, value := range result.Values {
+			// Entries are [timestamp, line] pairs, followed by the structured metadata on recent versions
+			if len(value) < 2 {
+				return nil, fmt.Errorf("unexpected Loki entry with %d values", len(value))
+			}
+			timestamp, _ := value[0].(string)
+			text, _ := value[1].(string)
+			nanoseconds, errTimestamp := strconv.ParseInt(timestamp, 10, 64)
+			if errTimestamp != nil {
+				return nil, fmt.Errorf("invalid Loki timestamp '%s'", timestamp)
+			}
+			entries = append(entries, lokiEntry{
+				timestamp: nanoseconds,
+				key:       fmt.Sprintf("%s/%d/%s", stream, nanoseconds, text),
+				stream:    result.Stream,
+				text:      text,
+			})
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b lokiEntry) int { return cmp.Compare(a.timestamp, b.timestamp) })
+	return entries, nil
+}
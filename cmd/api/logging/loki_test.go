@@ -0,0 +1,245 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ohler55/ojg/jp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLokiQuery(t *testing.T) {
+	logURL, _ := url.Parse("http://loki" + lokiQueryRangePath + "?query=%7Bpod%3D%22a%22%7D&start=1000&direction=backward")
+
+	tests := []struct {
+		name          string
+		url           string
+		options       LogOptions
+		expectedStart string
+		expectedLimit int
+		expectedError bool
+	}{
+		{
+			name:          "no options",
+			url:           logURL.String(),
+			expectedStart: "1000",
+			expectedLimit: lokiDefaultLimit,
+		},
+		{
+			name:          "since after start",
+			url:           logURL.String() + "&limit=5000",
+			options:       LogOptions{SinceTime: ptr(time.Unix(0, 2000))},
+			expectedStart: "2000",
+			expectedLimit: 5000,
+		},
+		{
+			name:          "since before start",
+			url:           logURL.String(),
+			options:       LogOptions{SinceTime: ptr(time.Unix(0, 500))},
+			expectedStart: "1000",
+			expectedLimit: lokiDefaultLimit,
+		},
+		{
+			name:          "invalid limit",
+			url:           logURL.String() + "&limit=all",
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsedURL, err := url.Parse(tt.url)
+			assert.NoError(t, err)
+			query, limit, err := lokiQuery(parsedURL, tt.options)
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStart, query.Get("start"))
+			assert.Equal(t, "forward", query.Get("direction"))
+			assert.Equal(t, tt.expectedLimit, limit)
+		})
+	}
+}
+
+func TestLokiBackendPages(t *testing.T) {
+	// Two streams with five entries in total, two of them logged at the same time at the end of a page
+	entries := []struct {
+		stream    string
+		timestamp int64
+		line      string
+	}{
+		{"stdout", 1, "line 1"}, {"stderr", 2, "line 2"}, {"stdout", 3, "line 3"},
+		{"stderr", 3, "line 4"}, {"stdout", 4, "line 5"},
+	}
+	var starts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		starts = append(starts, r.URL.Query().Get("start"))
+
+		streams := map[string][][]string{}
+		count := 0
+		for _, entry := range entries {
+			if entry.timestamp >= start && count < limit {
+				streams[entry.stream] = append(streams[entry.stream],
+					[]string{strconv.FormatInt(entry.timestamp, 10), entry.line})
+				count++
+			}
+		}
+		result := []map[string]any{}
+		for stream, values := range streams {
+			result = append(result, map[string]any{"stream": map[string]string{"stream": stream}, "values": values})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"result": result}})
+	}))
+	defer server.Close()
+
+	logURL, _ := url.Parse(server.URL + lokiQueryRangePath + "?query=%7Bpod%3D%22a%22%7D&start=0&limit=3")
+	var lines []string
+	backend := newLogBackend(server.Client(), nil, nil, logURL)
+	err := backend.Stream(context.Background(), logURL, nil, LogOptions{}, func(line logLine) (bool, error) {
+		lines = append(lines, line.text)
+		return true, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line 1", "line 2", "line 3", "line 4", "line 5"}, lines)
+	assert.Equal(t, []string{"0", "3", "4"}, starts)
+}
+
+func TestLokiBackendErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "max entries limit per query exceeded", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	logURL, _ := url.Parse(server.URL + lokiQueryRangePath + "?query=%7Bpod%3D%22a%22%7D")
+	backend := newLogBackend(server.Client(), nil, nil, logURL)
+	err := backend.Stream(context.Background(), logURL, nil, LogOptions{}, func(line logLine) (bool, error) {
+		return true, nil
+	})
+
+	var errStatus *statusError
+	if assert.ErrorAs(t, err, &errStatus) {
+		assert.Equal(t, http.StatusBadRequest, errStatus.code)
+	}
+}
+
+func TestLokiBackendPageAtTheSameTime(t *testing.T) {
+	// More entries logged at the same time than the limit
+	var limits []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		limits = append(limits, r.URL.Query().Get("limit"))
+
+		values := [][]string{}
+		for i := range 5 {
+			timestamp := int64(1)
+			if i == 4 {
+				timestamp = 2
+			}
+			if timestamp >= start && len(values) < limit {
+				values = append(values, []string{strconv.FormatInt(timestamp, 10), fmt.Sprintf("line %d", i+1)})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"result": []map[string]any{
+			{"stream": map[string]string{"stream": "stdout"}, "values": values},
+		}}})
+	}))
+	defer server.Close()
+
+	logURL, _ := url.Parse(server.URL + lokiQueryRangePath + "?query=%7Bpod%3D%22a%22%7D&start=0&limit=2")
+	var lines []string
+	backend := newLogBackend(server.Client(), nil, nil, logURL)
+	err := backend.Stream(context.Background(), logURL, nil, LogOptions{}, func(line logLine) (bool, error) {
+		lines = append(lines, line.text)
+		return true, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line 1", "line 2", "line 3", "line 4", "line 5"}, lines)
+	assert.Equal(t, []string{"2", "4", "8"}, limits)
+}
+
+func TestLokiBackendJsonPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"data":{"result":[{"stream":{"container":"step"},"values":[["1","line 1"]]}]}}`)
+	}))
+	defer server.Close()
+
+	logURL, _ := url.Parse(server.URL + lokiQueryRangePath + "?query=%7Bpod%3D%22a%22%7D")
+	var lines []string
+	backend := newLogBackend(server.Client(), nil, nil, logURL)
+	err := backend.Stream(context.Background(), logURL, jp.MustParseString("$.data.result[*].stream.container"),
+		LogOptions{}, func(line logLine) (bool, error) {
+			lines = append(lines, line.text)
+			return true, nil
+		})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"step"}, lines)
+}
+
+func TestLokiBackendTailLines(t *testing.T) {
+	// Loki returns the newest entries first with the backward direction
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"result": []map[string]any{
+			{"stream": map[string]string{"stream": "stdout"}, "values": [][]string{{"3", "line 4"}, {"3", "line 3"}}},
+			{"stream": map[string]string{"stream": "stderr"}, "values": [][]string{{"4", "line 5"}}},
+		}}})
+	}))
+	defer server.Close()
+
+	logURL, _ := url.Parse(server.URL + lokiQueryRangePath + "?query=%7Bpod%3D%22a%22%7D&start=0&limit=2")
+	var lines []string
+	backend := newLogBackend(server.Client(), nil, nil, logURL)
+	err := backend.Stream(context.Background(), logURL, nil, LogOptions{TailLines: ptr(int64(3))},
+		func(line logLine) (bool, error) {
+			lines = append(lines, line.text)
+			return true, nil
+		})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line 3", "line 4", "line 5"}, lines)
+	if assert.Len(t, queries, 1) {
+		assert.Equal(t, "backward", queries[0].Get("direction"))
+		assert.Equal(t, "3", queries[0].Get("limit"))
+		assert.Equal(t, "0", queries[0].Get("start"))
+	}
+}
+
+func TestLokiBackendTailLinesWithGrep(t *testing.T) {
+	// The lines that match may be before the last ones, so all the lines are retrieved
+	var directions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		directions = append(directions, r.URL.Query().Get("direction"))
+		_, _ = fmt.Fprintln(w, `{"data":{"result":[{"stream":{},"values":[["1","line 1"]]}]}}`)
+	}))
+	defer server.Close()
+
+	logURL, _ := url.Parse(server.URL + lokiQueryRangePath + "?query=%7Bpod%3D%22a%22%7D")
+	backend := newLogBackend(server.Client(), nil, nil, logURL)
+	err := backend.Stream(context.Background(), logURL, nil,
+		LogOptions{TailLines: ptr(int64(1)), Grep: regexp.MustCompile("line")}, func(line logLine) (bool, error) {
+			return true, nil
+		})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"forward"}, directions)
+}
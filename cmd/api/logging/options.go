@@ -0,0 +1,260 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LogOptions are the query parameters of the Kubernetes PodLogOptions supported by the log routes
+type LogOptions struct {
+	// TailLines is the number of lines from the end of the logs to return, all of them when nil
+	TailLines *int64
+	// SinceTime is the time from which to return the logs, set from sinceTime or sinceSeconds
+	SinceTime *time.Time
+	// Timestamps prefixes every line with its RFC3339 timestamp when the logging backend provides it
+	Timestamps bool
+	// LimitBytes is the maximum number of bytes to return, all of them when nil
+	LimitBytes *int64
+	// Grep selects the lines that match it and their context lines, all the lines when nil
+	Grep *regexp.Regexp
+	// BeforeContext and AfterContext are the number of lines returned before and after each line
+	// that matches Grep
+	BeforeContext int
+	AfterContext  int
+}
+
+// parseLogOptions returns the LogOptions in the query parameters of the request
+func parseLogOptions(c *gin.Context) (LogOptions, error) {
+	options := LogOptions{}
+
+	if tailLines := c.Query("tailLines"); tailLines != "" {
+		lines, err := strconv.ParseInt(tailLines, 10, 64)
+		if err != nil || lines < 0 {
+			return options, fmt.Errorf("tailLines must be a non-negative integer, got '%s'", tailLines)
+		}
+		options.TailLines = &lines
+	}
+
+	sinceSeconds := c.Query("sinceSeconds")
+	sinceTime := c.Query("sinceTime")
+	if sinceSeconds != "" && sinceTime != "" {
+		return options, errors.New("at most one of sinceTime or sinceSeconds may be specified")
+	}
+	if sinceSeconds != "" {
+		seconds, err := strconv.ParseInt(sinceSeconds, 10, 64)
+		if err != nil || seconds < 1 {
+			return options, fmt.Errorf("sinceSeconds must be a positive integer, got '%s'", sinceSeconds)
+		}
+		since := time.Now().Add(-time.Duration(seconds) * time.Second)
+		options.SinceTime = &since
+	}
+	if sinceTime != "" {
+		since, err := time.Parse(time.RFC3339, sinceTime)
+		if err != nil {
+			return options, fmt.Errorf("sinceTime must be a RFC3339 timestamp, got '%s'", sinceTime)
+		}
+		options.SinceTime = &since
+	}
+
+	if timestamps := c.Query("timestamps"); timestamps != "" {
+		enabled, err := strconv.ParseBool(timestamps)
+		if err != nil {
+			return options, fmt.Errorf("timestamps must be a boolean, got '%s'", timestamps)
+		}
+		options.Timestamps = enabled
+	}
+
+	if limitBytes := c.Query("limitBytes"); limitBytes != "" {
+		limit, err := strconv.ParseInt(limitBytes, 10, 64)
+		if err != nil || limit < 1 {
+			return options, fmt.Errorf("limitBytes must be a positive integer, got '%s'", limitBytes)
+		}
+		options.LimitBytes = &limit
+	}
+
+	if grep := c.Query("grep"); grep != "" {
+		pattern, err := regexp.Compile(grep)
+		if err != nil {
+			return options, fmt.Errorf("grep must be a regular expression: %w", err)
+		}
+		options.Grep = pattern
+	}
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"beforeContext", &options.BeforeContext}, {"afterContext", &options.AfterContext}} {
+		if lines := c.Query(param.name); lines != "" {
+			if options.Grep == nil {
+				return options, fmt.Errorf("%s can only be used with grep", param.name)
+			}
+			count, err := strconv.Atoi(lines)
+			if err != nil || count < 0 {
+				return options, fmt.Errorf("%s must be a non-negative integer, got '%s'", param.name, lines)
+			}
+			*param.value = count
+		}
+	}
+
+	return options, nil
+}
+
+// tailLimit returns the number of lines a logging backend can request from the end of the logs instead of
+// retrieving all of them. It returns false when tailLines is not set, or when grep is set because the lines
+// that match may be before the last ones.
+func (o LogOptions) tailLimit() (int, bool) {
+	if o.TailLines == nil || o.Grep != nil {
+		return 0, false
+	}
+	return int(*o.TailLines), true
+}
+
+type logLine struct {
+	// timestamp is the zero time when the logging backend does not provide it
+	timestamp time.Time
+	text      string
+}
+
+// logWriter writes the log lines to the response applying the LogOptions the logging backend did not.
+// When the logs of several containers are written, the options apply to each of them.
+type logWriter struct {
+	c       *gin.Context
+	options LogOptions
+	// prefix is added to every line, like [pod/<pod>/<container>] when all the containers are written
+	prefix string
+	// tail keeps the last TailLines lines until all the lines are read
+	tail []logLine
+	// before keeps the last BeforeContext lines that did not match Grep, after is the number of lines
+	// still to write after the last line that matched
+	before  []logLine
+	after   int
+	written int64
+	// found is true when the logging backend returned any line, started when the response was started
+	found   bool
+	started bool
+}
+
+func newLogWriter(c *gin.Context, options LogOptions) *logWriter {
+	return &logWriter{c: c, options: options}
+}
+
+// reset prepares the writer for the lines of the next container, which are prefixed with prefix
+func (w *logWriter) reset(prefix string) {
+	w.prefix = prefix
+	w.tail = nil
+	w.before = nil
+	w.after = 0
+	w.written = 0
+}
+
+// add writes the line, or keeps it when tailLines is set. It returns false when no more lines are
+// needed because limitBytes was reached
+func (w *logWriter) add(line logLine) (bool, error) {
+	w.found = true
+	if w.options.SinceTime != nil && !line.timestamp.IsZero() && line.timestamp.Before(*w.options.SinceTime) {
+		return true, nil
+	}
+	if w.options.Grep == nil {
+		return w.keep(line)
+	}
+
+	for _, selected := range w.grep(line) {
+		next, err := w.keep(selected)
+		if err != nil || !next {
+			return next, err
+		}
+	}
+	return true, nil
+}
+
+// grep returns the lines to write for line: the line with the lines kept before it when it matches,
+// the line when it is after a line that matched, or none
+func (w *logWriter) grep(line logLine) []logLine {
+	if w.options.Grep.MatchString(line.text) {
+		selected := append(w.before, line)
+		w.before = nil
+		w.after = w.options.AfterContext
+		return selected
+	}
+	if w.after > 0 {
+		w.after--
+		return []logLine{line}
+	}
+	if w.options.BeforeContext > 0 {
+		if len(w.before) == w.options.BeforeContext {
+			w.before = w.before[1:]
+		}
+		w.before = append(w.before, line)
+	}
+	return nil
+}
+
+// keep writes the line, or keeps it when tailLines is set
+func (w *logWriter) keep(line logLine) (bool, error) {
+	if w.options.TailLines == nil {
+		return w.write(line)
+	}
+	if *w.options.TailLines == 0 {
+		return true, nil
+	}
+	if int64(len(w.tail)) == *w.options.TailLines {
+		w.tail = w.tail[1:]
+	}
+	w.tail = append(w.tail, line)
+	return true, nil
+}
+
+// flush writes the lines kept for tailLines
+func (w *logWriter) flush() error {
+	for _, line := range w.tail {
+		next, err := w.write(line)
+		if err != nil {
+			return err
+		}
+		if !next {
+			break
+		}
+	}
+	w.tail = nil
+	return nil
+}
+
+func (w *logWriter) start() {
+	if w.started {
+		return
+	}
+	w.c.Header("Content-Type", "text/plain; charset=utf-8")
+	w.c.Status(http.StatusOK)
+	w.started = true
+}
+
+func (w *logWriter) write(line logLine) (bool, error) {
+	w.start()
+
+	text := line.text + "\n"
+	if w.options.Timestamps && !line.timestamp.IsZero() {
+		text = fmt.Sprintf("%s %s", line.timestamp.UTC().Format(time.RFC3339Nano), text)
+	}
+	text = w.prefix + text
+
+	next := true
+	if w.options.LimitBytes != nil && w.written+int64(len(text)) >= *w.options.LimitBytes {
+		text = text[:*w.options.LimitBytes-w.written]
+		next = false
+	}
+	n, err := w.c.Writer.WriteString(text) // #nosec G705 -- Content-Type is text/plain
+	w.written += int64(n)
+	if err != nil {
+		return false, err
+	}
+	w.c.Writer.Flush()
+	return next, nil
+}
@@ -0,0 +1,352 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedError bool
+		expected      LogOptions
+	}{
+		{
+			name:     "no options",
+			query:    "",
+			expected: LogOptions{},
+		},
+		{
+			name:  "all options",
+			query: "tailLines=10&sinceTime=2025-01-02T03:04:05Z&timestamps=true&limitBytes=100",
+			expected: LogOptions{
+				TailLines:  ptr(int64(10)),
+				SinceTime:  ptr(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)),
+				Timestamps: true,
+				LimitBytes: ptr(int64(100)),
+			},
+		},
+		{
+			name:          "negative tailLines",
+			query:         "tailLines=-1",
+			expectedError: true,
+		},
+		{
+			name:          "sinceTime and sinceSeconds",
+			query:         "sinceTime=2025-01-02T03:04:05Z&sinceSeconds=10",
+			expectedError: true,
+		},
+		{
+			name:          "zero sinceSeconds",
+			query:         "sinceSeconds=0",
+			expectedError: true,
+		},
+		{
+			name:          "invalid sinceTime",
+			query:         "sinceTime=yesterday",
+			expectedError: true,
+		},
+		{
+			name:          "invalid timestamps",
+			query:         "timestamps=maybe",
+			expectedError: true,
+		},
+		{
+			name:          "zero limitBytes",
+			query:         "limitBytes=0",
+			expectedError: true,
+		},
+		{
+			name:  "grep with context",
+			query: "grep=err(or)?&beforeContext=2&afterContext=1",
+			expected: LogOptions{
+				Grep:          regexp.MustCompile("err(or)?"),
+				BeforeContext: 2,
+				AfterContext:  1,
+			},
+		},
+		{
+			name:          "invalid grep",
+			query:         "grep=(",
+			expectedError: true,
+		},
+		{
+			name:          "context without grep",
+			query:         "afterContext=1",
+			expectedError: true,
+		},
+		{
+			name:          "negative beforeContext",
+			query:         "grep=error&beforeContext=-1",
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+
+			options, err := parseLogOptions(c)
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, options)
+		})
+	}
+}
+
+func TestParseLogOptionsSinceSeconds(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/?sinceSeconds=60", nil)
+
+	options, err := parseLogOptions(c)
+	assert.NoError(t, err)
+	if assert.NotNil(t, options.SinceTime) {
+		assert.WithinDuration(t, time.Now().Add(-time.Minute), *options.SinceTime, 5*time.Second)
+	}
+}
+
+func TestLogRetrievalOptions(t *testing.T) {
+	// Loki returns the entries as [timestamp in nanoseconds, line] pairs
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"data":{"result":[{"values":[["1000000000","line 1"],["2000000000","line 2"],["3000000000","line 3"]]}]}}`)
+	}))
+	defer server.Close()
+	lokiURL := server.URL + lokiQueryRangePath + "?query=%7Bpod%3D%22a%22%7D"
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "no options",
+			expected: "line 1\nline 2\nline 3\n",
+		},
+		{
+			name:     "tailLines",
+			query:    "tailLines=2",
+			expected: "line 2\nline 3\n",
+		},
+		{
+			name:     "no lines",
+			query:    "tailLines=0",
+			expected: "",
+		},
+		{
+			name:     "sinceTime",
+			query:    "sinceTime=1970-01-01T00:00:02Z",
+			expected: "line 2\nline 3\n",
+		},
+		{
+			name:     "timestamps",
+			query:    "timestamps=true&tailLines=1",
+			expected: "1970-01-01T00:00:03Z line 3\n",
+		},
+		{
+			name:     "limitBytes",
+			query:    "limitBytes=9",
+			expected: "line 1\nli",
+		},
+		{
+			name:     "tailLines and limitBytes",
+			query:    "tailLines=2&limitBytes=7",
+			expected: "line 2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(res)
+			c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+			c.Set("logURL", lokiURL)
+			c.Set("jsonPath", "$.data.result[*].values[*][1]")
+
+			LogRetrieval(nil)(c)
+
+			assert.Equal(t, http.StatusOK, res.Code)
+			assert.Equal(t, tt.expected, res.Body.String())
+		})
+	}
+}
+
+func TestLogRetrievalAllContainers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		container := r.URL.Query().Get("query")
+		if container == "empty" {
+			_, _ = fmt.Fprintln(w, `{"data":{"result":[]}}`)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"data":{"result":[{"values":[["1000000000","%s 1"],["2000000000","%s 2"]]}]}}`,
+			container, container)
+	}))
+	defer server.Close()
+	logURLs := []models.ContainerLogURL{
+		{PodName: "my-pod", ContainerName: "init", Url: server.URL + lokiQueryRangePath + "?query=init"},
+		{PodName: "my-pod", ContainerName: "empty", Url: server.URL + lokiQueryRangePath + "?query=empty"},
+		{PodName: "my-pod", ContainerName: "main", Url: server.URL + lokiQueryRangePath + "?query=main"},
+	}
+	for i := range logURLs {
+		logURLs[i].JsonPath = "$.data.result[*].values[*][1]"
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name: "no options",
+			expected: "[pod/my-pod/init] init 1\n[pod/my-pod/init] init 2\n" +
+				"[pod/my-pod/main] main 1\n[pod/my-pod/main] main 2\n",
+		},
+		{
+			name:     "tailLines per container",
+			query:    "tailLines=1&timestamps=true",
+			expected: "[pod/my-pod/init] 1970-01-01T00:00:02Z init 2\n[pod/my-pod/main] 1970-01-01T00:00:02Z main 2\n",
+		},
+		{
+			name:     "limitBytes per container",
+			query:    "limitBytes=20",
+			expected: "[pod/my-pod/init] in[pod/my-pod/main] ma",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(res)
+			c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+			c.Set("logURLs", logURLs)
+
+			LogRetrieval(nil)(c)
+
+			assert.Equal(t, http.StatusOK, res.Code)
+			assert.Equal(t, tt.expected, res.Body.String())
+		})
+	}
+}
+
+func TestLogRetrievalGrep(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, line := range []string{"start", "step 1", "step 2", "panic: boom", "goroutine 1", "step 3",
+			"step 4", "step 5", "panic: again", "exit"} {
+			_, _ = fmt.Fprintln(w, line)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "matching lines",
+			query:    "grep=^panic",
+			expected: "panic: boom\npanic: again\n",
+		},
+		{
+			name:     "context lines",
+			query:    "grep=^panic&beforeContext=1&afterContext=1",
+			expected: "step 2\npanic: boom\ngoroutine 1\nstep 5\npanic: again\nexit\n",
+		},
+		{
+			name:     "overlapping context lines",
+			query:    "grep=^panic&beforeContext=4",
+			expected: "start\nstep 1\nstep 2\npanic: boom\ngoroutine 1\nstep 3\nstep 4\nstep 5\npanic: again\n",
+		},
+		{
+			name:     "tailLines of the matching lines",
+			query:    "grep=step&tailLines=2",
+			expected: "step 4\nstep 5\n",
+		},
+		{
+			name:     "no matching lines",
+			query:    "grep=fatal",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(res)
+			c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+			c.Set("logURL", server.URL)
+
+			LogRetrieval(nil)(c)
+
+			assert.Equal(t, http.StatusOK, res.Code)
+			assert.Equal(t, tt.expected, res.Body.String())
+		})
+	}
+}
+
+func TestLogRetrievalSearchSkipsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/expired" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprintln(w, "panic: boom")
+	}))
+	defer server.Close()
+
+	res := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(res)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?grep=panic", nil)
+	c.Set("logURLs", []models.ContainerLogURL{
+		{PodName: "pod-1", ContainerName: "main", Url: server.URL + "/expired"},
+		{PodName: "pod-2", ContainerName: "main", Url: server.URL + "/logs"},
+	})
+	c.Set("logSearch", true)
+
+	LogRetrieval(nil)(c)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "[pod/pod-2/main] panic: boom\n", res.Body.String())
+}
+
+func TestLogRetrievalAllContainersWithoutURLs(t *testing.T) {
+	res := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(res)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Set("logURLs", []models.ContainerLogURL{{PodName: "my-pod", ContainerName: "main"}})
+
+	LogRetrieval(nil)(c)
+
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestLogRetrievalInvalidOptions(t *testing.T) {
+	res := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(res)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?tailLines=all", nil)
+	c.Set("logURL", "http://example.com")
+
+	LogRetrieval(nil)(c)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Contains(t, res.Body.String(), "tailLines")
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
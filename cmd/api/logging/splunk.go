@@ -0,0 +1,141 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohler55/ojg/jp"
+)
+
+const (
+	splunkJobsPath = "/services/search/jobs"
+	// splunkPageSize is the number of results requested per page, below the default maxresultrows of 50000
+	splunkPageSize = 10000
+	// splunkPollInterval is the time between the checks of the status of a search job
+	splunkPollInterval = 500 * time.Millisecond
+)
+
+var (
+	splunkSid           = jp.MustParseString("$.sid")
+	splunkJobContent    = jp.MustParseString("$.entry[0].content")
+	splunkResults       = jp.MustParseString("$.results")
+	splunkDefaultSource = jp.MustParseString("$.result._raw")
+)
+
+// splunkBackend retrieves the logs with a Splunk search job. The search of the log URL, usually meant for
+// the export endpoint, is run as a search job and its results are retrieved in pages of splunkPageSize
+// once the job is done, so they are not cut off by the limits of the export endpoint. The jsonPath is
+// applied to every result wrapped as the export endpoint does, {"result": {...}}.
+type splunkBackend struct {
+	backendClient
+	pollInterval time.Duration
+}
+
+// splunkJobsURL returns the URL of the search jobs endpoint of logURL and the parameters of the search job
+func splunkJobsURL(logURL *url.URL, options LogOptions) (string, url.Values) {
+	jobsURL := *logURL
+	jobsURL.Path = logURL.Path[:strings.Index(logURL.Path, splunkJobsPath)+len(splunkJobsPath)]
+	jobsURL.RawQuery = ""
+
+	params := logURL.Query()
+	params.Set("output_mode", "json")
+	params.Del("exec_mode")
+	if options.SinceTime != nil {
+		params.Set("earliest_time", strconv.FormatFloat(float64(options.SinceTime.UnixNano())/1e9, 'f', 6, 64))
+	}
+	return jobsURL.String(), params
+}
+
+func (b *splunkBackend) Stream(ctx context.Context, logURL *url.URL, jsonPath jp.Expr, options LogOptions,
+	add addLineFunc) error {
+	if jsonPath == nil {
+		jsonPath = splunkDefaultSource
+	}
+	jobsURL, params := splunkJobsURL(logURL, options)
+
+	job, err := b.doJSON(ctx, http.MethodPost, jobsURL, strings.NewReader(params.Encode()),
+		"application/x-www-form-urlencoded")
+	if err != nil {
+		return err
+	}
+	sid, _ := splunkSid.First(job).(string)
+	if sid == "" {
+		return errors.New("splunk did not return the id of the search job")
+	}
+	jobURL := fmt.Sprintf("%s/%s", jobsURL, url.PathEscape(sid))
+	defer b.cancel(ctx, jobURL)
+
+	resultCount, err := b.wait(ctx, jobURL)
+	if err != nil {
+		return err
+	}
+
+	for offset := 0; offset < resultCount; offset += splunkPageSize {
+		resultsURL := fmt.Sprintf("%s/results?output_mode=json&count=%d&offset=%d", jobURL, splunkPageSize, offset)
+		page, errPage := b.doJSON(ctx, http.MethodGet, resultsURL, nil, "")
+		if errPage != nil {
+			return errPage
+		}
+		results, _ := splunkResults.First(page).([]any)
+		if len(results) == 0 {
+			return nil
+		}
+		for _, result := range results {
+			var timestamp time.Time
+			if fields, ok := result.(map[string]any); ok {
+				if value, isString := fields["_time"].(string); isString {
+					timestamp, _ = time.Parse(time.RFC3339Nano, value)
+				}
+			}
+			next, errAdd := addLines(map[string]any{"result": result}, jsonPath, timestamp, add)
+			if errAdd != nil || !next {
+				return errAdd
+			}
+		}
+	}
+	return nil
+}
+
+// wait polls the search job until it is done and returns the number of results
+func (b *splunkBackend) wait(ctx context.Context, jobURL string) (int, error) {
+	for {
+		status, err := b.doJSON(ctx, http.MethodGet, jobURL+"?output_mode=json", nil, "")
+		if err != nil {
+			return 0, err
+		}
+		content, _ := splunkJobContent.First(status).(map[string]any)
+		switch state, _ := content["dispatchState"].(string); state {
+		case "DONE":
+			resultCount, _ := content["resultCount"].(int64)
+			return int(resultCount), nil
+		case "FAILED":
+			return 0, fmt.Errorf("splunk search job failed: %v", content["messages"])
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(b.pollInterval):
+		}
+	}
+}
+
+// cancel deletes the search job, so Splunk does not keep its results until they expire
+func (b *splunkBackend) cancel(ctx context.Context, jobURL string) {
+	response, err := b.do(context.WithoutCancel(ctx), http.MethodDelete, jobURL, nil, "")
+	if err != nil {
+		slog.WarnContext(ctx, "could not delete the Splunk search job", "job", jobURL, "error", err)
+		return
+	}
+	response.Body.Close()
+}
@@ -0,0 +1,95 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ohler55/ojg/jp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplunkBackend(t *testing.T) {
+	const total = splunkPageSize + 2
+	polls, deleted := 0, false
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+splunkJobsPath, func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "search * | table message", r.PostForm.Get("search"))
+		assert.Equal(t, "json", r.PostForm.Get("output_mode"))
+		assert.Equal(t, "2.000000", r.PostForm.Get("earliest_time"))
+		_, _ = fmt.Fprintln(w, `{"sid":"1234.5"}`)
+	})
+	mux.HandleFunc("GET "+splunkJobsPath+"/1234.5", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		state := "RUNNING"
+		if polls > 1 {
+			state = "DONE"
+		}
+		_, _ = fmt.Fprintf(w, `{"entry":[{"content":{"dispatchState":"%s","resultCount":%d}}]}`, state, total)
+	})
+	mux.HandleFunc("GET "+splunkJobsPath+"/1234.5/results", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+		results := []map[string]string{}
+		for i := offset; i < total && len(results) < count; i++ {
+			results = append(results, map[string]string{
+				"_time": "2025-01-02T03:04:05.000+00:00", "message": fmt.Sprintf("line %d", i+1),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"results": results})
+	})
+	mux.HandleFunc("DELETE "+splunkJobsPath+"/1234.5", func(w http.ResponseWriter, r *http.Request) {
+		deleted = true
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	logURL, _ := url.Parse(server.URL + splunkJobsPath + "/export?search=search%20%2A%20%7C%20table%20message&output_mode=json")
+	backend := &splunkBackend{backendClient: backendClient{client: server.Client()}, pollInterval: time.Millisecond}
+	var lines []logLine
+	err := backend.Stream(context.Background(), logURL, jp.MustParseString("$.result.message"),
+		LogOptions{SinceTime: ptr(time.Unix(2, 0))}, func(line logLine) (bool, error) {
+			lines = append(lines, line)
+			return true, nil
+		})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, polls)
+	assert.True(t, deleted)
+	if assert.Len(t, lines, total) {
+		assert.Equal(t, "line 1", lines[0].text)
+		assert.Equal(t, fmt.Sprintf("line %d", total), lines[total-1].text)
+		assert.Equal(t, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), lines[0].timestamp.UTC())
+	}
+}
+
+func TestSplunkBackendJobFailed(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+splunkJobsPath, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"sid":"1234.5"}`)
+	})
+	mux.HandleFunc("GET "+splunkJobsPath+"/1234.5", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"entry":[{"content":{"dispatchState":"FAILED","messages":[{"text":"bad search"}]}}]}`)
+	})
+	mux.HandleFunc("DELETE "+splunkJobsPath+"/1234.5", func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	logURL, _ := url.Parse(server.URL + splunkJobsPath + "/export?search=search%20%2A")
+	backend := newLogBackend(server.Client(), nil, nil, logURL)
+	err := backend.Stream(context.Background(), logURL, nil, LogOptions{}, func(line logLine) (bool, error) {
+		return true, nil
+	})
+
+	assert.ErrorContains(t, err, "bad search")
+}
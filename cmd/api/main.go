@@ -21,6 +21,7 @@ import (
 	"github.com/kubearchive/kubearchive/cmd/api/logging"
 	"github.com/kubearchive/kubearchive/cmd/api/pagination"
 	"github.com/kubearchive/kubearchive/cmd/api/routers"
+	"github.com/kubearchive/kubearchive/pkg/blobstore"
 	"github.com/kubearchive/kubearchive/pkg/cache"
 	"github.com/kubearchive/kubearchive/pkg/database"
 	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
@@ -48,8 +49,12 @@ type Server struct {
 	router    *gin.Engine
 }
 
+// NewServer creates the server, requestHeaderConfig enables the authentication of the requests proxied
+// by the Kubernetes API server when KubeArchive runs as an aggregated API, nil disables it. logStore holds
+// the logs archived by the sink, nil when no log store is configured.
 func NewServer(k8sClient kubernetes.Interface, controller routers.Controller, cache *cache.Cache,
-	cacheExpirations *routers.CacheExpirations) *Server {
+	cacheExpirations *routers.CacheExpirations, requestHeaderConfig *auth.RequestHeaderConfig,
+	loggingSecret *logging.LoggingSecret, logStore blobstore.Store) *Server {
 	router := gin.New()
 	router.Use(gin.Recovery())
 	router.Use(otelgin.Middleware("", otelgin.WithDisableGinErrorsOnMetrics(true))) // Empty string so the library sets the proper server
@@ -59,22 +64,66 @@ func NewServer(k8sClient kubernetes.Interface, controller routers.Controller, ca
 		"/readyz": "DEBUG",
 	}}))
 
+	authentication := auth.RequestHeaderAuthentication(requestHeaderConfig,
+		auth.Authentication(k8sClient.AuthenticationV1().TokenReviews(), cache,
+			cacheExpirations.Authorized, cacheExpirations.Unauthorized))
+
 	apiGroup := router.Group("/api")
 	apisGroup := router.Group("/apis")
 	groups := [...]*gin.RouterGroup{apisGroup, apiGroup}
 	// Set up middleware for each group
 	for _, group := range groups {
 		group.Use(gzip.Gzip(gzip.DefaultCompression))
-		group.Use(auth.Authentication(k8sClient.AuthenticationV1().TokenReviews(), cache,
-			cacheExpirations.Authorized, cacheExpirations.Unauthorized))
+		group.Use(authentication)
 		group.Use(auth.Impersonation(k8sClient.AuthorizationV1().SubjectAccessReviews(), cache,
 			cacheExpirations.Authorized, cacheExpirations.Unauthorized))
-		group.Use(discovery.GetAPIResource(k8sClient.Discovery().RESTClient(), cache))
+		group.Use(discovery.GetAPIResource(k8sClient.Discovery().RESTClient(), controller.Database, cache))
 		group.Use(auth.RBACAuthorization(k8sClient.AuthorizationV1().SubjectAccessReviews(), cache,
 			cacheExpirations.Authorized, cacheExpirations.Unauthorized))
+		group.Use(discovery.GetPrinterColumns(k8sClient.Discovery().RESTClient(), controller.Database, cache))
 		group.Use(pagination.Middleware())
 	}
 
+	// The tree routes return the owned resources of any kind, so each kind is authorized separately
+	archivedAPI := discovery.NewArchivedAPI(k8sClient.Discovery().RESTClient(), controller.Database, cache)
+	controller.KindAuthorizer = auth.NewKindAuthorizer(k8sClient.AuthorizationV1().SubjectAccessReviews(),
+		archivedAPI, cache, cacheExpirations.Authorized, cacheExpirations.Unauthorized)
+
+	// The stats routes aggregate resources of any kind so they do not go through the discovery
+	// of the resource and are authorized against the stats resource of the kubearchive.org group
+	statsGroup := router.Group("/apis/kubearchive.org/v1")
+	statsGroup.Use(gzip.Gzip(gzip.DefaultCompression))
+	statsGroup.Use(authentication)
+	statsGroup.Use(auth.Impersonation(k8sClient.AuthorizationV1().SubjectAccessReviews(), cache,
+		cacheExpirations.Authorized, cacheExpirations.Unauthorized))
+	statsGroup.Use(auth.StatsAuthorization(k8sClient.AuthorizationV1().SubjectAccessReviews(), cache,
+		cacheExpirations.Authorized, cacheExpirations.Unauthorized))
+	statsGroup.GET("/stats", controller.GetStats)
+	statsGroup.GET("/namespaces/:namespace/stats", controller.GetStats)
+
+	// The discovery and OpenAPI documents describe the kinds with archived resources without their data,
+	// so they are available to every authenticated user as they are in the Kubernetes API
+	discoveryGroup := router.Group("")
+	discoveryGroup.Use(gzip.Gzip(gzip.DefaultCompression))
+	discoveryGroup.Use(authentication)
+	discoveryGroup.Use(auth.Impersonation(k8sClient.AuthorizationV1().SubjectAccessReviews(), cache,
+		cacheExpirations.Authorized, cacheExpirations.Unauthorized))
+	discoveryGroup.GET("/api", archivedAPI.GetAPIVersions)
+	discoveryGroup.GET("/api/:version", archivedAPI.GetAPIResourceList)
+	discoveryGroup.GET("/apis", archivedAPI.GetAPIGroupList)
+	discoveryGroup.GET("/apis/:group", archivedAPI.GetAPIGroup)
+	discoveryGroup.GET("/apis/:group/:version", archivedAPI.GetAPIResourceList)
+	discoveryGroup.GET("/openapi/v3", archivedAPI.GetOpenAPIIndex)
+	discoveryGroup.GET("/openapi/v3/api/:version", archivedAPI.GetOpenAPI)
+	discoveryGroup.GET("/openapi/v3/apis/:group/:version", archivedAPI.GetOpenAPI)
+
+	// The discovery documents of the aggregated API are requested by the Kubernetes API server to
+	// check KubeArchive is available, they do not contain archived data so they are not authenticated
+	if requestHeaderConfig != nil {
+		router.GET(discovery.AggregatedGroupPath, discovery.GetAggregatedAPIGroup)
+		router.GET(discovery.AggregatedPathPrefix, discovery.GetAggregatedAPIResourceList)
+	}
+
 	router.GET("/livez", controller.Livez)
 	router.GET("/readyz", controller.Readyz)
 
@@ -82,19 +131,32 @@ func NewServer(k8sClient kubernetes.Interface, controller routers.Controller, ca
 	apisGroup.GET("/:group/:version/namespaces/:namespace/:resourceType", controller.GetResources)
 	apisGroup.GET("/:group/:version/namespaces/:namespace/:resourceType/:name", controller.GetResources)
 	apisGroup.GET("/:group/:version/namespaces/:namespace/:resourceType/:name/log",
-		logging.SetLoggingHeaders(), controller.GetLogURL, logging.LogRetrieval())
+		logging.SetLoggingHeaders(loggingSecret), controller.GetLogURL, logging.LogRetrieval(logStore))
+	apisGroup.GET("/:group/:version/namespaces/:namespace/:resourceType/:name/tree", controller.GetResourceTree)
 	apisGroup.GET("/:group/:version/namespaces/:namespace/:resourceType/uid/:uid", controller.GetResourceByUID)
+	apisGroup.GET("/:group/:version/namespaces/:namespace/:resourceType/uid/:uid/tree", controller.GetResourceTree)
+	apisGroup.GET("/:group/:version/namespaces/:namespace/:resourceType/uid/:uid/history", controller.GetResourceHistory)
+	apisGroup.GET("/:group/:version/namespaces/:namespace/:resourceType/uid/:uid/history/:revision",
+		controller.GetResourceRevision)
 	apisGroup.GET("/:group/:version/namespaces/:namespace/:resourceType/uid/:uid/log",
-		logging.SetLoggingHeaders(), controller.GetLogURL, logging.LogRetrieval())
+		logging.SetLoggingHeaders(loggingSecret), controller.GetLogURL, logging.LogRetrieval(logStore))
 
 	apiGroup.GET("/:version/:resourceType", controller.GetResources)
 	apiGroup.GET("/:version/namespaces/:namespace/:resourceType", controller.GetResources)
+	// The log search is under "-", which is not a valid name, so it does not shadow a Pod named "log"
+	apiGroup.GET("/:version/namespaces/:namespace/:resourceType/-/log",
+		logging.SetLoggingHeaders(loggingSecret), controller.SearchLogURLs, logging.LogRetrieval(logStore))
 	apiGroup.GET("/:version/namespaces/:namespace/:resourceType/:name", controller.GetResources)
 	apiGroup.GET("/:version/namespaces/:namespace/:resourceType/:name/log",
-		logging.SetLoggingHeaders(), controller.GetLogURL, logging.LogRetrieval())
+		logging.SetLoggingHeaders(loggingSecret), controller.GetLogURL, logging.LogRetrieval(logStore))
+	apiGroup.GET("/:version/namespaces/:namespace/:resourceType/:name/tree", controller.GetResourceTree)
 	apiGroup.GET("/:version/namespaces/:namespace/:resourceType/uid/:uid", controller.GetResourceByUID)
+	apiGroup.GET("/:version/namespaces/:namespace/:resourceType/uid/:uid/tree", controller.GetResourceTree)
+	apiGroup.GET("/:version/namespaces/:namespace/:resourceType/uid/:uid/history", controller.GetResourceHistory)
+	apiGroup.GET("/:version/namespaces/:namespace/:resourceType/uid/:uid/history/:revision",
+		controller.GetResourceRevision)
 	apiGroup.GET("/:version/namespaces/:namespace/:resourceType/uid/:uid/log",
-		logging.SetLoggingHeaders(), controller.GetLogURL, logging.LogRetrieval())
+		logging.SetLoggingHeaders(loggingSecret), controller.GetLogURL, logging.LogRetrieval(logStore))
 
 	return &Server{
 		router:    router,
@@ -152,16 +214,49 @@ func main() {
 		os.Exit(1)
 	}
 
-	server := NewServer(k8sClient, controller, memCache, cacheExpirations)
+	var requestHeaderConfig *auth.RequestHeaderConfig
+	if os.Getenv(auth.AggregatedAPIEnvVar) == "true" {
+		requestHeaderConfig, err = auth.NewRequestHeaderConfig(context.Background(), k8sClient.CoreV1())
+		if err != nil {
+			slog.Error("Could not load the aggregated API configuration", "error", err.Error())
+			os.Exit(1)
+		}
+		slog.Info("Serving as an aggregated API", "path", discovery.AggregatedPathPrefix)
+	}
+
+	loggingSecret := logging.NewLoggingSecret()
+	watchContext, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go func() {
+		if watchErr := loggingSecret.Watch(watchContext); watchErr != nil {
+			slog.Error("Could not watch the kubearchive-logging secret", "error", watchErr.Error())
+		}
+	}()
+
+	logStore, err := blobstore.NewFromEnv()
+	if err != nil {
+		slog.Error("Could not configure the log store", "error", err.Error())
+		os.Exit(1)
+	}
+
+	server := NewServer(k8sClient, controller, memCache, cacheExpirations, requestHeaderConfig, loggingSecret,
+		logStore)
+	handler := server.router.Handler()
+	if requestHeaderConfig != nil {
+		handler = discovery.StripAggregatedPathPrefix(handler)
+	}
 	httpServer := http.Server{
 		Addr:    "0.0.0.0:8081",
-		Handler: server.router.Handler(),
+		Handler: handler,
 		// We do not accept bodies yet, because we are read-only, so we set a
 		// small timeout for headers and complete request. This prevents the
 		// SlowLoris attack (see Wikipedia) by closing open connections fast
 		ReadHeaderTimeout: 2 * time.Second,
 		ReadTimeout:       2 * time.Second,
 	}
+	if requestHeaderConfig != nil {
+		httpServer.TLSConfig = requestHeaderConfig.TLSConfig()
+	}
 
 	go func() {
 		shutdownErr := httpServer.ListenAndServeTLS("/etc/kubearchive/ssl/tls.crt", "/etc/kubearchive/ssl/tls.key")
@@ -12,6 +12,8 @@ import (
 	"k8s.io/client-go/kubernetes"
 	fakeK8s "k8s.io/client-go/kubernetes/fake"
 
+	"github.com/kubearchive/kubearchive/cmd/api/auth"
+	"github.com/kubearchive/kubearchive/cmd/api/logging"
 	"github.com/kubearchive/kubearchive/cmd/api/routers"
 	"github.com/kubearchive/kubearchive/pkg/cache"
 	fakeDB "github.com/kubearchive/kubearchive/pkg/database/fake"
@@ -25,7 +27,7 @@ func fakeServer(k8sClient kubernetes.Interface, cache *cache.Cache) *Server {
 	}
 	controller := routers.Controller{Database: fakeDB.NewFakeDatabase(nil, nil, "")}
 	expirations := &routers.CacheExpirations{Authorized: 1 * time.Second, Unauthorized: 1 * time.Second}
-	return NewServer(k8sClient, controller, cache, expirations)
+	return NewServer(k8sClient, controller, cache, expirations, nil, logging.NewLoggingSecret(), nil)
 }
 
 func TestNewServer(t *testing.T) {
@@ -50,6 +52,37 @@ func TestUnauthQuery(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, res.Code)
 }
 
+func TestAggregatedAPIDiscovery(t *testing.T) {
+	memCache := cache.New()
+	controller := routers.Controller{Database: fakeDB.NewFakeDatabase(nil, nil, "")}
+	expirations := &routers.CacheExpirations{Authorized: 1 * time.Second, Unauthorized: 1 * time.Second}
+
+	for _, path := range []string{"/apis/archive.kubearchive.org", "/apis/archive.kubearchive.org/v1"} {
+		// Not registered unless KubeArchive runs as an aggregated API
+		server := NewServer(fakeK8s.NewSimpleClientset(), controller, memCache, expirations, nil, logging.NewLoggingSecret(), nil)
+		res := httptest.NewRecorder()
+		server.router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.NotEqual(t, http.StatusOK, res.Code)
+
+		server = NewServer(fakeK8s.NewSimpleClientset(), controller, memCache, expirations, &auth.RequestHeaderConfig{},
+			logging.NewLoggingSecret(), nil)
+		res = httptest.NewRecorder()
+		server.router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, res.Code)
+	}
+}
+
+func TestDiscoveryRoutes(t *testing.T) {
+	server := fakeServer(nil, cache.New())
+	for _, path := range []string{"/api", "/api/v1", "/apis", "/apis/batch", "/apis/batch/v1", "/apis/kubearchive.org/v1",
+		"/openapi/v3", "/openapi/v3/api/v1", "/openapi/v3/apis/batch/v1"} {
+		res := httptest.NewRecorder()
+		server.router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		// The request reaches the authentication, so the route exists
+		assert.Equal(t, http.StatusUnauthorized, res.Code, path)
+	}
+}
+
 func TestGetCacheExpirationsErrorPaths(t *testing.T) {
 	testCases := []struct {
 		name                   string
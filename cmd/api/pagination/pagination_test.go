@@ -40,7 +40,7 @@ func TestMiddleware(t *testing.T) {
 			expectedInt64:      "",
 			expectedDate:       "",
 			expectedStatusCode: http.StatusBadRequest,
-			expectedBody:       `{"message":"bad limit parameter"}`,
+			expectedBody:       badRequestStatus("bad limit parameter"),
 		},
 		{
 			name:               "negative limit",
@@ -49,7 +49,7 @@ func TestMiddleware(t *testing.T) {
 			expectedInt64:      "",
 			expectedDate:       "",
 			expectedStatusCode: http.StatusBadRequest,
-			expectedBody:       `{"message":"bad limit parameter"}`,
+			expectedBody:       badRequestStatus("bad limit parameter"),
 		},
 		{
 			name:               "hexadecimal limit",
@@ -58,7 +58,7 @@ func TestMiddleware(t *testing.T) {
 			expectedInt64:      "",
 			expectedDate:       "",
 			expectedStatusCode: http.StatusBadRequest,
-			expectedBody:       `{"message":"bad limit parameter"}`,
+			expectedBody:       badRequestStatus("bad limit parameter"),
 		},
 		{
 			name:               "valid limit",
@@ -76,7 +76,7 @@ func TestMiddleware(t *testing.T) {
 			expectedInt64:      "",
 			expectedDate:       "",
 			expectedStatusCode: http.StatusBadRequest,
-			expectedBody:       `{"message":"bad limit parameter"}`,
+			expectedBody:       badRequestStatus("bad limit parameter"),
 		},
 		{
 			name:               "invalid continue",
@@ -85,7 +85,7 @@ func TestMiddleware(t *testing.T) {
 			expectedInt64:      "",
 			expectedDate:       "",
 			expectedStatusCode: http.StatusBadRequest,
-			expectedBody:       `{"message":"bad continue token"}`,
+			expectedBody:       badRequestStatus("bad continue token"),
 		},
 		{
 			name:               "invalid first part of continue",
@@ -94,7 +94,7 @@ func TestMiddleware(t *testing.T) {
 			expectedInt64:      "",
 			expectedDate:       "",
 			expectedStatusCode: http.StatusBadRequest,
-			expectedBody:       `{"message":"bad continue token"}`,
+			expectedBody:       badRequestStatus("bad continue token"),
 		},
 		{
 			name:               "invalid second part of continue",
@@ -103,7 +103,7 @@ func TestMiddleware(t *testing.T) {
 			expectedInt64:      "",
 			expectedDate:       "",
 			expectedStatusCode: http.StatusBadRequest,
-			expectedBody:       `{"message":"bad continue token"}`,
+			expectedBody:       badRequestStatus("bad continue token"),
 		},
 		{
 			name:               "valid limit and continue",
@@ -145,3 +145,9 @@ func TestMiddleware(t *testing.T) {
 	}
 
 }
+
+// badRequestStatus returns the metav1.Status of a bad request with message
+func badRequestStatus(message string) string {
+	return `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"` + message +
+		`","reason":"BadRequest","code":400}`
+}
@@ -0,0 +1,151 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/cmd/api/discovery"
+	"github.com/kubearchive/kubearchive/pkg/abort"
+	"github.com/kubearchive/kubearchive/pkg/models"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	// exportFlushSize is the number of resources written between flushes of the response
+	exportFlushSize = 100
+)
+
+// isExportRequest returns true when the `export` query parameter is true
+func isExportRequest(context *gin.Context) (bool, error) {
+	value := context.Query("export")
+	if value == "" {
+		return false, nil
+	}
+
+	isExport, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid export value: %s. Expected a boolean (e.g., export=true)", value)
+	}
+	return isExport, nil
+}
+
+// ExportResources streams every resource of the collection that matches the filters as newline
+// delimited JSON, one resource per line. The resources are read through a database cursor, so
+// there is no pagination and the response is compressed when the client accepts gzip.
+// GetResources calls it when the `export` query parameter is true
+func (c *Controller) ExportResources(context *gin.Context) {
+	kind, err := discovery.GetAPIResourceKind(context)
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+
+	if context.Param("name") != "" {
+		abort.Abort(context, errors.New("export is only supported on collections, use ?name= to filter by name"),
+			http.StatusBadRequest)
+		return
+	}
+	isWatch, err := isWatchRequest(context)
+	if err != nil {
+		abort.Abort(context, err, http.StatusBadRequest)
+		return
+	}
+	if isWatch {
+		abort.Abort(context, errors.New("export can not be combined with watch"), http.StatusBadRequest)
+		return
+	}
+
+	group := context.Param("group")
+	version := context.Param("version")
+	namespace := context.Param("namespace")
+	name := context.Query("name")
+
+	if strings.HasPrefix(context.Request.URL.Path, "/apis/") && group == "" {
+		abort.Abort(context, errors.New(http.StatusText(http.StatusNotFound)), http.StatusNotFound)
+		return
+	}
+
+	filters, err := parseListFilters(context)
+	if err != nil {
+		abort.Abort(context, err, http.StatusBadRequest)
+		return
+	}
+
+	apiVersion := version
+	if group != "" {
+		apiVersion = fmt.Sprintf("%s/%s", group, version)
+	}
+
+	ctx := context.Request.Context()
+	exported := 0
+	line := &bytes.Buffer{}
+	err = c.Database.StreamResources(ctx, kind, apiVersion, namespace, name, filters.labelFilters,
+		filters.fieldFilters, filters.creationTimestampAfter, filters.creationTimestampBefore, filters.asOf,
+		func(resource models.Resource) error {
+			if filters.celFilter != nil {
+				matched, matchErr := matchesCELFilter(ctx, filters.celFilter, resource.Data)
+				if matchErr != nil {
+					return matchErr
+				}
+				if !matched {
+					return nil
+				}
+			}
+
+			line.Reset()
+			if compactErr := json.Compact(line, []byte(resource.Data)); compactErr != nil {
+				return fmt.Errorf("unable to serialize resource %s: %w", resource.Uuid, compactErr)
+			}
+			line.WriteByte('\n')
+
+			// The status is sent with the first resource, so errors found before that are
+			// returned as a regular error response
+			if exported == 0 {
+				context.Header("Content-Type", ndjsonContentType)
+				context.Status(http.StatusOK)
+			}
+			if _, writeErr := context.Writer.Write(line.Bytes()); writeErr != nil {
+				return writeErr
+			}
+			exported++
+			if exported%exportFlushSize == 0 {
+				context.Writer.Flush()
+			}
+			return nil
+		})
+
+	if err != nil && exported == 0 {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		writeExportError(context, err)
+		return
+	}
+	if exported == 0 {
+		context.Header("Content-Type", ndjsonContentType)
+		context.Status(http.StatusOK)
+	}
+	context.Writer.Flush()
+}
+
+// writeExportError writes a Status as the last line of the export, the response status is already
+// sent so this is the only way to let the client know the export is incomplete
+func writeExportError(context *gin.Context, err error) {
+	status := abort.NewStatus(context, err, http.StatusInternalServerError)
+	if encodeErr := json.NewEncoder(context.Writer).Encode(status); encodeErr != nil {
+		slog.ErrorContext(context.Request.Context(), "error writing export error", "error", encodeErr.Error())
+		return
+	}
+	context.Writer.Flush()
+}
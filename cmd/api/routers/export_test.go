@@ -0,0 +1,185 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestExportResources(t *testing.T) {
+	tests := []struct {
+		name          string
+		database      interfaces.DBReader
+		core          bool
+		endpoint      string
+		expectedCode  int
+		expectedNames []string
+	}{
+		{
+			name:          "core resources",
+			database:      fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			core:          true,
+			endpoint:      "/api/v1/pods?export=true",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{"test"},
+		},
+		{
+			name:          "namespaced resources",
+			database:      fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint:      "/apis/stable.example.com/v1/namespaces/test/crontabs?export=true",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{"test", "test-e2e-job", "my-e2e-service", "production-deployment"},
+		},
+		{
+			name:          "wildcard name",
+			database:      fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint:      "/apis/stable.example.com/v1/namespaces/test/crontabs?export=true&name=*e2e*",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{"test-e2e-job", "my-e2e-service"},
+		},
+		{
+			name:     "CEL filter",
+			database: fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint: "/apis/stable.example.com/v1/namespaces/test/crontabs?export=true&filter=" +
+				url.QueryEscape(`metadata.name.startsWith("test")`),
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{"test", "test-e2e-job"},
+		},
+		{
+			name:          "no resources",
+			database:      fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint:      "/apis/stable.example.com/v1/namespaces/other/crontabs?export=true",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{},
+		},
+		{
+			name:         "invalid label selector",
+			database:     fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint:     "/apis/stable.example.com/v1/crontabs?export=true&labelSelector=app%20in%20(",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid CEL filter",
+			database:     fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint:     "/apis/stable.example.com/v1/crontabs?export=true&filter=metadata.name%20%3D%3D",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "database error",
+			database:     fake.NewFakeDatabaseWithError(errors.New("test error")),
+			endpoint:     "/apis/stable.example.com/v1/crontabs?export=true",
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "invalid export value",
+			database:     fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint:     "/apis/stable.example.com/v1/crontabs?export=yes",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "single resource",
+			database:     fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint:     "/apis/stable.example.com/v1/namespaces/test/crontabs/test-e2e-crontab?export=true",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "combined with watch",
+			database:     fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint:     "/apis/stable.example.com/v1/crontabs?export=true&watch=true",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			router := setupRouter(tc.database, tc.core)
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.endpoint, nil)
+			router.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, ndjsonContentType, res.Header().Get("Content-Type"))
+
+			names := []string{}
+			scanner := bufio.NewScanner(res.Body)
+			for scanner.Scan() {
+				resource := &unstructured.Unstructured{}
+				assert.NoError(t, resource.UnmarshalJSON(scanner.Bytes()))
+				names = append(names, resource.GetName())
+			}
+			assert.ElementsMatch(t, tc.expectedNames, names)
+		})
+	}
+}
+
+// failingStreamDatabase sends its resources and then fails, as a connection lost in the middle of an export would
+type failingStreamDatabase struct {
+	interfaces.DBReader
+	resources []models.Resource
+}
+
+func (f failingStreamDatabase) StreamResources(_ context.Context, _, _, _, _ string, _ *models.LabelFilters,
+	_ *models.FieldFilters, _, _, _ *time.Time, fn func(models.Resource) error) error {
+	for _, resource := range f.resources {
+		if err := fn(resource); err != nil {
+			return err
+		}
+	}
+	return errors.New("connection lost")
+}
+
+func TestExportResourcesError(t *testing.T) {
+	database := failingStreamDatabase{resources: []models.Resource{
+		{Id: 1, Data: `{"kind": "Pod", "apiVersion": "v1", "metadata": {"name": "pod-1"}}`},
+	}}
+	router := setupRouter(database, true)
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/test/pods?export=true", nil)
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, `{"kind":"Pod","apiVersion":"v1","metadata":{"name":"pod-1"}}`, lines[0])
+
+	var status metav1.Status
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &status))
+	assert.Equal(t, metav1.StatusFailure, status.Status)
+	assert.Equal(t, "connection lost", status.Message)
+}
+
+func TestGetResourceNamedExport(t *testing.T) {
+	pod := &unstructured.Unstructured{}
+	pod.SetKind("Pod")
+	pod.SetAPIVersion("v1")
+	pod.SetNamespace("test")
+	pod.SetName("export")
+	router := setupRouter(fake.NewFakeDatabase([]*unstructured.Unstructured{pod}, testLogUrls, testLogJsonPath), true)
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/test/pods/export", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	resource := &unstructured.Unstructured{}
+	assert.NoError(t, resource.UnmarshalJSON(res.Body.Bytes()))
+	assert.Equal(t, "export", resource.GetName())
+}
@@ -0,0 +1,93 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/cel-go/cel"
+	kcel "github.com/kubearchive/kubearchive/pkg/cel"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// celFilterBatchSize is the number of resources retrieved on each query to the database
+	// when the resources are filtered with a CEL expression
+	celFilterBatchSize = 100
+	// maxCELFilterEvaluations bounds the resources evaluated on a single request, so expressions
+	// that match few resources do not go through the whole database at once
+	maxCELFilterEvaluations = 10000
+)
+
+// resourceQuery queries a page of resources starting after continueId and continueDate
+type resourceQuery func(continueId, continueDate string, limit int) ([]models.Resource, error)
+
+// parseCELFilter compiles the CEL expression of the `filter` query parameter, nil is returned when it is empty
+func parseCELFilter(context *gin.Context) (*cel.Program, error) {
+	expression := context.Query("filter")
+	if expression == "" {
+		return nil, nil //nolint:nilnil // This is intentional - empty parameter means no filter
+	}
+
+	program, err := kcel.CompileCELExpr(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	return program, nil
+}
+
+// matchesCELFilter returns true when the resource data passes the CEL program
+func matchesCELFilter(ctx context.Context, program *cel.Program, data string) (bool, error) {
+	object := &unstructured.Unstructured{}
+	if err := object.UnmarshalJSON([]byte(data)); err != nil {
+		return false, fmt.Errorf("unable to deserialize resource: %w", err)
+	}
+	return kcel.ExecuteBooleanCEL(ctx, program, object), nil
+}
+
+// queryFilteredResources queries the resources in batches and keeps the ones that pass the CEL program
+// until there are `limit` of them or there are no more resources. When maxCELFilterEvaluations resources
+// are evaluated before that, the resources found are returned along with the last resource evaluated,
+// so the continue token points after it and the client keeps going from there
+func queryFilteredResources(ctx context.Context, program *cel.Program, query resourceQuery,
+	continueId, continueDate string, limit int) ([]models.Resource, *models.Resource, error) {
+	matches := []models.Resource{}
+	evaluations := 0
+	for {
+		resources, err := query(continueId, continueDate, celFilterBatchSize)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := range resources {
+			matched, matchErr := matchesCELFilter(ctx, program, resources[i].Data)
+			if matchErr != nil {
+				return nil, nil, matchErr
+			}
+			if matched {
+				matches = append(matches, resources[i])
+				if len(matches) == limit {
+					return matches, nil, nil
+				}
+			}
+
+			evaluations++
+			if evaluations == maxCELFilterEvaluations {
+				return matches, &resources[i], nil
+			}
+		}
+
+		if len(resources) < celFilterBatchSize {
+			return matches, nil, nil
+		}
+		last := resources[len(resources)-1]
+		continueId = strconv.FormatInt(last.Id, 10)
+		continueDate = strings.Trim(last.Date, "\"")
+	}
+}
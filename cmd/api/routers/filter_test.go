@@ -0,0 +1,201 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	kcel "github.com/kubearchive/kubearchive/pkg/cel"
+	"github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newFilterTestPod(name, phase string) *unstructured.Unstructured {
+	pod := &unstructured.Unstructured{}
+	pod.SetKind("Pod")
+	pod.SetAPIVersion("v1")
+	pod.SetNamespace("test")
+	pod.SetName(name)
+	_ = unstructured.SetNestedField(pod.Object, phase, "status", "phase")
+	return pod
+}
+
+func TestGetResourcesWithCELFilter(t *testing.T) {
+	pods := []*unstructured.Unstructured{
+		newFilterTestPod("failed-1", "Failed"),
+		newFilterTestPod("succeeded", "Succeeded"),
+		newFilterTestPod("failed-2", "Failed"),
+	}
+	router := setupRouter(fake.NewFakeDatabase(pods, testLogUrls, testLogJsonPath), true)
+
+	tests := []struct {
+		name          string
+		filter        string
+		limit         string
+		expectedCode  int
+		expectedNames []string
+		expectedToken bool
+	}{
+		{
+			name:          "matching resources",
+			filter:        "status.phase == 'Failed'",
+			limit:         "10",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{"failed-1", "failed-2"},
+		},
+		{
+			name:          "more matches than the limit",
+			filter:        "status.phase == 'Failed'",
+			limit:         "1",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{"failed-1"},
+			expectedToken: true,
+		},
+		{
+			name:          "no matches",
+			filter:        "metadata.name.startsWith('running')",
+			limit:         "10",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{},
+		},
+		{
+			name:         "invalid expression",
+			filter:       "status.phase ==",
+			limit:        "10",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/namespaces/test/pods?limit=%s&filter=%s",
+				tt.limit, url.QueryEscape(tt.filter)), nil)
+			router.ServeHTTP(res, req)
+
+			assert.Equal(t, tt.expectedCode, res.Code)
+			if tt.expectedCode != http.StatusOK {
+				assert.Contains(t, res.Body.String(), "invalid filter")
+				return
+			}
+			var list unstructured.UnstructuredList
+			assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &list.Object))
+			names := []string{}
+			items, _, _ := unstructured.NestedSlice(list.Object, "items")
+			for _, item := range items {
+				names = append(names, item.(map[string]any)["metadata"].(map[string]any)["name"].(string))
+			}
+			assert.Equal(t, tt.expectedNames, names)
+			continueToken, _, _ := unstructured.NestedString(list.Object, "metadata", "continue")
+			assert.Equal(t, tt.expectedToken, continueToken != "")
+		})
+	}
+}
+
+func TestGetResourceByNameWithCELFilter(t *testing.T) {
+	pods := []*unstructured.Unstructured{newFilterTestPod("failed-1", "Failed")}
+	router := setupRouter(fake.NewFakeDatabase(pods, testLogUrls, testLogJsonPath), true)
+
+	tests := []struct {
+		name         string
+		url          string
+		filter       string
+		expectedCode int
+	}{
+		{
+			name:         "path name matching",
+			url:          "/api/v1/namespaces/test/pods/failed-1",
+			filter:       "status.phase == 'Failed'",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "path name not matching",
+			url:          "/api/v1/namespaces/test/pods/failed-1",
+			filter:       "status.phase == 'Succeeded'",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "query name not matching",
+			url:          "/api/v1/namespaces/test/pods?name=failed-1",
+			filter:       "status.phase == 'Succeeded'",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := url.Parse(tt.url)
+			assert.NoError(t, err)
+			query := target.Query()
+			query.Set("filter", tt.filter)
+			target.RawQuery = query.Encode()
+
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, target.String(), nil))
+			assert.Equal(t, tt.expectedCode, res.Code)
+		})
+	}
+}
+
+func TestQueryFilteredResources(t *testing.T) {
+	// The resources are sorted as the database does, from the highest id to the lowest
+	total := maxCELFilterEvaluations + 2*celFilterBatchSize
+	resources := make([]models.Resource, 0, total)
+	for id := total; id > 0; id-- {
+		phase := "Succeeded"
+		if id%1000 == 0 {
+			phase = "Failed"
+		}
+		data, _ := json.Marshal(newFilterTestPod(fmt.Sprintf("pod-%d", id), phase).Object)
+		resources = append(resources, models.Resource{Id: int64(id), Date: "2025-01-01T00:00:00Z", Data: string(data)})
+	}
+	queries := 0
+	query := func(continueId, _ string, limit int) ([]models.Resource, error) {
+		queries++
+		start := 0
+		if continueId != "" {
+			id, _ := strconv.Atoi(continueId)
+			start = total - id + 1
+		}
+		end := min(start+limit, total)
+		return resources[start:end], nil
+	}
+
+	failed, _ := kcel.CompileCELExpr("status.phase == 'Failed'")
+	matches, lastEvaluated, err := queryFilteredResources(context.Background(), failed, query, "", "", 3)
+	assert.NoError(t, err)
+	assert.Len(t, matches, 3)
+	assert.Nil(t, lastEvaluated)
+	assert.Equal(t, int64(total-200), matches[0].Id)
+	assert.Equal(t, int64(total-2200), matches[2].Id)
+
+	queries = 0
+	matches, lastEvaluated, err = queryFilteredResources(context.Background(), failed, query, "", "", 100)
+	assert.NoError(t, err)
+	assert.Len(t, matches, maxCELFilterEvaluations/1000)
+	if assert.NotNil(t, lastEvaluated) {
+		assert.Equal(t, int64(total-maxCELFilterEvaluations+1), lastEvaluated.Id)
+	}
+	assert.Equal(t, maxCELFilterEvaluations/celFilterBatchSize, queries)
+
+	matches, lastEvaluated, err = queryFilteredResources(context.Background(), failed, query,
+		strconv.FormatInt(int64(total-maxCELFilterEvaluations+1), 10), "", 100)
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+	assert.Nil(t, lastEvaluated)
+
+	_, _, err = queryFilteredResources(context.Background(), failed,
+		func(_, _ string, _ int) ([]models.Resource, error) { return nil, errors.New("test error") }, "", "", 3)
+	assert.EqualError(t, err, "test error")
+}
@@ -0,0 +1,108 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/cmd/api/discovery"
+	"github.com/kubearchive/kubearchive/pkg/abort"
+	dbErrors "github.com/kubearchive/kubearchive/pkg/database/errors"
+	"github.com/kubearchive/kubearchive/pkg/models"
+)
+
+// resourceHistory is the response of the history endpoint
+type resourceHistory struct {
+	UID       string                    `json:"uid"`
+	Revisions []models.ResourceRevision `json:"revisions"`
+}
+
+// GetResourceHistory returns the revisions archived for the resource, oldest first
+func (c *Controller) GetResourceHistory(context *gin.Context) {
+	uid, ok := c.validateHistoryResource(context)
+	if !ok {
+		return
+	}
+
+	revisions, err := c.Database.QueryResourceRevisions(context.Request.Context(), uid)
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+	if revisions == nil {
+		revisions = []models.ResourceRevision{}
+	}
+
+	context.JSON(http.StatusOK, resourceHistory{UID: uid, Revisions: revisions})
+}
+
+// GetResourceRevision returns the resource as it was archived in the requested revision
+func (c *Controller) GetResourceRevision(context *gin.Context) {
+	revision, err := strconv.ParseInt(context.Param("revision"), 10, 64)
+	if err != nil || revision <= 0 {
+		abort.Abort(context,
+			fmt.Errorf("invalid revision: %s. Expected a positive integer", context.Param("revision")),
+			http.StatusBadRequest)
+		return
+	}
+
+	uid, ok := c.validateHistoryResource(context)
+	if !ok {
+		return
+	}
+
+	data, err := c.Database.QueryResourceRevision(context.Request.Context(), uid, revision)
+	if errors.Is(err, dbErrors.ErrResourceNotFound) {
+		abort.Abort(context, fmt.Errorf("revision %d not found", revision), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+
+	context.String(http.StatusOK, data)
+}
+
+// validateHistoryResource checks that the resource of the uid in the path matches the kind, version and
+// namespace in the path, so the history is only returned to users allowed to read that resource
+func (c *Controller) validateHistoryResource(context *gin.Context) (string, bool) {
+	kind, err := discovery.GetAPIResourceKind(context)
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return "", false
+	}
+
+	group := context.Param("group")
+	version := context.Param("version")
+	namespace := context.Param("namespace")
+	uid := context.Param("uid")
+
+	if strings.HasPrefix(context.Request.URL.Path, "/apis/") && group == "" {
+		abort.Abort(context, errors.New(http.StatusText(http.StatusNotFound)), http.StatusNotFound)
+		return "", false
+	}
+
+	apiVersion := version
+	if group != "" {
+		apiVersion = fmt.Sprintf("%s/%s", group, version)
+	}
+
+	resource, err := c.Database.QueryResourceByUID(context.Request.Context(), kind, apiVersion, namespace, uid, nil)
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return "", false
+	}
+	if resource == nil {
+		abort.Abort(context, notFoundError(context, uid), http.StatusNotFound)
+		return "", false
+	}
+
+	return uid, true
+}
@@ -0,0 +1,117 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetResourceHistory(t *testing.T) {
+	uid := string(coreResources[0].GetUID())
+	tests := []struct {
+		name              string
+		database          interfaces.DBReader
+		endpoint          string
+		expectedCode      int
+		expectedRevisions int
+	}{
+		{
+			name:              "history",
+			database:          fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint:          fmt.Sprintf("/api/v1/namespaces/test/pods/uid/%s/history", uid),
+			expectedCode:      http.StatusOK,
+			expectedRevisions: 1,
+		},
+		{
+			name:         "resource in another namespace",
+			database:     fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint:     fmt.Sprintf("/api/v1/namespaces/other/pods/uid/%s/history", uid),
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "database error",
+			database:     fake.NewFakeDatabaseWithError(errors.New("test error")),
+			endpoint:     fmt.Sprintf("/api/v1/namespaces/test/pods/uid/%s/history", uid),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter(tt.database, true)
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.endpoint, nil)
+			router.ServeHTTP(res, req)
+
+			assert.Equal(t, tt.expectedCode, res.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			var history resourceHistory
+			assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &history))
+			assert.Equal(t, uid, history.UID)
+			if assert.Len(t, history.Revisions, tt.expectedRevisions) {
+				assert.Equal(t, int64(1), history.Revisions[0].Revision)
+			}
+		})
+	}
+}
+
+func TestGetResourceRevision(t *testing.T) {
+	uid := string(coreResources[0].GetUID())
+	coreResourceBytes, _ := json.Marshal(coreResources[0])
+	tests := []struct {
+		name         string
+		revision     string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "existing revision",
+			revision:     "1",
+			expectedCode: http.StatusOK,
+			expectedBody: string(coreResourceBytes),
+		},
+		{
+			name:         "missing revision",
+			revision:     "2",
+			expectedCode: http.StatusNotFound,
+			expectedBody: "revision 2 not found",
+		},
+		{
+			name:         "invalid revision",
+			revision:     "latest",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "invalid revision",
+		},
+		{
+			name:         "zero revision",
+			revision:     "0",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "invalid revision",
+		},
+	}
+
+	router := setupRouter(fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath), true)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet,
+				fmt.Sprintf("/api/v1/namespaces/test/pods/uid/%s/history/%s", uid, tt.revision), nil)
+			router.ServeHTTP(res, req)
+
+			assert.Equal(t, tt.expectedCode, res.Code)
+			assert.Contains(t, res.Body.String(), tt.expectedBody)
+		})
+	}
+}
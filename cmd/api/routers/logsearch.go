@@ -0,0 +1,127 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/cmd/api/discovery"
+	"github.com/kubearchive/kubearchive/cmd/api/pagination"
+	"github.com/kubearchive/kubearchive/pkg/abort"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// maxLogSearchLimit is the maximum number of Pods whose logs are searched in a request, the next Pods are
+	// searched following the continue token
+	maxLogSearchLimit = 100
+	// maxLogSearchURLs is the maximum number of logs retrieved in a request
+	maxLogSearchURLs = 500
+	// LogSearchContinueHeader is the header of the response with the continue token of the next Pods to search,
+	// it is not set when there are no more Pods
+	LogSearchContinueHeader = "X-KubeArchive-Continue"
+)
+
+// SearchLogURLs sets logURLs attribute in the context with the log URLs of the containers of a page of the
+// archived Pods of the namespace that match the filters, so logging.LogRetrieval searches the grep pattern
+// in all of them, skipping the logs it can not retrieve. The container query parameter selects a single
+// container of each Pod. The page has limit Pods, at most maxLogSearchLimit, and the continue token of the
+// next page is returned in the LogSearchContinueHeader header.
+func (c *Controller) SearchLogURLs(context *gin.Context) {
+	kind, err := discovery.GetAPIResourceKind(context)
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+	if kind != "Pod" || context.Param("version") != "v1" {
+		abort.Abort(context, fmt.Errorf("logs can only be searched across pods, not %s", context.Param("resourceType")),
+			http.StatusBadRequest)
+		return
+	}
+	if context.Query("grep") == "" {
+		abort.Abort(context, errors.New("grep is required to search the logs"), http.StatusBadRequest)
+		return
+	}
+	limit, id, date := pagination.GetValuesFromContext(context)
+	if limit > maxLogSearchLimit {
+		abort.Abort(context, fmt.Errorf("the logs of at most %d pods can be searched at once", maxLogSearchLimit),
+			http.StatusBadRequest)
+		return
+	}
+
+	filters, err := parseListFilters(context)
+	if err != nil {
+		abort.Abort(context, err, http.StatusBadRequest)
+		return
+	}
+
+	// One more Pod than requested is queried to know if there are more Pods to search
+	ctx := context.Request.Context()
+	namespace := context.Param("namespace")
+	queryResources := func(continueId, continueDate string, limit int) ([]models.Resource, error) {
+		return c.Database.QueryResources(ctx, kind, "v1", namespace, "", continueId, continueDate,
+			filters.labelFilters, filters.fieldFilters, filters.creationTimestampAfter,
+			filters.creationTimestampBefore, filters.asOf, limit)
+	}
+	var resources []models.Resource
+	var lastEvaluated *models.Resource
+	if filters.celFilter != nil {
+		resources, lastEvaluated, err = queryFilteredResources(ctx, filters.celFilter, queryResources, id, date,
+			limit+1)
+	} else {
+		resources, err = queryResources(id, date, limit+1)
+	}
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+	if len(resources) > limit {
+		resources = resources[:limit]
+		lastEvaluated = &resources[limit-1]
+	}
+	if lastEvaluated != nil {
+		context.Header(LogSearchContinueHeader, pagination.CreateToken(lastEvaluated.Id, lastEvaluated.Date))
+	}
+
+	uids := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		var pod metav1.PartialObjectMetadata
+		if unmarshalErr := json.Unmarshal([]byte(resource.Data), &pod); unmarshalErr != nil {
+			abort.Abort(context, fmt.Errorf("unable to deserialize pod: %w", unmarshalErr),
+				http.StatusInternalServerError)
+			return
+		}
+		uids = append(uids, string(pod.UID))
+	}
+	podLogURLs, err := c.Database.QueryLogURLsByUIDs(ctx, namespace, uids)
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+
+	containerName := context.Query("container")
+	var logURLs []models.ContainerLogURL
+	for _, logURL := range podLogURLs {
+		if containerName == "" || logURL.ContainerName == containerName {
+			logURLs = append(logURLs, logURL)
+		}
+	}
+	if len(logURLs) > maxLogSearchURLs {
+		abort.Abort(context, fmt.Errorf("the pods have %d logs and at most %d can be searched at once, use a lower "+
+			"limit or the container parameter", len(logURLs), maxLogSearchURLs), http.StatusBadRequest)
+		return
+	}
+	if len(logURLs) == 0 {
+		abort.Abort(context, errors.New("no archived pods with logs match the filters"), http.StatusNotFound)
+		return
+	}
+
+	context.Set("logURLs", logURLs)
+	context.Set("logSearch", true)
+}
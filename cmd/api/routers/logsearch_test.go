@@ -0,0 +1,175 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchLogURLs(t *testing.T) {
+	podUID := string(coreResources[0].GetUID())
+
+	tests := []struct {
+		name         string
+		core         bool
+		api          string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "all containers",
+			core:         true,
+			api:          "/api/v1/namespaces/test/pods/-/log?grep=panic",
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"PodName":"` + podUID + `","ContainerName":"container-1","Url":"fake.com","JsonPath":"$."},` +
+				`{"PodName":"` + podUID + `","ContainerName":"container-2","Url":"fake.org","JsonPath":"$."},` +
+				`{"PodName":"` + podUID + `","ContainerName":"foo","Url":"fake.org","JsonPath":"$."}]`,
+		},
+		{
+			name:         "single container",
+			core:         true,
+			api:          "/api/v1/namespaces/test/pods/-/log?grep=panic&container=foo",
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"PodName":"` + podUID + `","ContainerName":"foo","Url":"fake.org","JsonPath":"$."}]`,
+		},
+		{
+			name:         "no matching pods",
+			core:         true,
+			api:          "/api/v1/namespaces/other/pods/-/log?grep=panic",
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure",` +
+				`"message":"no archived pods with logs match the filters","reason":"NotFound","details":{"kind":"pods"},"code":404}`,
+		},
+		{
+			name:         "without grep",
+			core:         true,
+			api:          "/api/v1/namespaces/test/pods/-/log",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure",` +
+				`"message":"grep is required to search the logs","reason":"BadRequest","details":{"kind":"pods"},"code":400}`,
+		},
+		{
+			name:         "not pods",
+			api:          "/api/v1/namespaces/test/crontabs/-/log?grep=panic",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure",` +
+				`"message":"logs can only be searched across pods, not crontabs","reason":"BadRequest","details":{"kind":"crontabs"},"code":400}`,
+		},
+		{
+			name:         "invalid label selector",
+			core:         true,
+			api:          "/api/v1/namespaces/test/pods/-/log?grep=panic&labelSelector=app%20in%20(",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := setupRouter(fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath), test.core)
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, test.api, nil))
+
+			assert.Equal(t, test.expectedCode, res.Code)
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, res.Body.String())
+			}
+		})
+	}
+}
+
+func TestSearchLogURLsDatabaseError(t *testing.T) {
+	router := setupRouter(fake.NewFakeDatabaseWithError(errors.New("test error")), true)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/test/pods/-/log?grep=panic", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+}
+
+func TestSearchLogURLsPages(t *testing.T) {
+	secondPod := coreResources[0].DeepCopy()
+	secondPod.SetName("second-pod")
+	secondPod.SetUID("second-pod-uid")
+	resources := append(slices.Clone(testResources), secondPod)
+
+	tests := []struct {
+		name             string
+		api              string
+		expectedCode     int
+		expectedPods     []string
+		expectedContinue bool
+	}{
+		{
+			name:         "all the pods",
+			api:          "/api/v1/namespaces/test/pods/-/log?grep=panic&container=foo",
+			expectedCode: http.StatusOK,
+			expectedPods: []string{string(coreResources[0].GetUID()), "second-pod-uid"},
+		},
+		{
+			name:             "first page",
+			api:              "/api/v1/namespaces/test/pods/-/log?grep=panic&container=foo&limit=1",
+			expectedCode:     http.StatusOK,
+			expectedPods:     []string{string(coreResources[0].GetUID())},
+			expectedContinue: true,
+		},
+		{
+			name:         "limit above the maximum",
+			api:          "/api/v1/namespaces/test/pods/-/log?grep=panic&limit=101",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := setupRouter(fake.NewFakeDatabase(resources, testLogUrls, testLogJsonPath), true)
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, test.api, nil))
+
+			assert.Equal(t, test.expectedCode, res.Code)
+			assert.Equal(t, test.expectedContinue, res.Header().Get(LogSearchContinueHeader) != "")
+			if test.expectedCode != http.StatusOK {
+				return
+			}
+			var logURLs []models.ContainerLogURL
+			assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &logURLs))
+			pods := []string{}
+			for _, logURL := range logURLs {
+				pods = append(pods, logURL.PodName)
+			}
+			assert.Equal(t, test.expectedPods, pods)
+		})
+	}
+}
+
+func TestSearchLogURLsTooManyLogs(t *testing.T) {
+	var logUrls []fake.LogUrlRow
+	for i := range maxLogSearchURLs + 1 {
+		logUrls = append(logUrls, fake.LogUrlRow{ContainerName: fmt.Sprintf("container-%d", i), Url: "fake.com"})
+	}
+	router := setupRouter(fake.NewFakeDatabase(testResources, logUrls, testLogJsonPath), true)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/test/pods/-/log?grep=panic", nil))
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Contains(t, res.Body.String(), "use a lower limit or the container parameter")
+}
+
+func TestGetPodNamedLog(t *testing.T) {
+	router := setupRouter(fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath), true)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/test/pods/log", nil))
+
+	// The Pod named log is not archived, the request is not a log search
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.Contains(t, res.Body.String(), `pods \"log\" not found`)
+}
@@ -7,18 +7,24 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/cel-go/cel"
 	"github.com/kubearchive/kubearchive/cmd/api/discovery"
 	"github.com/kubearchive/kubearchive/cmd/api/pagination"
+	"github.com/kubearchive/kubearchive/cmd/api/tables"
 	"github.com/kubearchive/kubearchive/pkg/abort"
 	dbErrors "github.com/kubearchive/kubearchive/pkg/database/errors"
 	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
 	labelFilter "github.com/kubearchive/kubearchive/pkg/models"
 	"github.com/kubearchive/kubearchive/pkg/observability"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 type CacheExpirations struct {
@@ -26,14 +32,34 @@ type CacheExpirations struct {
 	Unauthorized time.Duration
 }
 
+// KindAuthorizer checks whether the user of a request can list the resources of a kind in a namespace
+type KindAuthorizer interface {
+	CanList(context *gin.Context, apiVersion, kind, namespace string) (bool, error)
+}
+
 type Controller struct {
 	Database           interfaces.DBReader
 	CacheConfiguration CacheExpirations
+	// WatchPollInterval is how often the database is queried for changes during a watch
+	WatchPollInterval time.Duration
+	// KindAuthorizer filters the resources of other kinds than the one in the path, all of them are returned
+	// when it is not set
+	KindAuthorizer KindAuthorizer
 }
 
 const listString = `{"kind": "List", "apiVersion": "v1", "metadata": {"continue": "%s"}, "items": [%s]}`
 
 func (c *Controller) GetResources(context *gin.Context) {
+	isExport, err := isExportRequest(context)
+	if err != nil {
+		abort.Abort(context, err, http.StatusBadRequest)
+		return
+	}
+	if isExport {
+		c.ExportResources(context)
+		return
+	}
+
 	limit, id, date := pagination.GetValuesFromContext(context)
 	kind, err := discovery.GetAPIResourceKind(context)
 	if err != nil {
@@ -61,45 +87,51 @@ func (c *Controller) GetResources(context *gin.Context) {
 	if queryName != "" {
 		name = queryName
 	}
-	selector, parserErr := labels.Parse(context.Query("labelSelector"))
-	if parserErr != nil {
-		abort.Abort(context, parserErr, http.StatusBadRequest)
-		return
-	}
-	reqs, _ := selector.Requirements()
-	labelFilters, labelFiltersErr := labelFilter.NewLabelFilters(reqs)
-	if labelFiltersErr != nil {
-		abort.Abort(context, labelFiltersErr, http.StatusBadRequest)
-	}
-
 	if strings.HasPrefix(context.Request.URL.Path, "/apis/") && group == "" {
 		abort.Abort(context, errors.New(http.StatusText(http.StatusNotFound)), http.StatusNotFound)
 		return
 	}
 
-	// Parse timestamp filters
-	creationTimestampAfter, err := parseTimestampQuery(context, "creationTimestampAfter")
+	filters, err := parseListFilters(context)
 	if err != nil {
 		abort.Abort(context, err, http.StatusBadRequest)
 		return
 	}
 
-	creationTimestampBefore, err := parseTimestampQuery(context, "creationTimestampBefore")
+	apiVersion := version
+	if group != "" {
+		apiVersion = fmt.Sprintf("%s/%s", group, version)
+	}
+
+	tableConverter, err := newTableConverter(context, group, kind)
 	if err != nil {
 		abort.Abort(context, err, http.StatusBadRequest)
 		return
 	}
 
-	if creationTimestampAfter != nil && creationTimestampBefore != nil {
-		if creationTimestampBefore.Before(*creationTimestampAfter) || creationTimestampBefore.Equal(*creationTimestampAfter) {
-			abort.Abort(context, errors.New("creationTimestampBefore must be after creationTimestampAfter"), http.StatusBadRequest)
+	isWatch, err := isWatchRequest(context)
+	if err != nil {
+		abort.Abort(context, err, http.StatusBadRequest)
+		return
+	}
+	if isWatch {
+		if filters.asOf != nil {
+			abort.Abort(context, errors.New("asOf is not supported when watching"), http.StatusBadRequest)
 			return
 		}
-	}
-
-	apiVersion := version
-	if group != "" {
-		apiVersion = fmt.Sprintf("%s/%s", group, version)
+		c.watchResources(context, watchFilters{
+			kind:                    kind,
+			apiVersion:              apiVersion,
+			namespace:               namespace,
+			name:                    name,
+			labelFilters:            filters.labelFilters,
+			fieldFilters:            filters.fieldFilters,
+			creationTimestampAfter:  filters.creationTimestampAfter,
+			creationTimestampBefore: filters.creationTimestampBefore,
+			celFilter:               filters.celFilter,
+			tableConverter:          tableConverter,
+		})
+		return
 	}
 
 	// We send namespace even if it's an empty string (non-namespaced resources) the Database
@@ -107,9 +139,21 @@ func (c *Controller) GetResources(context *gin.Context) {
 	// We send limit+1 because we want to know if there are more resources than requested
 	// later we just returned what we were asked to return
 	newLimit := limit + 1
-	resources, err := c.Database.QueryResources(
-		context.Request.Context(), kind, apiVersion, namespace, name, id, date, labelFilters,
-		creationTimestampAfter, creationTimestampBefore, newLimit)
+	queryResources := func(continueId, continueDate string, limit int) ([]labelFilter.Resource, error) {
+		return c.Database.QueryResources(
+			context.Request.Context(), kind, apiVersion, namespace, name, continueId, continueDate, filters.labelFilters,
+			filters.fieldFilters, filters.creationTimestampAfter, filters.creationTimestampBefore, filters.asOf, limit)
+	}
+
+	var resources []labelFilter.Resource
+	// lastEvaluated is set when the CEL filter stopped before finding enough resources
+	var lastEvaluated *labelFilter.Resource
+	if filters.celFilter != nil && (name == "" || strings.Contains(name, "*")) {
+		resources, lastEvaluated, err = queryFilteredResources(
+			context.Request.Context(), filters.celFilter, queryResources, id, date, newLimit)
+	} else {
+		resources, err = queryResources(id, date, newLimit)
+	}
 
 	if err != nil {
 		abort.Abort(context, err, http.StatusInternalServerError)
@@ -118,12 +162,27 @@ func (c *Controller) GetResources(context *gin.Context) {
 
 	if name != "" && !strings.Contains(name, "*") {
 		if len(resources) == 0 {
-			abort.Abort(context, errors.New("resource not found"), http.StatusNotFound)
+			abort.Abort(context, notFoundError(context, name), http.StatusNotFound)
 			return
 		} else if len(resources) > 1 {
 			abort.Abort(context, errors.New("more than one resource found"), http.StatusInternalServerError)
 			return
 		}
+		if filters.celFilter != nil {
+			matched, matchErr := matchesCELFilter(context.Request.Context(), filters.celFilter, resources[0].Data)
+			if matchErr != nil {
+				abort.Abort(context, matchErr, http.StatusInternalServerError)
+				return
+			}
+			if !matched {
+				abort.Abort(context, notFoundError(context, name), http.StatusNotFound)
+				return
+			}
+		}
+		if tableConverter != nil {
+			writeTable(context, tableConverter, []string{resources[0].Data}, "")
+			return
+		}
 		context.String(http.StatusOK, resources[0].Data)
 		return
 	}
@@ -135,15 +194,75 @@ func (c *Controller) GetResources(context *gin.Context) {
 	if len(resources) > limit {
 		continueToken = pagination.CreateToken(resources[len(resources)-2].Id, resources[len(resources)-2].Date)
 		returnedResources = resources[:len(resources)-1] // all but the last
+	} else if lastEvaluated != nil {
+		continueToken = pagination.CreateToken(lastEvaluated.Id, lastEvaluated.Date)
 	}
 
 	resourceStrings := []string{}
 	for _, resource := range returnedResources {
 		resourceStrings = append(resourceStrings, resource.Data)
 	}
+	if tableConverter != nil {
+		writeTable(context, tableConverter, resourceStrings, continueToken)
+		return
+	}
 	context.String(http.StatusOK, listString, continueToken, strings.Join(resourceStrings, ","))
 }
 
+// listFilters are the query parameters that filter the resources of a collection
+type listFilters struct {
+	labelFilters            *labelFilter.LabelFilters
+	fieldFilters            *labelFilter.FieldFilters
+	creationTimestampAfter  *time.Time
+	creationTimestampBefore *time.Time
+	asOf                    *time.Time
+	celFilter               *cel.Program
+}
+
+// parseListFilters parses the query parameters shared by the endpoints that return collections of resources
+func parseListFilters(context *gin.Context) (listFilters, error) {
+	var filters listFilters
+	selector, err := labels.Parse(context.Query("labelSelector"))
+	if err != nil {
+		return filters, err
+	}
+	reqs, _ := selector.Requirements()
+	filters.labelFilters, err = labelFilter.NewLabelFilters(reqs)
+	if err != nil {
+		return filters, err
+	}
+
+	fieldSelector, err := fields.ParseSelector(context.Query("fieldSelector"))
+	if err != nil {
+		return filters, err
+	}
+	filters.fieldFilters, err = labelFilter.NewFieldFilters(fieldSelector.Requirements())
+	if err != nil {
+		return filters, err
+	}
+
+	filters.creationTimestampAfter, err = parseTimestampQuery(context, "creationTimestampAfter")
+	if err != nil {
+		return filters, err
+	}
+	filters.creationTimestampBefore, err = parseTimestampQuery(context, "creationTimestampBefore")
+	if err != nil {
+		return filters, err
+	}
+	if filters.creationTimestampAfter != nil && filters.creationTimestampBefore != nil &&
+		!filters.creationTimestampBefore.After(*filters.creationTimestampAfter) {
+		return filters, errors.New("creationTimestampBefore must be after creationTimestampAfter")
+	}
+
+	filters.asOf, err = parseTimestampQuery(context, "asOf")
+	if err != nil {
+		return filters, err
+	}
+
+	filters.celFilter, err = parseCELFilter(context)
+	return filters, err
+}
+
 // parseTimestampQuery parses a timestamp query parameter and returns a pointer to time.Time
 // Returns nil if the parameter is not provided or empty
 func parseTimestampQuery(context *gin.Context, paramName string) (*time.Time, error) {
@@ -190,6 +309,24 @@ func (c *Controller) GetLogURL(context *gin.Context) {
 		apiVersion = fmt.Sprintf("%s/%s", group, version)
 	}
 
+	allContainers := false
+	if value := context.Query("allContainers"); value != "" {
+		allContainers, err = strconv.ParseBool(value)
+		if err != nil {
+			abort.Abort(context, fmt.Errorf("allContainers must be a boolean, got '%s'", value), http.StatusBadRequest)
+			return
+		}
+	}
+	if allContainers {
+		if containerName != "" {
+			abort.Abort(context, errors.New("container and allContainers can not be used together"),
+				http.StatusBadRequest)
+			return
+		}
+		c.getLogURLs(context, kind, apiVersion, namespace, name, uid)
+		return
+	}
+
 	var logURL, jsonPath string
 	if name != "" {
 		logURL, jsonPath, err = c.Database.QueryLogURLByName(
@@ -212,6 +349,28 @@ func (c *Controller) GetLogURL(context *gin.Context) {
 	context.Set("jsonPath", jsonPath)
 }
 
+// getLogURLs sets logURLs attribute in the context with the log URLs of all the containers of the Pod
+func (c *Controller) getLogURLs(context *gin.Context, kind, apiVersion, namespace, name, uid string) {
+	var logURLs []labelFilter.ContainerLogURL
+	var err error
+	if name != "" {
+		logURLs, err = c.Database.QueryLogURLsByName(context.Request.Context(), kind, apiVersion, namespace, name)
+	} else {
+		logURLs, err = c.Database.QueryLogURLsByUID(context.Request.Context(), kind, apiVersion, namespace, uid)
+	}
+
+	if errors.Is(err, dbErrors.ErrResourceNotFound) {
+		abort.Abort(context, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+
+	context.Set("logURLs", logURLs)
+}
+
 func (c *Controller) GetResourceByUID(context *gin.Context) {
 	kind, err := discovery.GetAPIResourceKind(context) // Not used but required for validation
 	if err != nil {
@@ -234,20 +393,67 @@ func (c *Controller) GetResourceByUID(context *gin.Context) {
 		return
 	}
 
-	resource, err := c.Database.QueryResourceByUID(context.Request.Context(), kind, apiVersion, namespace, uid)
+	tableConverter, err := newTableConverter(context, group, kind)
+	if err != nil {
+		abort.Abort(context, err, http.StatusBadRequest)
+		return
+	}
+
+	asOf, err := parseTimestampQuery(context, "asOf")
+	if err != nil {
+		abort.Abort(context, err, http.StatusBadRequest)
+		return
+	}
+
+	resource, err := c.Database.QueryResourceByUID(context.Request.Context(), kind, apiVersion, namespace, uid, asOf)
 	if err != nil {
 		abort.Abort(context, err, http.StatusInternalServerError)
 		return
 	}
 
 	if resource == nil {
-		abort.Abort(context, errors.New("resource not found"), http.StatusNotFound)
+		abort.Abort(context, notFoundError(context, uid), http.StatusNotFound)
 		return
 	}
 
+	if tableConverter != nil {
+		writeTable(context, tableConverter, []string{resource.Data}, "")
+		return
+	}
 	context.String(http.StatusOK, resource.Data)
 }
 
+// newTableConverter returns the converter used to build the response when the client asked for
+// a Table through the Accept header, nil otherwise
+func newTableConverter(context *gin.Context, group, kind string) (*tables.Converter, error) {
+	if !tables.IsTableRequest(context.GetHeader("Accept")) {
+		return nil, nil //nolint:nilnil // This is intentional - no converter means no Table requested
+	}
+
+	includeObject, err := tables.ParseIncludeObject(context.Query("includeObject"))
+	if err != nil {
+		return nil, err
+	}
+	return tables.NewConverter(group, kind, discovery.GetPrinterColumnsFromContext(context), includeObject)
+}
+
+// notFoundError returns the error the Kubernetes API returns when the resource with name, or uid, of
+// the resource type in the path is not found
+func notFoundError(context *gin.Context, name string) error {
+	return apierrors.NewNotFound(
+		schema.GroupResource{Group: context.Param("group"), Resource: context.Param("resourceType")}, name)
+}
+
+// writeTable responds with a Table containing a row for each resource
+func writeTable(context *gin.Context, converter *tables.Converter, resources []string, continueToken string) {
+	table, err := converter.ConvertToTable(resources, continueToken)
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+	context.JSON(http.StatusOK, table)
+}
+
 // Livez returns current server configuration as we don't have a clear deadlock indicator
 func (c *Controller) Livez(context *gin.Context) {
 	observabilityConfig := observability.Status()
@@ -12,12 +12,14 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kubearchive/kubearchive/cmd/api/pagination"
 	"github.com/kubearchive/kubearchive/pkg/database/fake"
 	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -32,6 +34,10 @@ type List struct {
 }
 
 func retrieveLogURL(c *gin.Context) {
+	if logURLs, exists := c.Get("logURLs"); exists {
+		c.JSON(http.StatusOK, logURLs)
+		return
+	}
 	c.JSON(http.StatusOK, fmt.Sprintf("%s-%s", c.GetString("logURL"), c.GetString("jsonPath")))
 }
 
@@ -51,15 +57,28 @@ func setupRouter(db interfaces.DBReader, core bool) *gin.Engine {
 	router.GET("/apis/:group/:version/:resourceType", ctrl.GetResources)
 	router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType", ctrl.GetResources)
 	router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType/:name", ctrl.GetResources)
+	router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType/:name/tree", ctrl.GetResourceTree)
 	router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType/uid/:uid", ctrl.GetResourceByUID)
+	router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType/uid/:uid/tree", ctrl.GetResourceTree)
+	router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType/uid/:uid/history", ctrl.GetResourceHistory)
+	router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType/uid/:uid/history/:revision",
+		ctrl.GetResourceRevision)
 	router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType/:name/log",
 		ctrl.GetLogURL, retrieveLogURL)
 	router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType/uid/:uid/log",
 		ctrl.GetLogURL, retrieveLogURL)
+	router.GET("/apis/kubearchive.org/v1/stats", ctrl.GetStats)
+	router.GET("/apis/kubearchive.org/v1/namespaces/:namespace/stats", ctrl.GetStats)
 	router.GET("/api/:version/:resourceType", ctrl.GetResources)
 	router.GET("/api/:version/namespaces/:namespace/:resourceType", ctrl.GetResources)
+	router.GET("/api/:version/namespaces/:namespace/:resourceType/-/log", ctrl.SearchLogURLs, retrieveLogURL)
 	router.GET("/api/:version/namespaces/:namespace/:resourceType/:name", ctrl.GetResources)
+	router.GET("/api/:version/namespaces/:namespace/:resourceType/:name/tree", ctrl.GetResourceTree)
 	router.GET("/api/:version/namespaces/:namespace/:resourceType/uid/:uid", ctrl.GetResourceByUID)
+	router.GET("/api/:version/namespaces/:namespace/:resourceType/uid/:uid/tree", ctrl.GetResourceTree)
+	router.GET("/api/:version/namespaces/:namespace/:resourceType/uid/:uid/history", ctrl.GetResourceHistory)
+	router.GET("/api/:version/namespaces/:namespace/:resourceType/uid/:uid/history/:revision",
+		ctrl.GetResourceRevision)
 	router.GET("/api/:version/namespaces/:namespace/:resourceType/:name/log",
 		ctrl.GetLogURL, retrieveLogURL)
 	router.GET("/api/:version/namespaces/:namespace/:resourceType/uid/:uid/log",
@@ -149,6 +168,73 @@ func TestLabelSelectorQueryParameter(t *testing.T) {
 	}
 }
 
+func TestFieldSelectorQueryParameter(t *testing.T) {
+	router := setupRouter(fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath), false)
+	tests := []struct {
+		name          string
+		fieldSelector string
+		expected      int
+	}{
+		{
+			name:          "empty field selector",
+			fieldSelector: "",
+			expected:      200,
+		},
+		{
+			name:          "valid equals operator",
+			fieldSelector: "status.phase=Failed",
+			expected:      200,
+		},
+		{
+			name:          "valid double equals operator",
+			fieldSelector: "status.phase==Failed",
+			expected:      200,
+		},
+		{
+			name:          "valid not equals operator",
+			fieldSelector: "status.phase!=Succeeded",
+			expected:      200,
+		},
+		{
+			name:          "valid empty value",
+			fieldSelector: "spec.nodeName=",
+			expected:      200,
+		},
+		{
+			name:          "multiple requirements",
+			fieldSelector: "status.phase=Failed,spec.nodeName=worker-3",
+			expected:      200,
+		},
+		{
+			name:          "invalid operator",
+			fieldSelector: "status.phase>Failed",
+			expected:      400,
+		},
+		{
+			name:          "invalid field path",
+			fieldSelector: "status..phase=Failed",
+			expected:      400,
+		},
+		{
+			name:          "invalid characters in field path",
+			fieldSelector: "status.phase'=Failed",
+			expected:      400,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(
+				http.MethodGet,
+				fmt.Sprintf("/apis/batch/v1/namespaces/ns/cronjobs?fieldSelector=%s", url.QueryEscape(tt.fieldSelector)),
+				nil,
+			)
+			router.ServeHTTP(res, req)
+			assert.Equal(t, tt.expected, res.Code)
+		})
+	}
+}
+
 func TestGetResourcesLogURLS(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -176,7 +262,8 @@ func TestGetResourcesLogURLS(t *testing.T) {
 			api:          "/apis//v1/namespaces/ns/pods/my-pod/log",
 			isCore:       true,
 			expectedCode: http.StatusNotFound,
-			expectedBody: "{\"message\":\"Not Found\"}",
+			expectedBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"Not Found",` +
+				`"reason":"NotFound","details":{"name":"my-pod","kind":"pods"},"code":404}`,
 		},
 		{
 			name:         "my-pod-uid",
@@ -197,7 +284,8 @@ func TestGetResourcesLogURLS(t *testing.T) {
 			api:          "/apis//v1/namespaces/ns/pods/uid/not-found/log",
 			isCore:       true,
 			expectedCode: http.StatusNotFound,
-			expectedBody: "{\"message\":\"Not Found\"}",
+			expectedBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure","message":"Not Found",` +
+				`"reason":"NotFound","details":{"kind":"pods","uid":"not-found"},"code":404}`,
 		},
 	}
 
@@ -214,6 +302,56 @@ func TestGetResourcesLogURLS(t *testing.T) {
 	}
 }
 
+func TestGetResourcesAllContainersLogURLs(t *testing.T) {
+	tests := []struct {
+		name         string
+		api          string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "all containers",
+			api:          "/api/v1/namespaces/ns/pods/my-pod/log?allContainers=true",
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"PodName":"my-pod","ContainerName":"container-1","Url":"fake.com","JsonPath":"$."},` +
+				`{"PodName":"my-pod","ContainerName":"container-2","Url":"fake.org","JsonPath":"$."},` +
+				`{"PodName":"my-pod","ContainerName":"foo","Url":"fake.org","JsonPath":"$."}]`,
+		},
+		{
+			name:         "all containers disabled",
+			api:          "/api/v1/namespaces/ns/pods/my-pod/log?allContainers=false",
+			expectedCode: http.StatusOK,
+			expectedBody: fmt.Sprintf("\"%s-%s\"", testLogUrls[0].Url, testLogJsonPath),
+		},
+		{
+			name:         "invalid allContainers",
+			api:          "/api/v1/namespaces/ns/pods/my-pod/log?allContainers=maybe",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure",` +
+				`"message":"allContainers must be a boolean, got 'maybe'","reason":"BadRequest","details":{"name":"my-pod","kind":"pods"},"code":400}`,
+		},
+		{
+			name:         "container and allContainers",
+			api:          "/api/v1/namespaces/ns/pods/my-pod/log?allContainers=true&container=foo",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"kind":"Status","apiVersion":"v1","metadata":{},"status":"Failure",` +
+				`"message":"container and allContainers can not be used together","reason":"BadRequest","details":{"name":"my-pod","kind":"pods"},"code":400}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := setupRouter(fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath), true)
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, test.api, nil)
+			router.ServeHTTP(res, req)
+
+			assert.Equal(t, test.expectedCode, res.Code)
+			assert.Equal(t, test.expectedBody, res.Body.String())
+		})
+	}
+}
+
 func TestGetResources(t *testing.T) {
 	tests := []struct {
 		name              string
@@ -283,6 +421,80 @@ func TestGetResources(t *testing.T) {
 	}
 }
 
+func TestGetResourcesAsTable(t *testing.T) {
+	tests := []struct {
+		name            string
+		api             string
+		isCore          bool
+		expectedCode    int
+		expectedColumns []string
+		expectedRows    int
+	}{
+		{
+			name:            "crontabs",
+			api:             "/apis/stable.example.com/v1/namespaces/test/crontabs",
+			expectedCode:    http.StatusOK,
+			expectedColumns: []string{"Name", "Age"},
+			expectedRows:    len(nonCoreResources),
+		},
+		{
+			name:            "pods",
+			api:             "/api/v1/namespaces/test/pods?includeObject=Object",
+			isCore:          true,
+			expectedCode:    http.StatusOK,
+			expectedColumns: []string{"Name", "Ready", "Status", "Restarts", "Age", "IP", "Node"},
+			expectedRows:    len(coreResources),
+		},
+		{
+			name:            "pod by name",
+			api:             "/api/v1/namespaces/test/pods/test",
+			isCore:          true,
+			expectedCode:    http.StatusOK,
+			expectedColumns: []string{"Name", "Ready", "Status", "Restarts", "Age", "IP", "Node"},
+			expectedRows:    1,
+		},
+		{
+			name:            "pod by uid",
+			api:             fmt.Sprintf("/api/v1/namespaces/test/pods/uid/%s", coreResources[0].GetUID()),
+			isCore:          true,
+			expectedCode:    http.StatusOK,
+			expectedColumns: []string{"Name", "Ready", "Status", "Restarts", "Age", "IP", "Node"},
+			expectedRows:    1,
+		},
+		{
+			name:         "invalid includeObject",
+			api:          "/api/v1/namespaces/test/pods?includeObject=All",
+			isCore:       true,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := setupRouter(fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath), test.isCore)
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, test.api, nil)
+			req.Header.Set("Accept", "application/json;as=Table;v=v1;g=meta.k8s.io,application/json")
+			router.ServeHTTP(res, req)
+
+			assert.Equal(t, test.expectedCode, res.Code)
+			if test.expectedCode != http.StatusOK {
+				return
+			}
+
+			var table metav1.Table
+			assert.NoError(t, json.NewDecoder(res.Body).Decode(&table))
+			assert.Equal(t, "Table", table.Kind)
+			columns := []string{}
+			for _, column := range table.ColumnDefinitions {
+				columns = append(columns, column.Name)
+			}
+			assert.Equal(t, test.expectedColumns, columns)
+			assert.Len(t, table.Rows, test.expectedRows)
+		})
+	}
+}
+
 func TestGetResourceByUID(t *testing.T) {
 	nonCoreResourceBytes, _ := json.Marshal(nonCoreResources[0])
 	coreResourceBytes, _ := json.Marshal(coreResources[0])
@@ -773,3 +985,100 @@ func TestTimestampValidationErrorMessages(t *testing.T) {
 		})
 	}
 }
+
+func TestGetResourcesAsOf(t *testing.T) {
+	deletedPod := &unstructured.Unstructured{}
+	deletedPod.SetKind("Pod")
+	deletedPod.SetAPIVersion("v1")
+	deletedPod.SetName("deleted")
+	deletedPod.SetNamespace("test")
+	deletedPod.SetUID("9d2d3c8a-0e0b-4b8f-a2b7-3e4f8f1c6f10")
+	deletedPod.SetCreationTimestamp(metav1.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	deletionTimestamp := metav1.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	deletedPod.SetDeletionTimestamp(&deletionTimestamp)
+
+	newPod := &unstructured.Unstructured{}
+	newPod.SetKind("Pod")
+	newPod.SetAPIVersion("v1")
+	newPod.SetName("new")
+	newPod.SetNamespace("test")
+	newPod.SetUID("0c6f6d3e-7b8a-4a34-9f0e-5d1b2c3a4e20")
+	newPod.SetCreationTimestamp(metav1.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC))
+
+	router := setupRouter(fake.NewFakeDatabase(
+		[]*unstructured.Unstructured{deletedPod, newPod}, testLogUrls, testLogJsonPath), true)
+
+	tests := []struct {
+		name          string
+		asOf          string
+		expectedCode  int
+		expectedNames []string
+	}{
+		{
+			name:          "before the deletion",
+			asOf:          "2025-01-01T12:00:00Z",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{"deleted"},
+		},
+		{
+			name:          "between the deletion and the creation",
+			asOf:          "2025-01-02T12:00:00+02:00",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{},
+		},
+		{
+			name:          "after the creation",
+			asOf:          "2025-01-04T00:00:00Z",
+			expectedCode:  http.StatusOK,
+			expectedNames: []string{"new"},
+		},
+		{
+			name:         "invalid asOf",
+			asOf:         "2025-01-04",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet,
+				"/api/v1/namespaces/test/pods?asOf="+url.QueryEscape(tt.asOf), nil)
+			router.ServeHTTP(res, req)
+
+			assert.Equal(t, tt.expectedCode, res.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			var list List
+			assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &list))
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.GetName())
+			}
+			assert.Equal(t, tt.expectedNames, names)
+		})
+	}
+
+	t.Run("by uid", func(t *testing.T) {
+		for asOf, expectedCode := range map[string]int{
+			"2025-01-01T12:00:00Z": http.StatusOK,
+			"2025-01-02T12:00:00Z": http.StatusNotFound,
+		} {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/namespaces/test/pods/uid/%s?asOf=%s",
+				deletedPod.GetUID(), asOf), nil)
+			router.ServeHTTP(res, req)
+			assert.Equal(t, expectedCode, res.Code, asOf)
+		}
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet,
+			"/api/v1/namespaces/test/pods?watch=true&asOf=2025-01-01T12:00:00Z", nil)
+		router.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Contains(t, res.Body.String(), "asOf is not supported when watching")
+	})
+}
@@ -0,0 +1,82 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/pkg/abort"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// resourceStats is the response of the stats endpoint
+type resourceStats struct {
+	Namespace    string                 `json:"namespace,omitempty"`
+	GroupByLabel string                 `json:"groupByLabel,omitempty"`
+	Total        int64                  `json:"total"`
+	Items        []models.ResourceCount `json:"items"`
+}
+
+// GetStats returns the number of archived resources grouped by kind, apiVersion and namespace and,
+// optionally, by the value of the label in the `groupByLabel` parameter
+func (c *Controller) GetStats(context *gin.Context) {
+	namespace := context.Param("namespace")
+
+	groupByLabel := context.Query("groupByLabel")
+	if groupByLabel != "" {
+		if errs := validation.IsQualifiedName(groupByLabel); len(errs) > 0 {
+			abort.Abort(context, fmt.Errorf("invalid groupByLabel %s: %s", groupByLabel, strings.Join(errs, "; ")),
+				http.StatusBadRequest)
+			return
+		}
+	}
+
+	selector, err := labels.Parse(context.Query("labelSelector"))
+	if err != nil {
+		abort.Abort(context, err, http.StatusBadRequest)
+		return
+	}
+	reqs, _ := selector.Requirements()
+	labelFilters, err := models.NewLabelFilters(reqs)
+	if err != nil {
+		abort.Abort(context, err, http.StatusBadRequest)
+		return
+	}
+
+	creationTimestampAfter, err := parseTimestampQuery(context, "creationTimestampAfter")
+	if err != nil {
+		abort.Abort(context, err, http.StatusBadRequest)
+		return
+	}
+	creationTimestampBefore, err := parseTimestampQuery(context, "creationTimestampBefore")
+	if err != nil {
+		abort.Abort(context, err, http.StatusBadRequest)
+		return
+	}
+	if creationTimestampAfter != nil && creationTimestampBefore != nil &&
+		!creationTimestampBefore.After(*creationTimestampAfter) {
+		abort.Abort(context, errors.New("creationTimestampBefore must be after creationTimestampAfter"), http.StatusBadRequest)
+		return
+	}
+
+	counts, err := c.Database.QueryResourceCounts(context.Request.Context(), namespace, groupByLabel, labelFilters,
+		creationTimestampAfter, creationTimestampBefore)
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+
+	stats := resourceStats{Namespace: namespace, GroupByLabel: groupByLabel, Items: []models.ResourceCount{}}
+	for _, count := range counts {
+		stats.Total += count.Count
+		stats.Items = append(stats.Items, count)
+	}
+	context.JSON(http.StatusOK, stats)
+}
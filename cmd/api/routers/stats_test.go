@@ -0,0 +1,147 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGetStats(t *testing.T) {
+	newResource := func(kind, apiVersion, namespace, name string, labels map[string]string) *unstructured.Unstructured {
+		resource := &unstructured.Unstructured{}
+		resource.SetKind(kind)
+		resource.SetAPIVersion(apiVersion)
+		resource.SetNamespace(namespace)
+		resource.SetName(name)
+		resource.SetLabels(labels)
+		return resource
+	}
+	resources := []*unstructured.Unstructured{
+		newResource("Pod", "v1", "team-a", "pod-1", map[string]string{"app": "frontend"}),
+		newResource("Pod", "v1", "team-a", "pod-2", map[string]string{"app": "backend"}),
+		newResource("Pod", "v1", "team-a", "pod-3", nil),
+		newResource("Job", "batch/v1", "team-a", "job-1", map[string]string{"app": "frontend"}),
+		newResource("Pod", "v1", "team-b", "pod-1", map[string]string{"app": "frontend"}),
+	}
+
+	tests := []struct {
+		name          string
+		database      interfaces.DBReader
+		endpoint      string
+		expectedCode  int
+		expectedTotal int64
+		expectedItems int
+	}{
+		{
+			name:          "cluster stats",
+			database:      fake.NewFakeDatabase(resources, testLogUrls, testLogJsonPath),
+			endpoint:      "/apis/kubearchive.org/v1/stats",
+			expectedCode:  http.StatusOK,
+			expectedTotal: 5,
+			expectedItems: 3,
+		},
+		{
+			name:          "namespaced stats",
+			database:      fake.NewFakeDatabase(resources, testLogUrls, testLogJsonPath),
+			endpoint:      "/apis/kubearchive.org/v1/namespaces/team-a/stats",
+			expectedCode:  http.StatusOK,
+			expectedTotal: 4,
+			expectedItems: 2,
+		},
+		{
+			name:          "namespaced stats grouped by label",
+			database:      fake.NewFakeDatabase(resources, testLogUrls, testLogJsonPath),
+			endpoint:      "/apis/kubearchive.org/v1/namespaces/team-a/stats?groupByLabel=app",
+			expectedCode:  http.StatusOK,
+			expectedTotal: 4,
+			expectedItems: 4,
+		},
+		{
+			name:          "no resources",
+			database:      fake.NewFakeDatabase(resources, testLogUrls, testLogJsonPath),
+			endpoint:      "/apis/kubearchive.org/v1/namespaces/team-c/stats",
+			expectedCode:  http.StatusOK,
+			expectedTotal: 0,
+			expectedItems: 0,
+		},
+		{
+			name:         "invalid label key",
+			database:     fake.NewFakeDatabase(resources, testLogUrls, testLogJsonPath),
+			endpoint:     "/apis/kubearchive.org/v1/stats?groupByLabel=app%20name",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid label selector",
+			database:     fake.NewFakeDatabase(resources, testLogUrls, testLogJsonPath),
+			endpoint:     "/apis/kubearchive.org/v1/stats?labelSelector=app>frontend",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid timestamp range",
+			database:     fake.NewFakeDatabase(resources, testLogUrls, testLogJsonPath),
+			endpoint:     "/apis/kubearchive.org/v1/stats?creationTimestampAfter=2025-01-02T00:00:00Z&creationTimestampBefore=2025-01-01T00:00:00Z",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "database error",
+			database:     fake.NewFakeDatabaseWithError(errors.New("test error")),
+			endpoint:     "/apis/kubearchive.org/v1/stats",
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter(tt.database, true)
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.endpoint, nil)
+			router.ServeHTTP(res, req)
+
+			assert.Equal(t, tt.expectedCode, res.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			var stats resourceStats
+			assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &stats))
+			assert.Equal(t, tt.expectedTotal, stats.Total)
+			assert.Len(t, stats.Items, tt.expectedItems)
+		})
+	}
+}
+
+func TestGetStatsGroupedByLabel(t *testing.T) {
+	resource := &unstructured.Unstructured{}
+	resource.SetKind("Pod")
+	resource.SetAPIVersion("v1")
+	resource.SetNamespace("team-a")
+	resource.SetLabels(map[string]string{"app": "frontend"})
+	unlabeled := resource.DeepCopy()
+	unlabeled.SetLabels(nil)
+
+	router := setupRouter(fake.NewFakeDatabase([]*unstructured.Unstructured{resource, unlabeled},
+		testLogUrls, testLogJsonPath), true)
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/apis/kubearchive.org/v1/namespaces/team-a/stats?groupByLabel=app", nil)
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{
+		"namespace": "team-a",
+		"groupByLabel": "app",
+		"total": 2,
+		"items": [
+			{"kind": "Pod", "apiVersion": "v1", "namespace": "team-a", "labelValue": "frontend", "count": 1},
+			{"kind": "Pod", "apiVersion": "v1", "namespace": "team-a", "count": 1}
+		]
+	}`, res.Body.String())
+}
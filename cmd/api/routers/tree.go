@@ -0,0 +1,182 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/cmd/api/discovery"
+	"github.com/kubearchive/kubearchive/pkg/abort"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	// maxTreeDepth is the maximum number of levels of owned resources returned in a tree
+	maxTreeDepth = 10
+	// maxTreeNodes is the maximum number of resources returned in a tree, the root included
+	maxTreeNodes = 1000
+)
+
+// GetResourceTree returns the archived resource identified by name or uid along with all the
+// archived resources it owns, directly or through other resources (e.g. Deployment -> ReplicaSets -> Pods)
+func (c *Controller) GetResourceTree(context *gin.Context) {
+	kind, err := discovery.GetAPIResourceKind(context)
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+
+	group := context.Param("group")
+	version := context.Param("version")
+	namespace := context.Param("namespace")
+	name := context.Param("name")
+	uid := context.Param("uid")
+
+	if strings.HasPrefix(context.Request.URL.Path, "/apis/") && group == "" {
+		abort.Abort(context, errors.New(http.StatusText(http.StatusNotFound)), http.StatusNotFound)
+		return
+	}
+
+	apiVersion := version
+	if group != "" {
+		apiVersion = fmt.Sprintf("%s/%s", group, version)
+	}
+
+	var data string
+	if uid != "" {
+		resource, queryErr := c.Database.QueryResourceByUID(context.Request.Context(), kind, apiVersion, namespace, uid, nil)
+		if queryErr != nil {
+			abort.Abort(context, queryErr, http.StatusInternalServerError)
+			return
+		}
+		if resource == nil {
+			abort.Abort(context, notFoundError(context, uid), http.StatusNotFound)
+			return
+		}
+		data = resource.Data
+	} else {
+		resources, queryErr := c.Database.QueryResources(context.Request.Context(), kind, apiVersion, namespace, name,
+			"", "", nil, nil, nil, nil, nil, 2)
+		if queryErr != nil {
+			abort.Abort(context, queryErr, http.StatusInternalServerError)
+			return
+		}
+		if len(resources) == 0 {
+			abort.Abort(context, notFoundError(context, name), http.StatusNotFound)
+			return
+		} else if len(resources) > 1 {
+			abort.Abort(context, errors.New("more than one resource found"), http.StatusInternalServerError)
+			return
+		}
+		data = resources[0].Data
+	}
+
+	root, err := newResourceNode(data)
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err = c.addOwnedResources(context, root); err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+
+	context.JSON(http.StatusOK, root)
+}
+
+// addOwnedResources walks the ownerReferences level by level, so the database is queried once per level
+// of the tree. Resources already in the tree are skipped to protect against ownership cycles, and so are
+// the resources of kinds the user can not list, along with the resources they own. The tree is truncated
+// to maxTreeDepth levels and maxTreeNodes resources.
+func (c *Controller) addOwnedResources(context *gin.Context, root *models.ResourceNode) error {
+	nodes := map[string]*models.ResourceNode{root.UID: root}
+	allowedKinds := map[string]bool{}
+	owners := []string{root.UID}
+	for depth := 1; len(owners) > 0; depth++ {
+		resources, err := c.Database.QueryOwnedResources(context.Request.Context(), owners)
+		if err != nil {
+			return err
+		}
+		if depth > maxTreeDepth {
+			root.Truncated = len(resources) > 0
+			break
+		}
+
+		owners = []string{}
+		for _, resource := range resources {
+			object := &unstructured.Unstructured{}
+			if err = object.UnmarshalJSON([]byte(resource.Data)); err != nil {
+				return fmt.Errorf("unable to deserialize resource %s: %w", resource.Uuid, err)
+			}
+			if _, found := nodes[string(object.GetUID())]; found {
+				continue
+			}
+			allowed, allowedErr := c.canListKind(context, allowedKinds, object)
+			if allowedErr != nil {
+				return allowedErr
+			}
+			if !allowed {
+				continue
+			}
+			if len(nodes) >= maxTreeNodes {
+				root.Truncated = true
+				owners = []string{}
+				break
+			}
+
+			node := models.NewResourceNode(object)
+			nodes[node.UID] = node
+			owners = append(owners, node.UID)
+			for _, ownerReference := range object.GetOwnerReferences() {
+				if owner, found := nodes[string(ownerReference.UID)]; found && owner != node {
+					owner.Children = append(owner.Children, node)
+				}
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		slices.SortFunc(node.Children, func(a, b *models.ResourceNode) int {
+			if a.Kind != b.Kind {
+				return strings.Compare(a.Kind, b.Kind)
+			}
+			return strings.Compare(a.Name, b.Name)
+		})
+	}
+	return nil
+}
+
+// canListKind returns whether the user can list the resources of the kind of the object in its namespace,
+// the answers are kept in allowedKinds so each kind is checked once
+func (c *Controller) canListKind(context *gin.Context, allowedKinds map[string]bool,
+	object *unstructured.Unstructured) (bool, error) {
+	if c.KindAuthorizer == nil {
+		return true, nil
+	}
+	key := strings.Join([]string{object.GetAPIVersion(), object.GetKind(), object.GetNamespace()}, "/")
+	if allowed, found := allowedKinds[key]; found {
+		return allowed, nil
+	}
+	allowed, err := c.KindAuthorizer.CanList(context, object.GetAPIVersion(), object.GetKind(), object.GetNamespace())
+	if err != nil {
+		return false, err
+	}
+	allowedKinds[key] = allowed
+	return allowed, nil
+}
+
+func newResourceNode(data string) (*models.ResourceNode, error) {
+	object := &unstructured.Unstructured{}
+	if err := object.UnmarshalJSON([]byte(data)); err != nil {
+		return nil, fmt.Errorf("unable to deserialize resource: %w", err)
+	}
+	return models.NewResourceNode(object), nil
+}
@@ -0,0 +1,212 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newOwnedResource(kind, apiVersion, name, uid string, owners ...*unstructured.Unstructured) *unstructured.Unstructured {
+	resource := &unstructured.Unstructured{}
+	resource.SetKind(kind)
+	resource.SetAPIVersion(apiVersion)
+	resource.SetName(name)
+	resource.SetNamespace("test")
+	resource.SetUID(types.UID(uid))
+	ownerReferences := []metav1.OwnerReference{}
+	for _, owner := range owners {
+		ownerReferences = append(ownerReferences, metav1.OwnerReference{
+			Kind: owner.GetKind(), APIVersion: owner.GetAPIVersion(), Name: owner.GetName(), UID: owner.GetUID()})
+	}
+	resource.SetOwnerReferences(ownerReferences)
+	return resource
+}
+
+func TestGetResourceTree(t *testing.T) {
+	deleted := metav1.NewTime(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	crontab := newOwnedResource("Crontab", "stable.example.com/v1", "tree", "crontab-uid")
+	jobA := newOwnedResource("Job", "batch/v1", "tree-a", "job-a-uid", crontab)
+	jobB := newOwnedResource("Job", "batch/v1", "tree-b", "job-b-uid", crontab)
+	jobB.SetDeletionTimestamp(&deleted)
+	pod := newOwnedResource("Pod", "v1", "tree-a-pod", "pod-uid", jobA)
+	// Ownership cycles must not make the walk loop forever
+	cycle := newOwnedResource("Pod", "v1", "cycle", "cycle-uid", pod)
+	cycle.SetOwnerReferences(append(cycle.GetOwnerReferences(), metav1.OwnerReference{UID: "cycle-uid"}))
+	unrelated := newOwnedResource("Pod", "v1", "unrelated", "unrelated-uid")
+
+	router := setupRouter(fake.NewFakeDatabase(
+		[]*unstructured.Unstructured{crontab, jobB, pod, jobA, cycle, unrelated}, testLogUrls, testLogJsonPath), false)
+
+	for _, endpoint := range []string{
+		"/apis/stable.example.com/v1/namespaces/test/crontabs/tree/tree",
+		"/apis/stable.example.com/v1/namespaces/test/crontabs/uid/crontab-uid/tree",
+	} {
+		t.Run(endpoint, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, endpoint, nil)
+			router.ServeHTTP(res, req)
+			assert.Equal(t, http.StatusOK, res.Code)
+
+			var root models.ResourceNode
+			assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &root))
+			assert.Equal(t, "Crontab", root.Kind)
+			assert.Equal(t, "crontab-uid", root.UID)
+			if assert.Len(t, root.Children, 2) {
+				assert.Equal(t, "tree-a", root.Children[0].Name)
+				assert.Nil(t, root.Children[0].DeletionTimestamp)
+				assert.Equal(t, "tree-b", root.Children[1].Name)
+				assert.Equal(t, deleted.Unix(), root.Children[1].DeletionTimestamp.Unix())
+				assert.Empty(t, root.Children[1].Children)
+				if assert.Len(t, root.Children[0].Children, 1) {
+					podNode := root.Children[0].Children[0]
+					assert.Equal(t, "Pod", podNode.Kind)
+					assert.Equal(t, "pod-uid", podNode.UID)
+					if assert.Len(t, podNode.Children, 1) {
+						assert.Equal(t, "cycle", podNode.Children[0].Name)
+						assert.Empty(t, podNode.Children[0].Children)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGetResourceTreeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		database interfaces.DBReader
+		endpoint string
+		expected int
+	}{
+		{
+			name:     "not found by name",
+			database: fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint: "/apis/stable.example.com/v1/namespaces/test/crontabs/notfound/tree",
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "not found by uid",
+			database: fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath),
+			endpoint: "/apis/stable.example.com/v1/namespaces/test/crontabs/uid/notfound/tree",
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "database error",
+			database: fake.NewFakeDatabaseWithError(errors.New("test error")),
+			endpoint: "/apis/stable.example.com/v1/namespaces/test/crontabs/test/tree",
+			expected: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter(tt.database, false)
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.endpoint, nil)
+			router.ServeHTTP(res, req)
+			assert.Equal(t, tt.expected, res.Code)
+		})
+	}
+}
+
+// fakeKindAuthorizer allows listing every kind except the denied ones
+type fakeKindAuthorizer struct {
+	denied []string
+	err    error
+	calls  int
+}
+
+func (f *fakeKindAuthorizer) CanList(_ *gin.Context, _, kind, _ string) (bool, error) {
+	f.calls++
+	return !slices.Contains(f.denied, kind), f.err
+}
+
+func getTree(t *testing.T, ctrl *Controller, endpoint string) (int, models.ResourceNode) {
+	t.Helper()
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("apiResourceKind", "Crontab") })
+	router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType/uid/:uid/tree", ctrl.GetResourceTree)
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, endpoint, nil))
+	var root models.ResourceNode
+	if res.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &root))
+	}
+	return res.Code, root
+}
+
+func TestGetResourceTreeDeniedKinds(t *testing.T) {
+	crontab := newOwnedResource("Crontab", "stable.example.com/v1", "tree", "crontab-uid")
+	jobA := newOwnedResource("Job", "batch/v1", "tree-a", "job-a-uid", crontab)
+	jobB := newOwnedResource("Job", "batch/v1", "tree-b", "job-b-uid", crontab)
+	podA := newOwnedResource("Pod", "v1", "tree-a-pod", "pod-a-uid", jobA)
+	podB := newOwnedResource("Pod", "v1", "tree-b-pod", "pod-b-uid", jobB)
+	// The resources owned by resources of a denied kind are not returned either
+	owned := newOwnedResource("ConfigMap", "v1", "owned", "owned-uid", podA)
+	database := fake.NewFakeDatabase(
+		[]*unstructured.Unstructured{crontab, jobA, jobB, podA, podB, owned}, testLogUrls, testLogJsonPath)
+	endpoint := "/apis/stable.example.com/v1/namespaces/test/crontabs/uid/crontab-uid/tree"
+
+	authorizer := &fakeKindAuthorizer{denied: []string{"Pod"}}
+	status, root := getTree(t, &Controller{Database: database, KindAuthorizer: authorizer}, endpoint)
+	assert.Equal(t, http.StatusOK, status)
+	if assert.Len(t, root.Children, 2) {
+		assert.Empty(t, root.Children[0].Children)
+		assert.Empty(t, root.Children[1].Children)
+	}
+	// Each kind is checked once
+	assert.Equal(t, 2, authorizer.calls)
+
+	status, _ = getTree(t, &Controller{Database: database,
+		KindAuthorizer: &fakeKindAuthorizer{err: errors.New("sar error")}}, endpoint)
+	assert.Equal(t, http.StatusInternalServerError, status)
+}
+
+func TestGetResourceTreeTruncated(t *testing.T) {
+	crontab := newOwnedResource("Crontab", "stable.example.com/v1", "tree", "crontab-uid")
+	jobA := newOwnedResource("Job", "batch/v1", "tree-a", "job-a-uid", crontab)
+	jobB := newOwnedResource("Job", "batch/v1", "tree-b", "job-b-uid", crontab)
+	pod := newOwnedResource("Pod", "v1", "tree-a-pod", "pod-uid", jobA)
+	ctrl := &Controller{Database: fake.NewFakeDatabase(
+		[]*unstructured.Unstructured{crontab, jobA, jobB, pod}, testLogUrls, testLogJsonPath)}
+	endpoint := "/apis/stable.example.com/v1/namespaces/test/crontabs/uid/crontab-uid/tree"
+
+	defer func(depth, nodes int) { maxTreeDepth, maxTreeNodes = depth, nodes }(maxTreeDepth, maxTreeNodes)
+	tests := []struct {
+		name      string
+		depth     int
+		nodes     int
+		children  int
+		truncated bool
+	}{
+		{name: "within the limits", depth: 2, nodes: 4, children: 2, truncated: false},
+		{name: "depth", depth: 1, nodes: 4, children: 2, truncated: true},
+		{name: "nodes", depth: 2, nodes: 2, children: 1, truncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxTreeDepth, maxTreeNodes = tt.depth, tt.nodes
+			status, root := getTree(t, ctrl, endpoint)
+			assert.Equal(t, http.StatusOK, status)
+			assert.Len(t, root.Children, tt.children)
+			assert.Equal(t, tt.truncated, root.Truncated)
+		})
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/cel-go/cel"
+	"github.com/kubearchive/kubearchive/cmd/api/tables"
+	"github.com/kubearchive/kubearchive/pkg/abort"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	defaultWatchPollInterval = 5 * time.Second
+	defaultWatchTimeout      = 30 * time.Minute
+)
+
+// watchBatchSize is the maximum number of changes retrieved from the database on each query,
+// when a query returns this amount of changes another one is done without waiting
+var watchBatchSize = 1000
+
+// watchOverlap is how far before the last change sent each poll starts. The time of a change is set
+// when its transaction starts, so a transaction that commits later than others that started after it
+// is only found when its changes are read again
+var watchOverlap = time.Minute
+
+// watchChange identifies a change already sent, a resource that changes again gets a new update time
+type watchChange struct {
+	id        int64
+	updatedAt time.Time
+}
+
+// watchCursor keeps the time of the last change sent and the changes sent since watchOverlap before it,
+// so the changes read again by the next poll are not sent twice
+type watchCursor struct {
+	changedAfter time.Time
+	sent         map[watchChange]struct{}
+}
+
+func newWatchCursor(watchStart time.Time) *watchCursor {
+	return &watchCursor{changedAfter: watchStart, sent: map[watchChange]struct{}{}}
+}
+
+// pollStart returns the time the next poll starts at and forgets the changes before it
+func (w *watchCursor) pollStart() time.Time {
+	start := w.changedAfter.Add(-watchOverlap)
+	for change := range w.sent {
+		if change.updatedAt.Before(start) {
+			delete(w.sent, change)
+		}
+	}
+	return start
+}
+
+// add records a change and returns false when it was already sent
+func (w *watchCursor) add(id int64, updatedAt time.Time) bool {
+	change := watchChange{id: id, updatedAt: updatedAt}
+	if _, ok := w.sent[change]; ok {
+		return false
+	}
+	w.sent[change] = struct{}{}
+	if updatedAt.After(w.changedAfter) {
+		w.changedAfter = updatedAt
+	}
+	return true
+}
+
+// watchEvent mirrors the Kubernetes watch event (k8s.io/apimachinery/pkg/apis/meta/v1.WatchEvent)
+// but keeps the object as it is stored in the database, so it is not serialized again
+type watchEvent struct {
+	Type   watch.EventType `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// watchFilters contains the filters already parsed by GetResources that are applied to the watch
+type watchFilters struct {
+	kind                    string
+	apiVersion              string
+	namespace               string
+	name                    string
+	labelFilters            *models.LabelFilters
+	fieldFilters            *models.FieldFilters
+	creationTimestampAfter  *time.Time
+	creationTimestampBefore *time.Time
+	// celFilter is set when the client sent a CEL expression, only changes of resources that pass it are sent
+	celFilter *cel.Program
+	// tableConverter is set when the client asked for a Table, each event then contains a Table with one row
+	tableConverter *tables.Converter
+}
+
+// isWatchRequest returns true when the `watch` query parameter is set to a true value
+func isWatchRequest(context *gin.Context) (bool, error) {
+	value := context.Query("watch")
+	if value == "" {
+		return false, nil
+	}
+
+	isWatch, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid watch value: %s. Expected a boolean (e.g., watch=true)", value)
+	}
+	return isWatch, nil
+}
+
+// parseWatchTimeout returns the duration of the watch based on the `timeoutSeconds` query parameter
+func parseWatchTimeout(context *gin.Context) (time.Duration, error) {
+	value := context.Query("timeoutSeconds")
+	if value == "" {
+		return defaultWatchTimeout, nil
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("invalid timeoutSeconds value: %s. Expected a positive integer", value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// watchResources streams the resources archived, updated or deleted after the watch started
+// as Kubernetes watch events. The database is polled for changes every WatchPollInterval until
+// the client disconnects or the watch times out.
+func (c *Controller) watchResources(context *gin.Context, filters watchFilters) {
+	timeout, err := parseWatchTimeout(context)
+	if err != nil {
+		abort.Abort(context, err, http.StatusBadRequest)
+		return
+	}
+
+	pollInterval := c.WatchPollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultWatchPollInterval
+	}
+
+	ctx := context.Request.Context()
+	// Only changes that happen after the watch starts are sent, the current state of the archive is retrieved
+	// using a regular list request. The clock of the database is used, as it sets the time of the changes
+	watchStart, err := c.Database.QueryCurrentTime(ctx)
+	if err != nil {
+		abort.Abort(context, err, http.StatusInternalServerError)
+		return
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	cursor := newWatchCursor(watchStart)
+
+	context.Header("Content-Type", "application/json")
+	context.Status(http.StatusOK)
+	context.Writer.Flush()
+
+	encoder := json.NewEncoder(context.Writer)
+	for {
+		// The changes are paged by their time and the id of their resource, so the changes at the same time are not
+		// skipped when a query returns part of them
+		changedAfter := cursor.pollStart()
+		var changedAfterId int64
+		for {
+			changes, queryErr := c.Database.QueryResourceChanges(ctx, filters.kind, filters.apiVersion,
+				filters.namespace, filters.name, filters.labelFilters, filters.fieldFilters,
+				filters.creationTimestampAfter, filters.creationTimestampBefore, changedAfter, changedAfterId,
+				watchBatchSize)
+			if queryErr != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.ErrorContext(ctx, "could not retrieve changes for the watch", "error", queryErr.Error())
+				writeWatchError(context, encoder, queryErr)
+				return
+			}
+
+			for _, change := range changes {
+				event, updatedAt, eventErr := newWatchEvent(change, watchStart)
+				if eventErr != nil {
+					slog.ErrorContext(ctx, "could not create watch event", "error", eventErr.Error())
+					writeWatchError(context, encoder, eventErr)
+					return
+				}
+				changedAfter, changedAfterId = updatedAt, change.Id
+				if !cursor.add(change.Id, updatedAt) {
+					continue
+				}
+				if filters.celFilter != nil {
+					matched, matchErr := matchesCELFilter(ctx, filters.celFilter, change.Data)
+					if matchErr != nil {
+						slog.ErrorContext(ctx, "could not evaluate the watch filter", "error", matchErr.Error())
+						writeWatchError(context, encoder, matchErr)
+						return
+					}
+					if !matched {
+						continue
+					}
+				}
+				if filters.tableConverter != nil {
+					if event.Object, eventErr = tableWatchObject(filters.tableConverter, change.Data); eventErr != nil {
+						slog.ErrorContext(ctx, "could not create watch event table", "error", eventErr.Error())
+						writeWatchError(context, encoder, eventErr)
+						return
+					}
+				}
+				if encodeErr := encoder.Encode(event); encodeErr != nil {
+					slog.ErrorContext(ctx, "error writing watch event", "error", encodeErr.Error())
+					return
+				}
+			}
+			context.Writer.Flush()
+
+			if len(changes) < watchBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// newWatchEvent creates the watch event for a change and returns it along with the time of the change.
+// Resources archived after `watchStart` are new to the client, so they are reported as ADDED instead of MODIFIED
+func newWatchEvent(change models.ResourceChange, watchStart time.Time) (watchEvent, time.Time, error) {
+	updatedAt, err := time.Parse(time.RFC3339Nano, change.UpdatedAt)
+	if err != nil {
+		return watchEvent{}, time.Time{}, fmt.Errorf("invalid update timestamp '%s' for resource %s: %w", change.UpdatedAt, change.Uuid, err)
+	}
+
+	eventType := watch.Modified
+	if change.Deleted {
+		eventType = watch.Deleted
+	} else {
+		archivedAt, err := time.Parse(time.RFC3339Nano, change.ArchivedAt)
+		if err != nil {
+			return watchEvent{}, time.Time{}, fmt.Errorf("invalid archive timestamp '%s' for resource %s: %w", change.ArchivedAt, change.Uuid, err)
+		}
+		if archivedAt.After(watchStart) {
+			eventType = watch.Added
+		}
+	}
+
+	return watchEvent{Type: eventType, Object: json.RawMessage(change.Data)}, updatedAt, nil
+}
+
+// tableWatchObject returns the Table with a single row sent as the object of the event, the same way
+// the Kubernetes API server does for watch requests that ask for a Table
+func tableWatchObject(converter *tables.Converter, data string) (json.RawMessage, error) {
+	table, err := converter.ConvertToTable([]string{data}, "")
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(table)
+}
+
+// writeWatchError sends an ERROR event, the response status is already sent so this is
+// the only way to let the client know the watch ended because of an error
+func writeWatchError(context *gin.Context, encoder *json.Encoder, err error) {
+	status := abort.NewStatus(context, err, http.StatusInternalServerError)
+	statusBytes, marshalErr := json.Marshal(status)
+	if marshalErr != nil {
+		slog.ErrorContext(context.Request.Context(), "could not serialize watch error", "error", marshalErr.Error())
+		return
+	}
+
+	if encodeErr := encoder.Encode(watchEvent{Type: watch.Error, Object: statusBytes}); encodeErr != nil {
+		slog.ErrorContext(context.Request.Context(), "error writing watch error event", "error", encodeErr.Error())
+		return
+	}
+	context.Writer.Flush()
+}
@@ -0,0 +1,325 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestWatchResources(t *testing.T) {
+	future := metav1.NewTime(time.Now().Add(time.Hour))
+	past := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	added := &unstructured.Unstructured{}
+	added.SetKind("Crontab")
+	added.SetAPIVersion("stable.example.com/v1")
+	added.SetName("added")
+	added.SetNamespace("test")
+	added.SetCreationTimestamp(future)
+
+	deleted := added.DeepCopy()
+	deleted.SetName("deleted")
+	deleted.SetCreationTimestamp(past)
+	deleted.SetDeletionTimestamp(&future)
+
+	old := added.DeepCopy()
+	old.SetName("old")
+	old.SetCreationTimestamp(past)
+
+	router := setupRouter(fake.NewFakeDatabase(
+		[]*unstructured.Unstructured{added, deleted, old}, testLogUrls, testLogJsonPath), false)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet,
+		"/apis/stable.example.com/v1/namespaces/test/crontabs?watch=true&timeoutSeconds=1", nil)
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+
+	var events []watchEvent
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		var event watchEvent
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+
+	if assert.Len(t, events, 2) {
+		// Both changes happen at the same time, so their order is not guaranteed
+		names := map[watch.EventType]string{}
+		for _, event := range events {
+			var object unstructured.Unstructured
+			assert.NoError(t, object.UnmarshalJSON(event.Object))
+			names[event.Type] = object.GetName()
+		}
+		assert.Equal(t, map[watch.EventType]string{watch.Added: "added", watch.Deleted: "deleted"}, names)
+	}
+}
+
+func TestWatchResourcesWithCELFilter(t *testing.T) {
+	future := metav1.NewTime(time.Now().Add(time.Hour))
+
+	added := &unstructured.Unstructured{}
+	added.SetKind("Crontab")
+	added.SetAPIVersion("stable.example.com/v1")
+	added.SetName("added")
+	added.SetNamespace("test")
+	added.SetCreationTimestamp(future)
+
+	filtered := added.DeepCopy()
+	filtered.SetName("filtered")
+
+	router := setupRouter(fake.NewFakeDatabase(
+		[]*unstructured.Unstructured{added, filtered}, testLogUrls, testLogJsonPath), false)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet,
+		"/apis/stable.example.com/v1/namespaces/test/crontabs?watch=true&timeoutSeconds=1&filter="+
+			url.QueryEscape("metadata.name == 'added'"), nil)
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	var events []watchEvent
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		var event watchEvent
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	if assert.Len(t, events, 1) {
+		var object unstructured.Unstructured
+		assert.NoError(t, object.UnmarshalJSON(events[0].Object))
+		assert.Equal(t, "added", object.GetName())
+	}
+}
+
+func TestWatchResourcesPagesChangesAtTheSameTime(t *testing.T) {
+	defer func(size int) { watchBatchSize = size }(watchBatchSize)
+	watchBatchSize = 1
+	future := metav1.NewTime(time.Now().Add(time.Hour))
+
+	var resources []*unstructured.Unstructured
+	for _, name := range []string{"first", "second", "third"} {
+		resource := &unstructured.Unstructured{}
+		resource.SetKind("Crontab")
+		resource.SetAPIVersion("stable.example.com/v1")
+		resource.SetName(name)
+		resource.SetNamespace("test")
+		resource.SetCreationTimestamp(future)
+		resources = append(resources, resource)
+	}
+
+	router := setupRouter(fake.NewFakeDatabase(resources, testLogUrls, testLogJsonPath), false)
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet,
+		"/apis/stable.example.com/v1/namespaces/test/crontabs?watch=true&timeoutSeconds=1", nil)
+	router.ServeHTTP(res, req)
+
+	var names []string
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		var event watchEvent
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		var object unstructured.Unstructured
+		assert.NoError(t, object.UnmarshalJSON(event.Object))
+		names = append(names, object.GetName())
+	}
+	assert.Equal(t, []string{"first", "second", "third"}, names)
+}
+
+func TestWatchResourcesStartsAtTheDatabaseTime(t *testing.T) {
+	added := &unstructured.Unstructured{}
+	added.SetKind("Crontab")
+	added.SetAPIVersion("stable.example.com/v1")
+	added.SetName("added")
+	added.SetNamespace("test")
+	added.SetCreationTimestamp(metav1.NewTime(time.Now().Add(time.Hour)))
+
+	// The clock of the database is ahead of the clock of the API server, so the change already happened
+	database := fake.NewFakeDatabase([]*unstructured.Unstructured{added}, testLogUrls, testLogJsonPath)
+	database.Now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	router := setupRouter(database, false)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet,
+		"/apis/stable.example.com/v1/namespaces/test/crontabs?watch=true&timeoutSeconds=1", nil)
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Empty(t, res.Body.String())
+}
+
+func TestWatchResourcesDoesNotRepeatChanges(t *testing.T) {
+	added := &unstructured.Unstructured{}
+	added.SetKind("Crontab")
+	added.SetAPIVersion("stable.example.com/v1")
+	added.SetName("added")
+	added.SetNamespace("test")
+	added.SetCreationTimestamp(metav1.NewTime(time.Now().Add(time.Second)))
+
+	// Every poll reads again the changes of the last minute, the change is sent once
+	router := gin.Default()
+	ctrl := Controller{
+		Database:          fake.NewFakeDatabase([]*unstructured.Unstructured{added}, testLogUrls, testLogJsonPath),
+		WatchPollInterval: 10 * time.Millisecond,
+	}
+	router.Use(func(c *gin.Context) { c.Set("apiResourceKind", "Crontab") })
+	router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType", ctrl.GetResources)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet,
+		"/apis/stable.example.com/v1/namespaces/test/crontabs?watch=true&timeoutSeconds=2", nil)
+	router.ServeHTTP(res, req)
+
+	var names []string
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		var event watchEvent
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		var object unstructured.Unstructured
+		assert.NoError(t, object.UnmarshalJSON(event.Object))
+		names = append(names, object.GetName())
+	}
+	assert.Equal(t, []string{"added"}, names)
+}
+
+func TestWatchCursor(t *testing.T) {
+	watchStart := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cursor := newWatchCursor(watchStart)
+	assert.Equal(t, watchStart.Add(-watchOverlap), cursor.pollStart())
+
+	assert.True(t, cursor.add(1, watchStart.Add(10*time.Second)))
+	assert.True(t, cursor.add(2, watchStart.Add(20*time.Second)))
+	assert.Equal(t, watchStart.Add(20*time.Second-watchOverlap), cursor.pollStart())
+
+	// The next poll reads the same changes again and one that committed late with an older time
+	assert.False(t, cursor.add(1, watchStart.Add(10*time.Second)))
+	assert.False(t, cursor.add(2, watchStart.Add(20*time.Second)))
+	assert.True(t, cursor.add(3, watchStart.Add(5*time.Second)))
+	// A resource that changes again is sent again
+	assert.True(t, cursor.add(1, watchStart.Add(30*time.Second)))
+	assert.Equal(t, watchStart.Add(30*time.Second), cursor.changedAfter)
+
+	// The changes before the start of the poll are not read again, so they are forgotten
+	cursor.add(4, watchStart.Add(2*watchOverlap))
+	assert.Equal(t, watchStart.Add(watchOverlap), cursor.pollStart())
+	assert.Len(t, cursor.sent, 1)
+}
+
+func TestWatchQueryParameters(t *testing.T) {
+	router := setupRouter(fake.NewFakeDatabase(testResources, testLogUrls, testLogJsonPath), false)
+	tests := []struct {
+		name     string
+		query    string
+		expected int
+	}{
+		{
+			name:     "watch disabled",
+			query:    "watch=false",
+			expected: http.StatusOK,
+		},
+		{
+			name:     "invalid watch value",
+			query:    "watch=yes",
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid timeoutSeconds",
+			query:    "watch=true&timeoutSeconds=abc",
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "negative timeoutSeconds",
+			query:    "watch=true&timeoutSeconds=-1",
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid label selector",
+			query:    "watch=true&labelSelector=app>kubearchive",
+			expected: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/apis/stable.example.com/v1/namespaces/test/crontabs?"+tt.query, nil)
+			router.ServeHTTP(res, req)
+			assert.Equal(t, tt.expected, res.Code)
+		})
+	}
+}
+
+func TestNewWatchEvent(t *testing.T) {
+	watchStart := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		change   models.ResourceChange
+		expected watch.EventType
+		isError  bool
+	}{
+		{
+			name: "archived after the watch started",
+			change: models.ResourceChange{
+				ArchivedAt: "2025-01-01T12:00:00.000001Z",
+				UpdatedAt:  "2025-01-01T12:00:00.000001Z",
+			},
+			expected: watch.Added,
+		},
+		{
+			name: "archived before the watch started",
+			change: models.ResourceChange{
+				ArchivedAt: "2025-01-01T11:00:00.000000Z",
+				UpdatedAt:  "2025-01-01T12:30:00.000000Z",
+			},
+			expected: watch.Modified,
+		},
+		{
+			name: "deleted",
+			change: models.ResourceChange{
+				ArchivedAt: "2025-01-01T12:10:00.000000Z",
+				UpdatedAt:  "2025-01-01T12:30:00.000000Z",
+				Deleted:    true,
+			},
+			expected: watch.Deleted,
+		},
+		{
+			name: "invalid update timestamp",
+			change: models.ResourceChange{
+				ArchivedAt: "2025-01-01T12:10:00.000000Z",
+				UpdatedAt:  "2025-01-01 12:30:00",
+			},
+			isError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change.Data = "{}"
+			event, updatedAt, err := newWatchEvent(tt.change, watchStart)
+			if tt.isError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, event.Type)
+			assert.Equal(t, tt.change.UpdatedAt, updatedAt.Format("2006-01-02T15:04:05.000000Z07:00"))
+		})
+	}
+}
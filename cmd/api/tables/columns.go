@@ -0,0 +1,314 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tables
+
+import (
+	"fmt"
+	"strings"
+
+	metatable "k8s.io/apimachinery/pkg/api/meta/table"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// builtinColumns are the columns of the core kinds, based on the ones the Kubernetes API server returns.
+// Columns that depend on live cluster state (e.g. pod readiness gates or endpoints) are not included
+var builtinColumns = map[schema.GroupKind][]column{
+	{Group: "", Kind: "Pod"}: {
+		{definition: nameColumn, value: nameValue},
+		{definition: metav1.TableColumnDefinition{Name: "Ready", Type: "string",
+			Description: "The aggregate readiness state of this pod for accepting traffic."}, value: podReady},
+		{definition: metav1.TableColumnDefinition{Name: "Status", Type: "string",
+			Description: "The aggregate status of the containers in this pod."}, value: podStatus},
+		{definition: metav1.TableColumnDefinition{Name: "Restarts", Type: "integer",
+			Description: "The number of times the containers in this pod have been restarted."}, value: podRestarts},
+		{definition: ageColumn, value: ageValue},
+		{definition: metav1.TableColumnDefinition{Name: "IP", Type: "string", Priority: 1,
+			Description: "IP address allocated to the pod."}, value: stringValue("status", "podIP")},
+		{definition: metav1.TableColumnDefinition{Name: "Node", Type: "string", Priority: 1,
+			Description: "The node the pod was scheduled onto."}, value: stringValue("spec", "nodeName")},
+	},
+	{Group: "", Kind: "Service"}: {
+		{definition: nameColumn, value: nameValue},
+		{definition: metav1.TableColumnDefinition{Name: "Type", Type: "string",
+			Description: "The type of the service."}, value: stringValue("spec", "type")},
+		{definition: metav1.TableColumnDefinition{Name: "Cluster-IP", Type: "string",
+			Description: "The IP address of the service."}, value: stringValue("spec", "clusterIP")},
+		{definition: metav1.TableColumnDefinition{Name: "Port(s)", Type: "string",
+			Description: "The ports exposed by the service."}, value: servicePorts},
+		{definition: ageColumn, value: ageValue},
+	},
+	{Group: "", Kind: "ConfigMap"}: {
+		{definition: nameColumn, value: nameValue},
+		{definition: metav1.TableColumnDefinition{Name: "Data", Type: "integer",
+			Description: "The number of keys in the config map."}, value: mapLength("data")},
+		{definition: ageColumn, value: ageValue},
+	},
+	{Group: "apps", Kind: "Deployment"}: {
+		{definition: nameColumn, value: nameValue},
+		{definition: metav1.TableColumnDefinition{Name: "Ready", Type: "string",
+			Description: "Number of the pod with ready state."}, value: readyReplicas},
+		{definition: metav1.TableColumnDefinition{Name: "Up-to-date", Type: "integer",
+			Description: "Total number of non-terminated pods targeted by this deployment that have the desired template spec."},
+			value: intValue("status", "updatedReplicas")},
+		{definition: metav1.TableColumnDefinition{Name: "Available", Type: "integer",
+			Description: "Total number of available pods (ready for at least minReadySeconds) targeted by this deployment."},
+			value: intValue("status", "availableReplicas")},
+		{definition: ageColumn, value: ageValue},
+	},
+	{Group: "apps", Kind: "ReplicaSet"}: {
+		{definition: nameColumn, value: nameValue},
+		{definition: metav1.TableColumnDefinition{Name: "Desired", Type: "integer",
+			Description: "Number of desired pods."}, value: intValue("spec", "replicas")},
+		{definition: metav1.TableColumnDefinition{Name: "Current", Type: "integer",
+			Description: "The most recently observed number of replicas."}, value: intValue("status", "replicas")},
+		{definition: metav1.TableColumnDefinition{Name: "Ready", Type: "integer",
+			Description: "The number of ready replicas for this replica set."}, value: intValue("status", "readyReplicas")},
+		{definition: ageColumn, value: ageValue},
+	},
+	{Group: "apps", Kind: "StatefulSet"}: {
+		{definition: nameColumn, value: nameValue},
+		{definition: metav1.TableColumnDefinition{Name: "Ready", Type: "string",
+			Description: "Number of the pod with ready state."}, value: readyReplicas},
+		{definition: ageColumn, value: ageValue},
+	},
+	{Group: "apps", Kind: "DaemonSet"}: {
+		{definition: nameColumn, value: nameValue},
+		{definition: metav1.TableColumnDefinition{Name: "Desired", Type: "integer",
+			Description: "The total number of nodes that should be running the daemon pod."},
+			value: intValue("status", "desiredNumberScheduled")},
+		{definition: metav1.TableColumnDefinition{Name: "Current", Type: "integer",
+			Description: "The number of nodes that are running at least 1 daemon pod."},
+			value: intValue("status", "currentNumberScheduled")},
+		{definition: metav1.TableColumnDefinition{Name: "Ready", Type: "integer",
+			Description: "The number of nodes that should be running the daemon pod and have one or more of the daemon pod running and ready."},
+			value: intValue("status", "numberReady")},
+		{definition: metav1.TableColumnDefinition{Name: "Up-to-date", Type: "integer",
+			Description: "The total number of nodes that are running updated daemon pod."},
+			value: intValue("status", "updatedNumberScheduled")},
+		{definition: metav1.TableColumnDefinition{Name: "Available", Type: "integer",
+			Description: "The number of nodes that should be running the daemon pod and have one or more of the daemon pod running and available."},
+			value: intValue("status", "numberAvailable")},
+		{definition: ageColumn, value: ageValue},
+	},
+	{Group: "batch", Kind: "Job"}: {
+		{definition: nameColumn, value: nameValue},
+		{definition: metav1.TableColumnDefinition{Name: "Status", Type: "string",
+			Description: "Status of the job."}, value: jobStatus},
+		{definition: metav1.TableColumnDefinition{Name: "Completions", Type: "string",
+			Description: "The desired number of successfully finished pods the job should be run with."}, value: jobCompletions},
+		{definition: metav1.TableColumnDefinition{Name: "Duration", Type: "string",
+			Description: "Time required to complete the job."}, value: jobDuration},
+		{definition: ageColumn, value: ageValue},
+	},
+	{Group: "batch", Kind: "CronJob"}: {
+		{definition: nameColumn, value: nameValue},
+		{definition: metav1.TableColumnDefinition{Name: "Schedule", Type: "string",
+			Description: "The schedule in Cron format."}, value: stringValue("spec", "schedule")},
+		{definition: metav1.TableColumnDefinition{Name: "Suspend", Type: "boolean",
+			Description: "Whether subsequent executions are suspended."}, value: boolValue("spec", "suspend")},
+		{definition: metav1.TableColumnDefinition{Name: "Active", Type: "integer",
+			Description: "The number of currently running jobs."}, value: sliceLength("status", "active")},
+		{definition: metav1.TableColumnDefinition{Name: "Last Schedule", Type: "string",
+			Description: "Information when was the last time the job was successfully scheduled."},
+			value: timestampValue("status", "lastScheduleTime")},
+		{definition: ageColumn, value: ageValue},
+	},
+}
+
+func stringValue(fields ...string) func(*unstructured.Unstructured) any {
+	return func(resource *unstructured.Unstructured) any {
+		value, found, err := unstructured.NestedString(resource.Object, fields...)
+		if !found || err != nil || value == "" {
+			return "<none>"
+		}
+		return value
+	}
+}
+
+func intValue(fields ...string) func(*unstructured.Unstructured) any {
+	return func(resource *unstructured.Unstructured) any {
+		value, _, _ := unstructured.NestedInt64(resource.Object, fields...)
+		return value
+	}
+}
+
+func boolValue(fields ...string) func(*unstructured.Unstructured) any {
+	return func(resource *unstructured.Unstructured) any {
+		value, _, _ := unstructured.NestedBool(resource.Object, fields...)
+		return value
+	}
+}
+
+func sliceLength(fields ...string) func(*unstructured.Unstructured) any {
+	return func(resource *unstructured.Unstructured) any {
+		value, _, _ := unstructured.NestedSlice(resource.Object, fields...)
+		return int64(len(value))
+	}
+}
+
+func mapLength(fields ...string) func(*unstructured.Unstructured) any {
+	return func(resource *unstructured.Unstructured) any {
+		value, _, _ := unstructured.NestedMap(resource.Object, fields...)
+		return int64(len(value))
+	}
+}
+
+func timestampValue(fields ...string) func(*unstructured.Unstructured) any {
+	return func(resource *unstructured.Unstructured) any {
+		value, found, err := unstructured.NestedString(resource.Object, fields...)
+		if !found || err != nil {
+			return "<none>"
+		}
+		return cellForJSONValue("date", value)
+	}
+}
+
+func readyReplicas(resource *unstructured.Unstructured) any {
+	desired, _, _ := unstructured.NestedInt64(resource.Object, "spec", "replicas")
+	ready, _, _ := unstructured.NestedInt64(resource.Object, "status", "readyReplicas")
+	return fmt.Sprintf("%d/%d", ready, desired)
+}
+
+func containerStatuses(resource *unstructured.Unstructured) []map[string]any {
+	statuses, _, _ := unstructured.NestedSlice(resource.Object, "status", "containerStatuses")
+	result := make([]map[string]any, 0, len(statuses))
+	for _, status := range statuses {
+		if statusMap, ok := status.(map[string]any); ok {
+			result = append(result, statusMap)
+		}
+	}
+	return result
+}
+
+func podReady(resource *unstructured.Unstructured) any {
+	containers, _, _ := unstructured.NestedSlice(resource.Object, "spec", "containers")
+	ready := 0
+	for _, status := range containerStatuses(resource) {
+		if isReady, _, _ := unstructured.NestedBool(status, "ready"); isReady {
+			ready++
+		}
+	}
+	return fmt.Sprintf("%d/%d", ready, len(containers))
+}
+
+// podStatus follows the same logic as kubectl, without the init containers: the reason of the pod,
+// overridden by the reason of the containers that are waiting or terminated
+func podStatus(resource *unstructured.Unstructured) any {
+	reason, _, _ := unstructured.NestedString(resource.Object, "status", "phase")
+	if podReason, _, _ := unstructured.NestedString(resource.Object, "status", "reason"); podReason != "" {
+		reason = podReason
+	}
+
+	for _, status := range containerStatuses(resource) {
+		if waiting, _, _ := unstructured.NestedString(status, "state", "waiting", "reason"); waiting != "" {
+			reason = waiting
+		} else if terminated, _, _ := unstructured.NestedString(status, "state", "terminated", "reason"); terminated != "" {
+			reason = terminated
+		}
+	}
+
+	if resource.GetDeletionTimestamp() != nil && reason != "Completed" && reason != "Succeeded" && reason != "Failed" {
+		reason = "Terminating"
+	}
+	return reason
+}
+
+func podRestarts(resource *unstructured.Unstructured) any {
+	restarts := int64(0)
+	for _, status := range containerStatuses(resource) {
+		count, _, _ := unstructured.NestedInt64(status, "restartCount")
+		restarts += count
+	}
+	return restarts
+}
+
+func servicePorts(resource *unstructured.Unstructured) any {
+	ports, _, _ := unstructured.NestedSlice(resource.Object, "spec", "ports")
+	if len(ports) == 0 {
+		return "<none>"
+	}
+
+	result := make([]string, 0, len(ports))
+	for _, port := range ports {
+		portMap, ok := port.(map[string]any)
+		if !ok {
+			continue
+		}
+		number, _, _ := unstructured.NestedInt64(portMap, "port")
+		protocol, _, _ := unstructured.NestedString(portMap, "protocol")
+		if protocol == "" {
+			protocol = "TCP"
+		}
+		if nodePort, _, _ := unstructured.NestedInt64(portMap, "nodePort"); nodePort > 0 {
+			result = append(result, fmt.Sprintf("%d:%d/%s", number, nodePort, protocol))
+		} else {
+			result = append(result, fmt.Sprintf("%d/%s", number, protocol))
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+func jobConditionIsTrue(resource *unstructured.Unstructured, conditionType string) bool {
+	conditions, _, _ := unstructured.NestedSlice(resource.Object, "status", "conditions")
+	for _, condition := range conditions {
+		conditionMap, ok := condition.(map[string]any)
+		if !ok {
+			continue
+		}
+		if conditionMap["type"] == conditionType && conditionMap["status"] == string(metav1.ConditionTrue) {
+			return true
+		}
+	}
+	return false
+}
+
+func jobStatus(resource *unstructured.Unstructured) any {
+	switch {
+	case jobConditionIsTrue(resource, "Complete"):
+		return "Complete"
+	case jobConditionIsTrue(resource, "Failed"):
+		return "Failed"
+	case jobConditionIsTrue(resource, "Suspended"):
+		return "Suspended"
+	default:
+		return "Running"
+	}
+}
+
+func jobCompletions(resource *unstructured.Unstructured) any {
+	succeeded, _, _ := unstructured.NestedInt64(resource.Object, "status", "succeeded")
+	completions, found, _ := unstructured.NestedInt64(resource.Object, "spec", "completions")
+	if !found {
+		parallelism, _, _ := unstructured.NestedInt64(resource.Object, "spec", "parallelism")
+		if parallelism > 1 {
+			return fmt.Sprintf("%d/1 of %d", succeeded, parallelism)
+		}
+		return fmt.Sprintf("%d/1", succeeded)
+	}
+	return fmt.Sprintf("%d/%d", succeeded, completions)
+}
+
+func jobDuration(resource *unstructured.Unstructured) any {
+	startTime, found, _ := unstructured.NestedString(resource.Object, "status", "startTime")
+	if !found {
+		return ""
+	}
+	var start metav1.Time
+	if err := start.UnmarshalQueryParameter(startTime); err != nil {
+		return "<invalid>"
+	}
+
+	completionTime, found, _ := unstructured.NestedString(resource.Object, "status", "completionTime")
+	if !found {
+		return metatable.ConvertToHumanReadableDateType(start)
+	}
+	var completion metav1.Time
+	if err := completion.UnmarshalQueryParameter(completionTime); err != nil {
+		return "<invalid>"
+	}
+	return duration.HumanDuration(completion.Sub(start.Time))
+}
@@ -0,0 +1,238 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tables
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"reflect"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metatable "k8s.io/apimachinery/pkg/api/meta/table"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+var swaggerMetadataDescriptions = metav1.ObjectMeta{}.SwaggerDoc()
+
+var nameColumn = metav1.TableColumnDefinition{
+	Name: "Name", Type: "string", Format: "name", Description: swaggerMetadataDescriptions["name"],
+}
+
+var ageColumn = metav1.TableColumnDefinition{
+	Name: "Age", Type: "string", Description: swaggerMetadataDescriptions["creationTimestamp"],
+}
+
+// column is a column of the table along with the function that extracts its value from a resource
+type column struct {
+	definition metav1.TableColumnDefinition
+	value      func(resource *unstructured.Unstructured) any
+}
+
+// Converter builds meta.k8s.io/v1 Tables from the resources stored in the database
+type Converter struct {
+	columns       []column
+	includeObject metav1.IncludeObjectPolicy
+}
+
+// IsTableRequest returns true when the Accept header asks for a meta.k8s.io/v1 Table
+// (e.g. application/json;as=Table;v=v1;g=meta.k8s.io), like kubectl does
+func IsTableRequest(accept string) bool {
+	for _, mediaType := range strings.Split(accept, ",") {
+		mimeType, params, err := mime.ParseMediaType(mediaType)
+		if err != nil {
+			continue
+		}
+		if mimeType == "application/json" && params["as"] == "Table" &&
+			params["g"] == metav1.GroupName && params["v"] == metav1.SchemeGroupVersion.Version {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseIncludeObject returns the policy of the `includeObject` query parameter, Metadata by default
+func ParseIncludeObject(value string) (metav1.IncludeObjectPolicy, error) {
+	switch policy := metav1.IncludeObjectPolicy(value); policy {
+	case "":
+		return metav1.IncludeMetadata, nil
+	case metav1.IncludeNone, metav1.IncludeMetadata, metav1.IncludeObject:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid includeObject value: %s. Expected one of None, Metadata or Object", value)
+	}
+}
+
+// NewConverter returns a Converter with the columns for the given group and kind. The printer columns
+// of the CustomResourceDefinition are used when present, then the built-in columns of the core kinds
+// and the Name and Age columns for any other kind.
+func NewConverter(group, kind string, printerColumns []apiextensionsv1.CustomResourceColumnDefinition,
+	includeObject metav1.IncludeObjectPolicy) (*Converter, error) {
+	converter := &Converter{includeObject: includeObject}
+
+	if len(printerColumns) > 0 {
+		converter.columns = []column{{definition: nameColumn, value: nameValue}}
+		for _, printerColumn := range printerColumns {
+			printerCol, err := newPrinterColumn(printerColumn)
+			if err != nil {
+				return nil, err
+			}
+			converter.columns = append(converter.columns, printerCol)
+		}
+		return converter, nil
+	}
+
+	if columns, ok := builtinColumns[schema.GroupKind{Group: group, Kind: kind}]; ok {
+		converter.columns = columns
+		return converter, nil
+	}
+
+	converter.columns = []column{{definition: nameColumn, value: nameValue}, {definition: ageColumn, value: ageValue}}
+	return converter, nil
+}
+
+// ConvertToTable returns a Table with a row for each resource
+func (c *Converter) ConvertToTable(data []string, continueToken string) (*metav1.Table, error) {
+	table := &metav1.Table{
+		TypeMeta: metav1.TypeMeta{Kind: "Table", APIVersion: metav1.SchemeGroupVersion.String()},
+		ListMeta: metav1.ListMeta{Continue: continueToken},
+		Rows:     make([]metav1.TableRow, 0, len(data)),
+	}
+	for _, col := range c.columns {
+		table.ColumnDefinitions = append(table.ColumnDefinitions, col.definition)
+	}
+
+	for _, resourceData := range data {
+		resource := &unstructured.Unstructured{}
+		if err := resource.UnmarshalJSON([]byte(resourceData)); err != nil {
+			return nil, fmt.Errorf("unable to deserialize resource: %w", err)
+		}
+
+		row := metav1.TableRow{Cells: make([]any, 0, len(c.columns))}
+		for _, col := range c.columns {
+			row.Cells = append(row.Cells, col.value(resource))
+		}
+
+		object, err := c.rowObject(resource, resourceData)
+		if err != nil {
+			return nil, err
+		}
+		row.Object = object
+		table.Rows = append(table.Rows, row)
+	}
+
+	return table, nil
+}
+
+// rowObject returns the object of a row based on the includeObject policy
+func (c *Converter) rowObject(resource *unstructured.Unstructured, data string) (runtime.RawExtension, error) {
+	switch c.includeObject {
+	case metav1.IncludeNone:
+		return runtime.RawExtension{}, nil
+	case metav1.IncludeObject:
+		return runtime.RawExtension{Raw: []byte(data)}, nil
+	}
+
+	metadata := metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{Kind: "PartialObjectMetadata", APIVersion: metav1.SchemeGroupVersion.String()},
+	}
+	objectMeta, _ := resource.Object["metadata"].(map[string]any)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(objectMeta, &metadata.ObjectMeta); err != nil {
+		return runtime.RawExtension{}, fmt.Errorf("unable to retrieve the metadata of the resource: %w", err)
+	}
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return runtime.RawExtension{}, fmt.Errorf("unable to serialize the metadata of the resource: %w", err)
+	}
+	return runtime.RawExtension{Raw: raw}, nil
+}
+
+// newPrinterColumn returns a column that evaluates the JSONPath of an additionalPrinterColumn
+// the same way the Kubernetes API server does for custom resources
+func newPrinterColumn(printerColumn apiextensionsv1.CustomResourceColumnDefinition) (column, error) {
+	path := jsonpath.New(printerColumn.Name)
+	if err := path.Parse(fmt.Sprintf("{%s}", printerColumn.JSONPath)); err != nil {
+		return column{}, fmt.Errorf("unrecognized column definition %q", printerColumn.JSONPath)
+	}
+	path.AllowMissingKeys(true)
+
+	description := fmt.Sprintf("Custom resource definition column (in JSONPath format): %s", printerColumn.JSONPath)
+	if printerColumn.Description != "" {
+		description = printerColumn.Description
+	}
+
+	return column{
+		definition: metav1.TableColumnDefinition{
+			Name:        printerColumn.Name,
+			Type:        printerColumn.Type,
+			Format:      printerColumn.Format,
+			Description: description,
+			Priority:    printerColumn.Priority,
+		},
+		value: func(resource *unstructured.Unstructured) any {
+			results, err := path.FindResults(resource.UnstructuredContent())
+			if err != nil || len(results) == 0 || len(results[0]) == 0 {
+				return nil
+			}
+
+			// Only simple JSONPaths are supported, so there is at most one result
+			value := results[0][0].Interface()
+			if printerColumn.Type == "string" {
+				buf := &bytes.Buffer{}
+				if err := path.PrintResults(buf, []reflect.Value{reflect.ValueOf(value)}); err != nil {
+					return nil
+				}
+				return buf.String()
+			}
+			return cellForJSONValue(printerColumn.Type, value)
+		},
+	}, nil
+}
+
+// cellForJSONValue converts the value to the type of the column, nil is returned when it does not match
+func cellForJSONValue(columnType string, value any) any {
+	switch columnType {
+	case "integer":
+		switch typed := value.(type) {
+		case int64:
+			return typed
+		case float64:
+			return int64(typed)
+		}
+	case "number":
+		switch typed := value.(type) {
+		case int64:
+			return float64(typed)
+		case float64:
+			return typed
+		}
+	case "boolean":
+		if typed, ok := value.(bool); ok {
+			return typed
+		}
+	case "date":
+		if typed, ok := value.(string); ok {
+			var timestamp metav1.Time
+			if err := timestamp.UnmarshalQueryParameter(typed); err != nil {
+				return "<invalid>"
+			}
+			return metatable.ConvertToHumanReadableDateType(timestamp)
+		}
+	}
+	return nil
+}
+
+func nameValue(resource *unstructured.Unstructured) any {
+	return resource.GetName()
+}
+
+func ageValue(resource *unstructured.Unstructured) any {
+	return metatable.ConvertToHumanReadableDateType(resource.GetCreationTimestamp())
+}
@@ -0,0 +1,174 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tables
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testPod = `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "test-pod", "namespace": "test",
+"creationTimestamp": null}, "spec": {"nodeName": "node-1", "containers": [{"name": "a"}, {"name": "b"}]},
+"status": {"phase": "Running", "podIP": "10.0.0.1", "containerStatuses": [
+{"name": "a", "ready": true, "restartCount": 2, "state": {"running": {}}},
+{"name": "b", "ready": false, "restartCount": 1, "state": {"waiting": {"reason": "CrashLoopBackOff"}}}]}}`
+
+const testCrontab = `{"apiVersion": "stable.example.com/v1", "kind": "CronTab", "metadata": {"name": "test-crontab",
+"namespace": "test"}, "spec": {"cronSpec": "* * * * */5", "replicas": 3, "enabled": true}}`
+
+func TestIsTableRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   string
+		expected bool
+	}{
+		{name: "empty", accept: "", expected: false},
+		{name: "json", accept: "application/json", expected: false},
+		{name: "table", accept: "application/json;as=Table;v=v1;g=meta.k8s.io", expected: true},
+		{
+			name:     "kubectl",
+			accept:   "application/json;as=Table;v=v1;g=meta.k8s.io,application/json;as=Table;v=v1beta1;g=meta.k8s.io,application/json",
+			expected: true,
+		},
+		{name: "unsupported version", accept: "application/json;as=Table;v=v1beta1;g=meta.k8s.io", expected: false},
+		{name: "unsupported group", accept: "application/json;as=Table;v=v1;g=example.com", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsTableRequest(tt.accept))
+		})
+	}
+}
+
+func TestParseIncludeObject(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected metav1.IncludeObjectPolicy
+		isError  bool
+	}{
+		{value: "", expected: metav1.IncludeMetadata},
+		{value: "None", expected: metav1.IncludeNone},
+		{value: "Metadata", expected: metav1.IncludeMetadata},
+		{value: "Object", expected: metav1.IncludeObject},
+		{value: "invalid", isError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			policy, err := ParseIncludeObject(tt.value)
+			if tt.isError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, policy)
+		})
+	}
+}
+
+func columnNames(table *metav1.Table) []string {
+	names := []string{}
+	for _, column := range table.ColumnDefinitions {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+func TestConvertToTableBuiltinColumns(t *testing.T) {
+	converter, err := NewConverter("", "Pod", nil, metav1.IncludeNone)
+	assert.NoError(t, err)
+
+	table, err := converter.ConvertToTable([]string{testPod}, "token")
+	assert.NoError(t, err)
+	assert.Equal(t, "Table", table.Kind)
+	assert.Equal(t, "meta.k8s.io/v1", table.APIVersion)
+	assert.Equal(t, "token", table.Continue)
+	assert.Equal(t, []string{"Name", "Ready", "Status", "Restarts", "Age", "IP", "Node"}, columnNames(table))
+	if assert.Len(t, table.Rows, 1) {
+		assert.Equal(t,
+			[]any{"test-pod", "1/2", "CrashLoopBackOff", int64(3), "<unknown>", "10.0.0.1", "node-1"},
+			table.Rows[0].Cells)
+		assert.Nil(t, table.Rows[0].Object.Raw)
+	}
+}
+
+func TestConvertToTableDefaultColumns(t *testing.T) {
+	// A kind with the same name as a built-in kind in another group uses the default columns
+	converter, err := NewConverter("example.com", "Pod", nil, metav1.IncludeNone)
+	assert.NoError(t, err)
+
+	table, err := converter.ConvertToTable([]string{testPod}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Name", "Age"}, columnNames(table))
+	if assert.Len(t, table.Rows, 1) {
+		assert.Equal(t, []any{"test-pod", "<unknown>"}, table.Rows[0].Cells)
+	}
+}
+
+func TestConvertToTablePrinterColumns(t *testing.T) {
+	printerColumns := []apiextensionsv1.CustomResourceColumnDefinition{
+		{Name: "Spec", Type: "string", JSONPath: ".spec.cronSpec"},
+		{Name: "Replicas", Type: "integer", JSONPath: ".spec.replicas", Priority: 1},
+		{Name: "Enabled", Type: "boolean", JSONPath: ".spec.enabled"},
+		{Name: "Missing", Type: "string", JSONPath: ".status.missing"},
+	}
+	converter, err := NewConverter("stable.example.com", "CronTab", printerColumns, metav1.IncludeNone)
+	assert.NoError(t, err)
+
+	table, err := converter.ConvertToTable([]string{testCrontab}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Name", "Spec", "Replicas", "Enabled", "Missing"}, columnNames(table))
+	assert.Equal(t, int32(1), table.ColumnDefinitions[2].Priority)
+	if assert.Len(t, table.Rows, 1) {
+		assert.Equal(t, []any{"test-crontab", "* * * * */5", int64(3), true, nil}, table.Rows[0].Cells)
+	}
+}
+
+func TestNewConverterInvalidPrinterColumn(t *testing.T) {
+	printerColumns := []apiextensionsv1.CustomResourceColumnDefinition{
+		{Name: "Invalid", Type: "string", JSONPath: ".spec[.invalid"},
+	}
+	_, err := NewConverter("stable.example.com", "CronTab", printerColumns, metav1.IncludeNone)
+	assert.Error(t, err)
+}
+
+func TestConvertToTableIncludeObject(t *testing.T) {
+	tests := []struct {
+		includeObject metav1.IncludeObjectPolicy
+		expectedKind  string
+	}{
+		{includeObject: metav1.IncludeMetadata, expectedKind: "PartialObjectMetadata"},
+		{includeObject: metav1.IncludeObject, expectedKind: "CronTab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.includeObject), func(t *testing.T) {
+			converter, err := NewConverter("stable.example.com", "CronTab", nil, tt.includeObject)
+			assert.NoError(t, err)
+
+			table, err := converter.ConvertToTable([]string{testCrontab}, "")
+			assert.NoError(t, err)
+			if assert.Len(t, table.Rows, 1) {
+				object := metav1.PartialObjectMetadata{}
+				assert.NoError(t, json.Unmarshal(table.Rows[0].Object.Raw, &object))
+				assert.Equal(t, tt.expectedKind, object.Kind)
+				assert.Equal(t, "test-crontab", object.Name)
+				assert.Equal(t, "test", object.Namespace)
+			}
+		})
+	}
+}
+
+func TestConvertToTableInvalidResource(t *testing.T) {
+	converter, err := NewConverter("", "Pod", nil, metav1.IncludeMetadata)
+	assert.NoError(t, err)
+
+	_, err = converter.ConvertToTable([]string{"not json"}, "")
+	assert.Error(t, err)
+}
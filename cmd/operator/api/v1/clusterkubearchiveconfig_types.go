@@ -28,6 +28,7 @@ type ClusterKubeArchiveConfigResource struct {
 	DeleteWhen      string                `json:"deleteWhen,omitempty" yaml:"deleteWhen,omitempty"`
 	ArchiveOnDelete string                `json:"archiveOnDelete,omitempty" yaml:"archiveOnDelete,omitempty"`
 	KeepLastWhen    []ClusterKeepLastRule `json:"keepLastWhen,omitempty" yaml:"keepLastWhen,omitempty"`
+	Transform       *TransformConfig      `json:"transform,omitempty" yaml:"transform,omitempty"`
 }
 
 // ClusterKubeArchiveConfigSpec defines the desired state of ClusterKubeArchiveConfig
@@ -124,6 +124,7 @@ func (ckaccv *ClusterKubeArchiveConfigCustomValidator) validateKAC(ckac *Cluster
 				errList = append(errList, validateDurationString(resource.ArchiveOnDelete)...)
 			}
 		}
+		errList = append(errList, validateTransform(resource.Transform)...)
 
 		// Validate KeepLastWhen rules
 		seenCELExpressions := make(map[string]string)
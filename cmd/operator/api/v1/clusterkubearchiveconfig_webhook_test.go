@@ -449,3 +449,28 @@ func TestClusterKubeArchiveConfigValidateKeepLastWhen(t *testing.T) {
 		})
 	}
 }
+
+func TestClusterKubeArchiveConfigValidateTransform(t *testing.T) {
+	k9eResourceName := "kubearchive"
+	validator := ClusterKubeArchiveConfigCustomValidator{kubearchiveResourceName: k9eResourceName}
+	ckac := &ClusterKubeArchiveConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: k9eResourceName},
+		Spec: ClusterKubeArchiveConfigSpec{
+			Resources: []ClusterKubeArchiveConfigResource{
+				{
+					Selector: APIVersionKind{APIVersion: "v1", Kind: "Secret"},
+					Transform: &TransformConfig{
+						Remove: []string{"$.data.*", "$..data"},
+					},
+				},
+			}},
+	}
+	warns, err := validator.ValidateCreate(context.Background(), ckac)
+	assert.Nil(t, warns)
+	assert.ErrorContains(t, err, "transform: remove[1]: invalid path '$..data'")
+
+	ckac.Spec.Resources[0].Transform.Remove = []string{"$.data.*"}
+	warns, err = validator.ValidateCreate(context.Background(), ckac)
+	assert.Nil(t, warns)
+	assert.NoError(t, err)
+}
@@ -6,6 +6,7 @@ package v1
 import (
 	"encoding/json"
 
+	"github.com/kubearchive/kubearchive/pkg/transform"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -41,6 +42,29 @@ type KeepLastWhenConfig struct {
 	Override []KeepLastOverrideRule `json:"override,omitempty" yaml:"override,omitempty"`
 }
 
+// TransformConfig are the transformations applied by the sink to the resources before writing them to the database
+// +kubebuilder:object:generate=true
+type TransformConfig struct {
+	// DropManagedFields removes metadata.managedFields
+	DropManagedFields bool `json:"dropManagedFields,omitempty" yaml:"dropManagedFields,omitempty"`
+	// Remove are the JSONPaths of the fields to remove
+	Remove []string `json:"remove,omitempty" yaml:"remove,omitempty"`
+	// Hash are the JSONPaths of the fields whose value is replaced by its HMAC-SHA256 with the hash key of the sink
+	Hash []string `json:"hash,omitempty" yaml:"hash,omitempty"`
+	// MaxStatusSize is the maximum size in bytes of the status, its largest fields are removed until it fits
+	// +kubebuilder:validation:Minimum=0
+	MaxStatusSize int `json:"maxStatusSize,omitempty" yaml:"maxStatusSize,omitempty"`
+}
+
+func (t TransformConfig) Rules() transform.Rules {
+	return transform.Rules{
+		DropManagedFields: t.DropManagedFields,
+		Remove:            t.Remove,
+		Hash:              t.Hash,
+		MaxStatusSize:     t.MaxStatusSize,
+	}
+}
+
 var KubeArchiveConfigGVR = schema.GroupVersionResource{Group: "kubearchive.org", Version: "v1", Resource: "kubearchiveconfigs"}
 
 type KubeArchiveConfigResource struct {
@@ -49,6 +73,7 @@ type KubeArchiveConfigResource struct {
 	DeleteWhen      string              `json:"deleteWhen,omitempty" yaml:"deleteWhen,omitempty"`
 	ArchiveOnDelete string              `json:"archiveOnDelete,omitempty" yaml:"archiveOnDelete,omitempty"`
 	KeepLastWhen    *KeepLastWhenConfig `json:"keepLastWhen,omitempty" yaml:"keepLastWhen,omitempty"`
+	Transform       *TransformConfig    `json:"transform,omitempty" yaml:"transform,omitempty"`
 }
 
 // KubeArchiveConfigSpec defines the desired state of KubeArchiveConfig
@@ -13,6 +13,7 @@ import (
 
 	"github.com/kubearchive/kubearchive/pkg/cel"
 	"github.com/kubearchive/kubearchive/pkg/constants"
+	"github.com/kubearchive/kubearchive/pkg/transform"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -159,6 +160,7 @@ func (kaccv *KubeArchiveConfigCustomValidator) validateKAC(ctx context.Context,
 				errList = append(errList, validateDurationString(resource.ArchiveOnDelete)...)
 			}
 		}
+		errList = append(errList, validateTransform(resource.Transform)...)
 
 		// Validate KeepLastWhen rules
 		if resource.KeepLastWhen != nil {
@@ -273,6 +275,17 @@ func (kaccv *KubeArchiveConfigCustomValidator) validateKAC(ctx context.Context,
 	return nil, errors.Join(errList...)
 }
 
+// validateTransform returns the errors of the transform rules of a resource
+func validateTransform(config *TransformConfig) []error {
+	if config == nil {
+		return nil
+	}
+	if _, err := transform.NewPipeline(config.Rules()); err != nil {
+		return []error{fmt.Errorf("transform: %w", err)}
+	}
+	return nil
+}
+
 func validateDurationString(expr string) []error {
 	emptyObj := unstructured.Unstructured{
 		Object: map[string]interface{}{},
@@ -306,6 +306,61 @@ func TestKubeArchiveConfigValidateCELExpression(t *testing.T) {
 	}
 }
 
+func TestKubeArchiveConfigValidateTransform(t *testing.T) {
+	tests := []struct {
+		name          string
+		transform     *TransformConfig
+		expectedError string
+	}{
+		{
+			name: "Valid transform",
+			transform: &TransformConfig{
+				DropManagedFields: true,
+				Remove:            []string{"$.spec.containers[*].env"},
+				Hash:              []string{"$.data.*"},
+				MaxStatusSize:     4096,
+			},
+		},
+		{
+			name:          "Malformed path",
+			transform:     &TransformConfig{Hash: []string{"$.data["}},
+			expectedError: "transform: hash[0]: invalid path '$.data['",
+		},
+		{
+			name:          "Path of a required field",
+			transform:     &TransformConfig{Remove: []string{"$.metadata.namespace"}},
+			expectedError: "it selects fields required to archive the resource",
+		},
+		{
+			name:          "Negative status size",
+			transform:     &TransformConfig{MaxStatusSize: -1},
+			expectedError: "maxStatusSize must be greater than or equal to 0",
+		},
+	}
+	validator := KubeArchiveConfigCustomValidator{kubearchiveResourceName: constants.KubeArchiveConfigResourceName}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kac := &KubeArchiveConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: constants.KubeArchiveConfigResourceName},
+				Spec: KubeArchiveConfigSpec{
+					Resources: []KubeArchiveConfigResource{
+						{
+							Selector:  APIVersionKind{APIVersion: "v1", Kind: "Secret"},
+							Transform: test.transform,
+						},
+					}},
+			}
+			warns, err := validator.ValidateCreate(context.Background(), kac)
+			assert.Nil(t, warns)
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.expectedError)
+			}
+		})
+	}
+}
+
 func TestKubeArchiveConfigValidateKeepLastWhenKeep(t *testing.T) {
 	k9eResourceName := "kubearchive"
 	validWhen := "has(status.completionTime)"
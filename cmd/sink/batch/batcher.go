@@ -0,0 +1,207 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	// SizeEnvVar is the maximum number of writes in a batch. Writes are not batched when unset
+	SizeEnvVar = "KUBEARCHIVE_SINK_BATCH_SIZE"
+	// WindowEnvVar is the maximum time a write waits for the batch to fill, as a duration like "100ms"
+	WindowEnvVar = "KUBEARCHIVE_SINK_BATCH_WINDOW"
+
+	defaultWindow = 100 * time.Millisecond
+	// writeTimeout is the maximum time to write a batch to the database
+	writeTimeout = 30 * time.Second
+)
+
+// ErrClosed is returned by Write when the Batcher is closed
+var ErrClosed = errors.New("the batcher is closed")
+
+type request struct {
+	write interfaces.ResourceWrite
+	done  chan<- response
+}
+
+type response struct {
+	result interfaces.WriteResourceResult
+	err    error
+}
+
+// Batcher buffers the writes of resources and writes them in batches with interfaces.DBWriter.WriteResources,
+// so the sink uses a single transaction and database connection for many CloudEvents. A batch is written
+// when it has size writes or when window passed since its first write. Batches are written one at a time.
+type Batcher struct {
+	db       interfaces.DBWriter
+	size     int
+	window   time.Duration
+	requests chan request
+	closing  chan struct{}
+	closed   chan struct{}
+}
+
+func NewBatcher(db interfaces.DBWriter, size int, window time.Duration) *Batcher {
+	b := &Batcher{
+		db:       db,
+		size:     size,
+		window:   window,
+		requests: make(chan request),
+		closing:  make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// NewBatcherFromEnv returns the Batcher configured in the environment, or nil when writes should not be batched
+func NewBatcherFromEnv(db interfaces.DBWriter) (*Batcher, error) {
+	rawSize := os.Getenv(SizeEnvVar)
+	if rawSize == "" {
+		return nil, nil
+	}
+	size, err := strconv.Atoi(rawSize)
+	if err != nil || size < 1 {
+		return nil, fmt.Errorf("invalid %s value '%s', it must be a positive integer", SizeEnvVar, rawSize)
+	}
+
+	window := defaultWindow
+	if rawWindow := os.Getenv(WindowEnvVar); rawWindow != "" {
+		window, err = time.ParseDuration(rawWindow)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid %s value '%s', it must be a positive duration", WindowEnvVar, rawWindow)
+		}
+	}
+	return NewBatcher(db, size, window), nil
+}
+
+// Write adds write to the current batch and returns its result once the batch is committed, so the
+// CloudEvent is only acknowledged after the resource is in the database. It returns the error of the
+// batch when the batch could not be written.
+func (b *Batcher) Write(ctx context.Context, write interfaces.ResourceWrite) (interfaces.WriteResourceResult, error) {
+	done := make(chan response, 1)
+	select {
+	case b.requests <- request{write: write, done: done}:
+	case <-b.closing:
+		return interfaces.WriteResourceResultError, ErrClosed
+	case <-ctx.Done():
+		return interfaces.WriteResourceResultError, ctx.Err()
+	}
+
+	select {
+	case res := <-done:
+		return res.result, res.err
+	case <-ctx.Done():
+		// The batch may still be committed, the write is retried when the CloudEvent is redelivered
+		return interfaces.WriteResourceResultError, ctx.Err()
+	}
+}
+
+// Close writes the pending batch and stops the Batcher. Writes after Close return ErrClosed
+func (b *Batcher) Close() {
+	close(b.closing)
+	<-b.closed
+}
+
+func (b *Batcher) run() {
+	defer close(b.closed)
+
+	var pending []request
+	timer := time.NewTimer(b.window)
+	timer.Stop()
+	for {
+		select {
+		case req := <-b.requests:
+			pending = append(pending, req)
+			if len(pending) == 1 {
+				timer.Reset(b.window)
+			}
+			if len(pending) >= b.size {
+				timer.Stop()
+				b.flush(pending)
+				pending = nil
+			}
+		case <-timer.C:
+			b.flush(pending)
+			pending = nil
+		case <-b.closing:
+			timer.Stop()
+			b.flush(pending)
+			return
+		}
+	}
+}
+
+// flush writes the batch and sends each request its result
+func (b *Batcher) flush(batch []request) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, span := otel.Tracer("kubearchive").Start(context.Background(), "writeBatch")
+	defer span.End()
+	span.SetAttributes(attribute.Int("size", len(batch)))
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	writes := make([]interfaces.ResourceWrite, 0, len(batch))
+	for _, req := range batch {
+		writes = append(writes, req.write)
+	}
+	results, err := b.db.WriteResources(ctx, writes)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to write the batch to the database", "size", len(batch), "err", err)
+		span.SetStatus(codes.Error, "failed")
+		span.RecordError(err)
+		if len(batch) == 1 {
+			batch[0].done <- response{result: interfaces.WriteResourceResultError, err: err}
+			return
+		}
+		b.writeOneByOne(batch)
+		return
+	}
+
+	slog.DebugContext(ctx, "Wrote the batch to the database", "size", len(batch))
+	for i, req := range batch {
+		req.done <- response{result: results[i]}
+	}
+}
+
+// writeOneByOne writes each request of a failed batch in its own transaction, so only the requests
+// that can not be written, like a resource the database rejects, fail
+func (b *Batcher) writeOneByOne(batch []request) {
+	ctx, span := otel.Tracer("kubearchive").Start(context.Background(), "writeBatchOneByOne")
+	defer span.End()
+	span.SetAttributes(attribute.Int("size", len(batch)))
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	failed := 0
+	for _, req := range batch {
+		results, err := b.db.WriteResources(ctx, []interfaces.ResourceWrite{req.write})
+		if err != nil {
+			failed++
+			req.done <- response{result: interfaces.WriteResourceResultError, err: err}
+			continue
+		}
+		req.done <- response{result: results[0]}
+	}
+	if failed > 0 {
+		slog.ErrorContext(ctx, "Failed to write resources of the batch one by one", "size", len(batch),
+			"failed", failed)
+		span.SetStatus(codes.Error, "failed")
+	}
+}
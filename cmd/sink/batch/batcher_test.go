@@ -0,0 +1,175 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package batch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTestWrite(name string) interfaces.ResourceWrite {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("batch/v1")
+	obj.SetKind("Job")
+	obj.SetNamespace("test")
+	obj.SetName(name)
+	obj.SetUID(types.UID("uid-" + name))
+	return interfaces.ResourceWrite{Object: obj, LastUpdated: time.Now()}
+}
+
+// writeAll writes all the writes concurrently and returns their errors
+func writeAll(batcher *Batcher, writes ...interfaces.ResourceWrite) []error {
+	errs := make([]error, len(writes))
+	var wg sync.WaitGroup
+	for i, write := range writes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = batcher.Write(context.Background(), write)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestBatcherSize(t *testing.T) {
+	db := fake.NewFakeDatabase(nil, nil, "")
+	// The window is too long for the test, the batch is written because it is full
+	batcher := NewBatcher(db, 2, time.Hour)
+	defer batcher.Close()
+
+	errs := writeAll(batcher, newTestWrite("a"), newTestWrite("b"))
+
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, 2, db.NumResources())
+}
+
+func TestBatcherWindow(t *testing.T) {
+	db := fake.NewFakeDatabase(nil, nil, "")
+	batcher := NewBatcher(db, 100, 10*time.Millisecond)
+	defer batcher.Close()
+
+	result, err := batcher.Write(context.Background(), newTestWrite("a"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, interfaces.WriteResourceResultInserted, result)
+	assert.Equal(t, 1, db.NumResources())
+}
+
+func TestBatcherError(t *testing.T) {
+	dbErr := errors.New("database unavailable")
+	batcher := NewBatcher(fake.NewFakeDatabaseWithError(dbErr), 2, time.Hour)
+	defer batcher.Close()
+
+	errs := writeAll(batcher, newTestWrite("a"), newTestWrite("b"))
+
+	assert.Equal(t, []error{dbErr, dbErr}, errs)
+}
+
+// badWriteDatabase fails the transactions that write the resource named bad
+type badWriteDatabase struct {
+	interfaces.DBWriter
+	bad string
+}
+
+func (db *badWriteDatabase) WriteResources(ctx context.Context, writes []interfaces.ResourceWrite,
+) ([]interfaces.WriteResourceResult, error) {
+	for _, write := range writes {
+		if write.Object.GetName() == db.bad {
+			return nil, errors.New("invalid resource")
+		}
+	}
+	return db.DBWriter.WriteResources(ctx, writes)
+}
+
+func TestBatcherErrorWritesOneByOne(t *testing.T) {
+	db := fake.NewFakeDatabase(nil, nil, "")
+	batcher := NewBatcher(&badWriteDatabase{DBWriter: db, bad: "b"}, 3, time.Hour)
+	defer batcher.Close()
+
+	errs := writeAll(batcher, newTestWrite("a"), newTestWrite("b"), newTestWrite("c"))
+
+	assert.NoError(t, errs[0])
+	assert.EqualError(t, errs[1], "invalid resource")
+	assert.NoError(t, errs[2])
+	assert.Equal(t, 2, db.NumResources())
+}
+
+func TestBatcherClose(t *testing.T) {
+	db := fake.NewFakeDatabase(nil, nil, "")
+	batcher := NewBatcher(db, 100, time.Hour)
+
+	// The request is sent as Write does, to know it is pending before closing the batcher
+	done := make(chan response, 1)
+	batcher.requests <- request{write: newTestWrite("a"), done: done}
+	batcher.Close()
+
+	assert.NoError(t, (<-done).err)
+	assert.Equal(t, 1, db.NumResources())
+	_, err := batcher.Write(context.Background(), newTestWrite("b"))
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestNewBatcherFromEnv(t *testing.T) {
+	tests := []struct {
+		name          string
+		size          string
+		window        string
+		enabled       bool
+		expectedError string
+	}{
+		{
+			name: "not configured",
+		},
+		{
+			name:    "size",
+			size:    "50",
+			enabled: true,
+		},
+		{
+			name:    "size and window",
+			size:    "50",
+			window:  "250ms",
+			enabled: true,
+		},
+		{
+			name:          "invalid size",
+			size:          "0",
+			expectedError: "KUBEARCHIVE_SINK_BATCH_SIZE",
+		},
+		{
+			name:          "invalid window",
+			size:          "50",
+			window:        "soon",
+			expectedError: "KUBEARCHIVE_SINK_BATCH_WINDOW",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(SizeEnvVar, tt.size)
+			t.Setenv(WindowEnvVar, tt.window)
+
+			batcher, err := NewBatcherFromEnv(fake.NewFakeDatabase(nil, nil, ""))
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.enabled, batcher != nil)
+			if batcher != nil {
+				batcher.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/cloudevents/sdk-go/protocol/kafka_sarama/v2"
+	"github.com/cloudevents/sdk-go/protocol/nats_jetstream/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// ProtocolEnvVar is the message broker the CloudEvents are consumed from, "kafka" or "nats". The CloudEvents
+	// are only received over HTTP when it is not set
+	ProtocolEnvVar = "KUBEARCHIVE_SINK_CONSUMER"
+	// KafkaBrokersEnvVar is the comma separated list of the Kafka brokers
+	KafkaBrokersEnvVar = "KUBEARCHIVE_SINK_KAFKA_BROKERS"
+	// KafkaTopicEnvVar is the Kafka topic the CloudEvents are consumed from
+	KafkaTopicEnvVar = "KUBEARCHIVE_SINK_KAFKA_TOPIC"
+	// KafkaGroupEnvVar is the Kafka consumer group shared by the replicas of the sink
+	KafkaGroupEnvVar = "KUBEARCHIVE_SINK_KAFKA_GROUP"
+	// NatsURLEnvVar is the URL of the NATS server
+	NatsURLEnvVar = "KUBEARCHIVE_SINK_NATS_URL"
+	// NatsStreamEnvVar is the JetStream stream of the CloudEvents, it is created when it does not exist
+	NatsStreamEnvVar = "KUBEARCHIVE_SINK_NATS_STREAM"
+	// NatsSubjectEnvVar is the subject the CloudEvents are consumed from
+	NatsSubjectEnvVar = "KUBEARCHIVE_SINK_NATS_SUBJECT"
+	// NatsDurableEnvVar is the durable JetStream consumer shared by the replicas of the sink
+	NatsDurableEnvVar = "KUBEARCHIVE_SINK_NATS_DURABLE"
+	// MaxAttemptsEnvVar is the number of attempts to process a CloudEvent consumed from NATS before its message
+	// is delivered again later and the following messages are processed. The CloudEvents consumed from Kafka are
+	// retried until they are processed, as Kafka does not deliver a message again
+	MaxAttemptsEnvVar = "KUBEARCHIVE_SINK_CONSUMER_MAX_ATTEMPTS"
+
+	Kafka = "kafka"
+	Nats  = "nats"
+
+	// defaultGroup is the Kafka consumer group and the NATS durable consumer when they are not set
+	defaultGroup       = "kubearchive-sink"
+	defaultMaxAttempts = 10
+	clientName         = "kubearchive-sink"
+)
+
+var (
+	// minBackoff and maxBackoff are the delays between the attempts to process a CloudEvent and to reconnect
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// ProcessFunc processes a CloudEvent and returns the HTTP status the sink answers for it
+type ProcessFunc func(ctx context.Context, event *cloudevents.Event) int
+
+// receiver is the CloudEvents protocol binding of a message broker
+type receiver interface {
+	protocol.Opener
+	protocol.Receiver
+	protocol.Closer
+}
+
+// connectFunc returns a new receiver connected to the message broker
+type connectFunc func() (receiver, error)
+
+// Consumer reads the CloudEvents from a message broker and processes them one at a time, in the order they are
+// read. A message is acknowledged, so its offset is committed, only after its CloudEvent is processed. When the
+// processing fails it is retried with a backoff, so the following messages are not processed before it.
+type Consumer struct {
+	protocol string
+	connect  connectFunc
+	process  ProcessFunc
+	// maxAttempts is the number of attempts before the message is delivered again later, zero retries the
+	// message until it is processed
+	maxAttempts int
+
+	cancel context.CancelFunc
+	closed chan struct{}
+}
+
+// NewConsumerFromEnv returns the Consumer configured in the environment, or nil when the CloudEvents are only
+// received over HTTP
+func NewConsumerFromEnv(process ProcessFunc) (*Consumer, error) {
+	if os.Getenv(ProtocolEnvVar) == "" {
+		return nil, nil
+	}
+	maxAttempts := defaultMaxAttempts
+	if value := os.Getenv(MaxAttemptsEnvVar); value != "" {
+		var err error
+		if maxAttempts, err = strconv.Atoi(value); err != nil || maxAttempts < 1 {
+			return nil, fmt.Errorf("invalid %s value '%s', it must be a positive integer", MaxAttemptsEnvVar, value)
+		}
+	}
+
+	switch os.Getenv(ProtocolEnvVar) {
+	case Kafka:
+		brokers := strings.Split(os.Getenv(KafkaBrokersEnvVar), ",")
+		topic := os.Getenv(KafkaTopicEnvVar)
+		if brokers[0] == "" || topic == "" {
+			return nil, fmt.Errorf("%s and %s are required to consume from Kafka", KafkaBrokersEnvVar, KafkaTopicEnvVar)
+		}
+		return NewKafkaConsumer(brokers, topic, envOrDefault(KafkaGroupEnvVar, defaultGroup), process)
+	case Nats:
+		url := os.Getenv(NatsURLEnvVar)
+		stream := os.Getenv(NatsStreamEnvVar)
+		subject := os.Getenv(NatsSubjectEnvVar)
+		if url == "" || stream == "" || subject == "" {
+			return nil, fmt.Errorf("%s, %s and %s are required to consume from NATS", NatsURLEnvVar,
+				NatsStreamEnvVar, NatsSubjectEnvVar)
+		}
+		return NewNatsConsumer(url, stream, subject, envOrDefault(NatsDurableEnvVar, defaultGroup), maxAttempts,
+			process)
+	default:
+		return nil, fmt.Errorf("invalid %s value '%s', it must be '%s' or '%s'", ProtocolEnvVar,
+			os.Getenv(ProtocolEnvVar), Kafka, Nats)
+	}
+}
+
+// NewKafkaConsumer consumes the CloudEvents of the topic as a member of the consumer group. A new consumer group
+// starts from the oldest message of the topic. The CloudEvents are retried until they are processed, so the
+// offset of a partition never moves past a CloudEvent that was not processed.
+func NewKafkaConsumer(brokers []string, topic, group string, process ProcessFunc) (*Consumer, error) {
+	connect := func() (receiver, error) {
+		config := sarama.NewConfig()
+		config.ClientID = clientName
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+		return kafka_sarama.NewConsumer(brokers, config, group, topic)
+	}
+	return newConsumer(Kafka, connect, 0, process)
+}
+
+// NewNatsConsumer consumes the CloudEvents of the subject with a durable JetStream consumer. The replicas of the
+// sink share the durable consumer, so each message is processed by one of them. A CloudEvent that fails
+// maxAttempts times is delivered again after the maximum backoff, while the following messages are processed.
+func NewNatsConsumer(url, stream, subject, durable string, maxAttempts int, process ProcessFunc) (*Consumer, error) {
+	connect := func() (receiver, error) {
+		return nats_jetstream.NewConsumer(
+			url, stream, subject,
+			[]nats.Option{nats.Name(clientName), nats.MaxReconnects(-1)},
+			nil,
+			[]nats.SubOpt{nats.Durable(durable), nats.ManualAck(), nats.AckExplicit(), nats.DeliverAll()},
+			nats_jetstream.WithQueueSubscriber(durable),
+		)
+	}
+	return newConsumer(Nats, connect, maxAttempts, process)
+}
+
+// newConsumer connects to the message broker, so an invalid configuration fails on start, and starts consuming
+func newConsumer(protocol string, connect connectFunc, maxAttempts int, process ProcessFunc) (*Consumer, error) {
+	r, err := connect()
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to %s: %w", protocol, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Consumer{
+		protocol:    protocol,
+		connect:     connect,
+		process:     process,
+		maxAttempts: maxAttempts,
+		cancel:      cancel,
+		closed:      make(chan struct{}),
+	}
+	go c.run(ctx, r)
+	return c, nil
+}
+
+// Close stops the Consumer after the CloudEvent in progress is processed
+func (c *Consumer) Close() {
+	c.cancel()
+	<-c.closed
+}
+
+// run consumes from the receiver and, when the connection to the message broker is lost, from a new receiver
+func (c *Consumer) run(ctx context.Context, r receiver) {
+	defer close(c.closed)
+
+	for attempt := 1; ; attempt++ {
+		if r != nil {
+			if c.consume(ctx, r) {
+				attempt = 0
+			}
+			if err := r.Close(context.Background()); err != nil {
+				slog.Warn("Could not close the connection to the message broker", "protocol", c.protocol, "err", err)
+			}
+			r = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff(attempt)):
+		}
+
+		var err error
+		if r, err = c.connect(); err != nil {
+			slog.Error("Could not connect to the message broker", "protocol", c.protocol, "err", err)
+		}
+	}
+}
+
+// consume processes the CloudEvents of the receiver until the context is done or the receiver fails. It returns
+// whether any message was read.
+func (c *Consumer) consume(ctx context.Context, r receiver) bool {
+	inboundCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	inbound := make(chan error, 1)
+	go func() {
+		err := r.OpenInbound(inboundCtx)
+		// Receive and the backoff end once the inbound is closed, so the connection is opened again
+		cancel()
+		inbound <- err
+	}()
+
+	received := false
+	failures := 0
+	for inboundCtx.Err() == nil {
+		msg, err := r.Receive(inboundCtx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			failures++
+			delay := backoff(failures)
+			slog.Warn("Could not receive a message", "protocol", c.protocol, "err", err, "delay", delay)
+			select {
+			case <-inboundCtx.Done():
+			case <-time.After(delay):
+			}
+			continue
+		}
+		failures = 0
+		received = true
+		c.processMessage(ctx, msg)
+	}
+
+	cancel()
+	if err := <-inbound; err != nil && ctx.Err() == nil {
+		slog.Error("Lost the connection to the message broker", "protocol", c.protocol, "err", err)
+	}
+	return received
+}
+
+// processMessage processes the CloudEvent of the message until it succeeds, and acknowledges the message. The
+// messages without a valid CloudEvent are acknowledged, as they would fail again. The message is not
+// acknowledged when the processing fails maxAttempts times, so it is delivered again later.
+func (c *Consumer) processMessage(ctx context.Context, msg binding.Message) {
+	event, err := binding.ToEvent(ctx, msg)
+	if err != nil {
+		slog.Error("Could not parse a CloudEvent from a message, skipping it", "protocol", c.protocol, "err", err)
+		finish(msg, true)
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		// The CloudEvent in progress is processed even when the Consumer is closing
+		status := c.process(context.WithoutCancel(ctx), event)
+		if !retryable(status) {
+			if status != http.StatusAccepted {
+				slog.Error("Skipping a CloudEvent that can not be processed", "protocol", c.protocol,
+					"event-id", event.ID(), "event-type", event.Type(), "status", status)
+			}
+			finish(msg, true)
+			return
+		}
+
+		if c.maxAttempts > 0 && attempt >= c.maxAttempts {
+			slog.Error("Could not process a CloudEvent, delivering it again later", "protocol", c.protocol,
+				"event-id", event.ID(), "event-type", event.Type(), "status", status, "attempts", attempt)
+			giveUp(msg)
+			return
+		}
+
+		delay := backoff(attempt)
+		slog.Warn("Could not process a CloudEvent, retrying", "protocol", c.protocol, "event-id", event.ID(),
+			"event-type", event.Type(), "status", status, "attempt", attempt, "delay", delay)
+		inProgress(msg)
+		select {
+		case <-ctx.Done():
+			// The message is delivered again, to this or another replica
+			finish(msg, false)
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryable returns whether processing the CloudEvent again may succeed
+func retryable(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
+
+// finish acknowledges the message when ack is true, otherwise it asks for its redelivery. The protocol bindings
+// of JetStream do not acknowledge the messages, so they are acknowledged with the NATS client.
+func finish(msg binding.Message, ack bool) {
+	var err error
+	if natsMsg, ok := msg.(*nats_jetstream.Message); ok {
+		if ack {
+			err = natsMsg.Msg.AckSync()
+		} else {
+			err = natsMsg.Msg.Nak()
+		}
+	} else if ack {
+		err = msg.Finish(protocol.ResultACK)
+	} else {
+		err = msg.Finish(protocol.ResultNACK)
+	}
+	if err != nil {
+		slog.Warn("Could not finish a message", "ack", ack, "err", err)
+	}
+}
+
+// giveUp does not acknowledge the message of a CloudEvent that failed too many times. NATS delivers it again
+// after the maximum backoff, while the following messages are processed.
+func giveUp(msg binding.Message) {
+	if natsMsg, ok := msg.(*nats_jetstream.Message); ok {
+		if err := natsMsg.Msg.NakWithDelay(maxBackoff); err != nil {
+			slog.Warn("Could not finish a message", "ack", false, "err", err)
+		}
+		return
+	}
+	finish(msg, false)
+}
+
+// inProgress resets the redelivery timer of the message while its CloudEvent is retried
+func inProgress(msg binding.Message) {
+	if natsMsg, ok := msg.(*nats_jetstream.Message); ok {
+		if err := natsMsg.Msg.InProgress(); err != nil {
+			slog.Warn("Could not extend the acknowledgement deadline of a message", "err", err)
+		}
+	}
+}
+
+// backoff returns the delay after the attempt failed
+func backoff(attempt int) time.Duration {
+	delay := minBackoff
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+func envOrDefault(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,355 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package consumer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/protocol/nats_jetstream/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testStream  = "kubearchive"
+	testSubject = "kubearchive.events"
+)
+
+func init() {
+	minBackoff = 10 * time.Millisecond
+	maxBackoff = 50 * time.Millisecond
+}
+
+// recorder is a ProcessFunc that records the ids of the CloudEvents and answers the statuses of its results in
+// order, and HTTP 202 once they are used
+type recorder struct {
+	mu      sync.Mutex
+	ids     []string
+	results []int
+}
+
+func (r *recorder) process(_ context.Context, event *cloudevents.Event) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids = append(r.ids, event.ID())
+	if len(r.results) == 0 {
+		return http.StatusAccepted
+	}
+	status := r.results[0]
+	r.results = r.results[1:]
+	return status
+}
+
+func (r *recorder) processed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.ids...)
+}
+
+func newEvent(id string) cloudevents.Event {
+	event := cloudevents.NewEvent()
+	event.SetID(id)
+	event.SetSource("localhost:443")
+	event.SetType("org.kubearchive.sinkfilters.resource.archive-when")
+	_ = event.SetData(cloudevents.ApplicationJSON, map[string]any{"apiVersion": "v1", "kind": "Pod"})
+	return event
+}
+
+func startNatsServer(t testing.TB) string {
+	t.Helper()
+	natsServer, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	go natsServer.Start()
+	if !natsServer.ReadyForConnections(5 * time.Second) {
+		assert.FailNow(t, "the NATS server did not start")
+	}
+	t.Cleanup(natsServer.Shutdown)
+	return natsServer.ClientURL()
+}
+
+func sendNatsEvents(t testing.TB, url string, ids ...string) {
+	t.Helper()
+	sender, err := nats_jetstream.NewSender(url, testStream, testSubject, nil, nil)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer sender.Close(context.Background())
+	client, err := cloudevents.NewClient(sender)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	for _, id := range ids {
+		result := client.Send(context.Background(), newEvent(id))
+		assert.False(t, cloudevents.IsUndelivered(result), result)
+	}
+}
+
+func TestNatsConsumer(t *testing.T) {
+	url := startNatsServer(t)
+	rec := &recorder{results: []int{http.StatusInternalServerError, http.StatusServiceUnavailable}}
+
+	consumer, err := NewNatsConsumer(url, testStream, testSubject, "test", defaultMaxAttempts, rec.process)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	sendNatsEvents(t, url, "1", "2")
+	// The first CloudEvent is retried until it is processed, before the second one
+	assert.Eventually(t, func() bool { return len(rec.processed()) == 4 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"1", "1", "1", "2"}, rec.processed())
+
+	conn, err := nats.Connect(url)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer conn.Close()
+	js, err := conn.JetStream()
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Eventually(t, func() bool {
+		info, infoErr := js.ConsumerInfo(testStream, "test")
+		return infoErr == nil && info.NumAckPending == 0 && info.NumPending == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	consumer.Close()
+
+	// The acknowledged CloudEvents are not delivered again to the durable consumer
+	consumer, err = NewNatsConsumer(url, testStream, testSubject, "test", defaultMaxAttempts, rec.process)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer consumer.Close()
+	sendNatsEvents(t, url, "3")
+	assert.Eventually(t, func() bool { return len(rec.processed()) == 5 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "3", rec.processed()[4])
+}
+
+// fakeReceiver delivers the messages of a channel
+type fakeReceiver struct {
+	messages chan binding.Message
+}
+
+func (f *fakeReceiver) OpenInbound(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (f *fakeReceiver) Receive(ctx context.Context) (binding.Message, error) {
+	select {
+	case msg := <-f.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, io.EOF
+	}
+}
+
+func (f *fakeReceiver) Close(context.Context) error {
+	return nil
+}
+
+func TestConsumerFinish(t *testing.T) {
+	retries := make([]int, 100)
+	for i := range retries {
+		retries[i] = http.StatusInternalServerError
+	}
+	tests := []struct {
+		name    string
+		results []int
+		ack     bool
+		calls   int
+	}{
+		{name: "processed", results: []int{http.StatusAccepted}, ack: true, calls: 1},
+		{name: "invalid", results: []int{http.StatusUnprocessableEntity}, ack: true, calls: 1},
+		{name: "retried", results: []int{http.StatusInternalServerError}, ack: true, calls: 2},
+		{name: "closed while retrying", results: retries, ack: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{results: tt.results}
+			fake := &fakeReceiver{messages: make(chan binding.Message)}
+			consumer, err := newConsumer("fake", func() (receiver, error) { return fake, nil }, defaultMaxAttempts,
+				rec.process)
+			if err != nil {
+				assert.FailNow(t, err.Error())
+			}
+
+			finished := make(chan error, 1)
+			event := newEvent("1")
+			fake.messages <- binding.WithFinish(binding.ToMessage(&event), func(err error) { finished <- err })
+			if tt.ack {
+				assert.Equal(t, protocol.ResultACK, <-finished)
+				assert.Len(t, rec.processed(), tt.calls)
+				consumer.Close()
+				return
+			}
+
+			assert.Eventually(t, func() bool { return len(rec.processed()) >= 2 }, time.Second, time.Millisecond)
+			consumer.Close()
+			assert.Equal(t, protocol.ResultNACK, <-finished)
+		})
+	}
+}
+
+func TestConsumerGivesUp(t *testing.T) {
+	rec := &recorder{results: []int{http.StatusInternalServerError, http.StatusInternalServerError,
+		http.StatusInternalServerError}}
+	fake := &fakeReceiver{messages: make(chan binding.Message)}
+	consumer, err := newConsumer(Nats, func() (receiver, error) { return fake, nil }, 3, rec.process)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer consumer.Close()
+
+	finished := make(chan error, 2)
+	for _, id := range []string{"1", "2"} {
+		event := newEvent(id)
+		fake.messages <- binding.WithFinish(binding.ToMessage(&event), func(err error) { finished <- err })
+	}
+	// The first CloudEvent is not acknowledged after three attempts and the second one is processed
+	assert.Equal(t, protocol.ResultNACK, <-finished)
+	assert.Equal(t, protocol.ResultACK, <-finished)
+	assert.Equal(t, []string{"1", "1", "1", "2"}, rec.processed())
+}
+
+func TestConsumerKafkaKeepsRetrying(t *testing.T) {
+	retries := make([]int, 2*defaultMaxAttempts)
+	for i := range retries {
+		retries[i] = http.StatusInternalServerError
+	}
+	rec := &recorder{results: retries}
+	fake := &fakeReceiver{messages: make(chan binding.Message)}
+	consumer, err := newConsumer(Kafka, func() (receiver, error) { return fake, nil }, 0, rec.process)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer consumer.Close()
+
+	finished := make(chan error, 1)
+	event := newEvent("1")
+	fake.messages <- binding.WithFinish(binding.ToMessage(&event), func(err error) { finished <- err })
+
+	// The CloudEvent is acknowledged once the failures end, after more attempts than the NATS maximum
+	assert.Equal(t, protocol.ResultACK, <-finished)
+	assert.Len(t, rec.processed(), len(retries)+1)
+}
+
+// failingReceiver fails to receive the messages, and closes its inbound when closeInbound is signaled
+type failingReceiver struct {
+	receives     atomic.Int32
+	closeInbound chan error
+}
+
+func (f *failingReceiver) OpenInbound(ctx context.Context) error {
+	select {
+	case err := <-f.closeInbound:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func (f *failingReceiver) Receive(ctx context.Context) (binding.Message, error) {
+	f.receives.Add(1)
+	if ctx.Err() != nil {
+		return nil, io.EOF
+	}
+	return nil, errors.New("broker unavailable")
+}
+
+func (f *failingReceiver) Close(context.Context) error {
+	return nil
+}
+
+func TestConsumerReceiveFails(t *testing.T) {
+	fake := &failingReceiver{closeInbound: make(chan error, 1)}
+	var connects atomic.Int32
+	consumer, err := newConsumer("fake", func() (receiver, error) {
+		connects.Add(1)
+		return fake, nil
+	}, defaultMaxAttempts, (&recorder{}).process)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer consumer.Close()
+
+	// The failures are retried with the backoff instead of in a busy loop
+	time.Sleep(100 * time.Millisecond)
+	assert.Less(t, fake.receives.Load(), int32(10))
+
+	// Closing the inbound interrupts the backoff and the receiver connects again
+	fake.closeInbound <- errors.New("connection lost")
+	assert.Eventually(t, func() bool { return connects.Load() == 2 }, time.Second, time.Millisecond)
+}
+
+func TestNewConsumerFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "disabled", env: map[string]string{}},
+		{name: "invalid protocol", env: map[string]string{ProtocolEnvVar: "amqp"}, wantErr: "invalid KUBEARCHIVE_SINK_CONSUMER"},
+		{
+			name:    "invalid max attempts",
+			env:     map[string]string{ProtocolEnvVar: Nats, MaxAttemptsEnvVar: "0"},
+			wantErr: "invalid KUBEARCHIVE_SINK_CONSUMER_MAX_ATTEMPTS",
+		},
+		{
+			name:    "kafka without topic",
+			env:     map[string]string{ProtocolEnvVar: Kafka, KafkaBrokersEnvVar: "kafka:9092"},
+			wantErr: "KUBEARCHIVE_SINK_KAFKA_TOPIC are required",
+		},
+		{
+			name:    "nats without subject",
+			env:     map[string]string{ProtocolEnvVar: Nats, NatsURLEnvVar: "nats://nats:4222", NatsStreamEnvVar: "kubearchive"},
+			wantErr: "KUBEARCHIVE_SINK_NATS_SUBJECT are required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{ProtocolEnvVar, KafkaBrokersEnvVar, KafkaTopicEnvVar, NatsURLEnvVar, NatsStreamEnvVar, NatsSubjectEnvVar, MaxAttemptsEnvVar} {
+				t.Setenv(name, tt.env[name])
+			}
+			consumer, err := NewConsumerFromEnv((&recorder{}).process)
+			assert.Nil(t, consumer)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewConsumerFromEnvNats(t *testing.T) {
+	t.Setenv(ProtocolEnvVar, Nats)
+	t.Setenv(NatsURLEnvVar, startNatsServer(t))
+	t.Setenv(NatsStreamEnvVar, testStream)
+	t.Setenv(NatsSubjectEnvVar, testSubject)
+
+	consumer, err := NewConsumerFromEnv((&recorder{}).process)
+	assert.NoError(t, err)
+	if assert.NotNil(t, consumer) {
+		consumer.Close()
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dedup
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+)
+
+const (
+	// SizeEnvVar is the number of processed CloudEvents remembered in memory. Duplicates are not detected when
+	// neither SizeEnvVar nor DatabaseEnvVar are set
+	SizeEnvVar = "KUBEARCHIVE_SINK_DEDUP_SIZE"
+	// DatabaseEnvVar records the processed CloudEvents in the database too when it is "true"
+	DatabaseEnvVar = "KUBEARCHIVE_SINK_DEDUP_DATABASE"
+	// RetentionEnvVar is how long the processed CloudEvents are kept in the database, as a duration like "24h"
+	RetentionEnvVar = "KUBEARCHIVE_SINK_DEDUP_RETENTION"
+
+	defaultSize      = 10000
+	defaultRetention = 24 * time.Hour
+	// pruneInterval is the time between the deletions of the expired CloudEvents from the database
+	pruneInterval = time.Hour
+	// dbTimeout is the maximum time of each query to the database
+	dbTimeout = 5 * time.Second
+)
+
+type Result int
+
+const (
+	// New is returned for the CloudEvents that must be processed
+	New Result = iota
+	// Duplicate is returned for the CloudEvents already processed
+	Duplicate
+	// InProgress is returned for the CloudEvents being processed by another request
+	InProgress
+)
+
+// Deduplicator detects the CloudEvents the sink already processed, keyed by their source and id. It remembers
+// the last processed CloudEvents in memory and, optionally, all the CloudEvents processed during the retention
+// in the database, so the duplicates are also detected after a restart or by other replicas.
+type Deduplicator struct {
+	// db is nil when the processed CloudEvents are only remembered in memory
+	db        interfaces.DBWriter
+	size      int
+	retention time.Duration
+
+	mu        sync.Mutex
+	order     *list.List
+	processed map[string]*list.Element
+	inFlight  map[string]struct{}
+
+	closing chan struct{}
+	closed  chan struct{}
+}
+
+func NewDeduplicator(size int, db interfaces.DBWriter, retention time.Duration) *Deduplicator {
+	d := &Deduplicator{
+		db:        db,
+		size:      size,
+		retention: retention,
+		order:     list.New(),
+		processed: make(map[string]*list.Element),
+		inFlight:  make(map[string]struct{}),
+		closing:   make(chan struct{}),
+		closed:    make(chan struct{}),
+	}
+	if db != nil {
+		go d.prune()
+	} else {
+		close(d.closed)
+	}
+	return d
+}
+
+// NewDeduplicatorFromEnv returns the Deduplicator configured in the environment, or nil when duplicates should
+// not be detected
+func NewDeduplicatorFromEnv(db interfaces.DBWriter) (*Deduplicator, error) {
+	rawSize := os.Getenv(SizeEnvVar)
+	useDatabase := os.Getenv(DatabaseEnvVar) == "true"
+	if rawSize == "" && !useDatabase {
+		return nil, nil
+	}
+
+	size := defaultSize
+	if rawSize != "" {
+		var err error
+		size, err = strconv.Atoi(rawSize)
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("invalid %s value '%s', it must be a positive integer", SizeEnvVar, rawSize)
+		}
+	}
+	if !useDatabase {
+		return NewDeduplicator(size, nil, 0), nil
+	}
+
+	retention := defaultRetention
+	if rawRetention := os.Getenv(RetentionEnvVar); rawRetention != "" {
+		var err error
+		retention, err = time.ParseDuration(rawRetention)
+		if err != nil || retention <= 0 {
+			return nil, fmt.Errorf("invalid %s value '%s', it must be a positive duration", RetentionEnvVar, rawRetention)
+		}
+	}
+	return NewDeduplicator(size, db, retention), nil
+}
+
+// Begin starts the processing of the CloudEvent. When it returns New, Done must be called once the CloudEvent
+// is processed. When the database can not be queried the CloudEvent is processed, as processing it twice is
+// better than losing it.
+func (d *Deduplicator) Begin(ctx context.Context, source, id string) Result {
+	key := eventKey(source, id)
+
+	d.mu.Lock()
+	if element, ok := d.processed[key]; ok {
+		d.order.MoveToFront(element)
+		d.mu.Unlock()
+		return Duplicate
+	}
+	if _, ok := d.inFlight[key]; ok {
+		d.mu.Unlock()
+		return InProgress
+	}
+	d.inFlight[key] = struct{}{}
+	d.mu.Unlock()
+
+	if d.db == nil {
+		return New
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+	processed, err := d.db.IsEventProcessed(dbCtx, source, id)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not check if the CloudEvent was processed", "event-id", id, "source", source,
+			"err", err)
+		return New
+	}
+	if processed {
+		d.mu.Lock()
+		delete(d.inFlight, key)
+		d.remember(key)
+		d.mu.Unlock()
+		return Duplicate
+	}
+	return New
+}
+
+// Done ends the processing of the CloudEvent started with Begin. The CloudEvent is recorded as processed when
+// processed is true, otherwise its redelivery is processed again.
+func (d *Deduplicator) Done(ctx context.Context, source, id string, processed bool) {
+	key := eventKey(source, id)
+
+	d.mu.Lock()
+	delete(d.inFlight, key)
+	if processed {
+		d.remember(key)
+	}
+	d.mu.Unlock()
+
+	if !processed || d.db == nil {
+		return
+	}
+	dbCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), dbTimeout)
+	defer cancel()
+	if err := d.db.WriteProcessedEvent(dbCtx, source, id); err != nil {
+		slog.WarnContext(ctx, "Could not record the CloudEvent as processed", "event-id", id, "source", source,
+			"err", err)
+	}
+}
+
+// Close stops the deletion of the expired CloudEvents from the database
+func (d *Deduplicator) Close() {
+	close(d.closing)
+	<-d.closed
+}
+
+// remember adds the key to the processed CloudEvents, forgetting the least recently seen when the Deduplicator
+// is full. It must be called with the lock held.
+func (d *Deduplicator) remember(key string) {
+	if element, ok := d.processed[key]; ok {
+		d.order.MoveToFront(element)
+		return
+	}
+	d.processed[key] = d.order.PushFront(key)
+	if d.order.Len() > d.size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.processed, oldest.Value.(string))
+	}
+}
+
+// prune deletes the CloudEvents processed before the retention from the database
+func (d *Deduplicator) prune() {
+	defer close(d.closed)
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.closing:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+		deleted, err := d.db.DeleteProcessedEvents(ctx, time.Now().Add(-d.retention))
+		cancel()
+		if err != nil {
+			slog.Warn("Could not delete the expired processed CloudEvents", "err", err)
+			continue
+		}
+		slog.Debug("Deleted the expired processed CloudEvents", "deleted", deleted)
+	}
+}
+
+func eventKey(source, id string) string {
+	return source + "\x00" + id
+}
@@ -0,0 +1,124 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dedup
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+const source = "localhost:443/apis/batch/v1/namespaces/test/jobs"
+
+func TestDeduplicatorMemory(t *testing.T) {
+	ctx := context.Background()
+	d := NewDeduplicator(2, nil, 0)
+	defer d.Close()
+
+	assert.Equal(t, New, d.Begin(ctx, source, "a"))
+	assert.Equal(t, InProgress, d.Begin(ctx, source, "a"))
+	d.Done(ctx, source, "a", true)
+	assert.Equal(t, Duplicate, d.Begin(ctx, source, "a"))
+	// The same id from another source is another CloudEvent
+	assert.Equal(t, New, d.Begin(ctx, "other", "a"))
+	d.Done(ctx, "other", "a", true)
+
+	// A CloudEvent that failed is processed again
+	assert.Equal(t, New, d.Begin(ctx, source, "b"))
+	d.Done(ctx, source, "b", false)
+	assert.Equal(t, New, d.Begin(ctx, source, "b"))
+	d.Done(ctx, source, "b", true)
+
+	// The least recently seen CloudEvent is forgotten
+	assert.Equal(t, New, d.Begin(ctx, source, "c"))
+	d.Done(ctx, source, "c", true)
+	assert.Equal(t, Duplicate, d.Begin(ctx, source, "b"))
+	assert.Equal(t, Duplicate, d.Begin(ctx, source, "c"))
+	assert.Equal(t, New, d.Begin(ctx, source, "a"))
+}
+
+func TestDeduplicatorDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := fake.NewFakeDatabase(nil, nil, "")
+	d := NewDeduplicator(1, db, defaultRetention)
+	assert.Equal(t, New, d.Begin(ctx, source, "a"))
+	d.Done(ctx, source, "a", true)
+	d.Close()
+
+	// Another Deduplicator, like the one of another replica, finds the CloudEvent in the database
+	d = NewDeduplicator(1, db, defaultRetention)
+	defer d.Close()
+	assert.Equal(t, Duplicate, d.Begin(ctx, source, "a"))
+	assert.Equal(t, New, d.Begin(ctx, source, "b"))
+}
+
+func TestDeduplicatorDatabaseError(t *testing.T) {
+	ctx := context.Background()
+	d := NewDeduplicator(1, fake.NewFakeDatabaseWithError(errors.New("database unavailable")), defaultRetention)
+	defer d.Close()
+
+	assert.Equal(t, New, d.Begin(ctx, source, "a"))
+	d.Done(ctx, source, "a", true)
+	// The CloudEvent is still remembered in memory
+	assert.Equal(t, Duplicate, d.Begin(ctx, source, "a"))
+}
+
+func TestNewDeduplicatorFromEnv(t *testing.T) {
+	tests := []struct {
+		name          string
+		size          string
+		database      string
+		retention     string
+		enabled       bool
+		expectedError string
+	}{
+		{
+			name: "not configured",
+		},
+		{
+			name:    "memory",
+			size:    "500",
+			enabled: true,
+		},
+		{
+			name:      "database",
+			database:  "true",
+			retention: "1h",
+			enabled:   true,
+		},
+		{
+			name:          "invalid size",
+			size:          "-1",
+			expectedError: SizeEnvVar,
+		},
+		{
+			name:          "invalid retention",
+			database:      "true",
+			retention:     "1d",
+			expectedError: RetentionEnvVar,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(SizeEnvVar, tt.size)
+			t.Setenv(DatabaseEnvVar, tt.database)
+			t.Setenv(RetentionEnvVar, tt.retention)
+
+			d, err := NewDeduplicatorFromEnv(fake.NewFakeDatabase(nil, nil, ""))
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.enabled, d != nil)
+			if d != nil {
+				d.Close()
+			}
+		})
+	}
+}
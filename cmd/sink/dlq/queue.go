@@ -0,0 +1,561 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dlq
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubearchive/kubearchive/pkg/observability"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// PathEnvVar is the directory of the dead-letter queue, usually a persistent volume. The queue is disabled
+	// when it is not set
+	PathEnvVar = "KUBEARCHIVE_SINK_DLQ_PATH"
+	// MaxSizeEnvVar is the maximum size of the entries in the queue, as a quantity like "1Gi". The resources
+	// that do not fit are not added to the queue
+	MaxSizeEnvVar  = "KUBEARCHIVE_SINK_DLQ_MAX_SIZE"
+	defaultMaxSize = 1024 * 1024 * 1024
+
+	segmentPrefix = "segment-"
+	segmentSuffix = ".log"
+
+	opAdd    = "add"
+	opRemove = "remove"
+	// opNextID is the first record of each segment after the first one, with the next id of the queue, so the
+	// ids are not reused when the segments of the removed entries are deleted
+	opNextID = "next"
+)
+
+// maxSegmentSize is the size of a segment after which the records are appended to a new segment
+var maxSegmentSize int64 = 8 * 1024 * 1024
+
+// ErrNotFound is returned when the entry is not in the queue
+var ErrNotFound = errors.New("the entry is not in the dead-letter queue")
+
+// ErrFull is returned when the entry does not fit in the maximum size of the queue
+var ErrFull = errors.New("the dead-letter queue is full")
+
+// Entry is a resource whose write to the database failed, with the CloudEvent it was received in
+type Entry struct {
+	ID         uint64    `json:"id"`
+	Added      time.Time `json:"added"`
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace"`
+	Name       string    `json:"name"`
+	UID        string    `json:"uid"`
+	// Error is the error of the write that failed
+	Error    string          `json:"error"`
+	Event    json.RawMessage `json:"event,omitempty"`
+	Resource json.RawMessage `json:"resource,omitempty"`
+
+	// Attempts, LastError and NextAttempt are the state of the replays, they are not persisted
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"lastError,omitempty"`
+	NextAttempt time.Time `json:"nextAttempt"`
+
+	// segment, offset and length locate the add record of the entry, the CloudEvent and the resource are read
+	// from it instead of being kept in memory
+	segment *segment
+	offset  int64
+	length  int64
+}
+
+// record is a line of a segment. Adding an entry appends an add record with the entry and removing it
+// appends a remove record with its id.
+type record struct {
+	Op    string `json:"op"`
+	ID    uint64 `json:"id"`
+	Entry *Entry `json:"entry,omitempty"`
+}
+
+// segment is a file of the log, live is the number of entries added in the segment that were not removed
+// and size the size of the file
+type segment struct {
+	index int
+	live  int
+	size  int64
+}
+
+// Queue is a durable queue of the resources that could not be written to the database. It is an append-only
+// log of records split in segment files, each record is synced to disk before Add or Remove return. Only the
+// summary of the entries and the location of their records are kept in memory, and the oldest segments are
+// deleted once all their entries are removed. When the records of the removed entries take more space than
+// the records of the entries in the queue, the entries of the oldest segment are appended again to the active
+// segment so it can be deleted, an entry that is never replayed does not keep the segments after it. The
+// entries are rejected with ErrFull once the records of the entries in the queue reach maxSize bytes, so the
+// segments take at most twice that size plus a segment.
+type Queue struct {
+	dir     string
+	maxSize int64
+	// wake is signaled when the entries should be replayed without waiting for the next replay
+	wake chan struct{}
+
+	mu       sync.Mutex
+	entries  map[uint64]*Entry
+	segments []*segment
+	file     *os.File
+	size     int64
+	nextID   uint64
+	// liveSize is the size of the add records of the entries in the queue
+	liveSize int64
+}
+
+// NewQueue opens the queue in dir, loading the entries of its segments
+func NewQueue(dir string, maxSize int64) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("could not create the dead-letter queue directory: %w", err)
+	}
+	q := &Queue{dir: dir, maxSize: maxSize, wake: make(chan struct{}, 1), entries: map[uint64]*Entry{}, nextID: 1}
+
+	indexes, err := q.segmentIndexes()
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		if err = q.load(&segment{index: index}); err != nil {
+			return nil, err
+		}
+	}
+	if len(q.segments) == 0 {
+		q.segments = append(q.segments, &segment{index: 1})
+	}
+	if err = q.openActive(); err != nil {
+		return nil, err
+	}
+	q.compact()
+
+	observability.DeadLetters.Add(context.Background(), int64(len(q.entries)))
+	return q, nil
+}
+
+// NewQueueFromEnv returns the Queue configured in the environment, or nil when the queue is disabled
+func NewQueueFromEnv() (*Queue, error) {
+	dir := os.Getenv(PathEnvVar)
+	if dir == "" {
+		return nil, nil
+	}
+
+	var maxSize int64 = defaultMaxSize
+	if value := os.Getenv(MaxSizeEnvVar); value != "" {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil || quantity.Value() < 1 {
+			return nil, fmt.Errorf("invalid %s value '%s', it must be a positive quantity like 1Gi", MaxSizeEnvVar,
+				value)
+		}
+		maxSize = quantity.Value()
+	}
+	return NewQueue(dir, maxSize)
+}
+
+// Add appends entry to the queue and returns it with its id. It returns ErrFull when the entry does not fit
+// in the maximum size of the queue.
+func (q *Queue) Add(entry Entry) (Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry.ID = q.nextID
+	entry.Added = time.Now().UTC()
+	line, err := marshalRecord(record{Op: opAdd, ID: entry.ID, Entry: &entry})
+	if err != nil {
+		return Entry{}, fmt.Errorf("could not add the entry to the dead-letter queue: %w", err)
+	}
+	if q.liveSize+int64(len(line)) > q.maxSize {
+		return Entry{}, ErrFull
+	}
+	offset, err := q.append(line)
+	if err != nil {
+		return Entry{}, fmt.Errorf("could not add the entry to the dead-letter queue: %w", err)
+	}
+	q.nextID++
+
+	summary := entry
+	summary.Event = nil
+	summary.Resource = nil
+	q.track(&summary, q.segments[len(q.segments)-1], offset, int64(len(line)))
+	observability.DeadLetters.Add(context.Background(), 1)
+	return entry, nil
+}
+
+// Remove removes the entry from the queue
+func (q *Queue) Remove(id uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, ok := q.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	line, err := marshalRecord(record{Op: opRemove, ID: id})
+	if err == nil {
+		_, err = q.append(line)
+	}
+	if err != nil {
+		return fmt.Errorf("could not remove the entry from the dead-letter queue: %w", err)
+	}
+
+	q.untrack(entry)
+	q.compact()
+	observability.DeadLetters.Add(context.Background(), -1)
+	return nil
+}
+
+// Get returns the entry with id, with its CloudEvent and resource read from its segment
+func (q *Queue) Get(id uint64) (Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, ok := q.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	// The segment is not deleted while the lock is held, because the entry is not removed
+	file, err := os.Open(q.path(entry.segment))
+	if err != nil {
+		return Entry{}, fmt.Errorf("could not open the segment of the dead-letter queue: %w", err)
+	}
+	defer file.Close()
+	line := make([]byte, entry.length)
+	if _, err = file.ReadAt(line, entry.offset); err != nil {
+		return Entry{}, fmt.Errorf("could not read the entry %d of the dead-letter queue: %w", id, err)
+	}
+	var rec record
+	if err = json.Unmarshal(line, &rec); err != nil || rec.Entry == nil || rec.ID != id {
+		return Entry{}, fmt.Errorf("invalid record of the entry %d of the dead-letter queue: %w", id, err)
+	}
+
+	result := *entry
+	result.Event = rec.Entry.Event
+	result.Resource = rec.Entry.Resource
+	return result, nil
+}
+
+// List returns the entries ordered by id, without their CloudEvent and resource
+func (q *Queue) List() []Entry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries := make([]Entry, 0, len(q.entries))
+	for _, entry := range q.entries {
+		entries = append(entries, *entry)
+	}
+	slices.SortFunc(entries, func(a, b Entry) int { return cmp.Compare(a.ID, b.ID) })
+	return entries
+}
+
+// Len returns the number of entries in the queue
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// Replay writes the entry with replay and removes it from the queue when it succeeds. When it fails, the
+// next attempt of the entry is delayed with an exponential backoff.
+func (q *Queue) Replay(ctx context.Context, id uint64, replay ReplayFunc) error {
+	entry, err := q.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if err = replay(ctx, entry); err != nil {
+		q.mu.Lock()
+		if current, ok := q.entries[id]; ok {
+			current.Attempts++
+			current.LastError = err.Error()
+			current.NextAttempt = time.Now().Add(backoff(current.Attempts))
+		}
+		q.mu.Unlock()
+		return err
+	}
+
+	// The entry may have been replayed at the same time by the replayer and an administrator
+	if err = q.Remove(id); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// RetryAll makes all the entries due and wakes up the replayer
+func (q *Queue) RetryAll() {
+	q.mu.Lock()
+	for _, entry := range q.entries {
+		entry.NextAttempt = time.Time{}
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close closes the active segment
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.file.Close()
+}
+
+// due returns the ids of the entries whose next attempt is before now, ordered by id
+func (q *Queue) due(now time.Time) []uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var ids []uint64
+	for id, entry := range q.entries {
+		if !entry.NextAttempt.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// marshalRecord returns the line of the record in a segment
+func marshalRecord(rec record) ([]byte, error) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// append writes the line of a record to the active segment, syncs it and returns its offset in the segment.
+// When the segment is full, the line is written to a new segment that starts with the next id of the queue.
+func (q *Queue) append(line []byte) (int64, error) {
+	if q.size > 0 && q.size+int64(len(line)) > maxSegmentSize {
+		if err := q.file.Close(); err != nil {
+			return 0, err
+		}
+		q.segments = append(q.segments, &segment{index: q.segments[len(q.segments)-1].index + 1})
+		if err := q.openActive(); err != nil {
+			return 0, err
+		}
+		next, err := marshalRecord(record{Op: opNextID, ID: q.nextID})
+		if err != nil {
+			return 0, err
+		}
+		if err = q.write(next); err != nil {
+			return 0, err
+		}
+	}
+
+	offset := q.size
+	if err := q.write(line); err != nil {
+		return 0, err
+	}
+	return offset, q.file.Sync()
+}
+
+func (q *Queue) write(line []byte) error {
+	if _, err := q.file.Write(line); err != nil {
+		return err
+	}
+	q.size += int64(len(line))
+	q.segments[len(q.segments)-1].size = q.size
+	return nil
+}
+
+// compact deletes the oldest segments without live entries. Only a prefix of the segments is deleted, so a
+// remove record is never deleted while the segment with its add record is kept. When the oldest segment has
+// live entries and most of the segments are records of removed entries, its entries are relocated first.
+func (q *Queue) compact() {
+	for len(q.segments) > 1 {
+		oldest := q.segments[0]
+		if oldest.live > 0 {
+			if q.diskSize()-q.liveSize <= q.liveSize {
+				return
+			}
+			if err := q.relocate(oldest); err != nil {
+				slog.Error("Could not relocate the entries of a segment of the dead-letter queue",
+					"segment", q.path(oldest), "err", err)
+				return
+			}
+		}
+		if err := os.Remove(q.path(oldest)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Could not delete a segment of the dead-letter queue", "segment", q.path(oldest), "err", err)
+			return
+		}
+		q.segments = q.segments[1:]
+	}
+}
+
+// relocate appends the add records of the live entries of the segment to the active segment, in the order of
+// their ids. A crash before the segment is deleted leaves both records, the last one is loaded.
+func (q *Queue) relocate(seg *segment) error {
+	var entries []*Entry
+	for _, entry := range q.entries {
+		if entry.segment == seg {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b *Entry) int { return cmp.Compare(a.ID, b.ID) })
+
+	file, err := os.Open(q.path(seg))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	for _, entry := range entries {
+		line := make([]byte, entry.length)
+		if _, err = file.ReadAt(line, entry.offset); err != nil {
+			return err
+		}
+		offset, appendErr := q.append(line)
+		if appendErr != nil {
+			return appendErr
+		}
+		q.untrack(entry)
+		q.track(entry, q.segments[len(q.segments)-1], offset, int64(len(line)))
+	}
+	return nil
+}
+
+// diskSize returns the size of the segments
+func (q *Queue) diskSize() int64 {
+	var size int64
+	for _, seg := range q.segments {
+		size += seg.size
+	}
+	return size
+}
+
+// openActive opens the last segment to append records to it
+func (q *Queue) openActive() error {
+	path := q.path(q.segments[len(q.segments)-1])
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600) // #nosec G304 -- the path is built by the queue
+	if err != nil {
+		return fmt.Errorf("could not open the segment of the dead-letter queue: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	q.file = file
+	q.size = info.Size()
+	return nil
+}
+
+// load applies the records of the segment. A record without the line end is the partial write of a crash,
+// it is truncated because it was never acknowledged.
+func (q *Queue) load(seg *segment) error {
+	path := q.path(seg)
+	file, err := os.OpenFile(path, os.O_RDWR, 0600) // #nosec G304 -- the path is built by the queue
+	if err != nil {
+		return fmt.Errorf("could not open the segment of the dead-letter queue: %w", err)
+	}
+	defer file.Close()
+	q.segments = append(q.segments, seg)
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if errors.Is(readErr, io.EOF) {
+			if len(line) > 0 {
+				slog.Warn("Truncating a partial record of the dead-letter queue", "segment", path, "offset", offset)
+				seg.size = offset
+				return file.Truncate(offset)
+			}
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+		seg.size = offset + int64(len(line))
+
+		var rec record
+		if err = json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("invalid record at offset %d of the segment %s: %w", offset, path, err)
+		}
+		q.apply(rec, seg, offset, int64(len(line)))
+		offset += int64(len(line))
+	}
+}
+
+// apply applies the record at offset of the segment, with length bytes
+func (q *Queue) apply(rec record, seg *segment, offset, length int64) {
+	switch rec.Op {
+	case opAdd:
+		if rec.Entry == nil {
+			return
+		}
+		entry := *rec.Entry
+		entry.Event = nil
+		entry.Resource = nil
+		// The entry was relocated, the record loaded last is kept
+		if relocated, ok := q.entries[entry.ID]; ok {
+			q.untrack(relocated)
+		}
+		q.track(&entry, seg, offset, length)
+		q.nextID = max(q.nextID, entry.ID+1)
+	case opRemove:
+		if entry, ok := q.entries[rec.ID]; ok {
+			q.untrack(entry)
+		}
+		q.nextID = max(q.nextID, rec.ID+1)
+	case opNextID:
+		q.nextID = max(q.nextID, rec.ID)
+	}
+}
+
+// track adds the entry whose add record is at offset of the segment, with length bytes
+func (q *Queue) track(entry *Entry, seg *segment, offset, length int64) {
+	entry.segment = seg
+	entry.offset = offset
+	entry.length = length
+	seg.live++
+	q.liveSize += length
+	q.entries[entry.ID] = entry
+}
+
+func (q *Queue) untrack(entry *Entry) {
+	entry.segment.live--
+	q.liveSize -= entry.length
+	delete(q.entries, entry.ID)
+}
+
+// segmentIndexes returns the indexes of the segment files in the directory in ascending order
+func (q *Queue) segmentIndexes() ([]int, error) {
+	files, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the dead-letter queue directory: %w", err)
+	}
+	var indexes []int
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		index, convErr := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix))
+		if convErr != nil {
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	slices.Sort(indexes)
+	return indexes, nil
+}
+
+func (q *Queue) path(seg *segment) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%s%010d%s", segmentPrefix, seg.index, segmentSuffix))
+}
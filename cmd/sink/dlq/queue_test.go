@@ -0,0 +1,266 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dlq
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEntry(name string) Entry {
+	return Entry{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Namespace:  "test",
+		Name:       name,
+		UID:        "uid-" + name,
+		Error:      "database unavailable",
+		Event:      json.RawMessage(`{"specversion":"1.0"}`),
+		Resource:   json.RawMessage(`{"kind":"Job"}`),
+	}
+}
+
+func newTestQueue(t *testing.T, dir string) *Queue {
+	t.Helper()
+	queue, err := NewQueue(dir, defaultMaxSize)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	t.Cleanup(func() { queue.Close() })
+	return queue
+}
+
+func listNames(queue *Queue) []string {
+	var names []string
+	for _, entry := range queue.List() {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func TestQueueReopen(t *testing.T) {
+	dir := t.TempDir()
+	queue := newTestQueue(t, dir)
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := queue.Add(newTestEntry(name))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, queue.Remove(2))
+	assert.ErrorIs(t, queue.Remove(2), ErrNotFound)
+	assert.NoError(t, queue.Close())
+
+	queue = newTestQueue(t, dir)
+	assert.Equal(t, []string{"a", "c"}, listNames(queue))
+	entry, err := queue.Get(3)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"kind":"Job"}`, string(entry.Resource))
+	assert.Nil(t, queue.List()[1].Resource)
+
+	// The ids are not reused after reopening the queue
+	entry, err = queue.Add(newTestEntry("d"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), entry.ID)
+}
+
+func TestQueuePartialRecord(t *testing.T) {
+	dir := t.TempDir()
+	queue := newTestQueue(t, dir)
+	_, err := queue.Add(newTestEntry("a"))
+	assert.NoError(t, err)
+	assert.NoError(t, queue.Close())
+
+	// A crash in the middle of an append leaves a record without the line end
+	path := filepath.Join(dir, "segment-0000000001.log")
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	_, err = file.WriteString(`{"op":"add","id":2,"entry":{"id":2,"na`)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	queue = newTestQueue(t, dir)
+	assert.Equal(t, []string{"a"}, listNames(queue))
+	_, err = queue.Add(newTestEntry("b"))
+	assert.NoError(t, err)
+	assert.NoError(t, queue.Close())
+
+	queue = newTestQueue(t, dir)
+	assert.Equal(t, []string{"a", "b"}, listNames(queue))
+}
+
+func TestQueueInvalidRecord(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "segment-0000000001.log"), []byte("not json\n"), 0600))
+
+	_, err := NewQueue(dir, defaultMaxSize)
+	assert.ErrorContains(t, err, "invalid record at offset 0")
+}
+
+func TestQueueSegments(t *testing.T) {
+	defaultSize := maxSegmentSize
+	maxSegmentSize = 1
+	t.Cleanup(func() { maxSegmentSize = defaultSize })
+
+	dir := t.TempDir()
+	queue := newTestQueue(t, dir)
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := queue.Add(newTestEntry(name))
+		assert.NoError(t, err)
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "segment-*.log"))
+	assert.Len(t, segments, 3)
+
+	// The segment of b is kept until the segment of a, which is older, is deleted
+	assert.NoError(t, queue.Remove(2))
+	segments, _ = filepath.Glob(filepath.Join(dir, "segment-*.log"))
+	assert.Len(t, segments, 4)
+
+	assert.NoError(t, queue.Remove(1))
+	segments, _ = filepath.Glob(filepath.Join(dir, "segment-*.log"))
+	assert.Equal(t, []string{
+		filepath.Join(dir, "segment-0000000003.log"),
+		filepath.Join(dir, "segment-0000000004.log"),
+		filepath.Join(dir, "segment-0000000005.log"),
+	}, segments)
+	assert.NoError(t, queue.Close())
+
+	queue = newTestQueue(t, dir)
+	assert.Equal(t, []string{"c"}, listNames(queue))
+}
+
+func TestQueueFull(t *testing.T) {
+	entry := newTestEntry("a")
+	entry.ID = 1
+	entry.Added = time.Now().UTC()
+	line, err := marshalRecord(record{Op: opAdd, ID: entry.ID, Entry: &entry})
+	assert.NoError(t, err)
+
+	// Only one entry fits in the queue
+	queue, err := NewQueue(t.TempDir(), int64(len(line)*3/2))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	t.Cleanup(func() { queue.Close() })
+
+	_, err = queue.Add(newTestEntry("a"))
+	assert.NoError(t, err)
+	_, err = queue.Add(newTestEntry("b"))
+	assert.ErrorIs(t, err, ErrFull)
+	assert.Equal(t, []string{"a"}, listNames(queue))
+
+	// The space of the removed entries is available again
+	assert.NoError(t, queue.Remove(1))
+	_, err = queue.Add(newTestEntry("b"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, listNames(queue))
+}
+
+func TestQueueIDsAfterCompaction(t *testing.T) {
+	defaultSize := maxSegmentSize
+	maxSegmentSize = 1
+	t.Cleanup(func() { maxSegmentSize = defaultSize })
+
+	dir := t.TempDir()
+	queue := newTestQueue(t, dir)
+	for _, name := range []string{"a", "b"} {
+		_, err := queue.Add(newTestEntry(name))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, queue.Remove(2))
+	assert.NoError(t, queue.Remove(1))
+	assert.NoError(t, queue.Close())
+
+	// Only the segment with the remove record of a is kept
+	segments, _ := filepath.Glob(filepath.Join(dir, "segment-*.log"))
+	assert.Len(t, segments, 1)
+
+	queue = newTestQueue(t, dir)
+	assert.Empty(t, listNames(queue))
+	entry, err := queue.Add(newTestEntry("c"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), entry.ID)
+}
+
+func TestQueueRelocatesStuckEntry(t *testing.T) {
+	defaultSize := maxSegmentSize
+	maxSegmentSize = 1
+	t.Cleanup(func() { maxSegmentSize = defaultSize })
+
+	dir := t.TempDir()
+	queue := newTestQueue(t, dir)
+	stuck, err := queue.Add(newTestEntry("stuck"))
+	assert.NoError(t, err)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		entry, addErr := queue.Add(newTestEntry(name))
+		assert.NoError(t, addErr)
+		assert.NoError(t, queue.Remove(entry.ID))
+	}
+
+	// The entry that is never replayed is appended again, so the segments of the removed entries are deleted
+	segments, _ := filepath.Glob(filepath.Join(dir, "segment-*.log"))
+	assert.LessOrEqual(t, len(segments), 2)
+	assert.LessOrEqual(t, queue.diskSize()-queue.liveSize, queue.liveSize+maxSegmentSize)
+	entry, err := queue.Get(stuck.ID)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"kind":"Job"}`, string(entry.Resource))
+	assert.NoError(t, queue.Close())
+
+	queue = newTestQueue(t, dir)
+	assert.Equal(t, []string{"stuck"}, listNames(queue))
+	entry, err = queue.Get(stuck.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "stuck", entry.Name)
+	added, err := queue.Add(newTestEntry("f"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), added.ID)
+}
+
+func TestQueueReopenRelocatedTwice(t *testing.T) {
+	dir := t.TempDir()
+	queue := newTestQueue(t, dir)
+	added, err := queue.Add(newTestEntry("a"))
+	assert.NoError(t, err)
+	// A crash after relocating the entry and before deleting its segment leaves two add records
+	entry := queue.entries[added.ID]
+	line := make([]byte, entry.length)
+	file, err := os.Open(queue.path(entry.segment))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	_, err = file.ReadAt(line, entry.offset)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+	_, err = queue.append(line)
+	assert.NoError(t, err)
+	assert.NoError(t, queue.Close())
+
+	queue = newTestQueue(t, dir)
+	assert.Equal(t, []string{"a"}, listNames(queue))
+	assert.Equal(t, entry.length, queue.liveSize)
+	assert.NoError(t, queue.Remove(added.ID))
+	assert.Zero(t, queue.liveSize)
+	assert.Zero(t, queue.Len())
+}
+
+func TestQueueGetReadsSegment(t *testing.T) {
+	dir := t.TempDir()
+	queue := newTestQueue(t, dir)
+	added, err := queue.Add(newTestEntry("a"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"kind":"Job"}`, string(added.Resource))
+
+	// Only the summary of the entry is kept in memory
+	assert.Nil(t, queue.entries[added.ID].Event)
+	assert.Nil(t, queue.entries[added.ID].Resource)
+
+	entry, err := queue.Get(added.ID)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"specversion":"1.0"}`, string(entry.Event))
+	assert.JSONEq(t, `{"kind":"Job"}`, string(entry.Resource))
+	assert.Equal(t, "a", entry.Name)
+}
@@ -0,0 +1,92 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dlq
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	// replayInterval is the time between the checks for due entries
+	replayInterval = time.Second
+	// replayTimeout is the maximum time to replay an entry
+	replayTimeout = 30 * time.Second
+	minBackoff    = 5 * time.Second
+	maxBackoff    = 5 * time.Minute
+)
+
+// ReplayFunc writes the resource of the entry to the database
+type ReplayFunc func(ctx context.Context, entry Entry) error
+
+// Replayer replays the due entries of a Queue in the background, in the order they were added. The replay
+// of the due entries stops at the first failure, so a database outage only costs one attempt per interval.
+type Replayer struct {
+	queue   *Queue
+	replay  ReplayFunc
+	closing chan struct{}
+	closed  chan struct{}
+}
+
+func NewReplayer(queue *Queue, replay ReplayFunc) *Replayer {
+	r := &Replayer{
+		queue:   queue,
+		replay:  replay,
+		closing: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Close stops the Replayer after the replay in progress
+func (r *Replayer) Close() {
+	close(r.closing)
+	<-r.closed
+}
+
+func (r *Replayer) run() {
+	defer close(r.closed)
+
+	ticker := time.NewTicker(replayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-r.queue.wake:
+		case <-r.closing:
+			return
+		}
+		r.replayDue()
+	}
+}
+
+func (r *Replayer) replayDue() {
+	for _, id := range r.queue.due(time.Now()) {
+		select {
+		case <-r.closing:
+			return
+		default:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), replayTimeout)
+		err := r.queue.Replay(ctx, id, r.replay)
+		cancel()
+		if err != nil {
+			slog.Warn("Could not replay an entry of the dead-letter queue", "entry", id, "err", err)
+			return
+		}
+		slog.Info("Replayed an entry of the dead-letter queue", "entry", id, "pending", r.queue.Len())
+	}
+}
+
+// backoff returns the delay before the next attempt of an entry that failed attempts times
+func backoff(attempts int) time.Duration {
+	delay := minBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
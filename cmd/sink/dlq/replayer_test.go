@@ -0,0 +1,61 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dlq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayer(t *testing.T) {
+	queue := newTestQueue(t, t.TempDir())
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := queue.Add(newTestEntry(name))
+		assert.NoError(t, err)
+	}
+
+	var mu sync.Mutex
+	var replayed []string
+	failing := true
+	replayer := NewReplayer(queue, func(_ context.Context, entry Entry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		replayed = append(replayed, entry.Name)
+		if failing {
+			return errors.New("database unavailable")
+		}
+		return nil
+	})
+	defer replayer.Close()
+
+	// The replay stops at the first failure and the entry waits for its backoff
+	assert.Eventually(t, func() bool { return queue.List()[0].Attempts == 1 }, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, []string{"a"}, replayed)
+	failing = false
+	replayed = nil
+	mu.Unlock()
+	entry, err := queue.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "database unavailable", entry.LastError)
+	assert.True(t, entry.NextAttempt.After(time.Now()))
+
+	queue.RetryAll()
+	assert.Eventually(t, func() bool { return queue.Len() == 0 }, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"a", "b", "c"}, replayed)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 5*time.Second, backoff(1))
+	assert.Equal(t, 10*time.Second, backoff(2))
+	assert.Equal(t, 40*time.Second, backoff(4))
+	assert.Equal(t, 5*time.Minute, backoff(10))
+}
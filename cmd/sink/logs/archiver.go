@@ -0,0 +1,271 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/kubearchive/kubearchive/pkg/blobstore"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// archiveTimeout is the maximum time to stream the log of a container to the store
+	archiveTimeout = 5 * time.Minute
+	// archiveQueueSize is the number of container logs waiting to be archived, the logs of the Pods
+	// received while the queue is full are not archived
+	archiveQueueSize = 1000
+	// archiveWorkers is the number of container logs archived at the same time
+	archiveWorkers = 4
+)
+
+// archiveJob is the log of a container waiting to be archived
+type archiveJob struct {
+	namespace string
+	pod       string
+	container string
+	key       string
+}
+
+// Archiver stores the logs of the containers of the archived Pods in a blobstore.Store, so they are
+// available after the retention of the logging system expires. The logs are archived in the background,
+// the CloudEvents do not wait for them.
+type Archiver struct {
+	pods  corev1client.PodsGetter
+	store blobstore.Store
+
+	jobs chan archiveJob
+	// lock guards pending and closed
+	lock sync.Mutex
+	// pending has the keys of the logs queued or being archived, so they are queued once
+	pending map[string]bool
+	closed  bool
+	// inFlight counts the queued jobs until they are archived
+	inFlight sync.WaitGroup
+	workers  sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewArchiver returns an Archiver that archives the logs in the background until it is closed
+func NewArchiver(pods corev1client.PodsGetter, store blobstore.Store) *Archiver {
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &Archiver{
+		pods:    pods,
+		store:   store,
+		jobs:    make(chan archiveJob, archiveQueueSize),
+		pending: map[string]bool{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	a.workers.Add(archiveWorkers)
+	for range archiveWorkers {
+		go a.work()
+	}
+	return a
+}
+
+// Close stops archiving, the logs still queued are not archived
+func (a *Archiver) Close() {
+	a.lock.Lock()
+	a.closed = true
+	a.lock.Unlock()
+	a.cancel()
+	a.workers.Wait()
+}
+
+// Archive queues the logs of the terminated containers of a Pod to be archived and returns urls with the
+// log URLs of those containers in the store, which fall back to their URL in urls until the log is stored.
+// The other containers keep their URL in urls. Logs stored when the Pod was archived before are not
+// retrieved again.
+func (a *Archiver) Archive(ctx context.Context, obj *unstructured.Unstructured, urls []models.LogTuple) []models.LogTuple {
+	if obj.GetAPIVersion() != "v1" || obj.GetKind() != "Pod" {
+		return urls
+	}
+
+	queued := map[string]string{}
+	for _, container := range terminatedContainers(obj) {
+		key := blobstore.ContainerLogKey(obj.GetNamespace(), string(obj.GetUID()), container)
+		if !a.enqueue(archiveJob{namespace: obj.GetNamespace(), pod: obj.GetName(), container: container, key: key}) {
+			slog.WarnContext(
+				ctx,
+				"Could not queue the log of the container to be stored, the log url is kept",
+				"id", string(obj.GetUID()),
+				"namespace", obj.GetNamespace(),
+				"name", obj.GetName(),
+				"container", container,
+			)
+			continue
+		}
+		queued[container] = key
+	}
+
+	result := make([]models.LogTuple, 0, len(urls)+len(queued))
+	withURL := map[string]bool{}
+	for _, tuple := range urls {
+		if key, ok := queued[tuple.ContainerName]; ok {
+			tuple.Url = blobstore.URL(key, tuple.Url)
+			withURL[tuple.ContainerName] = true
+		}
+		result = append(result, tuple)
+	}
+	for _, container := range containerNames(obj) {
+		if key, ok := queued[container]; ok && !withURL[container] {
+			result = append(result, models.LogTuple{ContainerName: container, Url: blobstore.URL(key, "")})
+		}
+	}
+	return result
+}
+
+// ArchiveBeforeDelete stores the logs of the terminated containers of a Pod that is about to be deleted,
+// the logs are gone once the Pod is deleted so they are not archived in the background. A log that can not
+// be stored is read from the logging system.
+func (a *Archiver) ArchiveBeforeDelete(ctx context.Context, obj *unstructured.Unstructured) {
+	if obj.GetAPIVersion() != "v1" || obj.GetKind() != "Pod" {
+		return
+	}
+
+	for _, container := range finishedContainers(obj) {
+		job := archiveJob{
+			namespace: obj.GetNamespace(),
+			pod:       obj.GetName(),
+			container: container,
+			key:       blobstore.ContainerLogKey(obj.GetNamespace(), string(obj.GetUID()), container),
+		}
+		if err := a.archiveContainer(ctx, job); err != nil {
+			slog.WarnContext(
+				ctx,
+				"Could not store the log of the container before deleting the Pod, it is read from the logging system",
+				"id", string(obj.GetUID()),
+				"namespace", obj.GetNamespace(),
+				"name", obj.GetName(),
+				"container", container,
+				"err", err,
+			)
+		}
+	}
+}
+
+// enqueue queues job unless its log is already queued, and returns false when the queue is full or closed
+func (a *Archiver) enqueue(job archiveJob) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.closed {
+		return false
+	}
+	if a.pending[job.key] {
+		return true
+	}
+	select {
+	case a.jobs <- job:
+		a.pending[job.key] = true
+		a.inFlight.Add(1)
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *Archiver) work() {
+	defer a.workers.Done()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case job := <-a.jobs:
+			if err := a.archiveContainer(a.ctx, job); err != nil {
+				slog.Warn(
+					"Could not store the log of the container, it is read from the logging system",
+					"namespace", job.namespace,
+					"name", job.pod,
+					"container", job.container,
+					"err", err,
+				)
+			}
+			a.lock.Lock()
+			delete(a.pending, job.key)
+			a.lock.Unlock()
+			a.inFlight.Done()
+		}
+	}
+}
+
+// archiveContainer streams the log of the container with its timestamps to the store, unless it is
+// already stored
+func (a *Archiver) archiveContainer(ctx context.Context, job archiveJob) error {
+	ctx, cancel := context.WithTimeout(ctx, archiveTimeout)
+	defer cancel()
+
+	exists, err := a.store.Exists(ctx, job.key)
+	if err != nil || exists {
+		return err
+	}
+	stream, err := a.pods.Pods(job.namespace).GetLogs(job.pod,
+		&corev1.PodLogOptions{Container: job.container, Timestamps: true}).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	return a.store.Put(ctx, job.key, stream)
+}
+
+// terminatedContainers returns the names of the init containers and the containers of the Pod whose
+// current state is terminated in order. A container that can restart is only terminated for good when the
+// Pod finished or is being deleted.
+func terminatedContainers(obj *unstructured.Unstructured) []string {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	if obj.GetDeletionTimestamp() == nil && phase != string(corev1.PodSucceeded) && phase != string(corev1.PodFailed) {
+		return nil
+	}
+	return finishedContainers(obj)
+}
+
+// finishedContainers returns the names of the init containers and the containers of the Pod whose current
+// state is terminated in order, regardless of whether they can restart
+func finishedContainers(obj *unstructured.Unstructured) []string {
+	terminated := map[string]bool{}
+	for _, field := range []string{"initContainerStatuses", "containerStatuses"} {
+		statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", field)
+		for _, status := range statuses {
+			fields, ok := status.(map[string]any)
+			if !ok {
+				continue
+			}
+			name, _ := fields["name"].(string)
+			if _, found, _ := unstructured.NestedMap(fields, "state", "terminated"); found && name != "" {
+				terminated[name] = true
+			}
+		}
+	}
+
+	var names []string
+	for _, name := range containerNames(obj) {
+		if terminated[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// containerNames returns the names of the init containers and the containers of the Pod in order
+func containerNames(obj *unstructured.Unstructured) []string {
+	var names []string
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", field)
+		for _, container := range containers {
+			if fields, ok := container.(map[string]any); ok {
+				if name, isString := fields["name"].(string); isString && name != "" {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,200 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kubearchive/kubearchive/pkg/blobstore"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestPod returns a Pod in phase whose containers in terminated have the terminated state and the others
+// the running state
+func newTestPod(phase string, terminated ...string) *unstructured.Unstructured {
+	status := func(name string) map[string]any {
+		state := map[string]any{"running": map[string]any{}}
+		if slices.Contains(terminated, name) {
+			state = map[string]any{"terminated": map[string]any{"exitCode": int64(0)}}
+		}
+		return map[string]any{"name": name, "state": state}
+	}
+	pod := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"initContainers": []any{map[string]any{"name": "init"}},
+			"containers":     []any{map[string]any{"name": "main"}},
+		},
+		"status": map[string]any{
+			"phase":                 phase,
+			"initContainerStatuses": []any{status("init")},
+			"containerStatuses":     []any{status("main")},
+		},
+	}}
+	pod.SetAPIVersion("v1")
+	pod.SetKind("Pod")
+	pod.SetNamespace("test")
+	pod.SetName("test-pod")
+	pod.SetUID("abc-123")
+	return pod
+}
+
+// failingStore is a blobstore.Store that can not be reached
+type failingStore struct{}
+
+func (failingStore) Put(context.Context, string, io.Reader) error { return errors.New("unreachable") }
+func (failingStore) Get(context.Context, string) (io.ReadCloser, error) {
+	return nil, errors.New("unreachable")
+}
+func (failingStore) Exists(context.Context, string) (bool, error) {
+	return false, errors.New("unreachable")
+}
+
+// readStored returns the content stored with key in store
+func readStored(t *testing.T, store blobstore.Store, key string) string {
+	reader, err := store.Get(context.Background(), key)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestArchive(t *testing.T) {
+	urls := []models.LogTuple{{ContainerName: "main", Url: "http://loki/main"}}
+	mainKey := blobstore.ContainerLogKey("test", "abc-123", "main")
+	initKey := blobstore.ContainerLogKey("test", "abc-123", "init")
+
+	tests := []struct {
+		name     string
+		pod      *unstructured.Unstructured
+		expected []models.LogTuple
+		stored   []string
+	}{
+		{
+			name:     "running pod",
+			pod:      newTestPod("Running", "init"),
+			expected: urls,
+		},
+		{
+			name: "succeeded pod",
+			pod:  newTestPod("Succeeded", "init", "main"),
+			expected: []models.LogTuple{
+				{ContainerName: "main", Url: blobstore.URL(mainKey, "http://loki/main")},
+				{ContainerName: "init", Url: blobstore.URL(initKey, "")},
+			},
+			stored: []string{mainKey, initKey},
+		},
+		{
+			name: "deleted pod with a running container",
+			pod: func() *unstructured.Unstructured {
+				pod := newTestPod("Running", "init")
+				now := metav1.Now()
+				pod.SetDeletionTimestamp(&now)
+				return pod
+			}(),
+			expected: []models.LogTuple{
+				{ContainerName: "main", Url: "http://loki/main"},
+				{ContainerName: "init", Url: blobstore.URL(initKey, "")},
+			},
+			stored: []string{initKey},
+		},
+		{
+			name: "deleted pod with terminated containers",
+			pod: func() *unstructured.Unstructured {
+				pod := newTestPod("Running", "init", "main")
+				now := metav1.Now()
+				pod.SetDeletionTimestamp(&now)
+				return pod
+			}(),
+			expected: []models.LogTuple{
+				{ContainerName: "main", Url: blobstore.URL(mainKey, "http://loki/main")},
+				{ContainerName: "init", Url: blobstore.URL(initKey, "")},
+			},
+			stored: []string{mainKey, initKey},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := blobstore.NewFilesystemStore(t.TempDir())
+			assert.NoError(t, err)
+			archiver := NewArchiver(fake.NewSimpleClientset().CoreV1(), store)
+			defer archiver.Close()
+
+			assert.Equal(t, tt.expected, archiver.Archive(context.Background(), tt.pod, urls))
+			archiver.inFlight.Wait()
+			for _, key := range []string{mainKey, initKey} {
+				exists, errExists := store.Exists(context.Background(), key)
+				assert.NoError(t, errExists)
+				assert.Equal(t, slices.Contains(tt.stored, key), exists, key)
+			}
+		})
+	}
+}
+
+func TestArchiveStoredLogs(t *testing.T) {
+	store, err := blobstore.NewFilesystemStore(t.TempDir())
+	assert.NoError(t, err)
+	archiver := NewArchiver(fake.NewSimpleClientset().CoreV1(), store)
+	defer archiver.Close()
+	key := blobstore.ContainerLogKey("test", "abc-123", "main")
+	assert.NoError(t, store.Put(context.Background(), key, strings.NewReader("stored before deletion\n")))
+
+	archiver.Archive(context.Background(), newTestPod("Succeeded", "init", "main"), nil)
+	archiver.inFlight.Wait()
+
+	// The logs of the Pod are not retrieved again, they may be gone once the Pod is deleted
+	assert.Equal(t, "stored before deletion\n", readStored(t, store, key))
+	assert.Equal(t, "fake logs", readStored(t, store, blobstore.ContainerLogKey("test", "abc-123", "init")))
+}
+
+func TestArchiveStoreFails(t *testing.T) {
+	urls := []models.LogTuple{{ContainerName: "main", Url: "http://loki/main"}}
+	archiver := NewArchiver(fake.NewSimpleClientset().CoreV1(), failingStore{})
+	defer archiver.Close()
+	pod := newTestPod("Failed", "main")
+	key := blobstore.ContainerLogKey("test", "abc-123", "main")
+	expected := []models.LogTuple{{ContainerName: "main", Url: blobstore.URL(key, "http://loki/main")}}
+
+	// The log URL falls back to the logging system, the failed log is queued again with the next update
+	assert.Equal(t, expected, archiver.Archive(context.Background(), pod, urls))
+	archiver.inFlight.Wait()
+	assert.Empty(t, archiver.pending)
+	assert.Equal(t, expected, archiver.Archive(context.Background(), pod, urls))
+}
+
+func TestArchiveClosed(t *testing.T) {
+	urls := []models.LogTuple{{ContainerName: "main", Url: "http://loki/main"}}
+	store, err := blobstore.NewFilesystemStore(t.TempDir())
+	assert.NoError(t, err)
+	archiver := NewArchiver(fake.NewSimpleClientset().CoreV1(), store)
+	archiver.Close()
+
+	assert.Equal(t, urls, archiver.Archive(context.Background(), newTestPod("Succeeded", "main"), urls))
+}
+
+func TestArchiveBeforeDelete(t *testing.T) {
+	store, err := blobstore.NewFilesystemStore(t.TempDir())
+	assert.NoError(t, err)
+	archiver := NewArchiver(fake.NewSimpleClientset().CoreV1(), store)
+	defer archiver.Close()
+
+	// The logs are stored when the call returns, the running container has no log to keep yet
+	archiver.ArchiveBeforeDelete(context.Background(), newTestPod("Running", "init"))
+	assert.Equal(t, "fake logs", readStored(t, store, blobstore.ContainerLogKey("test", "abc-123", "init")))
+	exists, err := store.Exists(context.Background(), blobstore.ContainerLogKey("test", "abc-123", "main"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -4,12 +4,19 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
 
+	"github.com/kubearchive/kubearchive/cmd/sink/batch"
+	"github.com/kubearchive/kubearchive/cmd/sink/consumer"
+	"github.com/kubearchive/kubearchive/cmd/sink/dedup"
+	"github.com/kubearchive/kubearchive/cmd/sink/dlq"
 	"github.com/kubearchive/kubearchive/cmd/sink/logs"
 	"github.com/kubearchive/kubearchive/cmd/sink/routers"
 	"github.com/kubearchive/kubearchive/cmd/sink/server"
+	"github.com/kubearchive/kubearchive/cmd/sink/transformer"
+	"github.com/kubearchive/kubearchive/pkg/blobstore"
 	"github.com/kubearchive/kubearchive/pkg/database"
 	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
 	"github.com/kubearchive/kubearchive/pkg/k8sclient"
@@ -74,6 +81,86 @@ func main() {
 		slog.Error("Could not enable log url creation", "error", err)
 	}
 	controller := routers.NewController(db, dynClient, builder)
+
+	store, err := blobstore.NewFromEnv()
+	if err != nil {
+		slog.Error("Could not configure the log store", "error", err)
+		os.Exit(1)
+	}
+	if store != nil {
+		clientset, clientErr := k8sclient.NewInstrumentedKubernetesClient()
+		if clientErr != nil {
+			slog.Error("Could not get a kubernetes client", "error", clientErr)
+			os.Exit(1)
+		}
+		archiver := logs.NewArchiver(clientset.CoreV1(), store)
+		defer archiver.Close()
+		controller.LogArchiver = archiver
+		slog.Info("Archiving the logs of the Pods", "store", os.Getenv(blobstore.LogStoreEnvVar))
+	}
+
+	batcher, err := batch.NewBatcherFromEnv(db)
+	if err != nil {
+		slog.Error("Could not configure the batching of the writes", "error", err)
+		os.Exit(1)
+	}
+	if batcher != nil {
+		// Runs before the deferred close of the database connection, so the pending batch is written
+		defer batcher.Close()
+		controller.Batcher = batcher
+		slog.Info("Writing the resources in batches", "size", os.Getenv(batch.SizeEnvVar),
+			"window", os.Getenv(batch.WindowEnvVar))
+	}
+
+	resourceTransformer, err := transformer.NewTransformerFromEnv(context.Background(), dynClient)
+	if err != nil {
+		slog.Error("Could not read the transform rules", "error", err)
+		os.Exit(1)
+	}
+	defer resourceTransformer.Close()
+	controller.Transformer = resourceTransformer
+
+	deduplicator, err := dedup.NewDeduplicatorFromEnv(db)
+	if err != nil {
+		slog.Error("Could not configure the detection of duplicated CloudEvents", "error", err)
+		os.Exit(1)
+	}
+	if deduplicator != nil {
+		defer deduplicator.Close()
+		controller.Deduplicator = deduplicator
+		slog.Info("Ignoring the CloudEvents already processed", "size", os.Getenv(dedup.SizeEnvVar),
+			"database", os.Getenv(dedup.DatabaseEnvVar) == "true")
+	}
+
+	deadLetters, err := dlq.NewQueueFromEnv()
+	if err != nil {
+		slog.Error("Could not open the dead-letter queue", "error", err)
+		os.Exit(1)
+	}
+	if deadLetters != nil {
+		defer func() {
+			if closeErr := deadLetters.Close(); closeErr != nil {
+				slog.Error("Could not close the dead-letter queue", "error", closeErr)
+			}
+		}()
+		// Runs before the deferred close of the batcher and the queue, so the replay in progress can finish
+		defer dlq.NewReplayer(deadLetters, controller.WriteDeadLetter).Close()
+		controller.DeadLetters = deadLetters
+		slog.Info("Adding the resources that fail to be written to the dead-letter queue",
+			"path", os.Getenv(dlq.PathEnvVar), "entries", deadLetters.Len())
+	}
+
+	brokerConsumer, err := consumer.NewConsumerFromEnv(controller.ConsumeCloudEvent)
+	if err != nil {
+		slog.Error("Could not consume the CloudEvents from the message broker", "error", err)
+		os.Exit(1)
+	}
+	if brokerConsumer != nil {
+		// Runs before the deferred close of the batcher and the dead-letter queue, so the CloudEvent in progress
+		// is processed
+		defer brokerConsumer.Close()
+		slog.Info("Consuming the CloudEvents from a message broker", "protocol", os.Getenv(consumer.ProtocolEnvVar))
+	}
 	server := server.NewServer(controller)
 	server.Serve()
 }
@@ -0,0 +1,101 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/cmd/sink/dlq"
+	"github.com/kubearchive/kubearchive/pkg/abort"
+)
+
+// ListDeadLetters returns the entries of the dead-letter queue without their CloudEvent and resource
+func (c *Controller) ListDeadLetters(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.DeadLetters.List())
+}
+
+// GetDeadLetter returns the entry of the dead-letter queue with its CloudEvent and resource
+func (c *Controller) GetDeadLetter(ctx *gin.Context) {
+	id, err := deadLetterID(ctx)
+	if err != nil {
+		abort.Abort(ctx, err, http.StatusBadRequest)
+		return
+	}
+	entry, err := c.DeadLetters.Get(id)
+	if err != nil {
+		abort.Abort(ctx, err, deadLetterErrorCode(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, entry)
+}
+
+// ReplayDeadLetter writes the resource of the entry to the database and removes the entry from the queue.
+// It returns HTTP 503 when the write fails, the entry is kept in the queue.
+func (c *Controller) ReplayDeadLetter(ctx *gin.Context) {
+	id, err := deadLetterID(ctx)
+	if err != nil {
+		abort.Abort(ctx, err, http.StatusBadRequest)
+		return
+	}
+	if err = c.DeadLetters.Replay(ctx.Request.Context(), id, c.WriteDeadLetter); err != nil {
+		abort.Abort(ctx, err, deadLetterErrorCode(err))
+		return
+	}
+	slog.InfoContext(ctx.Request.Context(), "Replayed an entry of the dead-letter queue", "entry", id)
+	ctx.Status(http.StatusNoContent)
+}
+
+// ReplayDeadLetters makes all the entries due, so they are replayed in the background without waiting for
+// their backoff
+func (c *Controller) ReplayDeadLetters(ctx *gin.Context) {
+	c.DeadLetters.RetryAll()
+	ctx.Status(http.StatusAccepted)
+}
+
+// DropDeadLetter removes the entry from the queue without writing its resource
+func (c *Controller) DropDeadLetter(ctx *gin.Context) {
+	id, err := deadLetterID(ctx)
+	if err != nil {
+		abort.Abort(ctx, err, http.StatusBadRequest)
+		return
+	}
+	entry, err := c.DeadLetters.Get(id)
+	if err == nil {
+		err = c.DeadLetters.Remove(id)
+	}
+	if err != nil {
+		abort.Abort(ctx, err, deadLetterErrorCode(err))
+		return
+	}
+	slog.WarnContext(
+		ctx.Request.Context(),
+		"Dropped an entry of the dead-letter queue, its resource was not archived",
+		"entry", entry.ID,
+		"id", entry.UID,
+		"kind", entry.Kind,
+		"namespace", entry.Namespace,
+		"name", entry.Name,
+	)
+	ctx.Status(http.StatusNoContent)
+}
+
+func deadLetterID(ctx *gin.Context) (uint64, error) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid entry id '%s'", ctx.Param("id"))
+	}
+	return id, nil
+}
+
+func deadLetterErrorCode(err error) int {
+	if errors.Is(err, dlq.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusServiceUnavailable
+}
@@ -0,0 +1,121 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/cmd/sink/dlq"
+	fakeDb "github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func setupDeadLetterRouter(t testing.TB, ctrl *Controller) *gin.Engine {
+	t.Helper()
+	queue, err := dlq.NewQueue(t.TempDir(), 1024*1024)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	t.Cleanup(func() { queue.Close() })
+	ctrl.DeadLetters = queue
+
+	router := gin.Default()
+	router.POST("/", ctrl.ReceiveCloudEvent)
+	router.GET("/dlq/entries", ctrl.ListDeadLetters)
+	router.GET("/dlq/entries/:id", ctrl.GetDeadLetter)
+	router.DELETE("/dlq/entries/:id", ctrl.DropDeadLetter)
+	router.POST("/dlq/entries/:id/replay", ctrl.ReplayDeadLetter)
+	return router
+}
+
+func sendJobEvent(t testing.TB, router *gin.Engine) *httptest.ResponseRecorder {
+	t.Helper()
+	reader, err := os.Open("testdata/CE-job.json")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	t.Cleanup(func() { reader.Close() })
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", reader)
+	req.Header.Add("Content-Type", "application/cloudevents+json")
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	ctrl := NewController(fakeDb.NewFakeDatabaseWithError(errors.New("database unavailable")), nil, nil)
+	router := setupDeadLetterRouter(t, ctrl)
+
+	res := sendJobEvent(t, router)
+	assert.Equal(t, http.StatusAccepted, res.Code)
+
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/dlq/entries", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	var entries []dlq.Entry
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &entries))
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "Job", entries[0].Kind)
+		assert.Equal(t, "database unavailable", entries[0].Error)
+		assert.Nil(t, entries[0].Resource)
+	}
+
+	// The replay fails while the database is unavailable and the entry is kept
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/dlq/entries/1/replay", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+	entry, err := ctrl.DeadLetters.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, entry.Attempts)
+
+	db := fakeDb.NewFakeDatabase([]*unstructured.Unstructured{}, []fakeDb.LogUrlRow{}, "$.")
+	ctrl.Db = db
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/dlq/entries/1/replay", nil))
+	assert.Equal(t, http.StatusNoContent, res.Code)
+	assert.Equal(t, 1, db.NumResources())
+	assert.Equal(t, 0, ctrl.DeadLetters.Len())
+}
+
+func TestDeadLetterDrop(t *testing.T) {
+	ctrl := NewController(fakeDb.NewFakeDatabaseWithError(errors.New("database unavailable")), nil, nil)
+	router := setupDeadLetterRouter(t, ctrl)
+	sendJobEvent(t, router)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{name: "invalid id", path: "/dlq/entries/first", expected: http.StatusBadRequest},
+		{name: "drop", path: "/dlq/entries/1", expected: http.StatusNoContent},
+		{name: "not found", path: "/dlq/entries/1", expected: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, httptest.NewRequest(http.MethodDelete, tt.path, nil))
+			assert.Equal(t, tt.expected, res.Code)
+		})
+	}
+	assert.Equal(t, 0, ctrl.DeadLetters.Len())
+}
+
+func TestDeadLetterQueueFails(t *testing.T) {
+	ctrl := NewController(fakeDb.NewFakeDatabaseWithError(errors.New("database unavailable")), nil, nil)
+	router := setupDeadLetterRouter(t, ctrl)
+	// The closed queue can not append entries, the sender has to retry the CloudEvent
+	assert.NoError(t, ctrl.DeadLetters.Close())
+
+	res := sendJobEvent(t, router)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+}
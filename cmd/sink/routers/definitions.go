@@ -0,0 +1,117 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/kubearchive/kubearchive/pkg/models"
+	errs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The definition of a custom resource rarely changes, so it is only stored again after this time
+var definitionsCacheExpirationTime = time.Hour
+
+var crdResource = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// recordResourceDefinition stores the CustomResourceDefinition of obj, so its archived instances can be queried
+// after the CustomResourceDefinition is removed from the cluster. Failures are logged but not returned because
+// the resource is already archived.
+func (c *Controller) recordResourceDefinition(ctx context.Context, obj *unstructured.Unstructured) {
+	gvk := obj.GroupVersionKind()
+	// The group of custom resources must contain a dot, the rest are built-in resources
+	if c.K8sClient == nil || !strings.Contains(gvk.Group, ".") {
+		return
+	}
+
+	cacheKey := fmt.Sprintf("%s/%s", gvk.Group, gvk.Kind)
+	if c.definitionsCache.Get(cacheKey) != nil {
+		return
+	}
+
+	crd, err := c.getResourceDefinition(ctx, gvk)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not retrieve the CustomResourceDefinition", "group", gvk.Group,
+			"kind", gvk.Kind, "err", err)
+		return
+	}
+	if crd == nil {
+		// Not defined by a CRD, for example served by an aggregated API
+		c.definitionsCache.Set(cacheKey, false, definitionsCacheExpirationTime)
+		return
+	}
+
+	definition, err := newResourceDefinition(crd)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not serialize the CustomResourceDefinition", "crd", crd.GetName(), "err", err)
+		return
+	}
+	if err = c.Db.WriteResourceDefinition(ctx, definition); err != nil {
+		slog.WarnContext(ctx, "Could not store the CustomResourceDefinition", "crd", crd.GetName(), "err", err)
+		return
+	}
+	c.definitionsCache.Set(cacheKey, true, definitionsCacheExpirationTime)
+}
+
+// getResourceDefinition returns the CustomResourceDefinition of gvk, or nil when there is none. The name of the
+// CRD is guessed from the kind, listing all the CRDs when the guess is wrong
+func (c *Controller) getResourceDefinition(ctx context.Context, gvk schema.GroupVersionKind) (*unstructured.Unstructured, error) {
+	resource, _ := meta.UnsafeGuessKindToResource(gvk)
+	crd, err := c.K8sClient.Resource(crdResource).Get(ctx, fmt.Sprintf("%s.%s", resource.Resource, gvk.Group),
+		metav1.GetOptions{})
+	if err == nil {
+		return crd, nil
+	}
+	if !errs.IsNotFound(err) {
+		return nil, err
+	}
+
+	crds, err := c.K8sClient.Resource(crdResource).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range crds.Items {
+		group, _, _ := unstructured.NestedString(item.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(item.Object, "spec", "names", "kind")
+		if group == gvk.Group && kind == gvk.Kind {
+			return &item, nil
+		}
+	}
+	return nil, nil
+}
+
+// newResourceDefinition returns the definition to store from crd, without its status and the
+// metadata that only makes sense in the cluster
+func newResourceDefinition(crd *unstructured.Unstructured) (models.ResourceDefinition, error) {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	if group == "" || plural == "" || kind == "" {
+		return models.ResourceDefinition{}, fmt.Errorf("CustomResourceDefinition %s has no group or names", crd.GetName())
+	}
+
+	data, err := json.Marshal(map[string]any{
+		"apiVersion": crd.GetAPIVersion(),
+		"kind":       crd.GetKind(),
+		"metadata":   map[string]any{"name": crd.GetName()},
+		"spec":       crd.Object["spec"],
+	})
+	if err != nil {
+		return models.ResourceDefinition{}, err
+	}
+	return models.ResourceDefinition{Group: group, Plural: plural, Kind: kind, Data: string(data)}, nil
+}
@@ -0,0 +1,113 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package routers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	fakeDb "github.com/kubearchive/kubearchive/pkg/database/fake"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func newTestCRD(name, group, plural, kind string) *unstructured.Unstructured {
+	crd := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"group": group,
+			"names": map[string]any{"plural": plural, "kind": kind},
+			"scope": "Namespaced",
+		},
+		"status": map[string]any{"acceptedNames": map[string]any{"plural": plural, "kind": kind}},
+	}}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName(name)
+	crd.SetResourceVersion("1")
+	return crd
+}
+
+func newTestCustomResource(apiVersion, kind string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace("test")
+	obj.SetName("test")
+	return obj
+}
+
+func TestRecordResourceDefinition(t *testing.T) {
+	tests := []struct {
+		name           string
+		obj            *unstructured.Unstructured
+		crds           []runtime.Object
+		expectedPlural string
+	}{
+		{
+			name:           "CRD name guessed from the kind",
+			obj:            newTestCustomResource("tekton.dev/v1", "PipelineRun"),
+			crds:           []runtime.Object{newTestCRD("pipelineruns.tekton.dev", "tekton.dev", "pipelineruns", "PipelineRun")},
+			expectedPlural: "pipelineruns",
+		},
+		{
+			name:           "CRD with an irregular plural",
+			obj:            newTestCustomResource("stable.example.com/v1", "Mouse"),
+			crds:           []runtime.Object{newTestCRD("mice.stable.example.com", "stable.example.com", "mice", "Mouse")},
+			expectedPlural: "mice",
+		},
+		{
+			name: "custom resource without CRD",
+			obj:  newTestCustomResource("metrics.k8s.io/v1beta1", "PodMetrics"),
+		},
+		{
+			name: "built-in resource",
+			obj:  newTestCustomResource("batch/v1", "Job"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := fakeDb.NewFakeDatabase([]*unstructured.Unstructured{}, []fakeDb.LogUrlRow{}, "")
+			client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{crdResource: "CustomResourceDefinitionList"}, tt.crds...)
+			controller := NewController(db, client, nil)
+
+			controller.recordResourceDefinition(context.Background(), tt.obj)
+
+			definitions, err := db.QueryResourceDefinitions(context.Background(), tt.obj.GroupVersionKind().Group)
+			assert.NoError(t, err)
+			if tt.expectedPlural == "" {
+				assert.Empty(t, definitions)
+				return
+			}
+			if assert.Len(t, definitions, 1) {
+				assert.Equal(t, tt.expectedPlural, definitions[0].Plural)
+				assert.Equal(t, tt.obj.GetKind(), definitions[0].Kind)
+				stored := map[string]any{}
+				assert.NoError(t, json.Unmarshal([]byte(definitions[0].Data), &stored))
+				assert.NotContains(t, stored, "status")
+				assert.Contains(t, stored, "spec")
+			}
+		})
+	}
+}
+
+func TestRecordResourceDefinitionWriteFails(t *testing.T) {
+	db := fakeDb.NewFakeDatabaseWithError(errors.New("test error"))
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{crdResource: "CustomResourceDefinitionList"},
+		newTestCRD("pipelineruns.tekton.dev", "tekton.dev", "pipelineruns", "PipelineRun"))
+	controller := NewController(db, client, nil)
+
+	obj := newTestCustomResource("tekton.dev/v1", "PipelineRun")
+	controller.recordResourceDefinition(context.Background(), obj)
+
+	// Failures are not cached, so the definition is stored with the next resource
+	assert.Nil(t, controller.definitionsCache.Get("tekton.dev/PipelineRun"))
+}
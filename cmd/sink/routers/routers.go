@@ -5,16 +5,26 @@ package routers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"github.com/gin-gonic/gin"
+	"github.com/kubearchive/kubearchive/cmd/sink/batch"
+	"github.com/kubearchive/kubearchive/cmd/sink/dedup"
+	"github.com/kubearchive/kubearchive/cmd/sink/dlq"
 	"github.com/kubearchive/kubearchive/cmd/sink/k8s"
 	"github.com/kubearchive/kubearchive/cmd/sink/logs"
+	"github.com/kubearchive/kubearchive/cmd/sink/transformer"
 	"github.com/kubearchive/kubearchive/pkg/abort"
+	"github.com/kubearchive/kubearchive/pkg/cache"
+	kaCloudEvents "github.com/kubearchive/kubearchive/pkg/cloudevents"
 	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
 	"github.com/kubearchive/kubearchive/pkg/models"
 	"github.com/kubearchive/kubearchive/pkg/observability"
@@ -22,6 +32,7 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	errs "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,17 +40,38 @@ import (
 	"k8s.io/client-go/dynamic"
 )
 
+const (
+	// batchConcurrency is the maximum number of events of a batch processed at the same time
+	batchConcurrency = 16
+	// inProgressRetryAfter is the Retry-After, in seconds, of an event being processed by another request
+	inProgressRetryAfter = "1"
+)
+
 type Controller struct {
 	Db            interfaces.DBWriter
 	K8sClient     dynamic.Interface
 	LogUrlBuilder *logs.UrlBuilder
+	// LogArchiver stores the logs of the finished Pods, it is nil when no log store is configured
+	LogArchiver *logs.Archiver
+	// Batcher writes the resources in batches, it is nil when the writes are not batched
+	Batcher *batch.Batcher
+	// DeadLetters keeps the resources whose write failed to write them later, it is nil when it is disabled
+	DeadLetters *dlq.Queue
+	// Deduplicator detects the CloudEvents already processed, it is nil when duplicates are processed again
+	Deduplicator *dedup.Deduplicator
+	// Transformer applies the transform rules to the resources before they are written, it is nil when the
+	// resources are written as received
+	Transformer *transformer.Transformer
+
+	// definitionsCache holds the custom resources whose definition is already stored
+	definitionsCache *cache.Cache
 }
 
 func NewController(
 	db interfaces.DBWriter, k8sClient dynamic.Interface, urlBuilder *logs.UrlBuilder,
 ) *Controller {
 	return &Controller{
-		Db: db, K8sClient: k8sClient, LogUrlBuilder: urlBuilder,
+		Db: db, K8sClient: k8sClient, LogUrlBuilder: urlBuilder, definitionsCache: cache.New(),
 	}
 }
 
@@ -84,8 +116,19 @@ func (c *Controller) writeResource(ctx context.Context, obj *unstructured.Unstru
 			)
 		}
 	}
+	if c.LogArchiver != nil {
+		urls = c.LogArchiver.Archive(ctx, obj, urls)
+	}
 
-	result, writeResourceErr := c.Db.WriteResource(dbCtx, obj, event.Data(), lastUpdateTs, jsonPath, urls...)
+	var result interfaces.WriteResourceResult
+	var writeResourceErr error
+	if c.Batcher != nil {
+		result, writeResourceErr = c.Batcher.Write(dbCtx, interfaces.ResourceWrite{
+			Object: obj, Data: event.Data(), LastUpdated: lastUpdateTs, JsonPath: jsonPath, Logs: urls,
+		})
+	} else {
+		result, writeResourceErr = c.Db.WriteResource(dbCtx, obj, event.Data(), lastUpdateTs, jsonPath, urls...)
+	}
 	if writeResourceErr != nil {
 		slog.ErrorContext(
 			ctx,
@@ -114,12 +157,121 @@ func (c *Controller) writeResource(ctx context.Context, obj *unstructured.Unstru
 		"name", obj.GetName(),
 	)
 
+	c.recordResourceDefinition(ctx, obj)
 	return result, nil
 }
 
+// transformResource applies the transform rules of its kind to the resource, and replaces the data of the event
+// with the transformed resource, so the fields removed by the rules are not written anywhere
+func (c *Controller) transformResource(obj *unstructured.Unstructured, event *cloudevents.Event) error {
+	if c.Transformer == nil {
+		return nil
+	}
+	transformed, err := c.Transformer.Transform(obj)
+	if err != nil || !transformed {
+		return err
+	}
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return event.SetData(cloudevents.ApplicationJSON, data)
+}
+
+// archiveResource writes the resource to the database and returns the result for the metrics. When the write
+// fails and the dead-letter queue is enabled, the resource is added to the queue to be written later and the
+// CloudEvent is processed as if the write succeeded, so the event is not lost when its sender gives up.
+func (c *Controller) archiveResource(ctx context.Context, obj *unstructured.Unstructured, event *cloudevents.Event) (observability.CEResult, error) {
+	result, err := c.writeResource(ctx, obj, event)
+	if err == nil {
+		return observability.NewCEResultFromWriteResourceResult(result), nil
+	}
+	if c.DeadLetters == nil {
+		return observability.CEResultError, err
+	}
+
+	entry, dlqErr := c.addDeadLetter(obj, event, err)
+	if dlqErr != nil {
+		slog.ErrorContext(
+			ctx,
+			"Could not add the resource to the dead-letter queue",
+			"event-id", event.ID(),
+			"id", string(obj.GetUID()),
+			"kind", obj.GetKind(),
+			"namespace", obj.GetNamespace(),
+			"name", obj.GetName(),
+			"err", dlqErr,
+		)
+		return observability.CEResultError, errors.Join(err, dlqErr)
+	}
+
+	slog.WarnContext(
+		ctx,
+		"Added the resource to the dead-letter queue",
+		"entry", entry.ID,
+		"event-id", event.ID(),
+		"id", string(obj.GetUID()),
+		"kind", obj.GetKind(),
+		"namespace", obj.GetNamespace(),
+		"name", obj.GetName(),
+	)
+	return observability.CEResultDeadLetter, nil
+}
+
+func (c *Controller) addDeadLetter(obj *unstructured.Unstructured, event *cloudevents.Event, writeErr error) (dlq.Entry, error) {
+	rawEvent, err := event.MarshalJSON()
+	if err != nil {
+		return dlq.Entry{}, err
+	}
+	// The resource is kept besides the event because it may have the deletion timestamp set by the sink
+	rawResource, err := obj.MarshalJSON()
+	if err != nil {
+		return dlq.Entry{}, err
+	}
+	return c.DeadLetters.Add(dlq.Entry{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		UID:        string(obj.GetUID()),
+		Error:      writeErr.Error(),
+		Event:      rawEvent,
+		Resource:   rawResource,
+	})
+}
+
+// WriteDeadLetter writes the resource of an entry of the dead-letter queue to the database
+func (c *Controller) WriteDeadLetter(ctx context.Context, entry dlq.Entry) error {
+	event := cloudevents.NewEvent()
+	if err := event.UnmarshalJSON(entry.Event); err != nil {
+		return fmt.Errorf("invalid CloudEvent in the entry %d: %w", entry.ID, err)
+	}
+	obj, err := models.UnstructuredFromByteSlice(entry.Resource)
+	if err != nil {
+		return fmt.Errorf("invalid resource in the entry %d: %w", entry.ID, err)
+	}
+
+	result, err := c.writeResource(ctx, obj, &event)
+	if err != nil {
+		return err
+	}
+	observability.CloudEvents.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("event_type", event.Type()),
+		attribute.String("resource_type", fmt.Sprintf("%s/%s", obj.GetAPIVersion(), obj.GetKind())),
+		attribute.String("result", string(observability.NewCEResultFromWriteResourceResult(result))),
+	))
+	return nil
+}
+
 // receiveCloudEvent returns an HTTP 400 if the request body is not a CloudEvent or HTTP 422 if event.Data is not a
-// kubernetes object. All other failures should return HTTP 500 instead.
+// kubernetes object. All other failures should return HTTP 500 instead. When the request is a batch of
+// CloudEvents it returns the result of each event, see receiveCloudEventBatch.
 func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
+	if cehttp.IsHTTPBatch(ctx.Request.Header) {
+		c.receiveCloudEventBatch(ctx)
+		return
+	}
+
 	tracer := otel.Tracer("kubearchive")
 	spanCtx, span := tracer.Start(ctx.Request.Context(), "ReceiveCloudEvent")
 	ctx.Request = ctx.Request.WithContext(spanCtx)
@@ -138,21 +290,131 @@ func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
 	}
 	childSpan.End()
 
+	status := c.processCloudEvent(ctx.Request.Context(), event)
+	if status == http.StatusServiceUnavailable {
+		ctx.Header("Retry-After", inProgressRetryAfter)
+	}
+	ctx.Status(status)
+}
+
+// ConsumeCloudEvent processes a CloudEvent read from a message broker and returns the HTTP status
+// ReceiveCloudEvent answers for it
+func (c *Controller) ConsumeCloudEvent(ctx context.Context, event *cloudevents.Event) int {
+	tracer := otel.Tracer("kubearchive")
+	ctx, span := tracer.Start(ctx, "ConsumeCloudEvent")
+	defer span.End()
+
+	return c.processCloudEvent(ctx, event)
+}
+
+// receiveCloudEventBatch handles a batch of CloudEvents in the batch content mode. The events of different
+// resources are processed concurrently, so their writes are batched when the Batcher is enabled, and the events
+// of the same resource are processed one after the other in the order of the batch. It returns the result of
+// each event with the HTTP status ReceiveCloudEvent returns for it, with HTTP 202 when all the events were
+// accepted and HTTP 207 otherwise. It returns HTTP 400 if the request body is not a batch of CloudEvents and
+// HTTP 413 if the batch has more than kaCloudEvents.MaxBatchSize events.
+func (c *Controller) receiveCloudEventBatch(ctx *gin.Context) {
+	tracer := otel.Tracer("kubearchive")
+	spanCtx, span := tracer.Start(ctx.Request.Context(), "ReceiveCloudEventBatch")
+	defer span.End()
+
+	events, eventsErr := cehttp.NewEventsFromHTTPRequest(ctx.Request)
+	if eventsErr != nil {
+		slog.ErrorContext(spanCtx, "Could not parse a batch of CloudEvents from http request", "err", eventsErr)
+		ctx.Status(http.StatusBadRequest)
+		span.SetStatus(codes.Error, "failed")
+		span.RecordError(eventsErr)
+		return
+	}
+	span.SetAttributes(attribute.Int("size", len(events)))
+	if len(events) > kaCloudEvents.MaxBatchSize {
+		slog.ErrorContext(spanCtx, "Received a batch of CloudEvents bigger than the maximum", "size", len(events),
+			"max", kaCloudEvents.MaxBatchSize)
+		ctx.Status(http.StatusRequestEntityTooLarge)
+		span.SetStatus(codes.Error, "batch too large")
+		return
+	}
+
+	results := make([]kaCloudEvents.BatchResult, len(events))
+	semaphore := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for _, group := range groupEventsByResource(events) {
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			for _, i := range group {
+				eventCtx, eventSpan := tracer.Start(spanCtx, "ReceiveCloudEvent")
+				results[i] = kaCloudEvents.BatchResult{ID: events[i].ID(), Status: c.processCloudEvent(eventCtx, &events[i])}
+				eventSpan.End()
+			}
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusAccepted
+	for _, result := range results {
+		if result.Status != http.StatusAccepted {
+			status = http.StatusMultiStatus
+			span.SetStatus(codes.Error, "failed")
+			break
+		}
+	}
+	ctx.JSON(status, results)
+}
+
+// groupEventsByResource returns the indexes of the events grouped by their resource, in the order of the
+// events in the batch
+func groupEventsByResource(events []cloudevents.Event) [][]int {
+	var groups [][]int
+	groupOf := map[string]int{}
+	for i := range events {
+		key := eventResourceKey(&events[i])
+		group, found := groupOf[key]
+		if !found {
+			group = len(groups)
+			groupOf[key] = group
+			groups = append(groups, nil)
+		}
+		groups[group] = append(groups[group], i)
+	}
+	return groups
+}
+
+// eventResourceKey returns the UID of the resource of the event, or the ID of the event when its data is not
+// a kubernetes object, so the events of the same resource in a batch are processed in order
+func eventResourceKey(event *cloudevents.Event) string {
+	var object metav1.PartialObjectMetadata
+	if err := json.Unmarshal(event.Data(), &object); err != nil || object.UID == "" {
+		return "event/" + event.ID()
+	}
+	return "uid/" + string(object.UID)
+}
+
+// processCloudEvent archives the resource of the event, and deletes it from the cluster when the event asks
+// for it, and returns the HTTP status of the result. When the Deduplicator is enabled, an event already processed
+// is accepted without side effects, and HTTP 503 is returned for an event being processed by another request,
+// so its sender retries it and it is processed again in case that processing fails.
+func (c *Controller) processCloudEvent(ctx context.Context, event *cloudevents.Event) (status int) {
+	tracer := otel.Tracer("kubearchive")
+	span := trace.SpanFromContext(ctx)
+
 	span.SetAttributes(
 		attribute.String("event-id", event.ID()),
 		attribute.String("event-type", event.Type()),
 	)
 
-	childSpanCtx, childSpan = tracer.Start(ctx.Request.Context(), "event.Validate")
+	childSpanCtx, childSpan := tracer.Start(ctx, "event.Validate")
 	validationErr := event.Validate()
 	if validationErr != nil {
 		slog.ErrorContext(childSpanCtx, "Received invalid CloudEvent from http request", "err", validationErr)
-		ctx.Status(http.StatusBadRequest)
 		childSpan.SetStatus(codes.Error, "failed")
 		childSpan.RecordError(validationErr)
 		span.SetStatus(codes.Error, "failed")
 		span.RecordError(validationErr)
-		return
+		return http.StatusBadRequest
 	}
 	childSpan.End()
 
@@ -171,18 +433,17 @@ func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
 		observability.CloudEvents.Add(ctx, 1, metric.WithAttributes(attrs...))
 	}()
 
-	childSpanCtx, childSpan = tracer.Start(ctx.Request.Context(), "models.UnstructuredFromByteSlice")
+	childSpanCtx, childSpan = tracer.Start(ctx, "models.UnstructuredFromByteSlice")
 	ex := event.Extensions()
 	slog.InfoContext(childSpanCtx, "Received CloudEvent", "event-id", event.ID(), "event-type", event.Type(), "kind", ex["kind"], "name", ex["name"], "namespace", ex["namespace"])
 	k8sObj, err := models.UnstructuredFromByteSlice(event.Data())
 	if err != nil {
 		slog.ErrorContext(childSpanCtx, "Received malformed CloudEvent", "event-id", event.ID(), "err", err)
-		ctx.Status(http.StatusUnprocessableEntity)
 		childSpan.SetStatus(codes.Error, "failed")
 		childSpan.RecordError(err)
 		span.SetStatus(codes.Error, "failed")
 		span.RecordError(err)
-		return
+		return http.StatusUnprocessableEntity
 	}
 	childSpan.End()
 
@@ -195,6 +456,26 @@ func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
 		attribute.String("name", k8sObj.GetName()),
 	)
 
+	if c.Deduplicator != nil {
+		switch c.Deduplicator.Begin(ctx, event.Source(), event.ID()) {
+		case dedup.Duplicate:
+			CEMetricAttrs["result"] = string(observability.CEResultDuplicate)
+			slog.InfoContext(ctx, "Ignoring a CloudEvent already processed", "event-id", event.ID(),
+				"event-type", event.Type(), "source", event.Source())
+			span.SetStatus(codes.Ok, "successful")
+			return http.StatusAccepted
+		case dedup.InProgress:
+			CEMetricAttrs["result"] = string(observability.CEResultInProgress)
+			slog.InfoContext(ctx, "Rejecting a CloudEvent being processed", "event-id", event.ID(),
+				"event-type", event.Type(), "source", event.Source())
+			span.SetStatus(codes.Error, "in progress")
+			return http.StatusServiceUnavailable
+		}
+		defer func() {
+			c.Deduplicator.Done(ctx, event.Source(), event.ID(), status == http.StatusAccepted)
+		}()
+	}
+
 	eventType := event.Type()
 	isDeleteWhen := strings.HasSuffix(eventType, ".delete-when")
 	isArchiveWhen := strings.HasSuffix(eventType, ".archive-when")
@@ -204,7 +485,7 @@ func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
 	if !isDeleteWhen && !isArchiveWhen && !isArchiveOnDelete && !isKeepLastWhenDelete {
 		CEMetricAttrs["result"] = string(observability.CEResultNoConfiguration)
 		slog.WarnContext(
-			ctx.Request.Context(),
+			ctx,
 			"Resource update received, unsupported event type",
 			"event-id", event.ID(),
 			"event-type", event.Type(),
@@ -213,20 +494,40 @@ func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
 			"apiVersion", k8sObj.GetAPIVersion(),
 			"namespace", k8sObj.GetNamespace(),
 			"name", k8sObj.GetName())
-		ctx.Status(http.StatusAccepted)
 		span.SetStatus(codes.Ok, "successful")
-		return
+		return http.StatusAccepted
 	}
 
-	result, err := c.writeResource(ctx.Request.Context(), k8sObj, event)
+	if err = c.transformResource(k8sObj, event); err != nil {
+		slog.ErrorContext(
+			ctx,
+			"Could not transform the resource",
+			"event-id", event.ID(),
+			"event-type", event.Type(),
+			"id", string(k8sObj.GetUID()),
+			"kind", k8sObj.GetKind(),
+			"namespace", k8sObj.GetNamespace(),
+			"name", k8sObj.GetName(),
+			"err", err,
+		)
+		span.SetStatus(codes.Error, "failed")
+		span.RecordError(err)
+		return http.StatusInternalServerError
+	}
+
+	// The logs of a Pod are gone once it is deleted, so they are stored before the deletion is scheduled
+	if (isDeleteWhen || isKeepLastWhenDelete) && c.LogArchiver != nil {
+		c.LogArchiver.ArchiveBeforeDelete(ctx, k8sObj)
+	}
+
+	result, err := c.archiveResource(ctx, k8sObj, event)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
 		span.SetStatus(codes.Error, "failed")
 		span.RecordError(err)
-		return
+		return http.StatusInternalServerError
 	}
 
-	CEMetricAttrs["result"] = string(observability.NewCEResultFromWriteResourceResult(result))
+	CEMetricAttrs["result"] = string(result)
 
 	if !isDeleteWhen && !isKeepLastWhenDelete {
 		var logMsg string
@@ -237,7 +538,7 @@ func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
 		}
 
 		slog.InfoContext(
-			ctx.Request.Context(),
+			ctx,
 			logMsg,
 			"event-id", event.ID(),
 			"event-type", event.Type(),
@@ -246,9 +547,8 @@ func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
 			"namespace", k8sObj.GetNamespace(),
 			"name", k8sObj.GetName(),
 		)
-		ctx.Status(http.StatusAccepted)
 		span.SetStatus(codes.Ok, "successful")
-		return
+		return http.StatusAccepted
 	}
 
 	// We first schedule the deletion from the cluster
@@ -256,7 +556,7 @@ func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
 	resource, _ := meta.UnsafeGuessKindToResource(kind)     // we only need the plural resource
 	propagationPolicy := metav1.DeletePropagationBackground // can't get address of a const
 
-	childSpanCtx, childSpan = tracer.Start(ctx.Request.Context(), "delete resource")
+	childSpanCtx, childSpan = tracer.Start(ctx, "delete resource")
 	deleteCtx, deleteCtxCancel := context.WithCancel(childSpanCtx)
 	defer deleteCtxCancel()
 
@@ -276,10 +576,9 @@ func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
 			"namespace", k8sObj.GetNamespace(),
 			"name", k8sObj.GetName(),
 		)
-		ctx.Status(http.StatusAccepted)
 		childSpan.SetStatus(codes.Ok, "successful")
 		span.SetStatus(codes.Ok, "successful")
-		return
+		return http.StatusAccepted
 	}
 	if err != nil {
 		slog.ErrorContext(
@@ -293,27 +592,25 @@ func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
 			"name", k8sObj.GetName(),
 			"err", err,
 		)
-		ctx.Status(http.StatusInternalServerError)
 		childSpan.SetStatus(codes.Error, "failed")
 		childSpan.RecordError(err)
 		span.SetStatus(codes.Error, "failed")
 		span.RecordError(err)
-		return
+		return http.StatusInternalServerError
 	}
 	childSpan.End()
 
 	// After deleting the resource we persist it with deletionTimestamp
 	deleteTs := metav1.Now()
 	k8sObj.SetDeletionTimestamp(&deleteTs)
-	result, err = c.writeResource(ctx.Request.Context(), k8sObj, event)
+	result, err = c.archiveResource(ctx, k8sObj, event)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
 		span.SetStatus(codes.Error, "failed")
 		span.RecordError(err)
-		return
+		return http.StatusInternalServerError
 	}
 
-	CEMetricAttrs["result"] = string(observability.NewCEResultFromWriteResourceResult(result))
+	CEMetricAttrs["result"] = string(result)
 
 	var logMsg string
 	if isKeepLastWhenDelete {
@@ -323,7 +620,7 @@ func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
 	}
 
 	slog.InfoContext(
-		ctx.Request.Context(),
+		ctx,
 		logMsg,
 		"event-id", event.ID(),
 		"event-type", event.Type(),
@@ -333,8 +630,8 @@ func (c *Controller) ReceiveCloudEvent(ctx *gin.Context) {
 		"name", k8sObj.GetName(),
 	)
 
-	ctx.Status(http.StatusAccepted)
 	span.SetStatus(codes.Ok, "successful")
+	return http.StatusAccepted
 }
 
 func (c *Controller) Livez(ctx *gin.Context) {
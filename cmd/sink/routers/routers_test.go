@@ -4,19 +4,30 @@
 package routers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 
+	ce "github.com/cloudevents/sdk-go/v2"
 	"github.com/gin-gonic/gin"
 	kubearchiveapi "github.com/kubearchive/kubearchive/cmd/operator/api/v1"
+	"github.com/kubearchive/kubearchive/cmd/sink/dedup"
 	"github.com/kubearchive/kubearchive/cmd/sink/logs"
+	"github.com/kubearchive/kubearchive/cmd/sink/transformer"
+	"github.com/kubearchive/kubearchive/pkg/blobstore"
+	"github.com/kubearchive/kubearchive/pkg/cloudevents"
+	"github.com/kubearchive/kubearchive/pkg/constants"
 	fakeDb "github.com/kubearchive/kubearchive/pkg/database/fake"
 	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
 	"github.com/kubearchive/kubearchive/pkg/files"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"github.com/kubearchive/kubearchive/pkg/transform"
 	"github.com/stretchr/testify/assert"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -25,6 +36,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func setupRouter(
@@ -506,3 +519,256 @@ func TestReadyz(t *testing.T) {
 		})
 	}
 }
+
+func TestReceiveCloudEventBatch(t *testing.T) {
+	db := fakeDb.NewFakeDatabase([]*unstructured.Unstructured{}, []fakeDb.LogUrlRow{}, "$.")
+	router := setupRouter(t, db, nil, nil)
+	reader, err := os.Open("testdata/CE-batch.json")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	t.Cleanup(func() { reader.Close() })
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", reader)
+	req.Header.Add("Content-Type", "application/cloudevents-batch+json")
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusMultiStatus, res.Code)
+	var results []cloudevents.BatchResult
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &results))
+	assert.Equal(t, []cloudevents.BatchResult{
+		{ID: "cbec9e69-accf-4dfd-a22b-e9947376e01c", Status: http.StatusAccepted},
+		{ID: "e7f5b0a2-4f0a-4d51-9c3e-2b8f6f0d1a77", Status: http.StatusUnprocessableEntity},
+	}, results)
+	assert.Equal(t, 1, db.NumResources())
+}
+
+func TestReceiveCloudEventBatchInvalid(t *testing.T) {
+	db := fakeDb.NewFakeDatabase([]*unstructured.Unstructured{}, []fakeDb.LogUrlRow{}, "$.")
+	router := setupRouter(t, db, nil, nil)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"foo": "bar"}`))
+	req.Header.Add("Content-Type", "application/cloudevents-batch+json")
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, 0, db.NumResources())
+}
+
+func TestReceiveCloudEventBatchTooLarge(t *testing.T) {
+	db := fakeDb.NewFakeDatabase([]*unstructured.Unstructured{}, []fakeDb.LogUrlRow{}, "$.")
+	router := setupRouter(t, db, nil, nil)
+
+	events := make([]ce.Event, cloudevents.MaxBatchSize+1)
+	for i := range events {
+		events[i] = ce.NewEvent()
+		events[i].SetID(strconv.Itoa(i))
+		events[i].SetSource("http://localhost")
+		events[i].SetType("org.kubearchive.vacuum.update")
+	}
+	body, err := json.Marshal(events)
+	assert.NoError(t, err)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	req.Header.Add("Content-Type", "application/cloudevents-batch+json")
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, res.Code)
+	assert.Equal(t, 0, db.NumResources())
+}
+
+func TestGroupEventsByResource(t *testing.T) {
+	newEvent := func(id string, uid string) ce.Event {
+		event := ce.NewEvent()
+		event.SetID(id)
+		data := map[string]any{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]any{"uid": uid}}
+		assert.NoError(t, event.SetData(ce.ApplicationJSON, data))
+		return event
+	}
+	invalid := ce.NewEvent()
+	invalid.SetID("invalid")
+	assert.NoError(t, invalid.SetData(ce.TextPlain, "not a resource"))
+
+	events := []ce.Event{
+		newEvent("1", "a"),
+		newEvent("2", "b"),
+		invalid,
+		newEvent("3", "a"),
+		newEvent("4", ""),
+		newEvent("5", "b"),
+		newEvent("6", "a"),
+	}
+
+	assert.Equal(t, [][]int{{0, 3, 6}, {1, 5}, {2}, {4}}, groupEventsByResource(events))
+}
+
+func TestReceiveDuplicateCloudEvent(t *testing.T) {
+	db := fakeDb.NewFakeDatabase([]*unstructured.Unstructured{}, []fakeDb.LogUrlRow{}, "$.")
+	ctrl := NewController(db, nil, nil)
+	ctrl.Deduplicator = dedup.NewDeduplicator(10, nil, 0)
+	defer ctrl.Deduplicator.Close()
+	router := gin.Default()
+	router.POST("/", ctrl.ReceiveCloudEvent)
+
+	res := sendJobEvent(t, router)
+	assert.Equal(t, http.StatusAccepted, res.Code)
+	assert.Equal(t, 1, db.NumResources())
+
+	// The duplicate is accepted without writing it again, the write would fail
+	ctrl.Db = fakeDb.NewFakeDatabaseWithError(errors.New("database unavailable"))
+	res = sendJobEvent(t, router)
+	assert.Equal(t, http.StatusAccepted, res.Code)
+}
+
+func TestReceiveCloudEventInProgress(t *testing.T) {
+	db := fakeDb.NewFakeDatabase([]*unstructured.Unstructured{}, []fakeDb.LogUrlRow{}, "$.")
+	ctrl := NewController(db, nil, nil)
+	ctrl.Deduplicator = dedup.NewDeduplicator(10, nil, 0)
+	defer ctrl.Deduplicator.Close()
+	router := gin.Default()
+	router.POST("/", ctrl.ReceiveCloudEvent)
+
+	// Another request is processing the CloudEvent
+	ctx := context.Background()
+	source := "http://localhost"
+	id := "cbec9e69-accf-4dfd-a22b-e9947376e01c"
+	assert.Equal(t, dedup.New, ctrl.Deduplicator.Begin(ctx, source, id))
+
+	res := sendJobEvent(t, router)
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+	assert.Equal(t, inProgressRetryAfter, res.Header().Get("Retry-After"))
+	assert.Equal(t, 0, db.NumResources())
+
+	// The other request fails, so the retry is processed
+	ctrl.Deduplicator.Done(ctx, source, id, false)
+	res = sendJobEvent(t, router)
+	assert.Equal(t, http.StatusAccepted, res.Code)
+	assert.Equal(t, 1, db.NumResources())
+}
+
+func TestReceiveCloudEventStoresLogsBeforeDelete(t *testing.T) {
+	pod := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{"containers": []any{map[string]any{"name": "main"}}},
+		"status": map[string]any{
+			"phase": "Succeeded",
+			"containerStatuses": []any{map[string]any{
+				"name": "main", "state": map[string]any{"terminated": map[string]any{"exitCode": int64(0)}},
+			}},
+		},
+	}}
+	pod.SetAPIVersion("v1")
+	pod.SetKind("Pod")
+	pod.SetNamespace("test")
+	pod.SetName("test-pod")
+	pod.SetUID("abc-123")
+	key := blobstore.ContainerLogKey("test", "abc-123", "main")
+
+	store, err := blobstore.NewFilesystemStore(t.TempDir())
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	client := setupClient(t, pod.DeepCopy())
+	// The log must be stored by the time the Pod is deleted, it can not be retrieved afterwards
+	var storedOnDelete bool
+	client.PrependReactor("delete", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		storedOnDelete, _ = store.Exists(context.Background(), key)
+		return false, nil, nil
+	})
+
+	db := fakeDb.NewFakeDatabase([]*unstructured.Unstructured{}, []fakeDb.LogUrlRow{}, "$.")
+	ctrl := NewController(db, client, nil)
+	ctrl.LogArchiver = logs.NewArchiver(k8sfake.NewSimpleClientset().CoreV1(), store)
+	defer ctrl.LogArchiver.Close()
+	router := gin.Default()
+	router.POST("/", ctrl.ReceiveCloudEvent)
+
+	data, err := pod.MarshalJSON()
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	event := ce.NewEvent()
+	event.SetID("d2a5c6f0-6d4b-4a53-9c51-5a8b7f3e1d20")
+	event.SetSource("http://localhost")
+	event.SetType("org.kubearchive.sinkfilters.resource.delete-when")
+	assert.NoError(t, event.SetData(ce.ApplicationJSON, data))
+	body, err := event.MarshalJSON()
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	req.Header.Add("Content-Type", "application/cloudevents+json")
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusAccepted, res.Code)
+	assert.True(t, storedOnDelete)
+	_, err = client.Resource(corev1.SchemeGroupVersion.WithResource("pods")).Namespace("test").Get(
+		context.Background(), "test-pod", metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func setupTransformer(t testing.TB, transform *kubearchiveapi.TransformConfig) *transformer.Transformer {
+	t.Helper()
+	sinkFilter, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&kubearchiveapi.SinkFilter{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "kubearchive.org/v1", Kind: "SinkFilter"},
+		ObjectMeta: metav1.ObjectMeta{Name: constants.SinkFilterResourceName, Namespace: constants.KubeArchiveNamespace},
+		Spec: kubearchiveapi.SinkFilterSpec{
+			Cluster: []kubearchiveapi.ClusterKubeArchiveConfigResource{
+				{
+					Selector:  kubearchiveapi.APIVersionKind{APIVersion: "batch/v1", Kind: "Job"},
+					Transform: transform,
+				},
+			},
+		},
+	})
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	tr, err := transformer.NewTransformer(context.Background(),
+		setupClient(t, &unstructured.Unstructured{Object: sinkFilter}), []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	t.Cleanup(tr.Close)
+	return tr
+}
+
+func TestReceiveTransformedCloudEvent(t *testing.T) {
+	// The resource is kept in the dead-letter queue, so both the resource and the CloudEvent can be checked
+	ctrl := NewController(fakeDb.NewFakeDatabaseWithError(errors.New("database unavailable")), nil, nil)
+	router := setupDeadLetterRouter(t, ctrl)
+	ctrl.Transformer = setupTransformer(t, &kubearchiveapi.TransformConfig{
+		DropManagedFields: true,
+		Hash:              []string{"$.metadata.labels['job-name']"},
+	})
+
+	res := sendJobEvent(t, router)
+	assert.Equal(t, http.StatusAccepted, res.Code)
+
+	entry, err := ctrl.DeadLetters.Get(1)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	event := ce.NewEvent()
+	assert.NoError(t, event.UnmarshalJSON(entry.Event))
+	for _, raw := range [][]byte{entry.Resource, event.Data()} {
+		obj, err := models.UnstructuredFromByteSlice(raw)
+		assert.NoError(t, err)
+		assert.Nil(t, obj.GetManagedFields())
+		assert.True(t, strings.HasPrefix(obj.GetLabels()["job-name"], transform.HashPrefix))
+		assert.Equal(t, "generate-log-1-28968184", obj.GetName())
+	}
+}
+
+func TestReceiveCloudEventInvalidTransform(t *testing.T) {
+	ctrl := NewController(fakeDb.NewFakeDatabaseWithError(errors.New("database unavailable")), nil, nil)
+	router := setupDeadLetterRouter(t, ctrl)
+	ctrl.Transformer = setupTransformer(t, &kubearchiveapi.TransformConfig{Remove: []string{"$..env"}})
+
+	res := sendJobEvent(t, router)
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	assert.Equal(t, 0, ctrl.DeadLetters.Len())
+}
@@ -20,9 +20,15 @@ import (
 	"github.com/kubearchive/kubearchive/pkg/observability"
 )
 
+// adminAddr is the address of the administration endpoints. It only listens on the loopback interface, so
+// they are only reachable with kubectl port-forward.
+const adminAddr = "127.0.0.1:8082"
+
 type Server struct {
 	controller *routers.Controller
 	router     *gin.Engine
+	// adminRouter serves the administration endpoints, it is nil when there are none
+	adminRouter *gin.Engine
 }
 
 func NewServer(controller *routers.Controller) *Server {
@@ -40,9 +46,23 @@ func NewServer(controller *routers.Controller) *Server {
 	router.GET("/livez", controller.Livez)
 	router.GET("/readyz", controller.Readyz)
 
+	var adminRouter *gin.Engine
+	if controller.DeadLetters != nil {
+		adminRouter = gin.New()
+		adminRouter.Use(gin.Recovery())
+		adminRouter.Use(middleware.Logger(middleware.LoggerConfig{}))
+
+		adminRouter.GET("/dlq/entries", controller.ListDeadLetters)
+		adminRouter.GET("/dlq/entries/:id", controller.GetDeadLetter)
+		adminRouter.DELETE("/dlq/entries/:id", controller.DropDeadLetter)
+		adminRouter.POST("/dlq/entries/:id/replay", controller.ReplayDeadLetter)
+		adminRouter.POST("/dlq/replay", controller.ReplayDeadLetters)
+	}
+
 	return &Server{
-		controller: controller,
-		router:     router,
+		controller:  controller,
+		router:      router,
+		adminRouter: adminRouter,
 	}
 }
 
@@ -61,6 +81,22 @@ func (s *Server) Serve() {
 		}
 	}()
 
+	var adminServer *http.Server
+	if s.adminRouter != nil {
+		adminServer = &http.Server{
+			Addr:              adminAddr,
+			Handler:           s.adminRouter.Handler(),
+			ReadHeaderTimeout: 2 * time.Second,
+		}
+		go func() {
+			err := adminServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				slog.Error("an error occurred while running the admin server", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	if os.Getenv(observability.EnablePprofEnvVar) == "true" {
 		pprofServer := observability.GetObservabilityServer()
 		go func() {
@@ -83,4 +119,10 @@ func (s *Server) Serve() {
 		slog.Error("Error shutting down the server", "error", err)
 		os.Exit(1)
 	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			slog.Error("Error shutting down the admin server", "error", err)
+			os.Exit(1)
+		}
+	}
 }
@@ -0,0 +1,210 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	kubearchiveapi "github.com/kubearchive/kubearchive/cmd/operator/api/v1"
+	"github.com/kubearchive/kubearchive/pkg/constants"
+	"github.com/kubearchive/kubearchive/pkg/transform"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// HashKeyEnvVar is the key of the HMAC of the fields hashed by the transform rules, usually read from a
+	// Secret. The resources with fields to hash are not archived without it
+	HashKeyEnvVar = "KUBEARCHIVE_SINK_HASH_KEY"
+	// minHashKeySize is the minimum size in bytes of the hash key, the size of the SHA-256 output
+	minHashKeySize = 32
+)
+
+// rule is the compiled transform of a kind, err is set when the transform is invalid
+type rule struct {
+	pipeline *transform.Pipeline
+	err      error
+}
+
+// Transformer applies the transform rules of the KubeArchiveConfig and ClusterKubeArchiveConfig resources to the
+// resources before they are written to the database. It watches the SinkFilter to update the rules as soon as
+// it changes.
+type Transformer struct {
+	client  dynamic.Interface
+	hashKey []byte
+
+	mu sync.RWMutex
+	// cluster are the rules of the ClusterKubeArchiveConfig by kind key
+	cluster map[string]rule
+	// namespaces are the rules of the KubeArchiveConfig by namespace and kind key
+	namespaces map[string]map[string]rule
+
+	informers dynamicinformer.DynamicSharedInformerFactory
+	stop      chan struct{}
+}
+
+// NewTransformer reads the rules from the SinkFilter and keeps them updated in the background. It fails when the
+// SinkFilter can not be read, so no resource is written without its transformations. The fields are hashed
+// with hashKey, the resources with fields to hash are not transformed when it is empty.
+func NewTransformer(ctx context.Context, client dynamic.Interface, hashKey []byte) (*Transformer, error) {
+	t := &Transformer{
+		client:     client,
+		hashKey:    hashKey,
+		cluster:    map[string]rule{},
+		namespaces: map[string]map[string]rule{},
+		stop:       make(chan struct{}),
+	}
+	if err := t.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	t.informers = dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, 0,
+		constants.KubeArchiveNamespace, func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name",
+				constants.SinkFilterResourceName).String()
+		})
+	informer := t.informers.ForResource(kubearchiveapi.SinkFilterGVR).Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    t.updateFromObject,
+		UpdateFunc: func(_, obj any) { t.updateFromObject(obj) },
+		DeleteFunc: func(any) { t.update(&kubearchiveapi.SinkFilter{}) },
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch SinkFilter: %w", err)
+	}
+	t.informers.Start(t.stop)
+	return t, nil
+}
+
+// NewTransformerFromEnv returns a Transformer that hashes the fields with the key of HashKeyEnvVar
+func NewTransformerFromEnv(ctx context.Context, client dynamic.Interface) (*Transformer, error) {
+	hashKey := []byte(os.Getenv(HashKeyEnvVar))
+	if len(hashKey) > 0 && len(hashKey) < minHashKeySize {
+		return nil, fmt.Errorf("invalid %s, it must have at least %d bytes", HashKeyEnvVar, minHashKeySize)
+	}
+	return NewTransformer(ctx, client, hashKey)
+}
+
+// Close stops the updates of the rules
+func (t *Transformer) Close() {
+	close(t.stop)
+	t.informers.Shutdown()
+}
+
+// Transform applies the rules of the kind of the resource in place, first the rules of the
+// ClusterKubeArchiveConfig and then the rules of the KubeArchiveConfig of its namespace. It returns whether any
+// rule was applied, and an error when a rule is invalid, as writing the resource could leak the fields it should
+// remove.
+func (t *Transformer) Transform(obj *unstructured.Unstructured) (bool, error) {
+	key := kubearchiveapi.APIVersionKind{Kind: obj.GetKind(), APIVersion: obj.GetAPIVersion()}.Key()
+
+	t.mu.RLock()
+	rules := make([]rule, 0, 2)
+	if clusterRule, ok := t.cluster[key]; ok {
+		rules = append(rules, clusterRule)
+	}
+	if namespaceRule, ok := t.namespaces[obj.GetNamespace()][key]; ok {
+		rules = append(rules, namespaceRule)
+	}
+	t.mu.RUnlock()
+
+	for _, r := range rules {
+		if r.err != nil {
+			return false, r.err
+		}
+		if err := r.pipeline.Apply(obj); err != nil {
+			return false, err
+		}
+	}
+	return len(rules) > 0, nil
+}
+
+// refresh replaces the rules with the ones in the SinkFilter. There are no rules when the SinkFilter does not
+// exist.
+func (t *Transformer) refresh(ctx context.Context) error {
+	obj, err := t.client.Resource(kubearchiveapi.SinkFilterGVR).
+		Namespace(constants.KubeArchiveNamespace).
+		Get(ctx, constants.SinkFilterResourceName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		t.update(&kubearchiveapi.SinkFilter{})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get SinkFilter: %w", err)
+	}
+
+	sinkFilter, err := kubearchiveapi.ConvertUnstructuredToSinkFilter(obj)
+	if err != nil {
+		return fmt.Errorf("failed to convert to SinkFilter: %w", err)
+	}
+	t.update(sinkFilter)
+	return nil
+}
+
+// updateFromObject replaces the rules with the ones in the SinkFilter received from the watch. The previous
+// rules are kept when it is not a valid SinkFilter.
+func (t *Transformer) updateFromObject(obj any) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	sinkFilter, err := kubearchiveapi.ConvertUnstructuredToSinkFilter(u)
+	if err != nil {
+		slog.Warn("Could not update the transform rules, keeping the previous ones", "err", err)
+		return
+	}
+	t.update(sinkFilter)
+}
+
+func (t *Transformer) update(sinkFilter *kubearchiveapi.SinkFilter) {
+	cluster := map[string]rule{}
+	for _, resource := range sinkFilter.Spec.Cluster {
+		if resource.Transform != nil {
+			cluster[resource.Selector.Key()] = t.newRule(resource.Selector, "", resource.Transform)
+		}
+	}
+	namespaces := map[string]map[string]rule{}
+	for namespace, resources := range sinkFilter.Spec.Namespaces {
+		for _, resource := range resources {
+			if resource.Transform == nil {
+				continue
+			}
+			if namespaces[namespace] == nil {
+				namespaces[namespace] = map[string]rule{}
+			}
+			namespaces[namespace][resource.Selector.Key()] = t.newRule(resource.Selector, namespace, resource.Transform)
+		}
+	}
+
+	t.mu.Lock()
+	t.cluster = cluster
+	t.namespaces = namespaces
+	t.mu.Unlock()
+}
+
+func (t *Transformer) newRule(selector kubearchiveapi.APIVersionKind, namespace string,
+	config *kubearchiveapi.TransformConfig) rule {
+	rules := config.Rules()
+	rules.HashKey = t.hashKey
+	if len(rules.Hash) > 0 && len(rules.HashKey) == 0 {
+		slog.Error("Transform rule with fields to hash without the hash key, the resources of its kind will not be archived",
+			"kind", selector.Kind, "apiVersion", selector.APIVersion, "namespace", namespace, "env", HashKeyEnvVar)
+	}
+	pipeline, err := transform.NewPipeline(rules)
+	if err != nil {
+		slog.Error("Invalid transform rule, the resources of its kind will not be archived", "kind", selector.Kind,
+			"apiVersion", selector.APIVersion, "namespace", namespace, "err", err)
+		return rule{err: fmt.Errorf("invalid transform rule for %s/%s: %w", selector.APIVersion, selector.Kind, err)}
+	}
+	return rule{pipeline: pipeline}
+}
@@ -0,0 +1,240 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	kubearchiveapi "github.com/kubearchive/kubearchive/cmd/operator/api/v1"
+	"github.com/kubearchive/kubearchive/pkg/constants"
+	"github.com/kubearchive/kubearchive/pkg/transform"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var (
+	secretSelector = kubearchiveapi.APIVersionKind{APIVersion: "v1", Kind: "Secret"}
+	testHashKey    = []byte("0123456789abcdef0123456789abcdef")
+)
+
+func hashOf(value string) string {
+	mac := hmac.New(sha256.New, testHashKey)
+	mac.Write([]byte(value))
+	return transform.HashPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newSinkFilter(t testing.TB, spec kubearchiveapi.SinkFilterSpec) *unstructured.Unstructured {
+	t.Helper()
+	sinkFilter := &kubearchiveapi.SinkFilter{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "kubearchive.org/v1", Kind: "SinkFilter"},
+		ObjectMeta: metav1.ObjectMeta{Name: constants.SinkFilterResourceName, Namespace: constants.KubeArchiveNamespace},
+		Spec:       spec,
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(sinkFilter)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func newFakeClient(objects ...runtime.Object) *fake.FakeDynamicClient {
+	return fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{kubearchiveapi.SinkFilterGVR: "SinkFilterList"}, objects...)
+}
+
+func newSecret(namespace string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]any{
+			"name":          "credentials",
+			"namespace":     namespace,
+			"uid":           "1234",
+			"managedFields": []any{map[string]any{"manager": "kubectl"}},
+			"labels":        map[string]any{"team": "a"},
+		},
+		"data": map[string]any{"password": "c2VjcmV0"},
+	}}
+}
+
+func TestTransform(t *testing.T) {
+	sinkFilter := newSinkFilter(t, kubearchiveapi.SinkFilterSpec{
+		Cluster: []kubearchiveapi.ClusterKubeArchiveConfigResource{
+			{
+				Selector:  secretSelector,
+				Transform: &kubearchiveapi.TransformConfig{Remove: []string{"$.data.*"}},
+			},
+		},
+		Namespaces: map[string][]kubearchiveapi.KubeArchiveConfigResource{
+			"test": {
+				{
+					Selector:  secretSelector,
+					Transform: &kubearchiveapi.TransformConfig{DropManagedFields: true, Hash: []string{"$.metadata.labels.team"}},
+				},
+				{
+					Selector:    kubearchiveapi.APIVersionKind{APIVersion: "batch/v1", Kind: "Job"},
+					ArchiveWhen: "true",
+				},
+			},
+		},
+	})
+	transformer, err := NewTransformer(context.Background(), newFakeClient(sinkFilter), testHashKey)
+	assert.NoError(t, err)
+	t.Cleanup(transformer.Close)
+
+	tests := []struct {
+		name          string
+		obj           *unstructured.Unstructured
+		transformed   bool
+		managedFields bool
+		labels        map[string]string
+	}{
+		{
+			name:          "cluster and namespace rules",
+			obj:           newSecret("test"),
+			transformed:   true,
+			managedFields: false,
+			labels:        map[string]string{"team": hashOf("a")},
+		},
+		{
+			name:          "cluster rules",
+			obj:           newSecret("other"),
+			transformed:   true,
+			managedFields: true,
+			labels:        map[string]string{"team": "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformed, err := transformer.Transform(tt.obj)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.transformed, transformed)
+			assert.Equal(t, map[string]any{}, tt.obj.Object["data"])
+			assert.Equal(t, tt.managedFields, tt.obj.GetManagedFields() != nil)
+			assert.Equal(t, tt.labels, tt.obj.GetLabels())
+		})
+	}
+
+	job := &unstructured.Unstructured{}
+	job.SetAPIVersion("batch/v1")
+	job.SetKind("Job")
+	job.SetNamespace("test")
+	transformed, err := transformer.Transform(job)
+	assert.NoError(t, err)
+	assert.False(t, transformed)
+}
+
+func TestTransformInvalidRule(t *testing.T) {
+	sinkFilter := newSinkFilter(t, kubearchiveapi.SinkFilterSpec{
+		Namespaces: map[string][]kubearchiveapi.KubeArchiveConfigResource{
+			"test": {
+				{
+					Selector:  secretSelector,
+					Transform: &kubearchiveapi.TransformConfig{Remove: []string{"$..data"}},
+				},
+			},
+		},
+	})
+	transformer, err := NewTransformer(context.Background(), newFakeClient(sinkFilter), testHashKey)
+	assert.NoError(t, err)
+	t.Cleanup(transformer.Close)
+
+	secret := newSecret("test")
+	_, err = transformer.Transform(secret)
+	assert.ErrorContains(t, err, "invalid transform rule for v1/Secret")
+	assert.Equal(t, newSecret("test"), secret)
+}
+
+func TestNewTransformerWithoutSinkFilter(t *testing.T) {
+	transformer, err := NewTransformer(context.Background(), newFakeClient(), testHashKey)
+	assert.NoError(t, err)
+	t.Cleanup(transformer.Close)
+
+	transformed, err := transformer.Transform(newSecret("test"))
+	assert.NoError(t, err)
+	assert.False(t, transformed)
+}
+
+func TestNewTransformerFails(t *testing.T) {
+	client := newFakeClient()
+	client.PrependReactor("get", "sinkfilters", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+
+	_, err := NewTransformer(context.Background(), client, testHashKey)
+	assert.ErrorContains(t, err, "failed to get SinkFilter: forbidden")
+}
+
+func TestTransformWithoutHashKey(t *testing.T) {
+	sinkFilter := newSinkFilter(t, kubearchiveapi.SinkFilterSpec{
+		Cluster: []kubearchiveapi.ClusterKubeArchiveConfigResource{
+			{
+				Selector:  secretSelector,
+				Transform: &kubearchiveapi.TransformConfig{Hash: []string{"$.data.*"}},
+			},
+		},
+	})
+	transformer, err := NewTransformer(context.Background(), newFakeClient(sinkFilter), nil)
+	assert.NoError(t, err)
+	t.Cleanup(transformer.Close)
+
+	secret := newSecret("test")
+	_, err = transformer.Transform(secret)
+	assert.ErrorIs(t, err, transform.ErrNoHashKey)
+	assert.Equal(t, newSecret("test"), secret)
+}
+
+func TestNewTransformerFromEnv(t *testing.T) {
+	t.Setenv(HashKeyEnvVar, "short")
+	_, err := NewTransformerFromEnv(context.Background(), newFakeClient())
+	assert.ErrorContains(t, err, HashKeyEnvVar)
+
+	t.Setenv(HashKeyEnvVar, string(testHashKey))
+	transformer, err := NewTransformerFromEnv(context.Background(), newFakeClient())
+	assert.NoError(t, err)
+	t.Cleanup(transformer.Close)
+	assert.Equal(t, testHashKey, transformer.hashKey)
+}
+
+func TestTransformWatchesSinkFilter(t *testing.T) {
+	client := newFakeClient()
+	transformer, err := NewTransformer(context.Background(), client, testHashKey)
+	assert.NoError(t, err)
+	t.Cleanup(transformer.Close)
+
+	sinkFilters := client.Resource(kubearchiveapi.SinkFilterGVR).Namespace(constants.KubeArchiveNamespace)
+	sinkFilter := newSinkFilter(t, kubearchiveapi.SinkFilterSpec{
+		Cluster: []kubearchiveapi.ClusterKubeArchiveConfigResource{
+			{
+				Selector:  secretSelector,
+				Transform: &kubearchiveapi.TransformConfig{Remove: []string{"$.data.*"}},
+			},
+		},
+	})
+	// The informer may not be watching yet, so the SinkFilter is created until the rules are updated
+	assert.Eventually(t, func() bool {
+		_ = sinkFilters.Delete(context.Background(), constants.SinkFilterResourceName, metav1.DeleteOptions{})
+		_, _ = sinkFilters.Create(context.Background(), sinkFilter, metav1.CreateOptions{})
+		transformed, _ := transformer.Transform(newSecret("test"))
+		return transformed
+	}, 5*time.Second, 50*time.Millisecond)
+
+	assert.NoError(t, sinkFilters.Delete(context.Background(), constants.SinkFilterResourceName,
+		metav1.DeleteOptions{}))
+	assert.Eventually(t, func() bool {
+		transformed, _ := transformer.Transform(newSecret("test"))
+		return !transformed
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -5,9 +5,12 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"os"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	ce "github.com/cloudevents/sdk-go/v2"
@@ -25,6 +28,13 @@ import (
 	"k8s.io/client-go/restmapper"
 )
 
+const (
+	// batchSizeEnvVar is the number of events sent to the sink in each request, up to publisher.MaxBatchSize.
+	// 1 sends them one by one
+	batchSizeEnvVar  = "KUBEARCHIVE_VACUUM_BATCH_SIZE"
+	defaultBatchSize = 1
+)
+
 type Keeper struct {
 	when   *filters.KeepLastWhenRule
 	bucket []*unstructured.Unstructured // Array of resources sorted by "sort" field, one greater than count
@@ -36,15 +46,35 @@ type VacuumCloudEventPublisher struct {
 	mapper           meta.RESTMapper
 	clusterFilters   map[string]filters.CelExpressions
 	namespaceFilters map[string]map[string]filters.CelExpressions
+	batchSize        int
+	// batch has the events not sent yet when batchSize is greater than 1
+	batch []pendingEvent
+}
+
+// pendingEvent is an event of the batch and the fields to log its result
+type pendingEvent struct {
+	event     publisher.BatchEvent
+	avk       *kubearchiveapi.APIVersionKind
+	namespace string
+	name      string
 }
 
 func NewVacuumCloudEventPublisher(source string, clusterFilters map[string]filters.CelExpressions, namespaceFilters map[string]map[string]filters.CelExpressions) (*VacuumCloudEventPublisher, error) {
 	vcep := &VacuumCloudEventPublisher{
 		clusterFilters:   clusterFilters,
 		namespaceFilters: namespaceFilters,
+		batchSize:        defaultBatchSize,
 	}
 
 	var err error
+	if value := os.Getenv(batchSizeEnvVar); value != "" {
+		if vcep.batchSize, err = strconv.Atoi(value); err != nil || vcep.batchSize < 1 ||
+			vcep.batchSize > publisher.MaxBatchSize {
+			return nil, fmt.Errorf("invalid %s value '%s', it must be an integer between 1 and %d", batchSizeEnvVar,
+				value, publisher.MaxBatchSize)
+		}
+	}
+
 	if vcep.publisher, err = publisher.NewSinkCloudEventPublisher(source); err != nil {
 		return nil, err
 	}
@@ -178,6 +208,7 @@ func (vcep *VacuumCloudEventPublisher) sendByAPIVersionKind(ctx context.Context,
 		}
 		slog.Info("No event sent", "apiversion", avk.APIVersion, "kind", avk.Kind, "namespace", namespace, "name", name)
 	}
+	vcep.sendBatch(ctx)
 
 	sort.Slice(keepers, func(i, j int) bool {
 		return keepers[i].when.WhenText < keepers[j].when.WhenText
@@ -270,8 +301,43 @@ func (vcep *VacuumCloudEventPublisher) createKeepers(clusterCel filters.CelExpre
 	return keepers
 }
 
+// sendCloudEvent sends the event, or adds it to the batch when the events are sent in batches
 func (vcep *VacuumCloudEventPublisher) sendCloudEvent(ctx context.Context, eventType string, avk *kubearchiveapi.APIVersionKind, namespace string, name string, item *unstructured.Unstructured) {
-	sendResult := vcep.publisher.Send(ctx, eventType, item.Object)
+	if vcep.batchSize <= 1 {
+		sendResult := vcep.publisher.SendWithRetries(ctx, eventType, item.Object)
+		logSendResult(sendResult, eventType, avk, namespace, name)
+		return
+	}
+
+	vcep.batch = append(vcep.batch, pendingEvent{
+		event:     publisher.BatchEvent{Type: eventType, Resource: item.Object},
+		avk:       avk,
+		namespace: namespace,
+		name:      name,
+	})
+	if len(vcep.batch) >= vcep.batchSize {
+		vcep.sendBatch(ctx)
+	}
+}
+
+// sendBatch sends the events of the batch in a single request
+func (vcep *VacuumCloudEventPublisher) sendBatch(ctx context.Context) {
+	if len(vcep.batch) == 0 {
+		return
+	}
+
+	events := make([]publisher.BatchEvent, 0, len(vcep.batch))
+	for _, pending := range vcep.batch {
+		events = append(events, pending.event)
+	}
+	for i, sendResult := range vcep.publisher.SendBatch(ctx, events) {
+		pending := vcep.batch[i]
+		logSendResult(sendResult, pending.event.Type, pending.avk, pending.namespace, pending.name)
+	}
+	vcep.batch = nil
+}
+
+func logSendResult(sendResult ce.Result, eventType string, avk *kubearchiveapi.APIVersionKind, namespace string, name string) {
 	var httpResult *cehttp.Result
 	statusCode := 0
 	if ce.ResultAs(sendResult, &httpResult) {
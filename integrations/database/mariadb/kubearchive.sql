@@ -34,17 +34,21 @@ DROP TABLE IF EXISTS `resource`;
 /*!40101 SET @saved_cs_client     = @@character_set_client */;
 /*!40101 SET character_set_client = utf8 */;
 CREATE TABLE `resource` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
   `uuid` char(36) NOT NULL,
   `api_version` varchar(256) NOT NULL,
   `kind` varchar(256) NOT NULL,
   `name` varchar(256) DEFAULT NULL,
   `namespace` varchar(256) DEFAULT NULL,
   `resource_version` varchar(256) DEFAULT NULL,
-  `created_at` timestamp NOT NULL DEFAULT current_timestamp(),
-  `updated_at` timestamp NOT NULL DEFAULT current_timestamp(),
+  `created_at` timestamp(6) NOT NULL DEFAULT current_timestamp(6),
+  `updated_at` timestamp(6) NOT NULL DEFAULT current_timestamp(6),
+  `cluster_updated_ts` timestamp NOT NULL DEFAULT current_timestamp(),
   `cluster_deleted_ts` timestamp NULL DEFAULT NULL,
   `data` longtext CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL CHECK (json_valid(`data`)),
-  PRIMARY KEY (`uuid`)
+  PRIMARY KEY (`uuid`),
+  UNIQUE KEY `resource_id` (`id`),
+  KEY `resource_updated_at_id_idx` (`updated_at`,`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COLLATE=utf8mb3_general_ci;
 
 
@@ -64,6 +68,62 @@ CREATE TABLE `log_url` (
   `updated_at` timestamp NOT NULL DEFAULT current_timestamp()
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COLLATE=utf8mb3_general_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `resource_revision`
+--
+
+DROP TABLE IF EXISTS `resource_revision`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `resource_revision` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `uuid` char(36) NOT NULL,
+  `revision` bigint NOT NULL,
+  `resource_version` varchar(256) DEFAULT NULL,
+  `cluster_updated_ts` timestamp NOT NULL DEFAULT current_timestamp(),
+  `cluster_deleted_ts` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp NOT NULL DEFAULT current_timestamp(),
+  `data` longtext CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL CHECK (json_valid(`data`)),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `resource_revision_uuid_revision` (`uuid`, `revision`),
+  CONSTRAINT `resource_revision_uuid_fk` FOREIGN KEY (`uuid`) REFERENCES `resource` (`uuid`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COLLATE=utf8mb3_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `resource_definition`
+--
+
+DROP TABLE IF EXISTS `resource_definition`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `resource_definition` (
+  `api_group` varchar(253) NOT NULL,
+  `plural` varchar(63) NOT NULL,
+  `kind` varchar(63) NOT NULL,
+  `created_at` timestamp NOT NULL DEFAULT current_timestamp(),
+  `updated_at` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+  `data` longtext CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL CHECK (json_valid(`data`)),
+  PRIMARY KEY (`api_group`, `plural`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COLLATE=utf8mb3_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `processed_event`
+--
+
+DROP TABLE IF EXISTS `processed_event`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `processed_event` (
+  `source` varchar(512) NOT NULL,
+  `event_id` varchar(255) NOT NULL,
+  `processed_at` timestamp NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`source`, `event_id`),
+  KEY `processed_event_processed_at_idx` (`processed_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COLLATE=utf8mb3_general_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
 /*!50003 SET @saved_cs_client      = @@character_set_client */ ;
 /*!50003 SET @saved_cs_results     = @@character_set_results */ ;
 /*!50003 SET @saved_col_connection = @@collation_connection */ ;
@@ -76,7 +136,7 @@ CREATE TABLE `log_url` (
 DELIMITER ;;
 /*!50003 CREATE*/ /*!50017 DEFINER=`root`@`%`*/ /*!50003 TRIGGER set_timestamp
   BEFORE update ON resource FOR EACH ROW
-    UPDATE resource SET resource.updated_at = now() */;;
+    SET NEW.updated_at = now(6) */;;
 DELIMITER ;;
 /*!50003 CREATE*/ /*!50017 DEFINER=`root`@`%`*/ /*!50003 TRIGGER set_timestamp
   BEFORE update ON log_url FOR EACH ROW
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS public.resource_revision (
+    id BIGSERIAL PRIMARY KEY,
+    uuid uuid NOT NULL REFERENCES public.resource(uuid) ON DELETE CASCADE,
+    revision bigint NOT NULL,
+    resource_version character varying,
+    cluster_updated_ts timestamp with time zone NOT NULL,
+    cluster_deleted_ts timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    data jsonb NOT NULL,
+    UNIQUE (uuid, revision)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS public.resource_definition (
+    api_group character varying NOT NULL,
+    plural character varying NOT NULL,
+    kind character varying NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    data jsonb NOT NULL,
+    PRIMARY KEY (api_group, plural)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS public.processed_event (
+    source character varying NOT NULL,
+    event_id character varying NOT NULL,
+    processed_at timestamp with time zone DEFAULT now() NOT NULL,
+    PRIMARY KEY (source, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS processed_event_processed_at_idx ON public.processed_event USING btree (processed_at);
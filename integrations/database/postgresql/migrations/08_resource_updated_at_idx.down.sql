@@ -0,0 +1 @@
+DROP INDEX IF EXISTS resource_updated_at_id_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS resource_updated_at_id_idx ON public.resource USING btree (updated_at, id);
@@ -33,4 +33,7 @@ KUBEARCHIVE_NS="kubearchive"
 if kubectl get ns ${KUBEARCHIVE_NS} >& /dev/null; then
     ELASTIC_PWD=$(kubectl -n ${NAMESPACE} get secret kubearchive-es-elastic-user -o=jsonpath='{.data.elastic}')
     kubectl patch -n ${KUBEARCHIVE_NS} secret kubearchive-logging -p "{\"data\": {\"PASSWORD\": \"${ELASTIC_PWD}\"}}"
+    # Verify the certificate of Elasticsearch with the CA created by the operator
+    ELASTIC_CA=$(kubectl -n ${NAMESPACE} get secret kubearchive-es-http-certs-public -o=jsonpath='{.data.ca\.crt}')
+    kubectl patch -n ${KUBEARCHIVE_NS} secret kubearchive-logging -p "{\"data\": {\"CA_BUNDLE\": \"${ELASTIC_CA}\"}}"
 fi
@@ -36,6 +36,8 @@ if kubectl get ns ${KUBEARCHIVE_NS} >& /dev/null; then
     # Configure the password for the api server
     SPLUNK_PWD=$(kubectl -n ${NAMESPACE} get secret splunk-splunk-operator-secret -o jsonpath='{.data.password}' | base64 --decode)
     kubectl patch -n ${KUBEARCHIVE_NS} secret kubearchive-logging -p "{\"stringData\": {\"Authorization\": \"Basic $(echo -n "admin:${SPLUNK_PWD}" | base64)\"}}"
+    # The development Splunk uses a self-signed certificate
+    kubectl patch -n ${KUBEARCHIVE_NS} secret kubearchive-logging -p "{\"stringData\": {\"TLS_INSECURE_SKIP_VERIFY\": \"true\"}}"
     kubectl -n ${KUBEARCHIVE_NS} rollout restart deployment kubearchive-api-server
 
     sleep 10 # FIXME - There is an issue with rollout and sometimes the old pod is running
@@ -4,13 +4,72 @@
 package abort
 
 import (
+	"errors"
 	"log/slog"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
+// reasons are the StatusReason the Kubernetes API server returns with each status code
+var reasons = map[int]metav1.StatusReason{
+	http.StatusBadRequest:            metav1.StatusReasonBadRequest,
+	http.StatusUnauthorized:          metav1.StatusReasonUnauthorized,
+	http.StatusForbidden:             metav1.StatusReasonForbidden,
+	http.StatusNotFound:              metav1.StatusReasonNotFound,
+	http.StatusMethodNotAllowed:      metav1.StatusReasonMethodNotAllowed,
+	http.StatusNotAcceptable:         metav1.StatusReasonNotAcceptable,
+	http.StatusConflict:              metav1.StatusReasonConflict,
+	http.StatusGone:                  metav1.StatusReasonExpired,
+	http.StatusRequestEntityTooLarge: metav1.StatusReasonRequestEntityTooLarge,
+	http.StatusUnsupportedMediaType:  metav1.StatusReasonUnsupportedMediaType,
+	http.StatusUnprocessableEntity:   metav1.StatusReasonInvalid,
+	http.StatusTooManyRequests:       metav1.StatusReasonTooManyRequests,
+	http.StatusInternalServerError:   metav1.StatusReasonInternalError,
+	http.StatusServiceUnavailable:    metav1.StatusReasonServiceUnavailable,
+	http.StatusGatewayTimeout:        metav1.StatusReasonTimeout,
+}
+
+// Abort aborts the request with a metav1.Status describing err, as the Kubernetes API server does, so
+// client-go and kubectl show the error
 func Abort(c *gin.Context, err error, code int) {
-	slog.ErrorContext(c.Request.Context(), "there was a problem", "error", err.Error(), "code", code)
-	c.JSON(code, gin.H{"message": err.Error()})
+	status := NewStatus(c, err, code)
+	slog.ErrorContext(c.Request.Context(), "there was a problem", "error", err.Error(), "code", status.Code,
+		"reason", status.Reason)
+	c.JSON(int(status.Code), status)
 	c.Abort()
 }
+
+// NewStatus returns the metav1.Status of err with the reason of code. The details of the status are the
+// group, resource type, name and uid in the path of the request. When err wraps an error of the Kubernetes
+// API with the same code, like the ones created with k8s.io/apimachinery/pkg/api/errors, its reason and
+// details are kept.
+func NewStatus(c *gin.Context, err error, code int) metav1.Status {
+	status := metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   int32(code), // #nosec G115 -- HTTP status codes fit in an int32
+		Reason: reasons[code],
+	}
+	if status.Reason == "" {
+		status.Reason = metav1.StatusReasonUnknown
+	}
+	if resourceType := c.Param("resourceType"); resourceType != "" {
+		status.Details = &metav1.StatusDetails{
+			Group: c.Param("group"),
+			Kind:  resourceType,
+			Name:  c.Param("name"),
+			UID:   types.UID(c.Param("uid")),
+		}
+	}
+
+	var apiStatus apierrors.APIStatus
+	if errors.As(err, &apiStatus) && apiStatus.Status().Code == status.Code {
+		status = apiStatus.Status()
+	}
+	status.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+	status.Message = err.Error()
+	return status
+}
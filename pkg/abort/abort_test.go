@@ -0,0 +1,117 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package abort
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestAbort(t *testing.T) {
+	notFound := apierrors.NewNotFound(schema.GroupResource{Group: "batch", Resource: "jobs"}, "test-job")
+
+	tests := []struct {
+		name     string
+		path     string
+		err      error
+		code     int
+		expected metav1.Status
+	}{
+		{
+			name: "bad request",
+			path: "/apis/batch/v1/namespaces/test/jobs",
+			err:  errors.New("invalid label selector"),
+			code: http.StatusBadRequest,
+			expected: metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: "invalid label selector",
+				Reason:  metav1.StatusReasonBadRequest,
+				Details: &metav1.StatusDetails{Group: "batch", Kind: "jobs"},
+				Code:    http.StatusBadRequest,
+			},
+		},
+		{
+			name: "no resource type",
+			path: "/apis",
+			err:  errors.New("database unavailable"),
+			code: http.StatusServiceUnavailable,
+			expected: metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: "database unavailable",
+				Reason:  metav1.StatusReasonServiceUnavailable,
+				Code:    http.StatusServiceUnavailable,
+			},
+		},
+		{
+			name: "unknown reason",
+			path: "/apis/batch/v1/namespaces/test/jobs",
+			err:  errors.New("upstream failed"),
+			code: http.StatusBadGateway,
+			expected: metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: "upstream failed",
+				Reason:  metav1.StatusReasonUnknown,
+				Details: &metav1.StatusDetails{Group: "batch", Kind: "jobs"},
+				Code:    http.StatusBadGateway,
+			},
+		},
+		{
+			name: "kubernetes error",
+			path: "/apis/batch/v1/namespaces/test/jobs/test-job",
+			err:  fmt.Errorf("retrieving job: %w", notFound),
+			code: http.StatusNotFound,
+			expected: metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: `retrieving job: jobs.batch "test-job" not found`,
+				Reason:  metav1.StatusReasonNotFound,
+				Details: &metav1.StatusDetails{Group: "batch", Kind: "jobs", Name: "test-job"},
+				Code:    http.StatusNotFound,
+			},
+		},
+		{
+			name: "kubernetes error with other code",
+			path: "/apis/batch/v1/namespaces/test/jobs/test-job",
+			err:  notFound,
+			code: http.StatusBadGateway,
+			expected: metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: `jobs.batch "test-job" not found`,
+				Reason:  metav1.StatusReasonUnknown,
+				Details: &metav1.StatusDetails{Group: "batch", Kind: "jobs", Name: "test-job"},
+				Code:    http.StatusBadGateway,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			handler := func(c *gin.Context) {
+				Abort(c, tt.err, tt.code)
+			}
+			router.GET("/apis", handler)
+			router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType", handler)
+			router.GET("/apis/:group/:version/namespaces/:namespace/:resourceType/:name", handler)
+
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			var status metav1.Status
+			assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &status))
+			tt.expected.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+			assert.Equal(t, tt.code, res.Code)
+			assert.Equal(t, tt.expected, status)
+		})
+	}
+}
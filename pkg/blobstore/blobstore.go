@@ -0,0 +1,90 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	// LogStoreEnvVar selects the Store of the archived logs, "filesystem" or "s3". Logs are not archived when unset
+	LogStoreEnvVar = "KUBEARCHIVE_LOG_STORE"
+	// LogStorePathEnvVar is the directory of the filesystem Store
+	LogStorePathEnvVar = "KUBEARCHIVE_LOG_STORE_PATH"
+	// LogStoreS3EndpointEnvVar, LogStoreS3BucketEnvVar and LogStoreS3RegionEnvVar configure the s3 Store. The
+	// credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and the optional AWS_SESSION_TOKEN
+	LogStoreS3EndpointEnvVar = "KUBEARCHIVE_LOG_STORE_S3_ENDPOINT"
+	LogStoreS3BucketEnvVar   = "KUBEARCHIVE_LOG_STORE_S3_BUCKET"
+	LogStoreS3RegionEnvVar   = "KUBEARCHIVE_LOG_STORE_S3_REGION"
+
+	// URLScheme is the scheme of the log URLs of the logs archived in a Store
+	URLScheme = "blob"
+	// fallbackParam is the query parameter of the log URL to use when the log is not in the Store
+	fallbackParam = "fallback"
+)
+
+// ErrNotFound is returned when the Store does not have an object with the key
+var ErrNotFound = errors.New("object not found in the log store")
+
+// Store keeps objects, like the logs of the archived containers, by key
+type Store interface {
+	// Put stores the content read from content with key, replacing the previous content if any. The
+	// content is streamed, it is not read into memory.
+	Put(ctx context.Context, key string, content io.Reader) error
+	// Get returns a reader of the content stored with key or ErrNotFound. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Exists returns whether there is content stored with key
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// NewFromEnv returns the Store configured in the environment, or nil when logs should not be archived
+func NewFromEnv() (Store, error) {
+	switch storeType := os.Getenv(LogStoreEnvVar); storeType {
+	case "":
+		return nil, nil
+	case "filesystem":
+		return NewFilesystemStore(os.Getenv(LogStorePathEnvVar))
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:        os.Getenv(LogStoreS3EndpointEnvVar),
+			Bucket:          os.Getenv(LogStoreS3BucketEnvVar),
+			Region:          os.Getenv(LogStoreS3RegionEnvVar),
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown log store '%s', it must be 'filesystem' or 's3'", storeType)
+	}
+}
+
+// ContainerLogKey returns the key of the log of a container of the Pod with uid
+func ContainerLogKey(namespace, uid, container string) string {
+	return fmt.Sprintf("logs/%s/%s/%s.log", namespace, uid, container)
+}
+
+// URL returns the log URL of the log stored with key. fallback is the log URL to use when the log is
+// not in the Store, it can be empty.
+func URL(key, fallback string) string {
+	logURL := url.URL{Scheme: URLScheme, Opaque: key}
+	if fallback != "" {
+		logURL.RawQuery = url.Values{fallbackParam: {fallback}}.Encode()
+	}
+	return logURL.String()
+}
+
+// ParseURL returns the key and the fallback log URL of a log URL returned by URL. ok is false when
+// logURL is not the URL of a stored log.
+func ParseURL(logURL *url.URL) (key string, fallback string, ok bool) {
+	if logURL.Scheme != URLScheme || logURL.Opaque == "" || strings.Contains(logURL.Opaque, "..") {
+		return "", "", false
+	}
+	return logURL.Opaque, logURL.Query().Get(fallbackParam), true
+}
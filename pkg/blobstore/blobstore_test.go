@@ -0,0 +1,112 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package blobstore
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		fallback string
+		expected string
+	}{
+		{
+			name:     "without fallback",
+			key:      "logs/test/abc-123/main.log",
+			expected: "blob:logs/test/abc-123/main.log",
+		},
+		{
+			name:     "with fallback",
+			key:      "logs/test/abc-123/main.log",
+			fallback: "http://loki/loki/api/v1/query_range?query={pod_id=\"abc-123\"}",
+			expected: "blob:logs/test/abc-123/main.log?fallback=http%3A%2F%2Floki%2Floki%2Fapi%2Fv1%2Fquery_range" +
+				"%3Fquery%3D%7Bpod_id%3D%22abc-123%22%7D",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logURL := URL(tt.key, tt.fallback)
+			assert.Equal(t, tt.expected, logURL)
+
+			parsedURL, err := url.Parse(logURL)
+			assert.NoError(t, err)
+			key, fallback, ok := ParseURL(parsedURL)
+			assert.True(t, ok)
+			assert.Equal(t, tt.key, key)
+			assert.Equal(t, tt.fallback, fallback)
+		})
+	}
+}
+
+func TestParseURLNotStored(t *testing.T) {
+	for _, logURL := range []string{"http://loki/loki/api/v1/query_range", "blob:", "blob:../../etc/passwd"} {
+		parsedURL, err := url.Parse(logURL)
+		assert.NoError(t, err)
+		_, _, ok := ParseURL(parsedURL)
+		assert.False(t, ok, logURL)
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv(LogStoreEnvVar, "")
+	store, err := NewFromEnv()
+	assert.NoError(t, err)
+	assert.Nil(t, store)
+
+	t.Setenv(LogStoreEnvVar, "filesystem")
+	t.Setenv(LogStorePathEnvVar, t.TempDir())
+	store, err = NewFromEnv()
+	assert.NoError(t, err)
+	assert.NotNil(t, store)
+
+	t.Setenv(LogStoreEnvVar, "tape")
+	_, err = NewFromEnv()
+	assert.ErrorContains(t, err, "unknown log store")
+}
+
+func TestFilesystemStore(t *testing.T) {
+	store, err := NewFilesystemStore(t.TempDir())
+	assert.NoError(t, err)
+	ctx := context.Background()
+	key := ContainerLogKey("test", "abc-123", "main")
+
+	exists, err := store.Exists(ctx, key)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	_, err = store.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, store.Put(ctx, key, strings.NewReader("line 1\n")))
+	assert.NoError(t, store.Put(ctx, key, strings.NewReader("line 1\nline 2\n")))
+	exists, err = store.Exists(ctx, key)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "line 1\nline 2\n", readAll(t, store, key))
+
+	assert.ErrorContains(t, store.Put(ctx, "../outside", strings.NewReader("line")), "invalid key")
+	_, err = store.Get(ctx, "/etc/passwd")
+	assert.ErrorContains(t, err, "invalid key")
+}
+
+// readAll returns the content stored with key in store
+func readAll(t *testing.T, store Store, key string) string {
+	reader, err := store.Get(context.Background(), key)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	return string(content)
+}
@@ -0,0 +1,104 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	s3DefaultRegion = "us-east-1"
+	// s3NoSuchKey is the code of the error response of an object that does not exist
+	s3NoSuchKey = "NoSuchKey"
+)
+
+// S3Config is the configuration of a bucket of an S3 compatible object storage
+type S3Config struct {
+	// Endpoint is the URL of the object storage without a path, like https://s3.us-east-1.amazonaws.com or
+	// the URL of a MinIO service. The bucket is addressed in the path of the requests.
+	Endpoint        string
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// s3Store keeps the objects in a bucket of an S3 compatible object storage
+type s3Store struct {
+	bucket string
+	client *minio.Client
+}
+
+// NewS3Store returns a Store that keeps the objects in the bucket of config
+func NewS3Store(config S3Config) (Store, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("%s and %s are required for the s3 log store", LogStoreS3EndpointEnvVar,
+			LogStoreS3BucketEnvVar)
+	}
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for the s3 log store")
+	}
+	if config.Region == "" {
+		config.Region = s3DefaultRegion
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" || strings.Trim(endpoint.Path, "/") != "" {
+		return nil, fmt.Errorf("invalid s3 endpoint '%s', it must be the URL of the object storage without a path",
+			config.Endpoint)
+	}
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, config.SessionToken),
+		Secure:       endpoint.Scheme == "https",
+		Region:       config.Region,
+		BucketLookup: minio.BucketLookupPath,
+		Transport:    otelhttp.NewTransport(http.DefaultTransport),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create the s3 client: %w", err)
+	}
+	return &s3Store{bucket: config.Bucket, client: client}, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, content io.Reader) error {
+	// The size is unknown, the client uploads the content in parts when it does not fit in one
+	_, err := s.client.PutObject(ctx, s.bucket, key, content, -1, minio.PutObjectOptions{
+		ContentType: "text/plain",
+	})
+	return err
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// The object is requested on its first use, so it is stat to return ErrNotFound here
+	if _, err = object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == s3NoSuchKey {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+func (s *s3Store) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if minio.ToErrorResponse(err).Code == s3NoSuchKey {
+		return false, nil
+	}
+	return err == nil, err
+}
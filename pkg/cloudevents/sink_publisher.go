@@ -5,44 +5,83 @@ package cloudevents
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"time"
 
 	otelObs "github.com/cloudevents/sdk-go/observability/opentelemetry/v2/client"
 	ce "github.com/cloudevents/sdk-go/v2"
 	"github.com/cloudevents/sdk-go/v2/client"
+	"github.com/cloudevents/sdk-go/v2/protocol"
 	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/google/uuid"
 	"github.com/kubearchive/kubearchive/pkg/constants"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+const (
+	// batchTimeout is the maximum time to send a batch and receive the results of its events
+	batchTimeout = 5 * time.Minute
+	// MaxBatchSize is the maximum number of events of a batch, the sink rejects bigger batches
+	MaxBatchSize = 1000
+	// sendRetries is the number of times SendWithRetries and SendBatch send an event again when the sink
+	// answers a retriable status
+	sendRetries = 3
+	// sendRetryPeriod is the base of the exponential backoff between the retries, 2^retry times this period
+	sendRetryPeriod = 250 * time.Millisecond
+)
+
+// Overrides defaultRetriableErrors in
+// https://github.com/cloudevents/sdk-go/blob/main/v2/protocol/http/protocol.go
+var retriableStatuses = map[int]bool{
+	http.StatusNotFound:              true, // 404
+	http.StatusRequestEntityTooLarge: true, // 413
+	http.StatusTooEarly:              true, // 425
+	http.StatusTooManyRequests:       true, // 429
+	http.StatusInternalServerError:   true, // 500
+	http.StatusBadGateway:            true, // 502
+	http.StatusServiceUnavailable:    true, // 503
+	http.StatusGatewayTimeout:        true, // 504
+}
+
 type SinkCloudEventPublisher struct {
 	httpClient client.Client
-	target     string
-	source     string
+	// batchClient sends the batches, which the CloudEvents client does not support. The batches are retried
+	// with the same policy as the single events
+	batchClient *http.Client
+	target      string
+	source      string
+	// retryPeriod is the base of the exponential backoff between the retries of the events
+	retryPeriod time.Duration
+}
+
+// BatchEvent is an event of the batches sent with SendBatch
+type BatchEvent struct {
+	Type     string
+	Resource map[string]interface{}
+}
+
+// BatchResult is the result of an event of a batch. The sink answers a batch with the results of its
+// events in the same order, with 202 Accepted when all the events were accepted or 207 Multi-Status otherwise
+type BatchResult struct {
+	ID string `json:"id"`
+	// Status is the HTTP status the sink answers when it receives the event alone
+	Status int `json:"status"`
 }
 
 func NewSinkCloudEventPublisher(source string) (*SinkCloudEventPublisher, error) {
 	scep := &SinkCloudEventPublisher{
-		source: source,
+		source:      source,
+		retryPeriod: sendRetryPeriod,
 	}
 
-	// Overrides defaultRetriableErrors in
-	// https://github.com/cloudevents/sdk-go/blob/main/v2/protocol/http/protocol.go
-	var retriableStatuses = map[int]bool{
-		http.StatusNotFound:              true, // 404
-		http.StatusRequestEntityTooLarge: true, // 413
-		http.StatusTooEarly:              true, // 425
-		http.StatusTooManyRequests:       true, // 429
-		http.StatusInternalServerError:   true, // 500
-		http.StatusBadGateway:            true, // 502
-		http.StatusServiceUnavailable:    true, // 503
-		http.StatusGatewayTimeout:        true, // 504
-	}
 	var ceOption = []cehttp.Option{
 		cehttp.WithIsRetriableFunc(func(statusCode int) bool {
-			_, retriable := retriableStatuses[statusCode]
-			return retriable
+			return retriableStatuses[statusCode]
 		},
 		)}
 
@@ -52,18 +91,150 @@ func NewSinkCloudEventPublisher(source string) (*SinkCloudEventPublisher, error)
 		return nil, err
 	}
 
+	scep.batchClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: batchTimeout}
 	scep.target = getSinkServiceUrl()
 
 	return scep, nil
 }
 
+// Send sends the event once, the callers that are requeued on failure, like the controllers, retry it
 func (scep *SinkCloudEventPublisher) Send(ctx context.Context, eventType string, resource map[string]interface{}) ce.Result {
+	return scep.send(ctx, eventType, resource, 0)
+}
+
+// SendWithRetries sends the event again, up to sendRetries times, while the sink answers a retriable status
+func (scep *SinkCloudEventPublisher) SendWithRetries(ctx context.Context, eventType string, resource map[string]interface{}) ce.Result {
+	return scep.send(ctx, eventType, resource, sendRetries)
+}
+
+func (scep *SinkCloudEventPublisher) send(ctx context.Context, eventType string, resource map[string]interface{}, retries int) ce.Result {
+	event, err := scep.newEvent(eventType, resource)
+	if err != nil {
+		slog.Error("Error setting cloudevent data", "error", err)
+		return ce.NewResult(err.Error())
+	}
+
+	ectx := ce.ContextWithTarget(ctx, scep.target)
+	if retries > 0 {
+		ectx = ce.ContextWithRetriesExponentialBackoff(ectx, scep.retryPeriod, retries)
+	}
+
+	return scep.httpClient.Send(ectx, event)
+}
+
+// SendBatch sends the events in a single request in the batch content mode of CloudEvents and returns
+// the result of each event in the same order. The results are ACK when the sink accepted the event
+// and have the HTTP status the sink answered for it. The events with a retriable status are sent again
+// in a new batch, up to sendRetries times, like SendWithRetries retries a single event. The events the sink
+// rejects as too large are not sent again, as they would be rejected again.
+func (scep *SinkCloudEventPublisher) SendBatch(ctx context.Context, batch []BatchEvent) []ce.Result {
+	results := make([]ce.Result, len(batch))
+	events := make([]ce.Event, 0, len(batch))
+	// sent has the index in batch of each event in events
+	sent := make([]int, 0, len(batch))
+	for i, batchEvent := range batch {
+		event, err := scep.newEvent(batchEvent.Type, batchEvent.Resource)
+		if err != nil {
+			slog.Error("Error setting cloudevent data", "error", err)
+			results[i] = ce.NewResult(err.Error())
+			continue
+		}
+		// The CloudEvents client sets them when sending a single event
+		event.SetID(uuid.NewString())
+		event.SetTime(time.Now())
+		events = append(events, event)
+		sent = append(sent, i)
+	}
+	if len(events) == 0 {
+		return results
+	}
+
+	// The events that fail with a retriable status, or all of them when the request fails, are sent again
+	// with the same ids, like the CloudEvents client retries the single events
+	for retry := 0; ; retry++ {
+		batchResults, result := scep.sendBatch(ctx, events)
+		var retryEvents []ce.Event
+		var retrySent []int
+		for j, i := range sent {
+			switch {
+			case result != nil:
+				results[i] = result
+			case batchResults[j].Status >= 200 && batchResults[j].Status < 300:
+				results[i] = cehttp.NewResult(batchResults[j].Status, "%w", protocol.ResultACK)
+			default:
+				results[i] = cehttp.NewResult(batchResults[j].Status, "%w", protocol.ResultNACK)
+			}
+			if isRetriable(results[i]) {
+				retryEvents = append(retryEvents, events[j])
+				retrySent = append(retrySent, i)
+			}
+		}
+		if len(retryEvents) == 0 || retry == sendRetries || !scep.backoff(ctx, retry+1) {
+			return results
+		}
+		events, sent = retryEvents, retrySent
+	}
+}
+
+// isRetriable returns whether the event of a batch should be sent again, because the request failed or the sink
+// answered a retriable status other than 413, a batch that is too large is too large again when it is sent again
+func isRetriable(result ce.Result) bool {
+	if ce.IsACK(result) {
+		return false
+	}
+	var httpResult *cehttp.Result
+	if !ce.ResultAs(result, &httpResult) {
+		return true
+	}
+	return httpResult.StatusCode != http.StatusRequestEntityTooLarge && retriableStatuses[httpResult.StatusCode]
+}
+
+// backoff waits before the retry, 2^retry times the retry period, and returns false when ctx is done first
+func (scep *SinkCloudEventPublisher) backoff(ctx context.Context, retry int) bool {
+	timer := time.NewTimer(scep.retryPeriod * time.Duration(math.Exp2(float64(retry))))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// sendBatch sends the events and returns the result of each one, or the result of the request
+// when the sink did not answer with the results of the events
+func (scep *SinkCloudEventPublisher) sendBatch(ctx context.Context, events []ce.Event) ([]BatchResult, ce.Result) {
+	req, err := cehttp.NewHTTPRequestFromEvents(ctx, scep.target, events)
+	if err != nil {
+		return nil, protocol.NewReceipt(false, "%w", err)
+	}
+	resp, err := scep.batchClient.Do(req)
+	if err != nil {
+		return nil, protocol.NewReceipt(false, "%w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, cehttp.NewResult(resp.StatusCode, "%w: %w", protocol.ResultNACK, err)
+	}
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusMultiStatus {
+		return nil, cehttp.NewResult(resp.StatusCode, "%w: %s", protocol.ResultNACK, body)
+	}
+	var results []BatchResult
+	if err = json.Unmarshal(body, &results); err != nil || len(results) != len(events) {
+		return nil, cehttp.NewResult(resp.StatusCode, "%w: the sink did not return the result of each event",
+			protocol.ResultNACK)
+	}
+	return results, nil
+}
+
+func (scep *SinkCloudEventPublisher) newEvent(eventType string, resource map[string]interface{}) (ce.Event, error) {
 	event := ce.NewEvent()
 	event.SetSource(scep.source)
 	event.SetType(eventType)
 	if err := event.SetData(ce.ApplicationJSON, resource); err != nil {
-		slog.Error("Error setting cloudevent data", "error", err)
-		return ce.NewResult(err.Error())
+		return event, err
 	}
 
 	event.SetExtension("apiversion", resource["apiVersion"].(string))
@@ -71,10 +242,7 @@ func (scep *SinkCloudEventPublisher) Send(ctx context.Context, eventType string,
 	metadata := resource["metadata"].(map[string]interface{})
 	event.SetExtension("name", metadata["name"])
 	event.SetExtension("namespace", metadata["namespace"])
-
-	ectx := ce.ContextWithTarget(ctx, scep.target)
-
-	return scep.httpClient.Send(ectx, event)
+	return event, nil
 }
 
 // getSinkServiceUrl constructs the URL for the local sink service
@@ -0,0 +1,250 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudevents
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestResource(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "test"},
+	}
+}
+
+func TestSendBatch(t *testing.T) {
+	batch := []BatchEvent{
+		{Type: "org.kubearchive.vacuum.namespace.resource.archive-when", Resource: newTestResource("job-1")},
+		{Type: "org.kubearchive.vacuum.namespace.resource.archive-when", Resource: newTestResource("job-2")},
+	}
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected []int
+		ack      []bool
+	}{
+		{
+			name: "per event results",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				events, err := cehttp.NewEventsFromHTTPRequest(r)
+				assert.NoError(t, err)
+				assert.Len(t, events, 2)
+				assert.Equal(t, "job-2", events[1].Extensions()["name"])
+				w.WriteHeader(http.StatusMultiStatus)
+				_ = json.NewEncoder(w).Encode([]BatchResult{
+					{ID: events[0].ID(), Status: http.StatusAccepted},
+					{ID: events[1].ID(), Status: http.StatusUnprocessableEntity},
+				})
+			},
+			expected: []int{http.StatusAccepted, http.StatusUnprocessableEntity},
+			ack:      []bool{true, false},
+		},
+		{
+			name: "batch not supported",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+			},
+			expected: []int{http.StatusBadRequest, http.StatusBadRequest},
+			ack:      []bool{false, false},
+		},
+		{
+			name: "missing results",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte("[]"))
+			},
+			expected: []int{http.StatusAccepted, http.StatusAccepted},
+			ack:      []bool{false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			scep := &SinkCloudEventPublisher{batchClient: server.Client(), target: server.URL, source: "test"}
+
+			results := scep.SendBatch(context.Background(), batch)
+
+			assert.Len(t, results, len(batch))
+			for i, result := range results {
+				var httpResult *cehttp.Result
+				if assert.True(t, ce.ResultAs(result, &httpResult)) {
+					assert.Equal(t, tt.expected[i], httpResult.StatusCode)
+				}
+				assert.Equal(t, tt.ack[i], ce.IsACK(result))
+			}
+		})
+	}
+}
+
+func TestSendBatchRetries(t *testing.T) {
+	batch := []BatchEvent{
+		{Type: "org.kubearchive.vacuum.namespace.resource.archive-when", Resource: newTestResource("job-1")},
+		{Type: "org.kubearchive.vacuum.namespace.resource.archive-when", Resource: newTestResource("job-2")},
+	}
+
+	tests := []struct {
+		name string
+		// statuses are the statuses of the events of each request, by name. A request whose events have no
+		// statuses fails with 503
+		statuses []map[string]int
+		expected []int
+		ack      []bool
+		// sent are the names of the events of each request
+		sent [][]string
+	}{
+		{
+			name: "retriable event",
+			statuses: []map[string]int{
+				{"job-1": http.StatusAccepted, "job-2": http.StatusServiceUnavailable},
+				{"job-2": http.StatusAccepted},
+			},
+			expected: []int{http.StatusAccepted, http.StatusAccepted},
+			ack:      []bool{true, true},
+			sent:     [][]string{{"job-1", "job-2"}, {"job-2"}},
+		},
+		{
+			name: "retriable request",
+			statuses: []map[string]int{
+				nil,
+				{"job-1": http.StatusAccepted, "job-2": http.StatusAccepted},
+			},
+			expected: []int{http.StatusAccepted, http.StatusAccepted},
+			ack:      []bool{true, true},
+			sent:     [][]string{{"job-1", "job-2"}, {"job-1", "job-2"}},
+		},
+		{
+			name: "retries exhausted",
+			statuses: []map[string]int{
+				{"job-1": http.StatusAccepted, "job-2": http.StatusInternalServerError},
+				{"job-2": http.StatusInternalServerError},
+				{"job-2": http.StatusInternalServerError},
+				{"job-2": http.StatusInternalServerError},
+			},
+			expected: []int{http.StatusAccepted, http.StatusInternalServerError},
+			ack:      []bool{true, false},
+			sent:     [][]string{{"job-1", "job-2"}, {"job-2"}, {"job-2"}, {"job-2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent [][]string
+			ids := map[string]string{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				events, err := cehttp.NewEventsFromHTTPRequest(r)
+				assert.NoError(t, err)
+				statuses := tt.statuses[len(sent)]
+				names := []string{}
+				results := []BatchResult{}
+				for _, event := range events {
+					name := event.Extensions()["name"].(string)
+					// The retries keep the id of the event, so the sink detects the duplicates
+					if id, found := ids[name]; found {
+						assert.Equal(t, id, event.ID())
+					}
+					ids[name] = event.ID()
+					names = append(names, name)
+					results = append(results, BatchResult{ID: event.ID(), Status: statuses[name]})
+				}
+				sent = append(sent, names)
+				if statuses == nil {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusMultiStatus)
+				_ = json.NewEncoder(w).Encode(results)
+			}))
+			defer server.Close()
+			scep := &SinkCloudEventPublisher{batchClient: server.Client(), target: server.URL, source: "test"}
+
+			results := scep.SendBatch(context.Background(), batch)
+
+			assert.Equal(t, tt.sent, sent)
+			for i, result := range results {
+				var httpResult *cehttp.Result
+				if assert.True(t, ce.ResultAs(result, &httpResult)) {
+					assert.Equal(t, tt.expected[i], httpResult.StatusCode)
+				}
+				assert.Equal(t, tt.ack[i], ce.IsACK(result))
+			}
+		})
+	}
+}
+
+func TestSendBatchTooLarge(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	}))
+	defer server.Close()
+	scep := &SinkCloudEventPublisher{batchClient: server.Client(), target: server.URL, source: "test"}
+
+	results := scep.SendBatch(context.Background(), []BatchEvent{
+		{Type: "org.kubearchive.vacuum.namespace.resource.archive-when", Resource: newTestResource("job-1")},
+	})
+
+	assert.Equal(t, 1, requests)
+	var httpResult *cehttp.Result
+	if assert.True(t, ce.ResultAs(results[0], &httpResult)) {
+		assert.Equal(t, http.StatusRequestEntityTooLarge, httpResult.StatusCode)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		send     func(*SinkCloudEventPublisher) ce.Result
+		requests int
+	}{
+		{
+			name: "send",
+			send: func(scep *SinkCloudEventPublisher) ce.Result {
+				return scep.Send(context.Background(), "org.kubearchive.test", newTestResource("job-1"))
+			},
+			requests: 1,
+		},
+		{
+			name: "send with retries",
+			send: func(scep *SinkCloudEventPublisher) ce.Result {
+				return scep.SendWithRetries(context.Background(), "org.kubearchive.test", newTestResource("job-1"))
+			},
+			requests: sendRetries + 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+			scep, err := NewSinkCloudEventPublisher("test")
+			assert.NoError(t, err)
+			scep.target = server.URL
+			scep.retryPeriod = time.Millisecond
+
+			result := tt.send(scep)
+
+			assert.False(t, ce.IsACK(result))
+			assert.Equal(t, tt.requests, requests)
+		})
+	}
+}
@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
 )
 
@@ -17,9 +18,11 @@ const (
 
 type APIError struct {
 	StatusCode int
-	URL        string
-	Message    string
-	Body       string
+	// Reason is the reason of the metav1.Status the API returned, empty when it returned other body
+	Reason  metav1.StatusReason
+	URL     string
+	Message string
+	Body    string
 }
 
 func (e *APIError) Error() string {
@@ -40,5 +43,6 @@ type KARetrieverCommand interface {
 	CompleteRetriever() error
 	AddRetrieverFlags(flags *pflag.FlagSet)
 	GetFromAPI(api API, path string) ([]byte, *APIError)
+	GetTableFromAPI(api API, path string) ([]byte, *APIError)
 	ResolveResourceSpec(resourceSpec string) (*ResourceInfo, error)
 }
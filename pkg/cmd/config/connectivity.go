@@ -61,9 +61,9 @@ func (d *DefaultConnectivityTester) TestKubeArchiveConnectivity(host string, tls
 	}
 	defer resp.Body.Close()
 
-	// Consider 200 (OK), 404 (Not Found), and 401 (Unauthorized) as success
+	// Consider 200 (OK), 404 (Not Found), 401 (Unauthorized) and 403 (Forbidden) as success
 	switch resp.StatusCode {
-	case http.StatusOK, http.StatusNotFound:
+	case http.StatusOK, http.StatusNotFound, http.StatusForbidden:
 		return nil // Success - server is reachable and responding
 	case http.StatusUnauthorized:
 		body, _ := io.ReadAll(resp.Body)
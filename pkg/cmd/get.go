@@ -4,6 +4,7 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/duration"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
@@ -39,6 +41,8 @@ type GetOptions struct {
 	After                  time.Time
 	Before                 time.Time
 	kubearchiveQueryParams url.Values
+	// columns are the columns of the table output, the ones of the first Table returned by the APIs
+	columns []metav1.TableColumnDefinition
 }
 
 // ResourceWithAvailability tracks a resource and its availability in different APIs
@@ -46,6 +50,14 @@ type ResourceWithAvailability struct {
 	Resource  *unstructured.Unstructured
 	InCluster bool
 	Archived  bool
+	// Cells are the values of the resource for the columns of the table output
+	Cells []any
+}
+
+// defaultColumns are the columns of the resources returned by the APIs that do not support Tables
+var defaultColumns = []metav1.TableColumnDefinition{
+	{Name: "Name", Type: "string", Format: "name"},
+	{Name: "Age", Type: "string"},
 }
 
 // KubeArchiveResponse represents the response structure from KubeArchive API
@@ -224,7 +236,7 @@ func (o *GetOptions) Complete(flags *pflag.FlagSet, args []string) error {
 // insertSortedResourcesOptimized inserts resources into a sorted slice with deduplication and limit handling
 func (o *GetOptions) insertSortedResourcesOptimized(
 	init []*ResourceWithAvailability,
-	resources []*unstructured.Unstructured,
+	resources []*ResourceWithAvailability,
 	fromK8s bool) ([]*ResourceWithAvailability, bool, bool) {
 
 	result := make([]*ResourceWithAvailability, len(init), min(len(resources)+len(init), o.Limit))
@@ -232,9 +244,9 @@ func (o *GetOptions) insertSortedResourcesOptimized(
 
 	var k8sTrimmed, k9eTrimmed bool
 
-	for _, resource := range resources {
+	for _, resourceWithAvailability := range resources {
 
-		idx, found := slices.BinarySearchFunc(result, resource, cmpResource)
+		idx, found := slices.BinarySearchFunc(result, resourceWithAvailability.Resource, cmpResource)
 
 		if found {
 			if fromK8s {
@@ -245,11 +257,8 @@ func (o *GetOptions) insertSortedResourcesOptimized(
 			continue
 		}
 
-		resourceWithAvailability := &ResourceWithAvailability{
-			Resource:  resource,
-			InCluster: fromK8s,
-			Archived:  !fromK8s,
-		}
+		resourceWithAvailability.InCluster = fromK8s
+		resourceWithAvailability.Archived = !fromK8s
 
 		// Insert at the correct position
 		if idx < cap(result) {
@@ -290,7 +299,108 @@ func cmpResource(existing *ResourceWithAvailability, target *unstructured.Unstru
 	return strings.Compare(target.GetName(), existing.Resource.GetName())
 }
 
-func (o *GetOptions) parseResourcesFromBytes(bodyBytes []byte) ([]*unstructured.Unstructured, error) {
+// getResources retrieves the resources from the API, as a Table when they are printed as a table
+func (o *GetOptions) getResources(api API, path string) ([]byte, *APIError) {
+	if o.OutputFormat == "" {
+		return o.GetTableFromAPI(api, path)
+	}
+	return o.GetFromAPI(api, path)
+}
+
+// parseResourcesFromBytes returns the resources of the body along with their cells for the columns of the table
+// output. The body is a Table, a list or a single resource, the cells of the resources that are not in a Table
+// are the ones of defaultColumns
+func (o *GetOptions) parseResourcesFromBytes(bodyBytes []byte) ([]*ResourceWithAvailability, error) {
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(bodyBytes, &typeMeta); err == nil &&
+		typeMeta.Kind == "Table" && typeMeta.APIVersion == metav1.SchemeGroupVersion.String() {
+		return o.parseTableFromBytes(bodyBytes)
+	}
+
+	resources, err := o.parseObjectsFromBytes(bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	columnIndexes := o.columnIndexes(defaultColumns)
+	result := make([]*ResourceWithAvailability, 0, len(resources))
+	for _, resource := range resources {
+		age := "<unknown>"
+		if !resource.GetCreationTimestamp().Time.IsZero() {
+			age = duration.HumanDuration(time.Since(resource.GetCreationTimestamp().Time))
+		}
+		result = append(result, &ResourceWithAvailability{
+			Resource: resource,
+			Cells:    alignCells([]any{resource.GetName(), age}, columnIndexes),
+		})
+	}
+	return result, nil
+}
+
+// parseTableFromBytes returns the resources of the rows of a Table along with their cells. The objects
+// of the rows are the metadata of the resources, which the APIs include by default
+func (o *GetOptions) parseTableFromBytes(bodyBytes []byte) ([]*ResourceWithAvailability, error) {
+	var table metav1.Table
+	// The numbers are kept as they are, so they are printed like the API returned them
+	decoder := json.NewDecoder(bytes.NewReader(bodyBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&table); err != nil {
+		return nil, fmt.Errorf("error deserializing the body into metav1.Table: %w", err)
+	}
+
+	columnIndexes := o.columnIndexes(table.ColumnDefinitions)
+	result := make([]*ResourceWithAvailability, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		if len(row.Object.Raw) == 0 {
+			return nil, fmt.Errorf("the Table does not include the objects of its rows")
+		}
+		var resource unstructured.Unstructured
+		if err := json.Unmarshal(row.Object.Raw, &resource.Object); err != nil {
+			return nil, fmt.Errorf("error deserializing the object of a row into unstructured.Unstructured: %w", err)
+		}
+		result = append(result, &ResourceWithAvailability{
+			Resource: &resource,
+			Cells:    alignCells(row.Cells, columnIndexes),
+		})
+	}
+	return result, nil
+}
+
+// columnIndexes returns the index in columns of each column of the table output, -1 when it is missing.
+// The first columns become the columns of the table output, without the columns kubectl only prints with
+// the wide output
+func (o *GetOptions) columnIndexes(columns []metav1.TableColumnDefinition) []int {
+	if o.columns == nil {
+		for _, column := range columns {
+			if column.Priority == 0 {
+				o.columns = append(o.columns, column)
+			}
+		}
+	}
+
+	indexes := make([]int, 0, len(o.columns))
+	for _, column := range o.columns {
+		indexes = append(indexes, slices.IndexFunc(columns, func(c metav1.TableColumnDefinition) bool {
+			return c.Name == column.Name
+		}))
+	}
+	return indexes
+}
+
+// alignCells returns the cells in the order of the columns of the table output, with nil for the missing ones
+func alignCells(cells []any, columnIndexes []int) []any {
+	aligned := make([]any, 0, len(columnIndexes))
+	for _, index := range columnIndexes {
+		if index < 0 || index >= len(cells) {
+			aligned = append(aligned, nil)
+			continue
+		}
+		aligned = append(aligned, cells[index])
+	}
+	return aligned
+}
+
+// parseObjectsFromBytes returns the resources of a list or the single resource of the body
+func (o *GetOptions) parseObjectsFromBytes(bodyBytes []byte) ([]*unstructured.Unstructured, error) {
 	// If a specific name was requested, the API returns a single resource, not a list
 	if o.Name != "" {
 		var resource unstructured.Unstructured
@@ -325,7 +435,7 @@ func (o *GetOptions) Run() error {
 
 	// Get resources from Kubernetes API (only if --in-cluster is true)
 	if o.InCluster {
-		bodyBytes, apiErr := o.GetFromAPI(Kubernetes, o.APIPath)
+		bodyBytes, apiErr := o.getResources(Kubernetes, o.APIPath)
 		if apiErr != nil {
 			if apiErr.StatusCode != http.StatusNotFound {
 				return apiErr
@@ -362,12 +472,10 @@ func (o *GetOptions) Run() error {
 			kubearchiveAPIPath = basePath
 		}
 
-		bodyBytes, apiErr := o.GetFromAPI(KubeArchive, kubearchiveAPIPath)
+		bodyBytes, apiErr := o.getResources(KubeArchive, kubearchiveAPIPath)
 		if apiErr != nil {
 			// If KubeArchive fails with authentication error, don't fall back to just Kubernetes
-			if apiErr.StatusCode == http.StatusUnauthorized ||
-				strings.Contains(apiErr.Message, "empty authorization bearer token given") ||
-				strings.Contains(apiErr.Message, "authentication failed") {
+			if apiErr.StatusCode == http.StatusUnauthorized || apiErr.Reason == metav1.StatusReasonUnauthorized {
 				return fmt.Errorf("KubeArchive authentication required: %s", apiErr.Message)
 			}
 			// If we have k8s resources, continue with those regardless of the error type
@@ -563,18 +671,24 @@ func (o *GetOptions) printResources(resources []*ResourceWithAvailability) error
 	return nil
 }
 
+// printCustomTable prints the columns the APIs returned for the resources, with the availability columns
+// after the first one, the name of the resources
 func (o *GetOptions) printCustomTable(resources []*ResourceWithAvailability) error {
 	w := tabwriter.NewWriter(o.Out, 0, 0, 3, ' ', 0)
 	defer w.Flush()
 
 	// Print header
-	fmt.Fprintln(w, "NAME\tIN-CLUSTER\tARCHIVED\tAGE")
+	header := make([]string, 0, len(o.columns)+2)
+	for i, column := range o.columns {
+		header = append(header, strings.ToUpper(column.Name))
+		if i == 0 {
+			header = append(header, "IN-CLUSTER", "ARCHIVED")
+		}
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
 
 	// Print each resource
 	for _, resourceWithAvailability := range resources {
-		obj := resourceWithAvailability.Resource
-		name := obj.GetName()
-
 		// Format availability columns
 		inCluster := "no"
 		if resourceWithAvailability.InCluster {
@@ -586,14 +700,23 @@ func (o *GetOptions) printCustomTable(resources []*ResourceWithAvailability) err
 			archived = "yes"
 		}
 
-		// Calculate age
-		age := "<unknown>"
-		if !obj.GetCreationTimestamp().Time.IsZero() {
-			age = duration.HumanDuration(time.Since(obj.GetCreationTimestamp().Time))
+		row := make([]string, 0, len(resourceWithAvailability.Cells)+2)
+		for i, cell := range resourceWithAvailability.Cells {
+			row = append(row, formatCell(cell))
+			if i == 0 {
+				row = append(row, inCluster, archived)
+			}
 		}
-
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, inCluster, archived, age)
+		fmt.Fprintln(w, strings.Join(row, "\t"))
 	}
 
 	return nil
 }
+
+// formatCell returns the value of a cell of the table output, <none> when the resource has no value
+func formatCell(cell any) string {
+	if cell == nil {
+		return "<none>"
+	}
+	return fmt.Sprint(cell)
+}
@@ -133,6 +133,8 @@ type MockKARetrieverCommandForGet struct {
 	namespaceValue   string
 	namespaceError   error
 	mockResourceInfo *ResourceInfo
+	// tableRequests are the APIs the resources were requested as a Table from
+	tableRequests []API
 }
 
 func NewMockKARetrieverCommandForGet(mockErr error, resourceInfo *ResourceInfo) *MockKARetrieverCommandForGet {
@@ -164,6 +166,11 @@ func (m *MockKARetrieverCommandForGet) GetFromAPI(api API, _ string) ([]byte, *A
 	}
 }
 
+func (m *MockKARetrieverCommandForGet) GetTableFromAPI(api API, path string) ([]byte, *APIError) {
+	m.tableRequests = append(m.tableRequests, api)
+	return m.GetFromAPI(api, path)
+}
+
 func (m *MockKARetrieverCommandForGet) CompleteK8sConfig() error {
 	return m.completeError
 }
@@ -846,3 +853,102 @@ func TestRun(t *testing.T) {
 		})
 	}
 }
+
+// createTestTable creates a Table of Pods with the columns the Kubernetes API server returns for them
+func createTestTable(t *testing.T, pods ...PodSpec) string {
+	t.Helper()
+	var rows []map[string]interface{}
+	for _, podSpec := range pods {
+		rows = append(rows, map[string]interface{}{
+			"cells": []interface{}{podSpec.Name, "1/1", podSpec.Status, 0, "5m", "10.0.0.1"},
+			"object": map[string]interface{}{
+				"kind":       "PartialObjectMetadata",
+				"apiVersion": "meta.k8s.io/v1",
+				"metadata": map[string]interface{}{
+					"name":              podSpec.Name,
+					"namespace":         "default",
+					"uid":               podSpec.UID,
+					"creationTimestamp": podSpec.Timestamp,
+				},
+			},
+		})
+	}
+	table := map[string]interface{}{
+		"kind":       "Table",
+		"apiVersion": "meta.k8s.io/v1",
+		"metadata":   map[string]interface{}{},
+		"columnDefinitions": []map[string]interface{}{
+			{"name": "Name", "type": "string", "format": "name"},
+			{"name": "Ready", "type": "string"},
+			{"name": "Status", "type": "string"},
+			{"name": "Restarts", "type": "string"},
+			{"name": "Age", "type": "string"},
+			{"name": "IP", "type": "string", "priority": 1},
+		},
+		"rows": rows,
+	}
+
+	jsonBytes, err := json.Marshal(table)
+	require.NoError(t, err)
+	return string(jsonBytes)
+}
+
+func TestRunServerTable(t *testing.T) {
+	timestamp := time.Now().Add(-5 * time.Minute).Format(time.RFC3339)
+	olderTimestamp := time.Now().Add(-10 * time.Minute).Format(time.RFC3339)
+
+	testCases := []struct {
+		name        string
+		k8sResponse string
+		k9eResponse string
+		expected    string
+	}{
+		{
+			name:        "server columns",
+			k8sResponse: createTestTable(t, PodSpec{"running-pod", timestamp, "Running", "running-pod-uid"}),
+			k9eResponse: createTestTable(t,
+				PodSpec{"running-pod", timestamp, "Running", "running-pod-uid"},
+				PodSpec{"completed-pod", olderTimestamp, "Completed", "completed-pod-uid"},
+			),
+			expected: "NAME            IN-CLUSTER   ARCHIVED   READY   STATUS      RESTARTS   AGE\n" +
+				"running-pod     yes          yes        1/1     Running     0          5m\n" +
+				"completed-pod   no           yes        1/1     Completed   0          5m\n",
+		},
+		{
+			name:        "archive without tables",
+			k8sResponse: createTestTable(t, PodSpec{"running-pod", timestamp, "Running", "running-pod-uid"}),
+			k9eResponse: createTestResponse(t, TestResponseOptions{
+				Pods:          []PodSpec{{"completed-pod", olderTimestamp, "", "completed-pod-uid"}},
+				IsKubeArchive: true,
+			}),
+			expected: "NAME            IN-CLUSTER   ARCHIVED   READY    STATUS    RESTARTS   AGE\n" +
+				"running-pod     yes          no         1/1      Running   0          5m\n" +
+				"completed-pod   no           yes        <none>   <none>    <none>     10m\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCLI := &MockKARetrieverCommandForGet{
+				k8sResponse:    tc.k8sResponse,
+				k9eResponse:    tc.k9eResponse,
+				namespaceValue: "default",
+			}
+			opts := NewTestGetOptions(mockCLI)
+			opts.APIPath = "/api/v1/namespaces/default/pods"
+			opts.Before = time.Now()
+			opts.ResourceInfo = &ResourceInfo{
+				Resource: "pods", Version: "v1", Group: "", GroupVersion: "v1", Kind: "Pod", Namespaced: true,
+			}
+			var outBuf, errBuf bytes.Buffer
+			opts.IOStreams = genericiooptions.IOStreams{Out: &outBuf, ErrOut: &errBuf}
+
+			err := opts.Run()
+
+			require.NoError(t, err)
+			assert.Equal(t, []API{Kubernetes, KubeArchive}, mockCLI.tableRequests)
+			assert.Equal(t, tc.expected, outBuf.String())
+			assert.Empty(t, errBuf.String())
+		})
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -15,9 +18,18 @@ import (
 type LogsOptions struct {
 	KARetrieverCommand
 	ContainerName string
+	AllContainers bool
 	Name          string
 	ResourceInfo  *ResourceInfo
 	LabelSelector string
+	Tail          int64
+	SinceTime     string
+	Since         time.Duration
+	Timestamps    bool
+	LimitBytes    int64
+	Grep          string
+	BeforeContext int
+	AfterContext  int
 }
 
 var logsLong = `Print the logs for a container in a pod or specified resource from KubeArchive.
@@ -34,6 +46,9 @@ kubectl ka logs pod/nginx
 kubectl ka logs nginx -c my-container
 kubectl ka logs pod/nginx -c my-container
 
+# Return logs from all the containers of pod nginx, prefixed with the pod and container name
+kubectl ka logs nginx --all-containers
+
 # Return logs from a deployment (it will pick one of the pod's logs)
 kubectl ka logs deployment/nginx
 kubectl ka logs deploy/nginx
@@ -41,11 +56,21 @@ kubectl ka logs deploy/nginx
 # Return logs using label selector
 kubectl ka logs pods -l app=nginx
 kubectl ka logs deployments -l app=nginx
+
+# Return the last 20 lines of the logs from pod nginx with their timestamps
+kubectl ka logs nginx --tail=20 --timestamps
+
+# Return the logs from pod nginx of the last hour
+kubectl ka logs nginx --since=1h
+
+# Return the lines of the pods with label app=tests that match a regular expression, with 5 lines after each one
+kubectl ka logs pods -l app=tests --all-containers --grep='panic:|FAIL' --after-context=5
 `
 
 func NewLogsOptions() *LogsOptions {
 	return &LogsOptions{
 		KARetrieverCommand: NewKARetrieverOptions(),
+		Tail:               -1,
 	}
 }
 
@@ -70,7 +95,16 @@ func NewLogCmd() *cobra.Command {
 
 	o.AddRetrieverFlags(cmd.Flags())
 	cmd.Flags().StringVarP(&o.ContainerName, "container", "c", o.ContainerName, "Name of the container to retrieve the logs from.")
+	cmd.Flags().BoolVar(&o.AllContainers, "all-containers", o.AllContainers, "Get all containers' logs in the pod(s), prefixed with the pod and container name.")
 	cmd.Flags().StringVarP(&o.LabelSelector, "selector", "l", o.LabelSelector, "Selector (label query) to filter on, supports '=', '==', '!=', 'in', 'notin'.(e.g. -l key1=value1,key2=value2,key3 in (value3)). Matching objects must satisfy all of the specified label constraints.")
+	cmd.Flags().Int64Var(&o.Tail, "tail", o.Tail, "Lines of recent log file to display. Defaults to -1, showing all log lines.")
+	cmd.Flags().StringVar(&o.SinceTime, "since-time", o.SinceTime, "Only return logs after a specific date (RFC3339). Only one of since-time / since may be used.")
+	cmd.Flags().DurationVar(&o.Since, "since", o.Since, "Only return logs newer than a relative duration like 5s, 2m, or 3h. Only one of since-time / since may be used.")
+	cmd.Flags().BoolVar(&o.Timestamps, "timestamps", o.Timestamps, "Include timestamps on each line in the log output.")
+	cmd.Flags().Int64Var(&o.LimitBytes, "limit-bytes", o.LimitBytes, "Maximum bytes of logs to return. Defaults to no limit.")
+	cmd.Flags().StringVar(&o.Grep, "grep", o.Grep, "Only return the lines that match this regular expression (RE2 syntax), the search runs in the KubeArchive API.")
+	cmd.Flags().IntVar(&o.BeforeContext, "before-context", o.BeforeContext, "Lines to return before each line that matches --grep, like grep -B.")
+	cmd.Flags().IntVar(&o.AfterContext, "after-context", o.AfterContext, "Lines to return after each line that matches --grep, like grep -A.")
 
 	return cmd
 }
@@ -105,6 +139,30 @@ func (o *LogsOptions) Complete(args []string) error {
 		return fmt.Errorf("cannot specify both a resource name and a label selector")
 	}
 
+	if o.AllContainers && o.ContainerName != "" {
+		return fmt.Errorf("--container cannot be used with --all-containers")
+	}
+
+	if o.SinceTime != "" && o.Since != 0 {
+		return fmt.Errorf("at most one of --since-time or --since may be specified")
+	}
+	if o.SinceTime != "" {
+		if _, timeErr := time.Parse(time.RFC3339, o.SinceTime); timeErr != nil {
+			return fmt.Errorf("--since-time must be a RFC3339 timestamp: %w", timeErr)
+		}
+	}
+
+	if o.Grep != "" {
+		if _, grepErr := regexp.Compile(o.Grep); grepErr != nil {
+			return fmt.Errorf("--grep must be a regular expression: %w", grepErr)
+		}
+	} else if o.BeforeContext != 0 || o.AfterContext != 0 {
+		return fmt.Errorf("--before-context and --after-context can only be used with --grep")
+	}
+	if o.BeforeContext < 0 || o.AfterContext < 0 {
+		return fmt.Errorf("--before-context and --after-context must be non-negative")
+	}
+
 	o.ResourceInfo, err = o.ResolveResourceSpec(resourceSpec)
 	if err != nil {
 		return err
@@ -113,6 +171,44 @@ func (o *LogsOptions) Complete(args []string) error {
 	return nil
 }
 
+// logQuery returns the query parameters of the log options set in the command
+func (o *LogsOptions) logQuery() url.Values {
+	query := url.Values{}
+	if o.ContainerName != "" {
+		query.Set("container", o.ContainerName)
+	}
+	if o.AllContainers {
+		query.Set("allContainers", "true")
+	}
+	if o.Tail >= 0 {
+		query.Set("tailLines", strconv.FormatInt(o.Tail, 10))
+	}
+	if o.SinceTime != "" {
+		query.Set("sinceTime", o.SinceTime)
+	}
+	if o.Since > 0 {
+		// The API server requires at least one second
+		seconds := max(int64(o.Since.Round(time.Second).Seconds()), 1)
+		query.Set("sinceSeconds", strconv.FormatInt(seconds, 10))
+	}
+	if o.Timestamps {
+		query.Set("timestamps", "true")
+	}
+	if o.LimitBytes > 0 {
+		query.Set("limitBytes", strconv.FormatInt(o.LimitBytes, 10))
+	}
+	if o.Grep != "" {
+		query.Set("grep", o.Grep)
+	}
+	if o.BeforeContext > 0 {
+		query.Set("beforeContext", strconv.Itoa(o.BeforeContext))
+	}
+	if o.AfterContext > 0 {
+		query.Set("afterContext", strconv.Itoa(o.AfterContext))
+	}
+	return query
+}
+
 func (o *LogsOptions) Run(cmd *cobra.Command) error {
 	apiPrefix := "/apis"
 	if o.ResourceInfo.Group == "" {
@@ -156,8 +252,8 @@ func (o *LogsOptions) Run(cmd *cobra.Command) error {
 
 	for _, name := range names {
 		apiPath := fmt.Sprintf("%s/%s/namespaces/%s/%s/%s/log", apiPrefix, o.ResourceInfo.GroupVersion, ns, o.ResourceInfo.Resource, name)
-		if o.ContainerName != "" {
-			apiPath = fmt.Sprintf("%s?container=%s", apiPath, o.ContainerName)
+		if query := o.logQuery(); len(query) > 0 {
+			apiPath = fmt.Sprintf("%s?%s", apiPath, query.Encode())
 		}
 
 		kubearchiveLog, apiErr := o.GetFromAPI(KubeArchive, apiPath)
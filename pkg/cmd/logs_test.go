@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -52,6 +53,10 @@ func (m *MockKACLICommandForLogs) GetFromAPI(_ API, path string) ([]byte, *APIEr
 	}
 }
 
+func (m *MockKACLICommandForLogs) GetTableFromAPI(api API, path string) ([]byte, *APIError) {
+	return m.GetFromAPI(api, path)
+}
+
 func (m *MockKACLICommandForLogs) CompleteK8sConfig() error {
 	return m.completeError
 }
@@ -88,6 +93,7 @@ func (m *MockKACLICommandForLogs) CompleteRetriever() error {
 func NewTestLogsOptions(mockCLI *MockKACLICommandForLogs) *LogsOptions {
 	return &LogsOptions{
 		KARetrieverCommand: mockCLI,
+		Tail:               -1,
 	}
 }
 
@@ -356,3 +362,87 @@ func TestLogsRun(t *testing.T) {
 		})
 	}
 }
+
+func TestLogsQuery(t *testing.T) {
+	testCases := []struct {
+		name     string
+		options  *LogsOptions
+		expected string
+	}{
+		{
+			name:     "no options",
+			options:  &LogsOptions{Tail: -1},
+			expected: "",
+		},
+		{
+			name:     "container and tail",
+			options:  &LogsOptions{ContainerName: "main", Tail: 20},
+			expected: "container=main&tailLines=20",
+		},
+		{
+			name:     "all containers",
+			options:  &LogsOptions{AllContainers: true, Tail: -1},
+			expected: "allContainers=true",
+		},
+		{
+			name:     "since",
+			options:  &LogsOptions{Tail: -1, Since: 90 * time.Minute, Timestamps: true},
+			expected: "sinceSeconds=5400&timestamps=true",
+		},
+		{
+			name:     "since less than a second",
+			options:  &LogsOptions{Tail: -1, Since: 10 * time.Millisecond},
+			expected: "sinceSeconds=1",
+		},
+		{
+			name:     "since time and limit bytes",
+			options:  &LogsOptions{Tail: 0, SinceTime: "2025-01-02T03:04:05Z", LimitBytes: 1024},
+			expected: "limitBytes=1024&sinceTime=2025-01-02T03%3A04%3A05Z&tailLines=0",
+		},
+		{
+			name:     "grep with context",
+			options:  &LogsOptions{Tail: -1, Grep: "panic:", BeforeContext: 2, AfterContext: 5},
+			expected: "afterContext=5&beforeContext=2&grep=panic%3A",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.options.logQuery().Encode())
+		})
+	}
+}
+
+func TestLogsCompleteSince(t *testing.T) {
+	resourceInfo := &ResourceInfo{Resource: "pods", Version: "v1", GroupVersion: "v1", Kind: "Pod", Namespaced: true}
+
+	options := NewTestLogsOptions(NewMockKACLICommandForLogs(nil, resourceInfo))
+	options.SinceTime = "2025-01-02T03:04:05Z"
+	options.Since = time.Hour
+	assert.ErrorContains(t, options.Complete([]string{"pod/test-pod"}), "at most one of --since-time or --since")
+
+	options = NewTestLogsOptions(NewMockKACLICommandForLogs(nil, resourceInfo))
+	options.SinceTime = "yesterday"
+	assert.ErrorContains(t, options.Complete([]string{"pod/test-pod"}), "--since-time must be a RFC3339 timestamp")
+}
+
+func TestLogsCompleteAllContainers(t *testing.T) {
+	resourceInfo := &ResourceInfo{Resource: "pods", Version: "v1", GroupVersion: "v1", Kind: "Pod", Namespaced: true}
+
+	options := NewTestLogsOptions(NewMockKACLICommandForLogs(nil, resourceInfo))
+	options.AllContainers = true
+	options.ContainerName = "main"
+	assert.ErrorContains(t, options.Complete([]string{"pod/test-pod"}), "--container cannot be used with --all-containers")
+}
+
+func TestLogsCompleteGrep(t *testing.T) {
+	resourceInfo := &ResourceInfo{Resource: "pods", Version: "v1", GroupVersion: "v1", Kind: "Pod", Namespaced: true}
+
+	options := NewTestLogsOptions(NewMockKACLICommandForLogs(nil, resourceInfo))
+	options.Grep = "panic("
+	assert.ErrorContains(t, options.Complete([]string{"pod/test-pod"}), "--grep must be a regular expression")
+
+	options = NewTestLogsOptions(NewMockKACLICommandForLogs(nil, resourceInfo))
+	options.AfterContext = 3
+	assert.ErrorContains(t, options.Complete([]string{"pod/test-pod"}), "can only be used with --grep")
+}
@@ -197,8 +197,23 @@ func (opts *KARetrieverOptions) AddK8sFlags(flags *pflag.FlagSet) {
 	opts.kubeFlags.AddFlags(flags)
 }
 
+// tableAcceptHeader asks for a meta.k8s.io/v1 Table like kubectl does, with a fallback to the resources
+// for the APIs that can not return Tables
+const tableAcceptHeader = "application/json;as=Table;v=v1;g=meta.k8s.io,application/json"
+
 // GetFromAPI retrieves data from either Kubernetes or KubeArchive API
 func (opts *KARetrieverOptions) GetFromAPI(api API, path string) ([]byte, *APIError) {
+	return opts.getFromAPI(api, path, "")
+}
+
+// GetTableFromAPI retrieves the resources as a meta.k8s.io/v1 Table from either Kubernetes or KubeArchive
+// API, so the columns are the ones the server prints for their kind. The APIs that do not support Tables
+// return the resources
+func (opts *KARetrieverOptions) GetTableFromAPI(api API, path string) ([]byte, *APIError) {
+	return opts.getFromAPI(api, path, tableAcceptHeader)
+}
+
+func (opts *KARetrieverOptions) getFromAPI(api API, path string, accept string) ([]byte, *APIError) {
 	var restConfig *rest.Config
 	var baseURL string
 
@@ -226,7 +241,19 @@ func (opts *KARetrieverOptions) GetFromAPI(api API, path string) ([]byte, *APIEr
 	fullURL := baseURL + path
 
 	// Create request
-	response, err := client.Get(fullURL)
+	request, err := http.NewRequest(http.MethodGet, fullURL, nil)
+	if err != nil {
+		return nil, &APIError{
+			StatusCode: 500,
+			URL:        fullURL,
+			Message:    fmt.Sprintf("error creating the request to '%s': %v", fullURL, err),
+			Body:       "",
+		}
+	}
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	response, err := client.Do(request)
 	if err != nil {
 		return nil, &APIError{
 			StatusCode: 500,
@@ -248,9 +275,10 @@ func (opts *KARetrieverOptions) GetFromAPI(api API, path string) ([]byte, *APIEr
 	}
 
 	if response.StatusCode != http.StatusOK {
-		message := extractErrorMessage(bodyBytes, response.StatusCode, fullURL)
+		message, reason := extractErrorMessage(bodyBytes, response.StatusCode, fullURL)
 		return nil, &APIError{
 			StatusCode: response.StatusCode,
+			Reason:     reason,
 			URL:        fullURL,
 			Message:    message,
 			Body:       string(bodyBytes),
@@ -260,24 +288,21 @@ func (opts *KARetrieverOptions) GetFromAPI(api API, path string) ([]byte, *APIEr
 	return bodyBytes, nil
 }
 
-func extractErrorMessage(body []byte, statusCode int, url string) string {
-	// Try to parse Kubernetes Status object for cleaner error messages
-	var status struct {
-		Message string `json:"message"`
-		Reason  string `json:"reason"`
-	}
-
-	if err := json.Unmarshal(body, &status); err == nil && status.Message != "" {
-		return status.Message
+// extractErrorMessage returns the message and reason of the metav1.Status both the Kubernetes and the
+// KubeArchive APIs return with their errors
+func extractErrorMessage(body []byte, statusCode int, url string) (string, v1.StatusReason) {
+	var status v1.Status
+	if err := json.Unmarshal(body, &status); err == nil && status.Kind == "Status" && status.Message != "" {
+		return status.Message, status.Reason
 	}
 
 	// Fallback to raw body or generic message
 	bodyStr := string(body)
 	if bodyStr != "" {
-		return fmt.Sprintf("unable to get '%s': %s (%d)", url, bodyStr, statusCode)
+		return fmt.Sprintf("unable to get '%s': %s (%d)", url, bodyStr, statusCode), ""
 	}
 
-	return fmt.Sprintf("unable to get '%s': HTTP %d", url, statusCode)
+	return fmt.Sprintf("unable to get '%s': HTTP %d", url, statusCode), ""
 }
 
 // GetNamespace get the provided namespace or the namespace used in kubeconfig context
@@ -446,6 +446,7 @@ func TestGetFromAPI(t *testing.T) {
 		expectedBody   string
 		expectError    bool
 		errorContains  string
+		expectedReason metav1.StatusReason
 		unreachable    bool
 	}{
 		{
@@ -469,6 +470,19 @@ func TestGetFromAPI(t *testing.T) {
 			expectError:   true,
 			errorContains: "HTTP 401",
 		},
+		{
+			name: "status response",
+			api:  KubeArchive,
+			path: "/api/v1/namespaces/test/pods",
+			serverResponse: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure",` +
+					`"message":"User \"test\" cannot list resource \"pods\"","reason":"Forbidden","code":403}`))
+			},
+			expectError:    true,
+			errorContains:  `User "test" cannot list resource "pods"`,
+			expectedReason: metav1.StatusReasonForbidden,
+		},
 		{
 			name: "not found response",
 			api:  Kubernetes,
@@ -530,6 +544,7 @@ func TestGetFromAPI(t *testing.T) {
 				if tc.errorContains != "" {
 					assert.Contains(t, apiErr.Error(), tc.errorContains)
 				}
+				assert.Equal(t, tc.expectedReason, apiErr.Reason)
 			} else {
 				assert.Nil(t, apiErr)
 				assert.Equal(t, tc.expectedBody, string(result))
@@ -538,6 +553,24 @@ func TestGetFromAPI(t *testing.T) {
 	}
 }
 
+func TestGetTableFromAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json;as=Table;v=v1;g=meta.k8s.io,application/json", r.Header.Get("Accept"))
+		_, _ = w.Write([]byte(`{"kind":"Table"}`))
+	}))
+	defer server.Close()
+	opts := &KARetrieverOptions{
+		host:          server.URL,
+		k8sRESTConfig: &rest.Config{Host: server.URL},
+		k9eRESTConfig: &rest.Config{Host: server.URL},
+	}
+
+	result, apiErr := opts.GetTableFromAPI(KubeArchive, "/api/v1/namespaces/test/pods")
+
+	assert.Nil(t, apiErr)
+	assert.Equal(t, `{"kind":"Table"}`, string(result))
+}
+
 func TestResolveResourceSpec(t *testing.T) {
 	testCases := []struct {
 		name             string
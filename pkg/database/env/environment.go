@@ -18,6 +18,8 @@ const (
 	DbPasswordEnvVar string = "DATABASE_PASSWORD" // nosec G101 not a password
 	DbHostEnvVar     string = "DATABASE_URL"
 	DbPortEnvVar     string = "DATABASE_PORT"
+	// DbHistoryEnvVar is optional, when true every revision of the archived resources is kept
+	DbHistoryEnvVar string = "DATABASE_HISTORY"
 )
 
 var DbEnvVars = [...]string{DbKindEnvVar, DbNameEnvVar, DbUserEnvVar, DbPasswordEnvVar, DbHostEnvVar, DbPortEnvVar}
@@ -34,6 +36,9 @@ func NewDatabaseEnvironment() (map[string]string, error) {
 			err = errors.Join(err, fmt.Errorf(dbConnectionErrStr, name))
 		}
 	}
+	if value, exists := os.LookupEnv(DbHistoryEnvVar); exists {
+		env[DbHistoryEnvVar] = value
+	}
 	if err == nil {
 		return env, nil
 	} else {
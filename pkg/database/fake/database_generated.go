@@ -4,14 +4,17 @@
 package fake
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	dbErrors "github.com/kubearchive/kubearchive/pkg/database/errors"
 	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
 	"github.com/kubearchive/kubearchive/pkg/models"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -129,7 +132,11 @@ type fakeDatabase struct {
 	jsonPath             string
 	err                  error
 	urlErr               error
+	definitions          []models.ResourceDefinition
+	processedEvents      map[string]time.Time
 	CurrentSchemaVersion string
+	// Now returns the time of the database, time.Now is used when it is not set
+	Now func() time.Time
 }
 
 func NewFakeDatabase(testResources []*unstructured.Unstructured, testLogs []LogUrlRow, jsonPath string) *fakeDatabase {
@@ -187,14 +194,51 @@ func (f *fakeDatabase) queryLogURL(_ context.Context, _, _, _, _, _ string) (str
 	return f.logUrl[0].Url, f.jsonPath, f.err
 }
 
-func (f *fakeDatabase) QueryResourceByUID(ctx context.Context, kind, apiVersion, namespace, uid string) (*models.Resource, error) {
+func (f *fakeDatabase) QueryLogURLsByName(ctx context.Context, kind, apiVersion, namespace, name string) ([]models.ContainerLogURL, error) {
+	return f.queryLogURLs(ctx, kind, apiVersion, namespace, name)
+}
+
+func (f *fakeDatabase) QueryLogURLsByUID(ctx context.Context, kind, apiVersion, namespace, uid string) ([]models.ContainerLogURL, error) {
+	return f.queryLogURLs(ctx, kind, apiVersion, namespace, uid)
+}
+
+func (f *fakeDatabase) QueryLogURLsByUIDs(ctx context.Context, namespace string, uids []string) ([]models.ContainerLogURL, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var logURLs []models.ContainerLogURL
+	for _, uid := range uids {
+		for _, resource := range f.resources {
+			if resource.GetKind() == "Pod" && resource.GetNamespace() == namespace && string(resource.GetUID()) == uid {
+				podLogURLs, _ := f.queryLogURLs(ctx, "Pod", "v1", namespace, uid)
+				logURLs = append(logURLs, podLogURLs...)
+			}
+		}
+	}
+	return logURLs, nil
+}
+
+func (f *fakeDatabase) queryLogURLs(_ context.Context, _, _, _, identifier string) ([]models.ContainerLogURL, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	logURLs := make([]models.ContainerLogURL, 0, len(f.logUrl))
+	for _, row := range f.logUrl {
+		logURLs = append(logURLs, models.ContainerLogURL{PodName: identifier, ContainerName: row.ContainerName,
+			Url: row.Url, JsonPath: f.jsonPath})
+	}
+	return logURLs, nil
+}
+
+func (f *fakeDatabase) QueryResourceByUID(ctx context.Context, kind, apiVersion, namespace, uid string,
+	asOf *time.Time) (*models.Resource, error) {
 	for _, resource := range f.resources {
 		sameKind := resource.GetKind() == kind
 		sameApiVersion := resource.GetAPIVersion() == apiVersion
 		sameNamespace := resource.GetNamespace() == namespace
 		sameUID := string(resource.GetUID()) == uid
 
-		if sameKind && sameApiVersion && sameNamespace && sameUID {
+		if sameKind && sameApiVersion && sameNamespace && sameUID && existedAt(resource, asOf) {
 			resourceString, err := json.Marshal(resource)
 			if err != nil {
 				panic(fmt.Sprintf("error while serializing resource: %s", resource))
@@ -206,9 +250,59 @@ func (f *fakeDatabase) QueryResourceByUID(ctx context.Context, kind, apiVersion,
 	return nil, f.err
 }
 
+func (f *fakeDatabase) QueryOwnedResources(_ context.Context, ownersUuids []string) ([]models.Resource, error) {
+	var ownedResources []models.Resource
+	for _, resource := range f.resources {
+		for _, owner := range resource.GetOwnerReferences() {
+			if slices.Contains(ownersUuids, string(owner.UID)) {
+				resourceString, err := json.Marshal(resource)
+				if err != nil {
+					panic(fmt.Sprintf("error while serializing resource: %s", resource))
+				}
+				ownedResources = append(ownedResources, models.Resource{Id: 0, Uuid: string(resource.GetUID()),
+					Data: string(resourceString), Date: resource.GetCreationTimestamp().GoString()})
+				break
+			}
+		}
+	}
+	return ownedResources, f.err
+}
+
+// QueryResourceRevisions returns the stored resource as the only revision of its history
+func (f *fakeDatabase) QueryResourceRevisions(_ context.Context, uid string) ([]models.ResourceRevision, error) {
+	revisions := []models.ResourceRevision{}
+	for _, resource := range f.resources {
+		if string(resource.GetUID()) == uid {
+			revisions = append(revisions, models.ResourceRevision{
+				Revision:                1,
+				ResourceVersion:         resource.GetResourceVersion(),
+				ClusterUpdatedTimestamp: resource.GetCreationTimestamp().UTC().Format(time.RFC3339),
+				ArchivedAt:              resource.GetCreationTimestamp().UTC().Format(time.RFC3339Nano),
+			})
+		}
+	}
+	return revisions, f.err
+}
+
+func (f *fakeDatabase) QueryResourceRevision(_ context.Context, uid string, revision int64) (string, error) {
+	for _, resource := range f.resources {
+		if string(resource.GetUID()) == uid && revision == 1 {
+			resourceString, err := json.Marshal(resource)
+			if err != nil {
+				panic(fmt.Sprintf("error while serializing resource: %s", resource))
+			}
+			return string(resourceString), f.err
+		}
+	}
+	if f.err != nil {
+		return "", f.err
+	}
+	return "", dbErrors.ErrResourceNotFound
+}
+
 func (f *fakeDatabase) QueryResources(ctx context.Context, kind, version, namespace, name,
-	continueId, continueDate string, _ *models.LabelFilters,
-	creationTimestampAfter, creationTimestampBefore *time.Time, limit int) ([]models.Resource, error) {
+	continueId, continueDate string, _ *models.LabelFilters, _ *models.FieldFilters,
+	creationTimestampAfter, creationTimestampBefore, asOf *time.Time, limit int) ([]models.Resource, error) {
 	var resources []models.Resource
 
 	if name != "" && strings.Contains(name, "*") {
@@ -225,9 +319,180 @@ func (f *fakeDatabase) QueryResources(ctx context.Context, kind, version, namesp
 		resources = f.filterResourcesByTimestamp(resources, creationTimestampAfter, creationTimestampBefore)
 	}
 
+	if asOf != nil {
+		resources = f.filterResourcesExistingAt(resources, *asOf)
+	}
+
 	return resources, f.err
 }
 
+// StreamResources returns the same resources as QueryResources, without pagination
+func (f *fakeDatabase) StreamResources(ctx context.Context, kind, apiVersion, namespace, name string,
+	labelFilters *models.LabelFilters, fieldFilters *models.FieldFilters,
+	creationTimestampAfter, creationTimestampBefore, asOf *time.Time, fn func(models.Resource) error) error {
+	resources, err := f.QueryResources(ctx, kind, apiVersion, namespace, name, "", "", labelFilters, fieldFilters,
+		creationTimestampAfter, creationTimestampBefore, asOf, len(f.resources))
+	if err != nil {
+		return err
+	}
+	for _, resource := range resources {
+		if err = fn(resource); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterResourcesExistingAt keeps the resources created before or at asOf and not deleted by then.
+// The fake database does not keep revisions, so resources are returned as they were last written
+func (f *fakeDatabase) filterResourcesExistingAt(resources []models.Resource, asOf time.Time) []models.Resource {
+	var filteredResources []models.Resource
+	for _, resource := range resources {
+		object := &unstructured.Unstructured{}
+		if err := object.UnmarshalJSON([]byte(resource.Data)); err != nil {
+			panic(fmt.Sprintf("error while deserializing resource: %s", err))
+		}
+		if existedAt(object, &asOf) {
+			filteredResources = append(filteredResources, resource)
+		}
+	}
+	return filteredResources
+}
+
+func existedAt(resource *unstructured.Unstructured, asOf *time.Time) bool {
+	if asOf == nil {
+		return true
+	}
+	if resource.GetCreationTimestamp().After(*asOf) {
+		return false
+	}
+	deletionTimestamp := resource.GetDeletionTimestamp()
+	return deletionTimestamp == nil || deletionTimestamp.After(*asOf)
+}
+
+func (f *fakeDatabase) QueryArchivedKinds(_ context.Context) ([]models.ArchivedKind, error) {
+	var kinds []models.ArchivedKind
+	for _, resource := range f.resources {
+		kind := models.ArchivedKind{Kind: resource.GetKind(), APIVersion: resource.GetAPIVersion()}
+		if !slices.Contains(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds, f.err
+}
+
+// QueryResourceCounts ignores the label filters, as QueryResources does
+func (f *fakeDatabase) QueryResourceCounts(_ context.Context, namespace, labelKey string, _ *models.LabelFilters,
+	creationTimestampAfter, creationTimestampBefore *time.Time) ([]models.ResourceCount, error) {
+	counts := []models.ResourceCount{}
+	for _, resource := range f.resources {
+		if namespace != "" && resource.GetNamespace() != namespace {
+			continue
+		}
+		creationTimestamp := resource.GetCreationTimestamp().Time
+		if creationTimestampAfter != nil && !creationTimestamp.After(*creationTimestampAfter) {
+			continue
+		}
+		if creationTimestampBefore != nil && !creationTimestamp.Before(*creationTimestampBefore) {
+			continue
+		}
+
+		count := models.ResourceCount{
+			Kind:       resource.GetKind(),
+			APIVersion: resource.GetAPIVersion(),
+			Namespace:  resource.GetNamespace(),
+		}
+		if value, found := resource.GetLabels()[labelKey]; labelKey != "" && found {
+			count.LabelValue = &value
+		}
+		index := slices.IndexFunc(counts, func(other models.ResourceCount) bool {
+			return countKey(other) == countKey(count)
+		})
+		if index == -1 {
+			counts = append(counts, count)
+			index = len(counts) - 1
+		}
+		counts[index].Count++
+	}
+
+	slices.SortFunc(counts, func(a, b models.ResourceCount) int {
+		return strings.Compare(countKey(a), countKey(b))
+	})
+	return counts, f.err
+}
+
+// countKey identifies the group of the count, using a character not allowed in label
+// values for the counts of resources without the label
+func countKey(count models.ResourceCount) string {
+	labelValue := "~"
+	if count.LabelValue != nil {
+		labelValue = *count.LabelValue
+	}
+	return strings.Join([]string{count.Kind, count.APIVersion, count.Namespace, labelValue}, "/")
+}
+
+// QueryResourceChanges uses the creation timestamp of the resources as the time they were archived, their
+// deletion timestamp, when set, as the time they were last updated and their position as their id
+func (f *fakeDatabase) QueryResourceChanges(_ context.Context, kind, apiVersion, namespace, name string,
+	_ *models.LabelFilters, _ *models.FieldFilters, _, _ *time.Time, changedAfter time.Time, changedAfterId int64,
+	limit int) ([]models.ResourceChange, error) {
+	var changes []models.ResourceChange
+	for i, resource := range f.resources {
+		id := int64(i + 1)
+		if resource.GetKind() != kind || resource.GetAPIVersion() != apiVersion {
+			continue
+		}
+		if namespace != "" && resource.GetNamespace() != namespace {
+			continue
+		}
+		if name != "" && !matchesWildcard(resource.GetName(), name) {
+			continue
+		}
+
+		archivedAt := resource.GetCreationTimestamp().Time
+		updatedAt := archivedAt
+		deleted := resource.GetDeletionTimestamp() != nil
+		if deleted {
+			updatedAt = resource.GetDeletionTimestamp().Time
+		}
+		if updatedAt.Before(changedAfter) || (updatedAt.Equal(changedAfter) && id <= changedAfterId) {
+			continue
+		}
+
+		resourceString, err := json.Marshal(resource)
+		if err != nil {
+			panic(fmt.Sprintf("error while serializing resource: %s", resource))
+		}
+		changes = append(changes, models.ResourceChange{
+			Resource:   models.Resource{Id: id, Uuid: string(resource.GetUID()), Data: string(resourceString)},
+			ArchivedAt: archivedAt.UTC().Format(time.RFC3339Nano),
+			UpdatedAt:  updatedAt.UTC().Format(time.RFC3339Nano),
+			Deleted:    deleted,
+		})
+	}
+
+	slices.SortFunc(changes, func(a, b models.ResourceChange) int {
+		aUpdatedAt, _ := time.Parse(time.RFC3339Nano, a.UpdatedAt)
+		bUpdatedAt, _ := time.Parse(time.RFC3339Nano, b.UpdatedAt)
+		if c := aUpdatedAt.Compare(bUpdatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Id, b.Id)
+	})
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, f.err
+}
+
+// QueryCurrentTime returns the current time, Now can be set to use another time
+func (f *fakeDatabase) QueryCurrentTime(_ context.Context) (time.Time, error) {
+	if f.Now != nil {
+		return f.Now(), f.err
+	}
+	return time.Now(), f.err
+}
+
 // filterResourcesByTimestamp filters resources based on creation timestamp
 func (f *fakeDatabase) filterResourcesByTimestamp(resources []models.Resource,
 	creationTimestampAfter, creationTimestampBefore *time.Time) []models.Resource {
@@ -351,6 +616,92 @@ func (f *fakeDatabase) WriteResource(_ context.Context, k8sObj *unstructured.Uns
 	return interfaces.WriteResourceResultInserted, nil
 }
 
+func (f *fakeDatabase) WriteResources(ctx context.Context, writes []interfaces.ResourceWrite) ([]interfaces.WriteResourceResult, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	for _, write := range writes {
+		if write.Object == nil {
+			return nil, errors.New("kubernetes object was 'nil', something went wrong")
+		}
+		if write.Object.GetKind() == "Pod" && f.urlErr != nil {
+			return nil, f.urlErr
+		}
+	}
+
+	results := make([]interfaces.WriteResourceResult, 0, len(writes))
+	for _, write := range writes {
+		result, err := f.WriteResource(ctx, write.Object, write.Data, write.LastUpdated, write.JsonPath, write.Logs...)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (f *fakeDatabase) WriteResourceDefinition(_ context.Context, definition models.ResourceDefinition) error {
+	if f.err != nil {
+		return f.err
+	}
+	for i, stored := range f.definitions {
+		if stored.Group == definition.Group && stored.Plural == definition.Plural {
+			f.definitions[i] = definition
+			return nil
+		}
+	}
+	f.definitions = append(f.definitions, definition)
+	return nil
+}
+
+func (f *fakeDatabase) QueryResourceDefinitions(_ context.Context, group string) ([]models.ResourceDefinition, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	definitions := make([]models.ResourceDefinition, 0)
+	for _, definition := range f.definitions {
+		if definition.Group == group {
+			definitions = append(definitions, definition)
+		}
+	}
+	return definitions, nil
+}
+
+func (f *fakeDatabase) IsEventProcessed(_ context.Context, source, id string) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	_, ok := f.processedEvents[source+"/"+id]
+	return ok, nil
+}
+
+func (f *fakeDatabase) WriteProcessedEvent(_ context.Context, source, id string) error {
+	if f.err != nil {
+		return f.err
+	}
+	if f.processedEvents == nil {
+		f.processedEvents = map[string]time.Time{}
+	}
+	if _, ok := f.processedEvents[source+"/"+id]; !ok {
+		f.processedEvents[source+"/"+id] = time.Now()
+	}
+	return nil
+}
+
+func (f *fakeDatabase) DeleteProcessedEvents(_ context.Context, before time.Time) (int64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	var deleted int64
+	for key, processedAt := range f.processedEvents {
+		if processedAt.Before(before) {
+			delete(f.processedEvents, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
 func (f *fakeDatabase) NumResources() int {
 	return len(f.resources)
 }
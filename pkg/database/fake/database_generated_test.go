@@ -109,7 +109,7 @@ func TestQueryResourcesWithoutNamespace(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			db := NewFakeDatabase(testResources, testLogUrls, testJsonPath)
-			filteredResources, err := db.QueryResources(context.TODO(), tt.kind, tt.version, tt.namespace, "", "", "", &models.LabelFilters{}, nil, nil, 100)
+			filteredResources, err := db.QueryResources(context.TODO(), tt.kind, tt.version, tt.namespace, "", "", "", &models.LabelFilters{}, &models.FieldFilters{}, nil, nil, nil, 100)
 			expectedUids := make([]string, 0)
 			for _, resource := range tt.expected {
 				expectedUids = append(expectedUids, string(resource.GetUID()))
@@ -204,7 +204,7 @@ func TestQueryNamespacedResourceByName(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			db := NewFakeDatabase(tt.testData, testLogUrls, testJsonPath)
 			filteredResources, err := db.QueryResources(context.TODO(), tt.kind, tt.version, tt.namespace,
-				tt.resourceName, "", "", &models.LabelFilters{}, nil, nil, 100)
+				tt.resourceName, "", "", &models.LabelFilters{}, &models.FieldFilters{}, nil, nil, nil, 100)
 
 			expectedUids := make([]string, 0)
 			for _, resource := range tt.expected {
@@ -428,7 +428,7 @@ func TestQueryResourcesWithWildcardName(t *testing.T) {
 		t.Run(tt.namePattern, func(t *testing.T) {
 			db := NewFakeDatabase(testResources, []LogUrlRow{}, "$.")
 			resources, err := db.QueryResources(context.TODO(), "Pod", "v1", "test",
-				tt.namePattern, "", "", &models.LabelFilters{}, nil, nil, 100)
+				tt.namePattern, "", "", &models.LabelFilters{}, &models.FieldFilters{}, nil, nil, nil, 100)
 
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedCount, len(resources))
@@ -20,13 +20,43 @@ const (
 	WriteResourceResultError
 )
 
+// ResourceWrite is a resource to write with DBWriter.WriteResources, it has the arguments of
+// DBWriter.WriteResource
+type ResourceWrite struct {
+	Object      *unstructured.Unstructured
+	Data        []byte
+	LastUpdated time.Time
+	JsonPath    string
+	Logs        []models.LogTuple
+}
+
 type DBReader interface {
 	QueryResources(ctx context.Context, kind, apiVersion, namespace,
-		name, continueId, continueDate string, labelFilters *models.LabelFilters,
-		creationTimestampAfter, creationTimestampBefore *time.Time, limit int) ([]models.Resource, error)
-	QueryResourceByUID(ctx context.Context, kind, apiVersion, namespace, uid string) (*models.Resource, error)
+		name, continueId, continueDate string, labelFilters *models.LabelFilters, fieldFilters *models.FieldFilters,
+		creationTimestampAfter, creationTimestampBefore, asOf *time.Time, limit int) ([]models.Resource, error)
+	StreamResources(ctx context.Context, kind, apiVersion, namespace, name string,
+		labelFilters *models.LabelFilters, fieldFilters *models.FieldFilters,
+		creationTimestampAfter, creationTimestampBefore, asOf *time.Time, fn func(models.Resource) error) error
+	QueryResourceChanges(ctx context.Context, kind, apiVersion, namespace, name string,
+		labelFilters *models.LabelFilters, fieldFilters *models.FieldFilters,
+		creationTimestampAfter, creationTimestampBefore *time.Time, changedAfter time.Time, changedAfterId int64,
+		limit int) ([]models.ResourceChange, error)
+	QueryCurrentTime(ctx context.Context) (time.Time, error)
+	QueryArchivedKinds(ctx context.Context) ([]models.ArchivedKind, error)
+	QueryResourceDefinitions(ctx context.Context, group string) ([]models.ResourceDefinition, error)
+	QueryResourceCounts(ctx context.Context, namespace, labelKey string, labelFilters *models.LabelFilters,
+		creationTimestampAfter, creationTimestampBefore *time.Time) ([]models.ResourceCount, error)
+	QueryResourceByUID(ctx context.Context, kind, apiVersion, namespace, uid string,
+		asOf *time.Time) (*models.Resource, error)
+	QueryOwnedResources(ctx context.Context, ownersUuids []string) ([]models.Resource, error)
+	QueryResourceRevisions(ctx context.Context, uid string) ([]models.ResourceRevision, error)
+	QueryResourceRevision(ctx context.Context, uid string, revision int64) (string, error)
 	QueryLogURLByName(ctx context.Context, kind, apiVersion, namespace, name, containerName string) (string, string, error)
 	QueryLogURLByUID(ctx context.Context, kind, apiVersion, namespace, uid, containerName string) (string, string, error)
+	QueryLogURLsByName(ctx context.Context, kind, apiVersion, namespace, name string) ([]models.ContainerLogURL, error)
+	QueryLogURLsByUID(ctx context.Context, kind, apiVersion, namespace, uid string) ([]models.ContainerLogURL, error)
+	// QueryLogURLsByUIDs returns the log urls of all the containers of the Pods with the uids, in the order of uids
+	QueryLogURLsByUIDs(ctx context.Context, namespace string, uids []string) ([]models.ContainerLogURL, error)
 	Ping(ctx context.Context) error
 	QueryDatabaseSchemaVersion(ctx context.Context) (string, error)
 	CloseDB() error
@@ -37,6 +67,17 @@ type DBWriter interface {
 	// WriteResource writes the logs (when the resource is a Pod) and the resource into their respective tables
 	// The log entries related to the resource are deleted first to prevent duplicates
 	WriteResource(ctx context.Context, k8sObj *unstructured.Unstructured, data []byte, lastUpdated time.Time, jsonPath string, logs ...models.LogTuple) (WriteResourceResult, error)
+	// WriteResources writes the resources as WriteResource does, in a single transaction, so either all of them
+	// are written or none. It returns the result of each write in the same order
+	WriteResources(ctx context.Context, writes []ResourceWrite) ([]WriteResourceResult, error)
+	// WriteResourceDefinition stores the CustomResourceDefinition of archived custom resources
+	WriteResourceDefinition(ctx context.Context, definition models.ResourceDefinition) error
+	// IsEventProcessed returns whether the CloudEvent with the source and id was recorded with WriteProcessedEvent
+	IsEventProcessed(ctx context.Context, source, id string) (bool, error)
+	// WriteProcessedEvent records that the sink processed the CloudEvent with the source and id
+	WriteProcessedEvent(ctx context.Context, source, id string) error
+	// DeleteProcessedEvents deletes the CloudEvents processed before the timestamp and returns how many were deleted
+	DeleteProcessedEvents(ctx context.Context, before time.Time) (int64, error)
 	Ping(ctx context.Context) error
 	QueryDatabaseSchemaVersion(ctx context.Context) (string, error)
 	CloseDB() error
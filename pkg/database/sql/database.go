@@ -5,9 +5,12 @@ package sql
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 
 	"github.com/huandu/go-sqlbuilder"
 	"github.com/jmoiron/sqlx"
+	dbEnv "github.com/kubearchive/kubearchive/pkg/database/env"
 	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
 	"github.com/kubearchive/kubearchive/pkg/database/sql/facade"
 )
@@ -35,9 +38,19 @@ type sqlDatabaseImpl struct {
 	inserter facade.DBInserter
 	deleter  facade.DBDeleter
 	creator  facade.DBCreator
+	// history enables storing every revision of the resources in the resource_revision table
+	history bool
 }
 
 func (db *sqlDatabaseImpl) Init(env map[string]string) error {
+	if value := env[dbEnv.DbHistoryEnvVar]; value != "" {
+		history, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value '%s': %w", dbEnv.DbHistoryEnvVar, value, err)
+		}
+		db.history = history
+	}
+
 	conn, err := establishConnection(db.creator.GetDriverName(), db.creator.GetConnectionString(env))
 	if err != nil {
 		return err
@@ -8,6 +8,7 @@ import "github.com/huandu/go-sqlbuilder"
 // DBDeleter encapsulates all the deletion functions that must be implemented by the drivers
 type DBDeleter interface {
 	UrlDeleter() *sqlbuilder.DeleteBuilder
+	ProcessedEventDeleter() *sqlbuilder.DeleteBuilder
 }
 
 type DBDeleterImpl struct{}
@@ -17,3 +18,9 @@ func (DBDeleterImpl) UrlDeleter() *sqlbuilder.DeleteBuilder {
 	db.DeleteFrom("log_url")
 	return db
 }
+
+func (DBDeleterImpl) ProcessedEventDeleter() *sqlbuilder.DeleteBuilder {
+	db := sqlbuilder.NewDeleteBuilder()
+	db.DeleteFrom("processed_event")
+	return db
+}
@@ -4,6 +4,7 @@
 package facade
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/huandu/go-sqlbuilder"
@@ -19,9 +20,15 @@ type DBFilter interface {
 	CreationTSAndIDFilter(cond sqlbuilder.Cond, continueDate, continueId string) string
 	CreationTimestampAfterFilter(cond sqlbuilder.Cond, timestamp time.Time) string
 	CreationTimestampBeforeFilter(cond sqlbuilder.Cond, timestamp time.Time) string
+	UpdatedAfterFilter(cond sqlbuilder.Cond, timestamp time.Time, id int64) string
+	ExistingAtFilter(cond sqlbuilder.Cond, asOf time.Time) string
 	OwnerFilter(cond sqlbuilder.Cond, ownersUuids []string) string
 	UuidsFilter(cond sqlbuilder.Cond, uuids []string) string
 	UuidFilter(cond sqlbuilder.Cond, uuid string) string
+	RevisionFilter(cond sqlbuilder.Cond, revision int64) string
+	APIGroupFilter(cond sqlbuilder.Cond, group string) string
+	EventFilter(cond sqlbuilder.Cond, source, id string) string
+	ProcessedBeforeFilter(cond sqlbuilder.Cond, timestamp time.Time) string
 
 	ExistsLabelFilter(cond sqlbuilder.Cond, labels []string, clause *sqlbuilder.WhereClause) string
 	NotExistsLabelFilter(cond sqlbuilder.Cond, labels []string, clause *sqlbuilder.WhereClause) string
@@ -30,6 +37,9 @@ type DBFilter interface {
 	InLabelFilter(cond sqlbuilder.Cond, labels map[string][]string, clause *sqlbuilder.WhereClause) string
 	NotInLabelFilter(cond sqlbuilder.Cond, labels map[string][]string, clause *sqlbuilder.WhereClause) string
 
+	EqualsFieldFilter(cond sqlbuilder.Cond, path []string, value string) string
+	NotEqualsFieldFilter(cond sqlbuilder.Cond, path []string, value string) string
+
 	ContainerNameFilter(cond sqlbuilder.Cond, containerName string) string
 }
 
@@ -53,6 +63,17 @@ func (PartialDBFilterImpl) NameWildcardFilter(cond sqlbuilder.Cond, namePattern
 	return cond.Like("LOWER(name)", cond.Var(namePattern))
 }
 
+// UpdatedAfterFilter matches the resources changed after the change of the resource with the id at the timestamp,
+// so the changes at the same timestamp are paged by id
+func (PartialDBFilterImpl) UpdatedAfterFilter(cond sqlbuilder.Cond, timestamp time.Time, id int64) string {
+	return fmt.Sprintf("(updated_at, id) > (%s, %s)", cond.Var(timestamp), cond.Var(id))
+}
+
+// NotDeletedAtFilter matches the resources not deleted in the cluster before or at asOf
+func (PartialDBFilterImpl) NotDeletedAtFilter(cond sqlbuilder.Cond, asOf time.Time) string {
+	return cond.Or(cond.IsNull("cluster_deleted_ts"), cond.GreaterThan("cluster_deleted_ts", asOf))
+}
+
 func (PartialDBFilterImpl) UuidsFilter(cond sqlbuilder.Cond, uuids []string) string {
 	var parsedUuids []any
 	for _, v := range uuids {
@@ -65,6 +86,22 @@ func (PartialDBFilterImpl) UuidFilter(cond sqlbuilder.Cond, uuid string) string
 	return cond.Equal("uuid", uuid)
 }
 
+func (PartialDBFilterImpl) RevisionFilter(cond sqlbuilder.Cond, revision int64) string {
+	return cond.Equal("revision", revision)
+}
+
+func (PartialDBFilterImpl) APIGroupFilter(cond sqlbuilder.Cond, group string) string {
+	return cond.Equal("api_group", group)
+}
+
+func (PartialDBFilterImpl) EventFilter(cond sqlbuilder.Cond, source, id string) string {
+	return cond.And(cond.Equal("source", source), cond.Equal("event_id", id))
+}
+
+func (PartialDBFilterImpl) ProcessedBeforeFilter(cond sqlbuilder.Cond, timestamp time.Time) string {
+	return cond.LessThan("processed_at", timestamp)
+}
+
 func (PartialDBFilterImpl) ContainerNameFilter(cond sqlbuilder.Cond, containerName string) string {
 	return cond.Equal("container_name", containerName)
 }
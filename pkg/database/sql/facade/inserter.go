@@ -10,6 +10,19 @@ import (
 	"github.com/huandu/go-sqlbuilder"
 )
 
+// ResourceRow has the columns of a row of the resource table
+type ResourceRow struct {
+	Uuid, ApiVersion, Kind, Name, Namespace, Version string
+	ClusterUpdatedTs                                 time.Time
+	ClusterDeletedTs                                 sql.NullString
+	Data                                             []byte
+}
+
+// UrlRow has the columns of a row of the log_url table
+type UrlRow struct {
+	Uuid, Url, ContainerName, JsonPath string
+}
+
 // DBInserter encapsulates all the writer functions that must be implemented by the drivers
 type DBInserter interface {
 	ResourceInserter(
@@ -18,7 +31,21 @@ type DBInserter interface {
 		clusterDeletedTs sql.NullString,
 		data []byte,
 	) *sqlbuilder.InsertBuilder
+	// ResourcesInserter inserts or updates the rows as ResourceInserter does with one. The uuids of the rows
+	// must be different
+	ResourcesInserter(rows []ResourceRow) *sqlbuilder.InsertBuilder
 	UrlInserter(uuid, url, containerName, jsonPath string) *sqlbuilder.InsertBuilder
+	UrlsInserter(rows []UrlRow) *sqlbuilder.InsertBuilder
+	ResourceDefinitionInserter(group, plural, kind string, data []byte) *sqlbuilder.InsertBuilder
+	// ProcessedEventInserter inserts the CloudEvent, keeping the row when it is already inserted
+	ProcessedEventInserter(source, id string) *sqlbuilder.InsertBuilder
+	ResourceRevisionInserter(
+		uuid, version string,
+		revision int64,
+		clusterUpdatedTs time.Time,
+		clusterDeletedTs sql.NullString,
+		data []byte,
+	) *sqlbuilder.InsertBuilder
 }
 
 type PartialDBInserterImpl struct{}
@@ -30,3 +57,27 @@ func (PartialDBInserterImpl) UrlInserter(uuid, url, containerName, jsonPath stri
 	ib.Values(uuid, url, containerName, jsonPath)
 	return ib
 }
+
+func (PartialDBInserterImpl) UrlsInserter(rows []UrlRow) *sqlbuilder.InsertBuilder {
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.InsertInto("log_url")
+	ib.Cols("uuid", "url", "container_name", "json_path")
+	for _, row := range rows {
+		ib.Values(row.Uuid, row.Url, row.ContainerName, row.JsonPath)
+	}
+	return ib
+}
+
+func (PartialDBInserterImpl) ResourceRevisionInserter(
+	uuid, version string,
+	revision int64,
+	clusterUpdatedTs time.Time,
+	clusterDeletedTs sql.NullString,
+	data []byte,
+) *sqlbuilder.InsertBuilder {
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.InsertInto("resource_revision")
+	ib.Cols("uuid", "revision", "resource_version", "cluster_updated_ts", "cluster_deleted_ts", "data")
+	ib.Values(uuid, revision, version, clusterUpdatedTs, clusterDeletedTs, data)
+	return ib
+}
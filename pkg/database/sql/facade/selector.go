@@ -3,16 +3,33 @@
 
 package facade
 
-import "github.com/huandu/go-sqlbuilder"
+import (
+	"fmt"
+	"time"
+
+	"github.com/huandu/go-sqlbuilder"
+)
 
 // DBSelector encapsulates all the selector functions that must be implemented by the drivers
 type DBSelector interface {
 	ResourceSelector() *sqlbuilder.SelectBuilder
+	ResourceAsOfSelector(asOf time.Time) *sqlbuilder.SelectBuilder
+	ResourceChangeSelector() *sqlbuilder.SelectBuilder
+	ResourceCountSelector(labelKey string) *sqlbuilder.SelectBuilder
+	ArchivedKindSelector() *sqlbuilder.SelectBuilder
+	ResourceDefinitionSelector() *sqlbuilder.SelectBuilder
 	UUIDResourceSelector() *sqlbuilder.SelectBuilder
 	OwnedResourceSelector() *sqlbuilder.SelectBuilder
+	ResourceRevisionSelector() *sqlbuilder.SelectBuilder
+	ResourceRevisionDataSelector() *sqlbuilder.SelectBuilder
+	LastRevisionSelector() *sqlbuilder.SelectBuilder
+	ProcessedEventSelector() *sqlbuilder.SelectBuilder
 	UrlFromResourceSelector() *sqlbuilder.SelectBuilder
 	UrlSelector() *sqlbuilder.SelectBuilder
+	ContainerUrlSelector() *sqlbuilder.SelectBuilder
+	PodContainerUrlSelector() *sqlbuilder.SelectBuilder
 	VersionSelector() *sqlbuilder.SelectBuilder
+	CurrentTimeSelector() *sqlbuilder.SelectBuilder
 }
 
 // PartialDBSelectorImpl implements partially the DBSelector interface
@@ -24,6 +41,18 @@ func (PartialDBSelectorImpl) UUIDResourceSelector() *sqlbuilder.SelectBuilder {
 	return sb.Select("uuid").From("resource")
 }
 
+// ArchivedKindSelector selects each kind and apiVersion with archived resources once
+func (PartialDBSelectorImpl) ArchivedKindSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.Select("kind", "api_version").Distinct().From("resource")
+	return sb.OrderBy("api_version", "kind")
+}
+
+func (PartialDBSelectorImpl) ResourceDefinitionSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	return sb.Select("api_group", "plural", "kind", "data").From("resource_definition")
+}
+
 func (PartialDBSelectorImpl) UrlFromResourceSelector() *sqlbuilder.SelectBuilder {
 	sb := sqlbuilder.NewSelectBuilder()
 	sb.Select("log.url", "log.json_path")
@@ -37,6 +66,53 @@ func (PartialDBSelectorImpl) UrlSelector() *sqlbuilder.SelectBuilder {
 	return sb.Select("url", "json_path").From("log_url")
 }
 
+func (PartialDBSelectorImpl) ContainerUrlSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	return sb.Select("container_name", "url", "json_path").From("log_url")
+}
+
+// PodContainerUrlSelector selects the log urls of the containers along with the uuid of their Pod, to query
+// the log urls of several Pods at once
+func (PartialDBSelectorImpl) PodContainerUrlSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	return sb.Select("uuid", "container_name", "url", "json_path").From("log_url")
+}
+
+// ResourceAsOfQuery returns a `resource` table with the resources and the data of their last revision
+// updated in the cluster before or at asOf, so the filters and sorters of the resources apply to the
+// revisions. The resources without such a revision are left out. The query in the table reads the stored
+// resource table, as it is not recursive
+func (PartialDBSelectorImpl) ResourceAsOfQuery(asOf time.Time) *sqlbuilder.CTEBuilder {
+	lastRevision := sqlbuilder.NewSelectBuilder()
+	lastRevision.Select("MAX(last.revision)").From("resource_revision last")
+	lastRevision.Where("last.uuid = res.uuid", lastRevision.LessEqualThan("last.cluster_updated_ts", asOf))
+
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.Select(
+		"res.id", "res.uuid", "res.kind", "res.api_version", "res.namespace", "res.name",
+		"res.cluster_deleted_ts", "rev.data",
+	)
+	sb.From("resource res")
+	sb.Join("resource_revision rev", "rev.uuid = res.uuid")
+	sb.Where(fmt.Sprintf("rev.revision = (%s)", sb.Var(lastRevision)))
+	return sqlbuilder.With(sqlbuilder.CTETable("resource").As(sb))
+}
+
+func (PartialDBSelectorImpl) ResourceRevisionDataSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	return sb.Select("data").From("resource_revision")
+}
+
+func (PartialDBSelectorImpl) LastRevisionSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	return sb.Select("COALESCE(MAX(revision), 0)").From("resource_revision")
+}
+
+func (PartialDBSelectorImpl) ProcessedEventSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	return sb.Select("COUNT(*)").From("processed_event")
+}
+
 func (PartialDBSelectorImpl) VersionSelector() *sqlbuilder.SelectBuilder {
 	sb := sqlbuilder.NewSelectBuilder()
 	return sb.Select("version").From("schema_migrations")
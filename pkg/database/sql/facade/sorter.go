@@ -8,4 +8,6 @@ import "github.com/huandu/go-sqlbuilder"
 // DBSorter encapsulates all the sorter functions that must be implemented by the drivers
 type DBSorter interface {
 	CreationTSAndIDSorter(sb *sqlbuilder.SelectBuilder) *sqlbuilder.SelectBuilder
+	UpdatedTSAndIDSorter(sb *sqlbuilder.SelectBuilder) *sqlbuilder.SelectBuilder
+	RevisionSorter(sb *sqlbuilder.SelectBuilder) *sqlbuilder.SelectBuilder
 }
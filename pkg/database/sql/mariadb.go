@@ -8,10 +8,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/huandu/go-sqlbuilder"
+	"github.com/jmoiron/sqlx"
 	"github.com/kubearchive/kubearchive/pkg/database/env"
 	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
 	"github.com/kubearchive/kubearchive/pkg/database/sql/facade"
@@ -39,10 +41,63 @@ func (mariaDBSelector) ResourceSelector() *sqlbuilder.SelectBuilder {
 	return sb.Select(
 		sb.As("JSON_VALUE(data, '$.metadata.creationTimestamp')", "created_at"),
 		"id",
+		"uuid",
+		"data",
+	).From("resource")
+}
+
+// ResourceAsOfSelector returns the resources with the data of their last revision updated before or at
+// asOf, see facade.PartialDBSelectorImpl.ResourceAsOfQuery
+func (s mariaDBSelector) ResourceAsOfSelector(asOf time.Time) *sqlbuilder.SelectBuilder {
+	sb := s.ResourceAsOfQuery(asOf).Select()
+	return sb.Select(
+		sb.As("JSON_VALUE(data, '$.metadata.creationTimestamp')", "created_at"),
+		"id",
+		"uuid",
+		"data",
+	)
+}
+
+// ResourceCountSelector counts the resources by kind, apiVersion and namespace and, when labelKey
+// is not empty, by the value of that label
+func (mariaDBSelector) ResourceCountSelector(labelKey string) *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.Select("kind", "api_version", "namespace")
+	sb.GroupBy("kind", "api_version", "namespace")
+	if labelKey != "" {
+		sb.SelectMore(sb.As(
+			fmt.Sprintf("JSON_VALUE(data, %s)", sb.Var(mariaDBJsonPath([]string{"metadata", "labels", labelKey}))),
+			"label_value",
+		))
+		sb.GroupBy("label_value")
+	}
+	sb.SelectMore(sb.As("COUNT(*)", "count"))
+	sb.OrderBy("kind", "api_version", "namespace")
+	if labelKey != "" {
+		sb.OrderBy("label_value")
+	}
+	return sb.From("resource")
+}
+
+func (mariaDBSelector) ResourceChangeSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	return sb.Select(
+		sb.As("JSON_VALUE(data, '$.metadata.creationTimestamp')", "created_at"),
+		"id",
+		"uuid",
 		"data",
+		sb.As("DATE_FORMAT(CONVERT_TZ(created_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%s.%fZ')", "archived_at"),
+		sb.As("DATE_FORMAT(CONVERT_TZ(updated_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%s.%fZ')", "updated_at"),
+		sb.As("cluster_deleted_ts IS NOT NULL", "deleted"),
 	).From("resource")
 }
 
+// CurrentTimeSelector returns the time of the database in UTC with the format of the updated_at of ResourceChangeSelector
+func (mariaDBSelector) CurrentTimeSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	return sb.Select("DATE_FORMAT(UTC_TIMESTAMP(6), '%Y-%m-%dT%H:%i:%s.%fZ')")
+}
+
 func (mariaDBSelector) OwnedResourceSelector() *sqlbuilder.SelectBuilder {
 	sb := sqlbuilder.NewSelectBuilder()
 	return sb.Select(
@@ -52,6 +107,36 @@ func (mariaDBSelector) OwnedResourceSelector() *sqlbuilder.SelectBuilder {
 	).From("resource")
 }
 
+// OutdatedResourceSelector selects and locks the stored rows of the resources, and whether they are older
+// than the new rows, which is the condition of mariaDBInserter.ResourcesInserter to update them
+func (mariaDBSelector) OutdatedResourceSelector(rows []facade.ResourceRow) *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	cases := make([]string, 0, len(rows))
+	uuids := make([]any, 0, len(rows))
+	for _, row := range rows {
+		cases = append(cases, fmt.Sprintf("WHEN %s THEN %s", sb.Var(row.Uuid), sb.Var(row.ClusterUpdatedTs)))
+		uuids = append(uuids, row.Uuid)
+	}
+	sb.Select(
+		"uuid",
+		sb.As(fmt.Sprintf("cluster_updated_ts < CASE uuid %s END", strings.Join(cases, " ")), "outdated"),
+	)
+	sb.From("resource")
+	sb.Where(sb.In("uuid", uuids...))
+	return sb.ForUpdate()
+}
+
+func (mariaDBSelector) ResourceRevisionSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	return sb.Select(
+		"revision",
+		sb.As("COALESCE(resource_version, '')", "resource_version"),
+		sb.As("DATE_FORMAT(CONVERT_TZ(cluster_updated_ts, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ')", "cluster_updated_ts"),
+		sb.As("DATE_FORMAT(CONVERT_TZ(cluster_deleted_ts, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%sZ')", "cluster_deleted_ts"),
+		sb.As("DATE_FORMAT(CONVERT_TZ(created_at, @@session.time_zone, '+00:00'), '%Y-%m-%dT%H:%i:%s.%fZ')", "archived_at"),
+	).From("resource_revision")
+}
+
 type mariaDBFilter struct {
 	facade.PartialDBFilterImpl
 }
@@ -77,6 +162,17 @@ func (mariaDBFilter) CreationTimestampBeforeFilter(cond sqlbuilder.Cond, timesta
 	)
 }
 
+// ExistingAtFilter matches the resources created before or at asOf and not deleted by then
+func (f mariaDBFilter) ExistingAtFilter(cond sqlbuilder.Cond, asOf time.Time) string {
+	return cond.And(
+		fmt.Sprintf(
+			"CONVERT(JSON_VALUE(data, '$.metadata.creationTimestamp'), datetime) <= %s",
+			cond.Var(asOf.UTC().Format("2006-01-02 15:04:05")),
+		),
+		f.NotDeletedAtFilter(cond, asOf),
+	)
+}
+
 func (mariaDBFilter) OwnerFilter(cond sqlbuilder.Cond, uuids []string) string {
 	return fmt.Sprintf(
 		"JSON_OVERLAPS(JSON_EXTRACT(data, '$.metadata.ownerReferences.**.uid'), JSON_ARRAY(%s))",
@@ -113,37 +209,96 @@ func (mariaDBFilter) NotInLabelFilter(cond sqlbuilder.Cond, labels map[string][]
 	return ""
 }
 
+func (mariaDBFilter) EqualsFieldFilter(cond sqlbuilder.Cond, path []string, value string) string {
+	return fmt.Sprintf(
+		"COALESCE(JSON_VALUE(data, %s), '') = %s",
+		cond.Var(mariaDBJsonPath(path)), cond.Var(value),
+	)
+}
+
+func (mariaDBFilter) NotEqualsFieldFilter(cond sqlbuilder.Cond, path []string, value string) string {
+	return fmt.Sprintf(
+		"COALESCE(JSON_VALUE(data, %s), '') <> %s",
+		cond.Var(mariaDBJsonPath(path)), cond.Var(value),
+	)
+}
+
+// mariaDBJsonPath converts a field path into a JSON path, for example ["status", "phase"] into `$."status"."phase"`
+func mariaDBJsonPath(path []string) string {
+	return fmt.Sprintf("$.\"%s\"", strings.Join(path, "\".\""))
+}
+
 type mariaDBSorter struct{}
 
 func (mariaDBSorter) CreationTSAndIDSorter(sb *sqlbuilder.SelectBuilder) *sqlbuilder.SelectBuilder {
 	return sb.OrderByDesc("CONVERT(JSON_VALUE(data, '$.metadata.creationTimestamp'), datetime)").OrderByDesc("id")
 }
 
+func (mariaDBSorter) UpdatedTSAndIDSorter(sb *sqlbuilder.SelectBuilder) *sqlbuilder.SelectBuilder {
+	return sb.OrderByAsc("updated_at").OrderByAsc("id")
+}
+
+func (mariaDBSorter) RevisionSorter(sb *sqlbuilder.SelectBuilder) *sqlbuilder.SelectBuilder {
+	return sb.OrderByAsc("revision")
+}
+
 type mariaDBInserter struct {
 	facade.PartialDBInserterImpl
 }
 
-func (mariaDBInserter) ResourceInserter(
+// ResourceInserter only updates the stored row when it is older than the new one, see ResourcesInserter
+func (i mariaDBInserter) ResourceInserter(
 	uuid, apiVersion, kind, name, namespace, version string,
 	clusterUpdatedTs time.Time,
 	clusterDeletedTs sql.NullString,
 	data []byte,
 ) *sqlbuilder.InsertBuilder {
+	return i.ResourcesInserter([]facade.ResourceRow{{
+		Uuid: uuid, ApiVersion: apiVersion, Kind: kind, Name: name, Namespace: namespace, Version: version,
+		ClusterUpdatedTs: clusterUpdatedTs, ClusterDeletedTs: clusterDeletedTs, Data: data,
+	}})
+}
+
+// ResourcesInserter only updates the stored rows older than the new ones. cluster_updated_ts is updated
+// last, as MariaDB uses the updated value of a column in the assignments that follow it
+func (mariaDBInserter) ResourcesInserter(rows []facade.ResourceRow) *sqlbuilder.InsertBuilder {
 	ib := sqlbuilder.NewInsertBuilder()
 	ib.InsertInto("resource")
 	ib.Cols(
 		"uuid", "api_version", "kind", "name", "namespace", "resource_version", "cluster_updated_ts",
 		"cluster_deleted_ts", "data",
 	)
-	ib.Values(uuid, apiVersion, kind, name, namespace, version, clusterUpdatedTs, clusterDeletedTs, data)
-	ib.SQL(ib.Var(sqlbuilder.Build(
-		"ON DUPLICATE KEY UPDATE name=$?, namespace=$?, resource_version=$?, cluster_updated_ts=$?, cluster_deleted_ts=$?, data=$?",
-		name, namespace, version, clusterUpdatedTs, clusterDeletedTs, data,
-	)))
-	ib.SQL(ib.Var(sqlbuilder.Build(
-		"WHERE resource.cluster_deleted_ts < $?",
-		clusterUpdatedTs,
-	)))
+	for _, row := range rows {
+		ib.Values(row.Uuid, row.ApiVersion, row.Kind, row.Name, row.Namespace, row.Version, row.ClusterUpdatedTs,
+			row.ClusterDeletedTs, row.Data)
+	}
+	assignments := make([]string, 0, 6)
+	for _, column := range []string{"name", "namespace", "resource_version", "cluster_deleted_ts", "data",
+		"cluster_updated_ts"} {
+		assignments = append(assignments, fmt.Sprintf(
+			"%[1]s=IF(cluster_updated_ts < VALUES(cluster_updated_ts), VALUES(%[1]s), %[1]s)", column))
+	}
+	ib.SQL("ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", "))
+	return ib
+}
+
+// ResourceDefinitionInserter inserts the definition or replaces the one stored for the same group and plural
+func (mariaDBInserter) ResourceDefinitionInserter(group, plural, kind string, data []byte) *sqlbuilder.InsertBuilder {
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.InsertInto("resource_definition")
+	ib.Cols("api_group", "plural", "kind", "data")
+	ib.Values(group, plural, kind, data)
+	ib.SQL(ib.Var(sqlbuilder.Build("ON DUPLICATE KEY UPDATE kind=$?, data=$?", kind, data)))
+	return ib
+}
+
+// ProcessedEventInserter inserts the CloudEvent, keeping the row when it is already inserted
+func (mariaDBInserter) ProcessedEventInserter(source, id string) *sqlbuilder.InsertBuilder {
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.InsertInto("processed_event")
+	ib.Cols("source", "event_id")
+	ib.Values(source, id)
+	ib.SQL("ON DUPLICATE KEY UPDATE processed_at=processed_at")
 	return ib
 }
 
@@ -151,7 +306,6 @@ type mariaDBDatabase struct {
 	*sqlDatabaseImpl
 }
 
-// FIXME: the WriteResourceResult return value must indicate if the query resulted in an insertion, update, nothing or an error
 func (db *mariaDBDatabase) WriteResource(
 	ctx context.Context,
 	k8sObj *unstructured.Unstructured,
@@ -164,7 +318,7 @@ func (db *mariaDBDatabase) WriteResource(
 		return interfaces.WriteResourceResultError, errors.New("kubernetes object was 'nil', something went wrong")
 	}
 
-	tx, err := db.db.BeginTx(ctx, nil)
+	tx, err := db.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return interfaces.WriteResourceResultError, fmt.Errorf("could not begin transaction for resource %s: %s", k8sObj.GetUID(), err)
 	}
@@ -221,7 +375,7 @@ func (db *mariaDBDatabase) WriteResource(
 		data,
 	).BuildWithFlavor(db.flavor)
 
-	_, execErr := tx.ExecContext(
+	result, execErr := tx.ExecContext(
 		ctx,
 		query,
 		args...,
@@ -234,6 +388,31 @@ func (db *mariaDBDatabase) WriteResource(
 		return interfaces.WriteResourceResultError, fmt.Errorf("write to database failed: %s", execErr)
 	}
 
+	// MariaDB reports 1 affected row for an insertion, 2 for an update and 0 when the stored row is kept
+	affected, execErr := result.RowsAffected()
+	if execErr != nil {
+		return interfaces.WriteResourceResultError, rollback(tx, fmt.Errorf("write to database failed: %w", execErr))
+	}
+	writeResult := interfaces.WriteResourceResultNone
+	switch affected {
+	case 1:
+		writeResult = interfaces.WriteResourceResultInserted
+	case 2:
+		writeResult = interfaces.WriteResourceResultUpdated
+	}
+
+	if writeResult != interfaces.WriteResourceResultNone {
+		execErr = db.writeResourceRevision(ctx, tx, k8sObj, data, lastUpdated)
+		if execErr != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil {
+				return interfaces.WriteResourceResultError, fmt.Errorf(
+					"%w and unable to roll back transaction: %w", execErr, rollbackErr)
+			}
+			return interfaces.WriteResourceResultError, execErr
+		}
+	}
+
 	execErr = tx.Commit()
 	if execErr != nil {
 		rollbackErr := tx.Rollback()
@@ -243,7 +422,52 @@ func (db *mariaDBDatabase) WriteResource(
 		return interfaces.WriteResourceResultError, fmt.Errorf("commit to database failed and the transactions was rolled back: %s", execErr)
 	}
 
-	return interfaces.WriteResourceResultInserted, nil
+	return writeResult, nil
+}
+
+func (db *mariaDBDatabase) WriteResources(
+	ctx context.Context,
+	writes []interfaces.ResourceWrite,
+) ([]interfaces.WriteResourceResult, error) {
+	return db.writeResources(ctx, writes, db.upsertResources)
+}
+
+// outdatedResource is a row returned by mariaDBSelector.OutdatedResourceSelector
+type outdatedResource struct {
+	Uuid     string `db:"uuid"`
+	Outdated bool   `db:"outdated"`
+}
+
+// upsertResources locks the stored rows before the upsert to know which resources it writes, as MariaDB
+// does not return which rows a multi-row upsert changed. The resources not stored yet are inserted
+func (db *mariaDBDatabase) upsertResources(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	rows []facade.ResourceRow,
+) (map[string]bool, error) {
+	stored, err := newQueryPerformer[outdatedResource](tx, db.flavor).performQuery(
+		ctx, mariaDBSelector{}.OutdatedResourceSelector(rows))
+	if err != nil {
+		return nil, err
+	}
+
+	query, args := db.inserter.ResourcesInserter(rows).BuildWithFlavor(db.flavor)
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return nil, err
+	}
+
+	written := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		written[row.Uuid] = true
+	}
+	for _, resource := range stored {
+		if resource.Outdated {
+			written[resource.Uuid] = false
+		} else {
+			delete(written, resource.Uuid)
+		}
+	}
+	return written, nil
 }
 
 func NewMariaDBDatabase() *mariaDBDatabase {
@@ -14,7 +14,10 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/huandu/go-sqlbuilder"
 	"github.com/jmoiron/sqlx"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+	"github.com/kubearchive/kubearchive/pkg/database/sql/facade"
 	"github.com/kubearchive/kubearchive/pkg/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -182,3 +185,172 @@ func TestMariaDBWriteResource(t *testing.T) {
 		})
 	}
 }
+
+func TestMariaDBResourceSelectors(t *testing.T) {
+	selector := NewMariaDBDatabase().getSelector()
+	// The log URLs of the Pods are matched to the resources by their uuid
+	for name, sb := range map[string]*sqlbuilder.SelectBuilder{
+		"ResourceSelector":     selector.ResourceSelector(),
+		"ResourceAsOfSelector": selector.ResourceAsOfSelector(time.Now()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			query, _ := sb.BuildWithFlavor(sqlbuilder.MySQL)
+			assert.Contains(t, query,
+				"SELECT JSON_VALUE(data, '$.metadata.creationTimestamp') AS created_at, id, uuid, data FROM ")
+		})
+	}
+}
+
+func TestMariaDBWriteResourceDefinition(t *testing.T) {
+	database := NewMariaDBDatabase()
+	db, mock := NewMock()
+	database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+	data := []byte(`{"kind":"CustomResourceDefinition"}`)
+	query, args := database.getInserter().ResourceDefinitionInserter(
+		"stable.example.com", "crontabs", "CronTab", data,
+	).BuildWithFlavor(database.getFlavor())
+	assert.Equal(t, "INSERT INTO resource_definition (api_group, plural, kind, data) VALUES (?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE kind=?, data=?", query)
+
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).
+		WillReturnError(errors.New("connection lost"))
+	err := database.WriteResourceDefinition(context.Background(), models.ResourceDefinition{
+		Group: "stable.example.com", Plural: "crontabs", Kind: "CronTab", Data: string(data),
+	})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMariaDBWriteProcessedEvent(t *testing.T) {
+	database := NewMariaDBDatabase()
+	db, mock := NewMock()
+	database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectExec(regexp.QuoteMeta(
+		"INSERT INTO processed_event (source, event_id) VALUES (?, ?) ON DUPLICATE KEY UPDATE processed_at=processed_at",
+	)).WithArgs("test", "event-1").WillReturnError(errors.New("connection lost"))
+	err := database.WriteProcessedEvent(context.Background(), "test", "event-1")
+	assert.ErrorContains(t, err, "could not write the CloudEvent event-1 from test")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMariaDBWriteResources(t *testing.T) {
+	jobData, err := os.ReadFile("../testdata/job.json")
+	assert.NoError(t, err)
+	job, err := models.UnstructuredFromByteSlice(jobData)
+	assert.NoError(t, err)
+
+	database := NewMariaDBDatabase()
+	db, mock := NewMock()
+	database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+	row := facade.ResourceRow{
+		Uuid: string(job.GetUID()), ApiVersion: job.GetAPIVersion(), Kind: job.GetKind(), Name: job.GetName(),
+		Namespace: job.GetNamespace(), Version: job.GetResourceVersion(), ClusterUpdatedTs: time.Now(), Data: jobData,
+	}
+	query, args := database.getInserter().ResourcesInserter([]facade.ResourceRow{row}).
+		BuildWithFlavor(database.getFlavor())
+	assert.Equal(t, "INSERT INTO resource (uuid, api_version, kind, name, namespace, resource_version, "+
+		"cluster_updated_ts, cluster_deleted_ts, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE "+
+		"name=IF(cluster_updated_ts < VALUES(cluster_updated_ts), VALUES(name), name), "+
+		"namespace=IF(cluster_updated_ts < VALUES(cluster_updated_ts), VALUES(namespace), namespace), "+
+		"resource_version=IF(cluster_updated_ts < VALUES(cluster_updated_ts), VALUES(resource_version), resource_version), "+
+		"cluster_deleted_ts=IF(cluster_updated_ts < VALUES(cluster_updated_ts), VALUES(cluster_deleted_ts), cluster_deleted_ts), "+
+		"data=IF(cluster_updated_ts < VALUES(cluster_updated_ts), VALUES(data), data), "+
+		"cluster_updated_ts=IF(cluster_updated_ts < VALUES(cluster_updated_ts), VALUES(cluster_updated_ts), cluster_updated_ts)",
+		query)
+
+	selectQuery, selectArgs := mariaDBSelector{}.OutdatedResourceSelector([]facade.ResourceRow{row}).
+		BuildWithFlavor(database.getFlavor())
+	assert.Equal(t, "SELECT uuid, cluster_updated_ts < CASE uuid WHEN ? THEN ? END AS outdated FROM resource "+
+		"WHERE uuid IN (?) FOR UPDATE", selectQuery)
+
+	tests := []struct {
+		name     string
+		stored   *sqlmock.Rows
+		expected interfaces.WriteResourceResult
+	}{
+		{
+			name:     "not stored",
+			stored:   sqlmock.NewRows([]string{"uuid", "outdated"}),
+			expected: interfaces.WriteResourceResultInserted,
+		},
+		{
+			name:     "stored older",
+			stored:   sqlmock.NewRows([]string{"uuid", "outdated"}).AddRow(row.Uuid, true),
+			expected: interfaces.WriteResourceResultUpdated,
+		},
+		{
+			name:     "stored newer",
+			stored:   sqlmock.NewRows([]string{"uuid", "outdated"}).AddRow(row.Uuid, false),
+			expected: interfaces.WriteResourceResultNone,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta(selectQuery)).WithArgs(sliceOfAny2sliceOfValue(selectArgs)...).
+				WillReturnRows(test.stored)
+			mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			results, err := database.WriteResources(context.Background(), []interfaces.ResourceWrite{
+				{Object: job, Data: jobData, LastUpdated: row.ClusterUpdatedTs},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []interfaces.WriteResourceResult{test.expected}, results)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMariaDBWriteResourceRevision(t *testing.T) {
+	jobData, err := os.ReadFile("../testdata/job.json")
+	assert.NoError(t, err)
+	job, err := models.UnstructuredFromByteSlice(jobData)
+	assert.NoError(t, err)
+	lastUpdated := time.Now()
+
+	tests := []struct {
+		name     string
+		affected int64
+		expected interfaces.WriteResourceResult
+	}{
+		{name: "inserted", affected: 1, expected: interfaces.WriteResourceResultInserted},
+		{name: "updated", affected: 2, expected: interfaces.WriteResourceResultUpdated},
+		{name: "stale", affected: 0, expected: interfaces.WriteResourceResultNone},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			database := NewMariaDBDatabase()
+			database.history = true
+			db, mock := NewMock()
+			database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+			query, args := database.getInserter().ResourceInserter(
+				string(job.GetUID()), job.GetAPIVersion(), job.GetKind(), job.GetName(), job.GetNamespace(),
+				job.GetResourceVersion(), lastUpdated, sql.NullString{}, jobData,
+			).BuildWithFlavor(database.getFlavor())
+
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).
+				WillReturnResult(sqlmock.NewResult(0, test.affected))
+			if test.affected > 0 {
+				mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"revision"}).AddRow(1))
+				revisionQuery, revisionArgs := database.getInserter().ResourceRevisionInserter(
+					string(job.GetUID()), job.GetResourceVersion(), 2, lastUpdated, sql.NullString{}, jobData,
+				).BuildWithFlavor(database.getFlavor())
+				mock.ExpectExec(regexp.QuoteMeta(revisionQuery)).WithArgs(sliceOfAny2sliceOfValue(revisionArgs)...).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			mock.ExpectCommit()
+
+			result, err := database.WriteResource(context.Background(), job, jobData, lastUpdated, "jsonPath")
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, result)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
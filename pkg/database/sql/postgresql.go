@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/huandu/go-sqlbuilder"
+	"github.com/jmoiron/sqlx"
 	"github.com/kubearchive/kubearchive/pkg/database/env"
 	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
 	"github.com/kubearchive/kubearchive/pkg/database/sql/facade"
@@ -47,6 +48,55 @@ func (postgreSQLSelector) ResourceSelector() *sqlbuilder.SelectBuilder {
 	).From("resource")
 }
 
+// ResourceAsOfSelector returns the resources with the data of their last revision updated before or at
+// asOf, see facade.PartialDBSelectorImpl.ResourceAsOfQuery
+func (s postgreSQLSelector) ResourceAsOfSelector(asOf time.Time) *sqlbuilder.SelectBuilder {
+	sb := s.ResourceAsOfQuery(asOf).Select()
+	return sb.Select(
+		sb.As("data->'metadata'->>'creationTimestamp'", "created_at"),
+		"id",
+		"uuid",
+		"data",
+	)
+}
+
+// ResourceCountSelector counts the resources by kind, apiVersion and namespace and, when labelKey
+// is not empty, by the value of that label
+func (postgreSQLSelector) ResourceCountSelector(labelKey string) *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	sb.Select("kind", "api_version", "namespace")
+	sb.GroupBy("kind", "api_version", "namespace")
+	if labelKey != "" {
+		sb.SelectMore(sb.As(fmt.Sprintf("data->'metadata'->'labels'->>%s", sb.Var(labelKey)), "label_value"))
+		sb.GroupBy("label_value")
+	}
+	sb.SelectMore(sb.As("COUNT(*)", "count"))
+	sb.OrderBy("kind", "api_version", "namespace")
+	if labelKey != "" {
+		sb.OrderBy("label_value")
+	}
+	return sb.From("resource")
+}
+
+func (postgreSQLSelector) ResourceChangeSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	return sb.Select(
+		sb.As("data->'metadata'->>'creationTimestamp'", "created_at"),
+		"id",
+		"uuid",
+		"data",
+		sb.As(`to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`, "archived_at"),
+		sb.As(`to_char(updated_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`, "updated_at"),
+		sb.As("cluster_deleted_ts IS NOT NULL", "deleted"),
+	).From("resource")
+}
+
+// CurrentTimeSelector returns the time of the database in UTC with the format of the updated_at of ResourceChangeSelector
+func (postgreSQLSelector) CurrentTimeSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	return sb.Select(`to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`)
+}
+
 func (postgreSQLSelector) OwnedResourceSelector() *sqlbuilder.SelectBuilder {
 	sb := sqlbuilder.NewSelectBuilder()
 	return sb.Select(
@@ -56,6 +106,17 @@ func (postgreSQLSelector) OwnedResourceSelector() *sqlbuilder.SelectBuilder {
 	).From("resource")
 }
 
+func (postgreSQLSelector) ResourceRevisionSelector() *sqlbuilder.SelectBuilder {
+	sb := sqlbuilder.NewSelectBuilder()
+	return sb.Select(
+		"revision",
+		sb.As("COALESCE(resource_version, '')", "resource_version"),
+		sb.As(`to_char(cluster_updated_ts AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')`, "cluster_updated_ts"),
+		sb.As(`to_char(cluster_deleted_ts AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')`, "cluster_deleted_ts"),
+		sb.As(`to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`, "archived_at"),
+	).From("resource_revision")
+}
+
 type postgreSQLFilter struct {
 	facade.PartialDBFilterImpl
 }
@@ -81,6 +142,17 @@ func (postgreSQLFilter) CreationTimestampBeforeFilter(cond sqlbuilder.Cond, time
 	)
 }
 
+// ExistingAtFilter matches the resources created before or at asOf and not deleted by then
+func (f postgreSQLFilter) ExistingAtFilter(cond sqlbuilder.Cond, asOf time.Time) string {
+	return cond.And(
+		fmt.Sprintf(
+			"data->'metadata'->>'creationTimestamp' <= %s",
+			cond.Var(asOf.UTC().Format(time.RFC3339)),
+		),
+		f.NotDeletedAtFilter(cond, asOf),
+	)
+}
+
 func (postgreSQLFilter) NameWildcardFilter(cond sqlbuilder.Cond, namePattern string) string {
 	return fmt.Sprintf("name ILIKE %s", cond.Var(namePattern))
 }
@@ -162,12 +234,36 @@ func (f postgreSQLFilter) NotInLabelFilter(cond sqlbuilder.Cond, labels map[stri
 	return cond.And(f.ExistsLabelFilter(cond, slices.Collect(keys), nil), notContainsClause)
 }
 
+// EqualsFieldFilter follows the Kubernetes behavior of treating missing fields as empty strings,
+// so `spec.nodeName=` matches resources without `spec.nodeName`
+func (postgreSQLFilter) EqualsFieldFilter(cond sqlbuilder.Cond, path []string, value string) string {
+	return fmt.Sprintf(
+		"COALESCE(data #>> %s, '') = %s",
+		cond.Var(pq.Array(path)), cond.Var(value),
+	)
+}
+
+func (postgreSQLFilter) NotEqualsFieldFilter(cond sqlbuilder.Cond, path []string, value string) string {
+	return fmt.Sprintf(
+		"COALESCE(data #>> %s, '') <> %s",
+		cond.Var(pq.Array(path)), cond.Var(value),
+	)
+}
+
 type postgreSQLSorter struct{}
 
 func (postgreSQLSorter) CreationTSAndIDSorter(sb *sqlbuilder.SelectBuilder) *sqlbuilder.SelectBuilder {
 	return sb.OrderByDesc("data->'metadata'->>'creationTimestamp'").OrderByDesc("id")
 }
 
+func (postgreSQLSorter) UpdatedTSAndIDSorter(sb *sqlbuilder.SelectBuilder) *sqlbuilder.SelectBuilder {
+	return sb.OrderByAsc("updated_at").OrderByAsc("id")
+}
+
+func (postgreSQLSorter) RevisionSorter(sb *sqlbuilder.SelectBuilder) *sqlbuilder.SelectBuilder {
+	return sb.OrderByAsc("revision")
+}
+
 type postgreSQLInserter struct {
 	facade.PartialDBInserterImpl
 }
@@ -197,6 +293,47 @@ func (postgreSQLInserter) ResourceInserter(
 	return ib
 }
 
+// ResourcesInserter returns the uuid of the rows inserted or updated and whether they were inserted
+func (postgreSQLInserter) ResourcesInserter(rows []facade.ResourceRow) *sqlbuilder.InsertBuilder {
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.InsertInto("resource")
+	ib.Cols(
+		"uuid", "api_version", "kind", "name", "namespace", "resource_version", "cluster_updated_ts",
+		"cluster_deleted_ts", "data",
+	)
+	for _, row := range rows {
+		ib.Values(row.Uuid, row.ApiVersion, row.Kind, row.Name, row.Namespace, row.Version, row.ClusterUpdatedTs,
+			row.ClusterDeletedTs, row.Data)
+	}
+	ib.SQL("ON CONFLICT(uuid) DO UPDATE SET name=EXCLUDED.name, namespace=EXCLUDED.namespace, " +
+		"resource_version=EXCLUDED.resource_version, cluster_updated_ts=EXCLUDED.cluster_updated_ts, " +
+		"cluster_deleted_ts=EXCLUDED.cluster_deleted_ts, data=EXCLUDED.data")
+	ib.SQL("WHERE resource.cluster_updated_ts < EXCLUDED.cluster_updated_ts")
+	ib.Returning("uuid", "(xmax = 0) AS inserted")
+	return ib
+}
+
+// ResourceDefinitionInserter inserts the definition or replaces the one stored for the same group and plural
+func (postgreSQLInserter) ResourceDefinitionInserter(group, plural, kind string, data []byte) *sqlbuilder.InsertBuilder {
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.InsertInto("resource_definition")
+	ib.Cols("api_group", "plural", "kind", "data")
+	ib.Values(group, plural, kind, data)
+	ib.SQL(ib.Var(sqlbuilder.Build("ON CONFLICT(api_group, plural) DO UPDATE SET kind=$?, data=$?, updated_at=now()",
+		kind, data)))
+	return ib
+}
+
+// ProcessedEventInserter inserts the CloudEvent, keeping the row when it is already inserted
+func (postgreSQLInserter) ProcessedEventInserter(source, id string) *sqlbuilder.InsertBuilder {
+	ib := sqlbuilder.NewInsertBuilder()
+	ib.InsertInto("processed_event")
+	ib.Cols("source", "event_id")
+	ib.Values(source, id)
+	ib.SQL("ON CONFLICT(source, event_id) DO NOTHING")
+	return ib
+}
+
 type postgreSQLDatabase struct {
 	*sqlDatabaseImpl
 }
@@ -241,6 +378,19 @@ func (db *postgreSQLDatabase) WriteResource(
 		return interfaces.WriteResourceResultError, fmt.Errorf("write resource to database failed: %s", execErr)
 	}
 
+	// Stale writes do not change the resource, so they are not a new revision
+	if execErr == nil {
+		revisionErr := db.writeResourceRevision(ctx, tx, k8sObj, data, lastUpdated)
+		if revisionErr != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil {
+				return interfaces.WriteResourceResultError, fmt.Errorf(
+					"%w and unable to roll back transaction: %w", revisionErr, rollbackErr)
+			}
+			return interfaces.WriteResourceResultError, revisionErr
+		}
+	}
+
 	if k8sObj.GetKind() == "Pod" {
 		delBuilder := db.deleter.UrlDeleter()
 		delBuilder.Where(db.filter.UuidFilter(delBuilder.Cond, string(k8sObj.GetUID())))
@@ -302,6 +452,36 @@ func (db *postgreSQLDatabase) WriteResource(
 	return result, nil
 }
 
+func (db *postgreSQLDatabase) WriteResources(
+	ctx context.Context,
+	writes []interfaces.ResourceWrite,
+) ([]interfaces.WriteResourceResult, error) {
+	return db.writeResources(ctx, writes, db.upsertResources)
+}
+
+// upsertedResource is a row returned by postgreSQLInserter.ResourcesInserter
+type upsertedResource struct {
+	Uuid     string `db:"uuid"`
+	Inserted bool   `db:"inserted"`
+}
+
+func (db *postgreSQLDatabase) upsertResources(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	rows []facade.ResourceRow,
+) (map[string]bool, error) {
+	upserted, err := newQueryPerformer[upsertedResource](tx, db.flavor).performQuery(
+		ctx, db.inserter.ResourcesInserter(rows))
+	if err != nil {
+		return nil, err
+	}
+	written := make(map[string]bool, len(upserted))
+	for _, resource := range upserted {
+		written[resource.Uuid] = resource.Inserted
+	}
+	return written, nil
+}
+
 func NewPostgreSQLDatabase() *postgreSQLDatabase {
 	return &postgreSQLDatabase{&sqlDatabaseImpl{
 		flavor:   sqlbuilder.PostgreSQL,
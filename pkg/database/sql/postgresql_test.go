@@ -16,6 +16,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
 	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
+	"github.com/kubearchive/kubearchive/pkg/database/sql/facade"
 	"github.com/kubearchive/kubearchive/pkg/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -187,3 +188,144 @@ func TestPostgreSQLWriteResource(t *testing.T) {
 		})
 	}
 }
+
+func TestPostgreSQLWriteResourceDefinition(t *testing.T) {
+	database := NewPostgreSQLDatabase()
+	db, mock := NewMock()
+	database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+	data := []byte(`{"kind":"CustomResourceDefinition"}`)
+	query, args := database.getInserter().ResourceDefinitionInserter(
+		"stable.example.com", "crontabs", "CronTab", data,
+	).BuildWithFlavor(database.getFlavor())
+	assert.Equal(t, "INSERT INTO resource_definition (api_group, plural, kind, data) VALUES ($1, $2, $3, $4) "+
+		"ON CONFLICT(api_group, plural) DO UPDATE SET kind=$5, data=$6, updated_at=now()", query)
+
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	err := database.WriteResourceDefinition(context.Background(), models.ResourceDefinition{
+		Group: "stable.example.com", Plural: "crontabs", Kind: "CronTab", Data: string(data),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgreSQLWriteResources(t *testing.T) {
+	podData, err := os.ReadFile("../testdata/pod-3-containers.json")
+	assert.NoError(t, err)
+	pod, err := models.UnstructuredFromByteSlice(podData)
+	assert.NoError(t, err)
+	jobData, err := os.ReadFile("../testdata/job.json")
+	assert.NoError(t, err)
+	job, err := models.UnstructuredFromByteSlice(jobData)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	logs := []models.LogTuple{{ContainerName: "hello", Url: "https://example.com/logs/hello"}}
+	writes := []interfaces.ResourceWrite{
+		{Object: pod, Data: podData, LastUpdated: now, JsonPath: "jsonPath"},
+		{Object: job, Data: jobData, LastUpdated: now},
+		{Object: pod, Data: podData, LastUpdated: now.Add(time.Second), JsonPath: "jsonPath", Logs: logs},
+	}
+	podRow := func(ts time.Time) facade.ResourceRow {
+		return facade.ResourceRow{
+			Uuid: string(pod.GetUID()), ApiVersion: pod.GetAPIVersion(), Kind: pod.GetKind(), Name: pod.GetName(),
+			Namespace: pod.GetNamespace(), Version: pod.GetResourceVersion(), ClusterUpdatedTs: ts, Data: podData,
+		}
+	}
+	jobRow := facade.ResourceRow{
+		Uuid: string(job.GetUID()), ApiVersion: job.GetAPIVersion(), Kind: job.GetKind(), Name: job.GetName(),
+		Namespace: job.GetNamespace(), Version: job.GetResourceVersion(), ClusterUpdatedTs: now, Data: jobData,
+	}
+
+	database := NewPostgreSQLDatabase()
+	db, mock := NewMock()
+	database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	// The pod is written twice, so its second write is in a second upsert
+	query, args := database.getInserter().ResourcesInserter(
+		[]facade.ResourceRow{podRow(now), jobRow}).BuildWithFlavor(database.getFlavor())
+	assert.Equal(t, "INSERT INTO resource (uuid, api_version, kind, name, namespace, resource_version, "+
+		"cluster_updated_ts, cluster_deleted_ts, data) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9), "+
+		"($10, $11, $12, $13, $14, $15, $16, $17, $18) ON CONFLICT(uuid) DO UPDATE SET name=EXCLUDED.name, "+
+		"namespace=EXCLUDED.namespace, resource_version=EXCLUDED.resource_version, "+
+		"cluster_updated_ts=EXCLUDED.cluster_updated_ts, cluster_deleted_ts=EXCLUDED.cluster_deleted_ts, "+
+		"data=EXCLUDED.data WHERE resource.cluster_updated_ts < EXCLUDED.cluster_updated_ts "+
+		"RETURNING uuid, (xmax = 0) AS inserted", query)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).
+		WillReturnRows(sqlmock.NewRows([]string{"uuid", "inserted"}).AddRow(string(pod.GetUID()), true))
+	query, args = database.getInserter().ResourcesInserter(
+		[]facade.ResourceRow{podRow(now.Add(time.Second))}).BuildWithFlavor(database.getFlavor())
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).
+		WillReturnRows(sqlmock.NewRows([]string{"uuid", "inserted"}).AddRow(string(pod.GetUID()), false))
+
+	delBuilder := database.getDeleter().UrlDeleter()
+	delBuilder.Where(database.getFilter().UuidsFilter(delBuilder.Cond, []string{string(pod.GetUID())}))
+	query, args = delBuilder.BuildWithFlavor(database.getFlavor())
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).
+		WillReturnResult(driver.ResultNoRows)
+	query, args = database.getInserter().UrlsInserter([]facade.UrlRow{{
+		Uuid: string(pod.GetUID()), Url: logs[0].Url, ContainerName: logs[0].ContainerName, JsonPath: "jsonPath",
+	}}).BuildWithFlavor(database.getFlavor())
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).
+		WillReturnResult(driver.ResultNoRows)
+	mock.ExpectCommit()
+
+	results, err := database.WriteResources(context.Background(), writes)
+	assert.NoError(t, err)
+	assert.Equal(t, []interfaces.WriteResourceResult{
+		interfaces.WriteResourceResultInserted,
+		interfaces.WriteResourceResultNone,
+		interfaces.WriteResourceResultUpdated,
+	}, results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgreSQLWriteResourcesFailure(t *testing.T) {
+	jobData, err := os.ReadFile("../testdata/job.json")
+	assert.NoError(t, err)
+	job, err := models.UnstructuredFromByteSlice(jobData)
+	assert.NoError(t, err)
+
+	database := NewPostgreSQLDatabase()
+	db, mock := NewMock()
+	database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO resource").WillReturnError(errors.New("error writing to the database"))
+	mock.ExpectRollback()
+
+	results, err := database.WriteResources(context.Background(),
+		[]interfaces.ResourceWrite{{Object: job, Data: jobData, LastUpdated: time.Now()}})
+	assert.ErrorContains(t, err, "error writing to the database")
+	assert.Nil(t, results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgreSQLProcessedEvents(t *testing.T) {
+	database := NewPostgreSQLDatabase()
+	db, mock := NewMock()
+	database.setConn(sqlx.NewDb(db, "sqlmock"))
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT COUNT(*) FROM processed_event WHERE (source = $1 AND event_id = $2)",
+	)).WithArgs("test", "event-1").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	processed, err := database.IsEventProcessed(ctx, "test", "event-1")
+	assert.NoError(t, err)
+	assert.True(t, processed)
+
+	mock.ExpectExec(regexp.QuoteMeta(
+		"INSERT INTO processed_event (source, event_id) VALUES ($1, $2) ON CONFLICT(source, event_id) DO NOTHING",
+	)).WithArgs("test", "event-1").WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.NoError(t, database.WriteProcessedEvent(ctx, "test", "event-1"))
+
+	before := time.Now()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM processed_event WHERE processed_at < $1")).
+		WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 3))
+	deleted, err := database.DeleteProcessedEvents(ctx, before)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
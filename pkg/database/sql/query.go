@@ -32,3 +32,25 @@ func (q queryPerformer[T]) performQuery(ctx context.Context, builder sqlbuilder.
 	err := sqlx.SelectContext(ctx, q.querier, &res, query, args...)
 	return res, err
 }
+
+// performStreamQuery calls fn with each row as it is read from the database, so the result set
+// does not need to fit in memory. It stops at the first error returned by fn
+func (q queryPerformer[T]) performStreamQuery(ctx context.Context, builder sqlbuilder.Builder, fn func(T) error) error {
+	query, args := builder.BuildWithFlavor(q.flavor)
+	rows, err := q.querier.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t T
+		if err = rows.StructScan(&t); err != nil {
+			return err
+		}
+		if err = fn(t); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
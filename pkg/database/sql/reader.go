@@ -21,8 +21,19 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
-func (db *sqlDatabaseImpl) QueryResourceByUID(ctx context.Context, kind, apiVersion, namespace, uid string) (*models.Resource, error) {
-	sb := db.selector.ResourceSelector()
+// resourceSelector selects the resources as they were at asOf or, when asOf is nil, as they were last archived
+func (db *sqlDatabaseImpl) resourceSelector(asOf *time.Time) *sqlbuilder.SelectBuilder {
+	if asOf == nil {
+		return db.selector.ResourceSelector()
+	}
+	sb := db.selector.ResourceAsOfSelector(*asOf)
+	sb.Where(db.filter.ExistingAtFilter(sb.Cond, *asOf))
+	return sb
+}
+
+func (db *sqlDatabaseImpl) QueryResourceByUID(ctx context.Context, kind, apiVersion, namespace, uid string,
+	asOf *time.Time) (*models.Resource, error) {
+	sb := db.resourceSelector(asOf)
 	sb.Where(
 		db.filter.KindApiVersionFilter(sb.Cond, kind, apiVersion),
 		db.filter.NamespaceFilter(sb.Cond, namespace),
@@ -43,9 +54,9 @@ func (db *sqlDatabaseImpl) QueryResourceByUID(ctx context.Context, kind, apiVers
 }
 
 func (db *sqlDatabaseImpl) QueryResources(ctx context.Context, kind, apiVersion, namespace, name,
-	continueId, continueDate string, labelFilters *models.LabelFilters,
-	creationTimestampAfter, creationTimestampBefore *time.Time, limit int) ([]models.Resource, error) {
-	sb := db.selector.ResourceSelector()
+	continueId, continueDate string, labelFilters *models.LabelFilters, fieldFilters *models.FieldFilters,
+	creationTimestampAfter, creationTimestampBefore, asOf *time.Time, limit int) ([]models.Resource, error) {
+	sb := db.resourceSelector(asOf)
 	sb.Where(db.filter.KindApiVersionFilter(sb.Cond, kind, apiVersion))
 	if namespace != "" {
 		sb.Where(db.filter.NamespaceFilter(sb.Cond, namespace))
@@ -67,34 +78,164 @@ func (db *sqlDatabaseImpl) QueryResources(ctx context.Context, kind, apiVersion,
 		if continueId != "" && continueDate != "" {
 			sb.Where(db.filter.CreationTSAndIDFilter(sb.Cond, continueDate, continueId))
 		}
-		if creationTimestampAfter != nil {
-			sb.Where(db.filter.CreationTimestampAfterFilter(sb.Cond, *creationTimestampAfter))
-		}
-		if creationTimestampBefore != nil {
-			sb.Where(db.filter.CreationTimestampBeforeFilter(sb.Cond, *creationTimestampBefore))
-		}
-		if labelFilters.Exists != nil {
-			sb.Where(db.filter.ExistsLabelFilter(sb.Cond, labelFilters.Exists, mainWhereClause))
-		}
-		if labelFilters.NotExists != nil {
-			sb.Where(db.filter.NotExistsLabelFilter(sb.Cond, labelFilters.NotExists, mainWhereClause))
-		}
-		if labelFilters.Equals != nil {
-			sb.Where(db.filter.EqualsLabelFilter(sb.Cond, labelFilters.Equals, mainWhereClause))
+		db.addListFilters(sb, mainWhereClause, labelFilters, fieldFilters, creationTimestampAfter, creationTimestampBefore)
+		sb = db.sorter.CreationTSAndIDSorter(sb)
+		sb.Limit(limit)
+	}
+	return db.performResourceQuery(ctx, sb)
+}
+
+// StreamResources calls fn with every resource that matches the filters, sorted as QueryResources
+// does. The resources are read through a database cursor instead of pages, so exporting a whole
+// collection does not load it in memory. It stops at the first error returned by fn
+func (db *sqlDatabaseImpl) StreamResources(ctx context.Context, kind, apiVersion, namespace, name string,
+	labelFilters *models.LabelFilters, fieldFilters *models.FieldFilters,
+	creationTimestampAfter, creationTimestampBefore, asOf *time.Time, fn func(models.Resource) error) error {
+	sb := db.resourceSelector(asOf)
+	sb.Where(db.filter.KindApiVersionFilter(sb.Cond, kind, apiVersion))
+	if namespace != "" {
+		sb.Where(db.filter.NamespaceFilter(sb.Cond, namespace))
+	}
+	mainWhereClause := sqlbuilder.CopyWhereClause(sb.WhereClause)
+
+	if name != "" {
+		if strings.Contains(name, "*") {
+			sb.Where(db.filter.NameWildcardFilter(sb.Cond, strings.ReplaceAll(name, "*", "%")))
+		} else {
+			sb.Where(db.filter.NameFilter(sb.Cond, name))
 		}
-		if labelFilters.NotEquals != nil {
-			sb.Where(db.filter.NotEqualsLabelFilter(sb.Cond, labelFilters.NotEquals, mainWhereClause))
+	}
+	db.addListFilters(sb, mainWhereClause, labelFilters, fieldFilters, creationTimestampAfter, creationTimestampBefore)
+	sb = db.sorter.CreationTSAndIDSorter(sb)
+
+	return newQueryPerformer[models.Resource](db.db, db.flavor).performStreamQuery(ctx, sb, fn)
+}
+
+// QueryResourceChanges returns the resources archived, updated or deleted after the change of the resource with
+// `changedAfterId` at `changedAfter`, sorted from oldest to newest change. It accepts the same filters as
+// QueryResources
+func (db *sqlDatabaseImpl) QueryResourceChanges(ctx context.Context, kind, apiVersion, namespace, name string,
+	labelFilters *models.LabelFilters, fieldFilters *models.FieldFilters,
+	creationTimestampAfter, creationTimestampBefore *time.Time,
+	changedAfter time.Time, changedAfterId int64, limit int) ([]models.ResourceChange, error) {
+	sb := db.selector.ResourceChangeSelector()
+	sb.Where(db.filter.KindApiVersionFilter(sb.Cond, kind, apiVersion))
+	if namespace != "" {
+		sb.Where(db.filter.NamespaceFilter(sb.Cond, namespace))
+	}
+	mainWhereClause := sqlbuilder.CopyWhereClause(sb.WhereClause)
+
+	if name != "" {
+		if strings.Contains(name, "*") {
+			sb.Where(db.filter.NameWildcardFilter(sb.Cond, strings.ReplaceAll(name, "*", "%")))
+		} else {
+			sb.Where(db.filter.NameFilter(sb.Cond, name))
 		}
-		if labelFilters.In != nil {
-			sb.Where(db.filter.InLabelFilter(sb.Cond, labelFilters.In, mainWhereClause))
+	}
+	sb.Where(db.filter.UpdatedAfterFilter(sb.Cond, changedAfter, changedAfterId))
+	db.addListFilters(sb, mainWhereClause, labelFilters, fieldFilters, creationTimestampAfter, creationTimestampBefore)
+	sb = db.sorter.UpdatedTSAndIDSorter(sb)
+	sb.Limit(limit)
+
+	changes, err := newQueryPerformer[models.ResourceChange](db.db, db.flavor).performQuery(ctx, sb)
+	if err != nil {
+		return []models.ResourceChange{}, err
+	}
+	return changes, nil
+}
+
+// QueryCurrentTime returns the time of the database, which is the clock of the updated_at of the changes returned
+// by QueryResourceChanges
+func (db *sqlDatabaseImpl) QueryCurrentTime(ctx context.Context) (time.Time, error) {
+	sb := db.selector.CurrentTimeSelector()
+	now, err := newQueryPerformer[string](db.db, db.flavor).performSingleRowQuery(ctx, sb)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, now)
+}
+
+// QueryResourceCounts returns the number of resources archived by kind, apiVersion and namespace and,
+// when labelKey is not empty, by the value of that label. It accepts the same timestamp and label
+// filters as QueryResources
+func (db *sqlDatabaseImpl) QueryResourceCounts(ctx context.Context, namespace, labelKey string,
+	labelFilters *models.LabelFilters, creationTimestampAfter, creationTimestampBefore *time.Time,
+) ([]models.ResourceCount, error) {
+	sb := db.selector.ResourceCountSelector(labelKey)
+	mainWhereClause := sqlbuilder.NewWhereClause()
+	if namespace != "" {
+		sb.Where(db.filter.NamespaceFilter(sb.Cond, namespace))
+		mainWhereClause = sqlbuilder.CopyWhereClause(sb.WhereClause)
+	}
+	db.addListFilters(sb, mainWhereClause, labelFilters, nil, creationTimestampAfter, creationTimestampBefore)
+
+	counts, err := newQueryPerformer[models.ResourceCount](db.db, db.flavor).performQuery(ctx, sb)
+	if err != nil {
+		return []models.ResourceCount{}, err
+	}
+	return counts, nil
+}
+
+// QueryArchivedKinds returns the kinds and apiVersions with resources in the archive
+func (db *sqlDatabaseImpl) QueryArchivedKinds(ctx context.Context) ([]models.ArchivedKind, error) {
+	sb := db.selector.ArchivedKindSelector()
+	kinds, err := newQueryPerformer[models.ArchivedKind](db.db, db.flavor).performQuery(ctx, sb)
+	if err != nil {
+		return []models.ArchivedKind{}, err
+	}
+	return kinds, nil
+}
+
+// QueryResourceDefinitions returns the definitions stored for the custom resources of the group
+func (db *sqlDatabaseImpl) QueryResourceDefinitions(ctx context.Context, group string) ([]models.ResourceDefinition, error) {
+	sb := db.selector.ResourceDefinitionSelector()
+	sb.Where(db.filter.APIGroupFilter(sb.Cond, group))
+	definitions, err := newQueryPerformer[models.ResourceDefinition](db.db, db.flavor).performQuery(ctx, sb)
+	if err != nil {
+		return []models.ResourceDefinition{}, err
+	}
+	return definitions, nil
+}
+
+// addListFilters adds the timestamp, field and label filters shared by the queries that return collections
+func (db *sqlDatabaseImpl) addListFilters(sb *sqlbuilder.SelectBuilder, mainWhereClause *sqlbuilder.WhereClause,
+	labelFilters *models.LabelFilters, fieldFilters *models.FieldFilters,
+	creationTimestampAfter, creationTimestampBefore *time.Time) {
+	if creationTimestampAfter != nil {
+		sb.Where(db.filter.CreationTimestampAfterFilter(sb.Cond, *creationTimestampAfter))
+	}
+	if creationTimestampBefore != nil {
+		sb.Where(db.filter.CreationTimestampBeforeFilter(sb.Cond, *creationTimestampBefore))
+	}
+	if fieldFilters != nil {
+		for _, field := range fieldFilters.Equals {
+			sb.Where(db.filter.EqualsFieldFilter(sb.Cond, field.Path, field.Value))
 		}
-		if labelFilters.NotIn != nil {
-			sb.Where(db.filter.NotInLabelFilter(sb.Cond, labelFilters.NotIn, mainWhereClause))
+		for _, field := range fieldFilters.NotEquals {
+			sb.Where(db.filter.NotEqualsFieldFilter(sb.Cond, field.Path, field.Value))
 		}
-		sb = db.sorter.CreationTSAndIDSorter(sb)
-		sb.Limit(limit)
 	}
-	return db.performResourceQuery(ctx, sb)
+	if labelFilters == nil {
+		return
+	}
+	if labelFilters.Exists != nil {
+		sb.Where(db.filter.ExistsLabelFilter(sb.Cond, labelFilters.Exists, mainWhereClause))
+	}
+	if labelFilters.NotExists != nil {
+		sb.Where(db.filter.NotExistsLabelFilter(sb.Cond, labelFilters.NotExists, mainWhereClause))
+	}
+	if labelFilters.Equals != nil {
+		sb.Where(db.filter.EqualsLabelFilter(sb.Cond, labelFilters.Equals, mainWhereClause))
+	}
+	if labelFilters.NotEquals != nil {
+		sb.Where(db.filter.NotEqualsLabelFilter(sb.Cond, labelFilters.NotEquals, mainWhereClause))
+	}
+	if labelFilters.In != nil {
+		sb.Where(db.filter.InLabelFilter(sb.Cond, labelFilters.In, mainWhereClause))
+	}
+	if labelFilters.NotIn != nil {
+		sb.Where(db.filter.NotInLabelFilter(sb.Cond, labelFilters.NotIn, mainWhereClause))
+	}
 }
 
 type uuidKindDate struct {
@@ -111,15 +252,10 @@ func (db *sqlDatabaseImpl) getLogsForPodSelector(ctx context.Context, sb *sqlbui
 	}
 	logQueryPerformer := newQueryPerformer[logURLJsonPath](db.db, db.flavor)
 
-	resources, err := db.performResourceQuery(ctx, sb)
+	resource, err := db.getPodForSelector(ctx, sb, namespace, name)
 	if err != nil {
-		return "", "", fmt.Errorf("could not retrieve resource '%s/%s': %s", namespace, name, err.Error())
-	}
-
-	if len(resources) == 0 {
-		return "", "", dbErrors.ErrResourceNotFound
+		return "", "", err
 	}
-	resource := resources[0]
 
 	if containerName == "" {
 		var pod corev1.Pod
@@ -163,6 +299,129 @@ func (db *sqlDatabaseImpl) getLogsForPodSelector(ctx context.Context, sb *sqlbui
 	}
 }
 
+// getPodForSelector returns the first Pod selected by the selector builder
+func (db *sqlDatabaseImpl) getPodForSelector(ctx context.Context, sb *sqlbuilder.SelectBuilder, namespace, name string) (models.Resource, error) {
+	resources, err := db.performResourceQuery(ctx, sb)
+	if err != nil {
+		return models.Resource{}, fmt.Errorf("could not retrieve resource '%s/%s': %s", namespace, name, err.Error())
+	}
+
+	if len(resources) == 0 {
+		return models.Resource{}, dbErrors.ErrResourceNotFound
+	}
+	return resources[0], nil
+}
+
+// Returns the log urls of all the containers of the Pod selected by the selector builder, init containers
+// first, in the order they are defined in the Pod
+func (db *sqlDatabaseImpl) getAllLogsForPodSelector(ctx context.Context, sb *sqlbuilder.SelectBuilder, namespace, name string) ([]models.ContainerLogURL, error) {
+	resource, err := db.getPodForSelector(ctx, sb, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var pod corev1.Pod
+	if err = json.Unmarshal([]byte(resource.Data), &pod); err != nil {
+		return nil, fmt.Errorf("failed to deserialize pod '%s/%s': %s", namespace, name, err.Error())
+	}
+
+	sb = db.selector.ContainerUrlSelector()
+	sb.Where(db.filter.UuidFilter(sb.Cond, resource.Uuid))
+	logUrls, err := newQueryPerformer[models.ContainerLogURL](db.db, db.flavor).performQuery(ctx, sb)
+	if err != nil {
+		return nil, err
+	}
+	if len(logUrls) == 0 {
+		return nil, dbErrors.ErrResourceNotFound
+	}
+
+	sortContainerLogURLs(logUrls, pod)
+	return logUrls, nil
+}
+
+// podLogURL is the log url of a container along with the uuid of its Pod
+type podLogURL struct {
+	Uuid string `db:"uuid"`
+	models.ContainerLogURL
+}
+
+// QueryLogURLsByUIDs returns the log urls of all the containers of the Pods with the uids in the namespace, in
+// the order of uids and, for each Pod, in the order QueryLogURLsByUID returns them. The Pods and their log urls
+// are queried once for all the uids. The Pods without log urls are left out
+func (db *sqlDatabaseImpl) QueryLogURLsByUIDs(ctx context.Context, namespace string, uids []string) ([]models.ContainerLogURL, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	sb := db.selector.ResourceSelector()
+	sb.Where(
+		db.filter.KindApiVersionFilter(sb.Cond, "Pod", "v1"),
+		db.filter.NamespaceFilter(sb.Cond, namespace),
+		db.filter.UuidsFilter(sb.Cond, uids),
+	)
+	resources, err := db.performResourceQuery(ctx, sb)
+	if err != nil {
+		return nil, err
+	}
+	pods := make(map[string]corev1.Pod, len(resources))
+	for _, resource := range resources {
+		var pod corev1.Pod
+		if err = json.Unmarshal([]byte(resource.Data), &pod); err != nil {
+			return nil, fmt.Errorf("failed to deserialize pod '%s/%s': %s", namespace, resource.Uuid, err.Error())
+		}
+		pods[resource.Uuid] = pod
+	}
+
+	sb = db.selector.PodContainerUrlSelector()
+	sb.Where(db.filter.UuidsFilter(sb.Cond, uids))
+	rows, err := newQueryPerformer[podLogURL](db.db, db.flavor).performQuery(ctx, sb)
+	if err != nil {
+		return nil, err
+	}
+	podLogUrls := map[string][]models.ContainerLogURL{}
+	for _, row := range rows {
+		podLogUrls[row.Uuid] = append(podLogUrls[row.Uuid], row.ContainerLogURL)
+	}
+
+	var logUrls []models.ContainerLogURL
+	for _, uid := range uids {
+		pod, found := pods[uid]
+		if !found || len(podLogUrls[uid]) == 0 {
+			continue
+		}
+		sortContainerLogURLs(podLogUrls[uid], pod)
+		logUrls = append(logUrls, podLogUrls[uid]...)
+	}
+	return logUrls, nil
+}
+
+// sortContainerLogURLs sorts the log urls of the containers of the Pod, init containers first, in the order they
+// are defined in the Pod, and sets their PodName
+func sortContainerLogURLs(logUrls []models.ContainerLogURL, pod corev1.Pod) {
+	order := map[string]int{}
+	for _, container := range pod.Spec.InitContainers {
+		order[container.Name] = len(order)
+	}
+	for _, container := range pod.Spec.Containers {
+		order[container.Name] = len(order)
+	}
+	containerOrder := func(name string) int {
+		if position, ok := order[name]; ok {
+			return position
+		}
+		// Containers not in the Pod definition, like ephemeral containers, go last
+		return len(order)
+	}
+	slices.SortStableFunc(logUrls, func(a, b models.ContainerLogURL) int {
+		if byOrder := containerOrder(a.ContainerName) - containerOrder(b.ContainerName); byOrder != 0 {
+			return byOrder
+		}
+		return strings.Compare(a.ContainerName, b.ContainerName)
+	})
+	for i := range logUrls {
+		logUrls[i].PodName = pod.Name
+	}
+}
+
 func (db *sqlDatabaseImpl) QueryLogURLByUID(ctx context.Context, kind, apiVersion, namespace, uid, containerName string) (string, string, error) {
 	return db.queryLogURL(ctx, kind, apiVersion, namespace, uid, containerName, true)
 }
@@ -171,7 +430,35 @@ func (db *sqlDatabaseImpl) QueryLogURLByName(ctx context.Context, kind, apiVersi
 	return db.queryLogURL(ctx, kind, apiVersion, namespace, name, containerName, false)
 }
 
+// QueryLogURLsByUID returns the log urls of all the containers of the Pod, or of the newest Pod owned by the resource
+func (db *sqlDatabaseImpl) QueryLogURLsByUID(ctx context.Context, kind, apiVersion, namespace, uid string) ([]models.ContainerLogURL, error) {
+	sb, identifier, err := db.logPodSelector(ctx, kind, apiVersion, namespace, uid, true)
+	if err != nil {
+		return nil, err
+	}
+	return db.getAllLogsForPodSelector(ctx, sb, namespace, identifier)
+}
+
+// QueryLogURLsByName returns the log urls of all the containers of the Pod, or of the newest Pod owned by the resource
+func (db *sqlDatabaseImpl) QueryLogURLsByName(ctx context.Context, kind, apiVersion, namespace, name string) ([]models.ContainerLogURL, error) {
+	sb, identifier, err := db.logPodSelector(ctx, kind, apiVersion, namespace, name, false)
+	if err != nil {
+		return nil, err
+	}
+	return db.getAllLogsForPodSelector(ctx, sb, namespace, identifier)
+}
+
 func (db *sqlDatabaseImpl) queryLogURL(ctx context.Context, kind, apiVersion, namespace, identifier, containerName string, useUID bool) (string, string, error) {
+	sb, identifier, err := db.logPodSelector(ctx, kind, apiVersion, namespace, identifier, useUID)
+	if err != nil {
+		return "", "", err
+	}
+	return db.getLogsForPodSelector(ctx, sb, namespace, identifier, containerName)
+}
+
+// logPodSelector returns the selector builder of the Pod with the logs of the resource, and its identifier.
+// For resources other than Pods it is the newest Pod owned by the resource, directly or indirectly
+func (db *sqlDatabaseImpl) logPodSelector(ctx context.Context, kind, apiVersion, namespace, identifier string, useUID bool) (*sqlbuilder.SelectBuilder, string, error) {
 	if kind == "Pod" {
 		sb := db.selector.ResourceSelector()
 		sb = db.sorter.CreationTSAndIDSorter(sb) // If resources are named the same, select the newest
@@ -186,7 +473,7 @@ func (db *sqlDatabaseImpl) queryLogURL(ctx context.Context, kind, apiVersion, na
 			sb.Where(db.filter.NameFilter(sb.Cond, identifier))
 		}
 
-		return db.getLogsForPodSelector(ctx, sb, namespace, identifier, containerName)
+		return sb, identifier, nil
 	}
 
 	sb := db.selector.UUIDResourceSelector()
@@ -205,10 +492,10 @@ func (db *sqlDatabaseImpl) queryLogURL(ctx context.Context, kind, apiVersion, na
 	strQueryPerformer := newQueryPerformer[string](db.db, db.flavor)
 	uuid, err := strQueryPerformer.performSingleRowQuery(ctx, sb)
 	if errors.Is(err, sql.ErrNoRows) {
-		return "", "", dbErrors.ErrResourceNotFound
+		return nil, "", dbErrors.ErrResourceNotFound
 	}
 	if err != nil {
-		return "", "", err
+		return nil, "", err
 	}
 
 	slog.DebugContext(
@@ -222,10 +509,10 @@ func (db *sqlDatabaseImpl) queryLogURL(ctx context.Context, kind, apiVersion, na
 
 	pods, err := db.getOwnedPodsUuids(ctx, []string{uuid}, []uuidKindDate{})
 	if err != nil {
-		return "", "", err
+		return nil, "", err
 	}
 	if len(pods) == 0 {
-		return "", "", dbErrors.ErrResourceNotFound
+		return nil, "", dbErrors.ErrResourceNotFound
 	}
 
 	slices.SortFunc(pods, func(a, b uuidKindDate) int {
@@ -235,7 +522,41 @@ func (db *sqlDatabaseImpl) queryLogURL(ctx context.Context, kind, apiVersion, na
 	sb = db.selector.ResourceSelector()
 	sb.Where(db.filter.UuidFilter(sb.Cond, pods[0].Uuid))
 
-	return db.getLogsForPodSelector(ctx, sb, namespace, uuid, containerName)
+	return sb, uuid, nil
+}
+
+// QueryResourceRevisions returns the revisions stored for the resource, oldest first
+func (db *sqlDatabaseImpl) QueryResourceRevisions(ctx context.Context, uid string) ([]models.ResourceRevision, error) {
+	sb := db.selector.ResourceRevisionSelector()
+	sb.Where(db.filter.UuidFilter(sb.Cond, uid))
+	sb = db.sorter.RevisionSorter(sb)
+	return newQueryPerformer[models.ResourceRevision](db.db, db.flavor).performQuery(ctx, sb)
+}
+
+// QueryResourceRevision returns the resource as it was archived in the given revision
+func (db *sqlDatabaseImpl) QueryResourceRevision(ctx context.Context, uid string, revision int64) (string, error) {
+	sb := db.selector.ResourceRevisionDataSelector()
+	sb.Where(
+		db.filter.UuidFilter(sb.Cond, uid),
+		db.filter.RevisionFilter(sb.Cond, revision),
+	)
+
+	data, err := newQueryPerformer[string](db.db, db.flavor).performSingleRowQuery(ctx, sb)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", dbErrors.ErrResourceNotFound
+	}
+	return data, err
+}
+
+// QueryOwnedResources returns the resources that have any of the given uuids in their ownerReferences
+func (db *sqlDatabaseImpl) QueryOwnedResources(ctx context.Context, ownersUuids []string) ([]models.Resource, error) {
+	if len(ownersUuids) == 0 {
+		return []models.Resource{}, nil
+	}
+
+	sb := db.selector.ResourceSelector()
+	sb.Where(db.filter.OwnerFilter(sb.Cond, ownersUuids))
+	return db.performResourceQuery(ctx, sb)
 }
 
 func (db *sqlDatabaseImpl) getOwnedPodsUuids(ctx context.Context, ownersUuids []string, podUuids []uuidKindDate,
@@ -7,6 +7,7 @@ import (
 	"context"
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -19,6 +20,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/huandu/go-sqlbuilder"
 	"github.com/jmoiron/sqlx"
+	dbErrors "github.com/kubearchive/kubearchive/pkg/database/errors"
 	"github.com/kubearchive/kubearchive/pkg/models"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
@@ -261,7 +263,7 @@ func TestQueryResourcesWithoutNamespace(t *testing.T) {
 					defer cancel()
 
 					resources, err := tt.database.QueryResources(
-						ctx, podKind, version, "", "", "", "", &models.LabelFilters{}, nil, nil, 100,
+						ctx, podKind, version, "", "", "", "", &models.LabelFilters{}, &models.FieldFilters{}, nil, nil, nil, 100,
 					)
 
 					if ttt.numResources == 0 {
@@ -305,7 +307,7 @@ func TestQueryResources(t *testing.T) {
 					defer cancel()
 
 					resources, err := tt.database.QueryResources(ctx, podKind, version, namespace,
-						"", "", "", &models.LabelFilters{}, nil, nil, 100)
+						"", "", "", &models.LabelFilters{}, &models.FieldFilters{}, nil, nil, nil, 100)
 					if ttt.numResources == 0 {
 						assert.Nil(t, resources)
 					} else {
@@ -485,7 +487,7 @@ func TestQueryResourcesWithLabelFilters(t *testing.T) {
 
 				resources, err := tt.database.QueryResources(ctx, podKind, version,
 					"", "", "", "",
-					&ttt.labelFilters, nil, nil, 100,
+					&ttt.labelFilters, &models.FieldFilters{}, nil, nil, nil, 100,
 				)
 				assert.NotNil(t, resources)
 				assert.NoError(t, err)
@@ -521,7 +523,7 @@ func TestQueryNamespacedResourceByName(t *testing.T) {
 					defer cancel()
 
 					resources, err := tt.database.QueryResources(ctx, kind, version, namespace, podName,
-						"", "", &models.LabelFilters{}, nil, nil, 100)
+						"", "", &models.LabelFilters{}, &models.FieldFilters{}, nil, nil, nil, 100)
 					if ttt.numResources == 0 {
 						assert.Empty(t, resources)
 					} else {
@@ -699,7 +701,7 @@ func TestQueryResourcesWithWildcardName(t *testing.T) {
 					defer cancel()
 
 					resources, err := tt.database.QueryResources(ctx, podKind, podApiVersion, namespace,
-						wt.namePattern, "", "", &models.LabelFilters{}, nil, nil, 100)
+						wt.namePattern, "", "", &models.LabelFilters{}, &models.FieldFilters{}, nil, nil, nil, 100)
 
 					assert.NoError(t, err)
 					assert.Equal(t, 1, len(resources))
@@ -790,7 +792,7 @@ func TestQueryResourcesWithTimestampFilters(t *testing.T) {
 				defer cancel()
 
 				resources, err := tt.database.QueryResources(ctx, podKind, podApiVersion, "", "",
-					"", "", &models.LabelFilters{}, timestampTest.creationTimestampAfter, timestampTest.creationTimestampBefore, 100)
+					"", "", &models.LabelFilters{}, &models.FieldFilters{}, timestampTest.creationTimestampAfter, timestampTest.creationTimestampBefore, nil, 100)
 				assert.NotNil(t, resources)
 				assert.NoError(t, err)
 			})
@@ -862,7 +864,7 @@ func TestTimestampFilterWithLabelFilters(t *testing.T) {
 			defer cancel()
 
 			resources, err := tt.database.QueryResources(ctx, podKind, podApiVersion, "", "",
-				"", "", labelFilters, &testTime, nil, 100)
+				"", "", labelFilters, &models.FieldFilters{}, &testTime, nil, nil, 100)
 			assert.NotNil(t, resources)
 			assert.NoError(t, err)
 		})
@@ -896,7 +898,7 @@ func TestQueryResourceByUID(t *testing.T) {
 					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 					defer cancel()
 
-					resource, err := tt.database.QueryResourceByUID(ctx, podKind, podApiVersion, namespace, podUUID)
+					resource, err := tt.database.QueryResourceByUID(ctx, podKind, podApiVersion, namespace, podUUID, nil)
 					if ttt.numResources == 0 {
 						assert.Nil(t, resource)
 					} else {
@@ -909,3 +911,531 @@ func TestQueryResourceByUID(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryOwnedResources(t *testing.T) {
+	owners := []string{"mock-uuid-deployment", "mock-uuid-replicaset"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.database.getFilter()
+			sb := tt.database.getSelector().ResourceSelector()
+			sb.Where(filter.OwnerFilter(sb.Cond, owners))
+			query, args := sb.BuildWithFlavor(tt.database.getFlavor())
+
+			for _, ttt := range subtests {
+				t.Run(ttt.name, func(t *testing.T) {
+					db, mock := NewMock()
+					tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+					rows := sqlmock.NewRows(resourceQueryColumns)
+					if ttt.data {
+						rows.AddRow("2025-10-29T15:07:00Z", 1, json.RawMessage(testPodResource))
+					}
+					mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).WillReturnRows(rows)
+
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+
+					resources, err := tt.database.QueryOwnedResources(ctx, owners)
+					assert.NoError(t, err)
+					assert.Equal(t, ttt.numResources, len(resources))
+					assert.NoError(t, mock.ExpectationsWereMet())
+				})
+			}
+
+			// No owners means no query
+			resources, err := tt.database.QueryOwnedResources(context.Background(), []string{})
+			assert.NoError(t, err)
+			assert.Empty(t, resources)
+		})
+	}
+}
+
+func TestQueryResourceChanges(t *testing.T) {
+	changedAfter := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	labelFilters := &models.LabelFilters{
+		Equals: map[string]string{"app": "frontend"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.database.getFilter()
+			sb := tt.database.getSelector().ResourceChangeSelector()
+			sb.Where(
+				filter.KindApiVersionFilter(sb.Cond, podKind, podApiVersion),
+				filter.NamespaceFilter(sb.Cond, namespace),
+				filter.UpdatedAfterFilter(sb.Cond, changedAfter, 1),
+				filter.EqualsLabelFilter(sb.Cond, labelFilters.Equals, nil),
+			)
+			sb = tt.database.getSorter().UpdatedTSAndIDSorter(sb)
+			sb.Limit(limit)
+			query, args := sb.BuildWithFlavor(tt.database.getFlavor())
+			assert.Contains(t, query, "(updated_at, id) >")
+
+			for _, ttt := range subtests {
+				t.Run(ttt.name, func(t *testing.T) {
+					db, mock := NewMock()
+					tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+					rows := sqlmock.NewRows([]string{"created_at", "id", "uuid", "data", "archived_at", "updated_at", "deleted"})
+					if ttt.data {
+						rows.AddRow("2024-04-05T09:58:03Z", 1, "42422d92-1a72-418d-97cf-97019c2d56e8",
+							json.RawMessage(testPodResource), "2025-01-01T11:00:00.000000Z", "2025-01-01T12:00:01.000000Z", true)
+					}
+					mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).WillReturnRows(rows)
+
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+
+					changes, err := tt.database.QueryResourceChanges(ctx, podKind, podApiVersion, namespace, "",
+						labelFilters, &models.FieldFilters{}, nil, nil, changedAfter, 1, limit)
+					assert.NoError(t, err)
+					assert.Equal(t, ttt.numResources, len(changes))
+					if ttt.data {
+						assert.Equal(t, "2025-01-01T12:00:01.000000Z", changes[0].UpdatedAt)
+						assert.True(t, changes[0].Deleted)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestQueryCurrentTime(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := NewMock()
+			tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+			query, _ := tt.database.getSelector().CurrentTimeSelector().BuildWithFlavor(tt.database.getFlavor())
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(
+				sqlmock.NewRows([]string{"now"}).AddRow("2025-01-01T12:00:01.123456Z"))
+
+			now, err := tt.database.QueryCurrentTime(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, time.Date(2025, 1, 1, 12, 0, 1, 123456000, time.UTC), now)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestQueryResourcesWithFieldFilters(t *testing.T) {
+	fieldFilters := &models.FieldFilters{
+		Equals:    []models.FieldFilter{{Path: []string{"status", "phase"}, Value: "Failed"}},
+		NotEquals: []models.FieldFilter{{Path: []string{"spec", "nodeName"}, Value: ""}},
+	}
+	expectedFilters := map[string][]string{
+		"postgresql": {"COALESCE(data #>> $3, '') = $4", "COALESCE(data #>> $5, '') <> $6"},
+		"mariadb":    {"COALESCE(JSON_VALUE(data, ?), '') = ?", "COALESCE(JSON_VALUE(data, ?), '') <> ?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.database.getFilter()
+			sb := tt.database.getSelector().ResourceSelector()
+			sb.Where(
+				filter.KindApiVersionFilter(sb.Cond, podKind, podApiVersion),
+				filter.EqualsFieldFilter(sb.Cond, fieldFilters.Equals[0].Path, fieldFilters.Equals[0].Value),
+				filter.NotEqualsFieldFilter(sb.Cond, fieldFilters.NotEquals[0].Path, fieldFilters.NotEquals[0].Value),
+			)
+			sb = tt.database.getSorter().CreationTSAndIDSorter(sb)
+			sb.Limit(limit)
+			query, args := sb.BuildWithFlavor(tt.database.getFlavor())
+			for _, expectedFilter := range expectedFilters[tt.name] {
+				assert.Contains(t, query, expectedFilter)
+			}
+			if tt.name == "mariadb" {
+				assert.Contains(t, args, `$."status"."phase"`)
+			}
+
+			db, mock := NewMock()
+			tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+			rows := sqlmock.NewRows(resourceQueryColumns)
+			rows.AddRow("2024-04-05T09:58:03Z", 1, json.RawMessage(testPodResource))
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).WillReturnRows(rows)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			resources, err := tt.database.QueryResources(ctx, podKind, podApiVersion, "", "",
+				"", "", &models.LabelFilters{}, fieldFilters, nil, nil, nil, limit)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(resources))
+		})
+	}
+}
+
+func TestQueryResourceRevisions(t *testing.T) {
+	podUUID := uuid.New().String()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sb := tt.database.getSelector().ResourceRevisionSelector()
+			sb.Where(tt.database.getFilter().UuidFilter(sb.Cond, podUUID))
+			sb = tt.database.getSorter().RevisionSorter(sb)
+			query, _ := sb.BuildWithFlavor(tt.database.getFlavor())
+
+			db, mock := NewMock()
+			tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+			rows := sqlmock.NewRows([]string{"revision", "resource_version", "cluster_updated_ts",
+				"cluster_deleted_ts", "archived_at"}).
+				AddRow(1, "100", "2025-10-29T15:07:00Z", nil, "2025-10-29T15:07:01Z").
+				AddRow(2, "105", "2025-10-29T15:08:00Z", "2025-10-29T15:08:00Z", "2025-10-29T15:08:01Z")
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(podUUID).WillReturnRows(rows)
+
+			revisions, err := tt.database.QueryResourceRevisions(context.Background(), podUUID)
+			assert.NoError(t, err)
+			if assert.Len(t, revisions, 2) {
+				assert.Equal(t, int64(1), revisions[0].Revision)
+				assert.Nil(t, revisions[0].ClusterDeletedTimestamp)
+				assert.Equal(t, "105", revisions[1].ResourceVersion)
+				assert.NotNil(t, revisions[1].ClusterDeletedTimestamp)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestQueryResourceRevision(t *testing.T) {
+	podUUID := uuid.New().String()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.database.getFilter()
+			sb := tt.database.getSelector().ResourceRevisionDataSelector()
+			sb.Where(filter.UuidFilter(sb.Cond, podUUID), filter.RevisionFilter(sb.Cond, 2))
+			query, args := sb.BuildWithFlavor(tt.database.getFlavor())
+
+			for _, ttt := range subtests {
+				t.Run(ttt.name, func(t *testing.T) {
+					db, mock := NewMock()
+					tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+					rows := sqlmock.NewRows([]string{"data"})
+					if ttt.data {
+						rows.AddRow(json.RawMessage(testPodResource))
+					}
+					mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).WillReturnRows(rows)
+
+					data, err := tt.database.QueryResourceRevision(context.Background(), podUUID, 2)
+					if ttt.numResources == 0 {
+						assert.ErrorIs(t, err, dbErrors.ErrResourceNotFound)
+						assert.Empty(t, data)
+					} else {
+						assert.NoError(t, err)
+						assert.NotEmpty(t, data)
+					}
+					assert.NoError(t, mock.ExpectationsWereMet())
+				})
+			}
+		})
+	}
+}
+
+func TestQueryResourcesAsOf(t *testing.T) {
+	asOf := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.database.getFilter()
+			sb := tt.database.getSelector().ResourceAsOfSelector(asOf)
+			sb.Where(
+				filter.ExistingAtFilter(sb.Cond, asOf),
+				filter.KindApiVersionFilter(sb.Cond, podKind, podApiVersion),
+				filter.NamespaceFilter(sb.Cond, namespace),
+			)
+			sb = tt.database.getSorter().CreationTSAndIDSorter(sb)
+			sb.Limit(100)
+			query, args := sb.BuildWithFlavor(tt.database.getFlavor())
+			assert.True(t, strings.HasPrefix(query, "WITH resource AS (SELECT res.id, res.uuid, res.kind, "+
+				"res.api_version, res.namespace, res.name, res.cluster_deleted_ts, rev.data FROM resource res "+
+				"JOIN resource_revision rev ON rev.uuid = res.uuid WHERE rev.revision = (SELECT MAX(last.revision) "+
+				"FROM resource_revision last WHERE last.uuid = res.uuid AND last.cluster_updated_ts <= "), query)
+
+			db, mock := NewMock()
+			tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+			rows := sqlmock.NewRows(resourceQueryColumns).
+				AddRow("2024-12-31T10:00:00Z", 1, json.RawMessage(testPodResource))
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).WillReturnRows(rows)
+
+			resources, err := tt.database.QueryResources(context.Background(), podKind, podApiVersion, namespace,
+				"", "", "", &models.LabelFilters{}, &models.FieldFilters{}, nil, nil, &asOf, 100)
+			assert.NoError(t, err)
+			assert.Len(t, resources, 1)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestQueryResourceByUIDAsOf(t *testing.T) {
+	asOf := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	podUUID := uuid.New().String()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.database.getFilter()
+			sb := tt.database.getSelector().ResourceAsOfSelector(asOf)
+			sb.Where(
+				filter.ExistingAtFilter(sb.Cond, asOf),
+				filter.KindApiVersionFilter(sb.Cond, podKind, podApiVersion),
+				filter.NamespaceFilter(sb.Cond, namespace),
+				filter.UuidFilter(sb.Cond, podUUID),
+			)
+			query, args := sb.BuildWithFlavor(tt.database.getFlavor())
+
+			db, mock := NewMock()
+			tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).
+				WillReturnRows(sqlmock.NewRows(resourceQueryColumns))
+
+			resource, err := tt.database.QueryResourceByUID(context.Background(), podKind, podApiVersion, namespace,
+				podUUID, &asOf)
+			assert.NoError(t, err)
+			assert.Nil(t, resource)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestStreamResources(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.database.getFilter()
+			sb := tt.database.getSelector().ResourceSelector()
+			sb.Where(
+				filter.KindApiVersionFilter(sb.Cond, podKind, podApiVersion),
+				filter.NamespaceFilter(sb.Cond, namespace),
+			)
+			sb = tt.database.getSorter().CreationTSAndIDSorter(sb)
+			query, args := sb.BuildWithFlavor(tt.database.getFlavor())
+			assert.NotContains(t, query, "LIMIT")
+
+			db, mock := NewMock()
+			tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+			rows := sqlmock.NewRows(resourceQueryColumns).
+				AddRow("2024-12-31T10:00:00Z", 1, json.RawMessage(testPodResource)).
+				AddRow("2024-12-31T11:00:00Z", 2, json.RawMessage(testPodResource))
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).WillReturnRows(rows)
+
+			var ids []int64
+			err := tt.database.StreamResources(context.Background(), podKind, podApiVersion, namespace, "",
+				&models.LabelFilters{}, &models.FieldFilters{}, nil, nil, nil, func(resource models.Resource) error {
+					ids = append(ids, resource.Id)
+					return nil
+				})
+			assert.NoError(t, err)
+			assert.Equal(t, []int64{1, 2}, ids)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestStreamResourcesCallbackError(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := NewMock()
+			tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+			rows := sqlmock.NewRows(resourceQueryColumns).
+				AddRow("2024-12-31T10:00:00Z", 1, json.RawMessage(testPodResource)).
+				AddRow("2024-12-31T11:00:00Z", 2, json.RawMessage(testPodResource))
+			mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+			calls := 0
+			expectedErr := errors.New("client disconnected")
+			err := tt.database.StreamResources(context.Background(), podKind, podApiVersion, "", "",
+				&models.LabelFilters{}, &models.FieldFilters{}, nil, nil, nil, func(resource models.Resource) error {
+					calls++
+					return expectedErr
+				})
+			assert.ErrorIs(t, err, expectedErr)
+			assert.Equal(t, 1, calls)
+		})
+	}
+}
+
+func TestQueryResourceCounts(t *testing.T) {
+	after := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	labelFilters := &models.LabelFilters{Exists: []string{"app"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, labelKey := range []string{"", "app"} {
+				t.Run(fmt.Sprintf("labelKey=%s", labelKey), func(t *testing.T) {
+					filter := tt.database.getFilter()
+					sb := tt.database.getSelector().ResourceCountSelector(labelKey)
+					sb.Where(filter.NamespaceFilter(sb.Cond, namespace))
+					mainWhereClause := sqlbuilder.CopyWhereClause(sb.WhereClause)
+					sb.Where(filter.CreationTimestampAfterFilter(sb.Cond, after))
+					sb.Where(filter.ExistsLabelFilter(sb.Cond, labelFilters.Exists, mainWhereClause))
+					query, args := sb.BuildWithFlavor(tt.database.getFlavor())
+					assert.Contains(t, query, "GROUP BY kind, api_version, namespace")
+
+					db, mock := NewMock()
+					tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+					columns := []string{"kind", "api_version", "namespace", "count"}
+					values := []driver.Value{"Pod", "v1", namespace, 3}
+					if labelKey != "" {
+						columns = []string{"kind", "api_version", "namespace", "label_value", "count"}
+						values = []driver.Value{"Pod", "v1", namespace, "frontend", 3}
+					}
+					mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).
+						WillReturnRows(sqlmock.NewRows(columns).AddRow(values...))
+
+					counts, err := tt.database.QueryResourceCounts(context.Background(), namespace, labelKey,
+						labelFilters, &after, nil)
+					assert.NoError(t, err)
+					if assert.Len(t, counts, 1) {
+						assert.Equal(t, int64(3), counts[0].Count)
+						assert.Equal(t, labelKey != "", counts[0].LabelValue != nil)
+					}
+					assert.NoError(t, mock.ExpectationsWereMet())
+				})
+			}
+		})
+	}
+}
+
+func TestQueryArchivedKinds(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := tt.database.getSelector().ArchivedKindSelector().BuildWithFlavor(tt.database.getFlavor())
+			assert.Equal(t, "SELECT DISTINCT kind, api_version FROM resource ORDER BY api_version, kind", query)
+
+			db, mock := NewMock()
+			tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(
+				sqlmock.NewRows([]string{"kind", "api_version"}).
+					AddRow("Job", "batch/v1").
+					AddRow("Pod", "v1"))
+
+			kinds, err := tt.database.QueryArchivedKinds(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, []models.ArchivedKind{
+				{Kind: "Job", APIVersion: "batch/v1"},
+				{Kind: "Pod", APIVersion: "v1"},
+			}, kinds)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestQueryResourceDefinitions(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sb := tt.database.getSelector().ResourceDefinitionSelector()
+			sb.Where(tt.database.getFilter().APIGroupFilter(sb.Cond, "stable.example.com"))
+			query, _ := sb.BuildWithFlavor(tt.database.getFlavor())
+
+			db, mock := NewMock()
+			tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("stable.example.com").WillReturnRows(
+				sqlmock.NewRows([]string{"api_group", "plural", "kind", "data"}).
+					AddRow("stable.example.com", "crontabs", "CronTab", `{"kind":"CustomResourceDefinition"}`))
+
+			definitions, err := tt.database.QueryResourceDefinitions(context.Background(), "stable.example.com")
+			assert.NoError(t, err)
+			assert.Equal(t, []models.ResourceDefinition{{Group: "stable.example.com", Plural: "crontabs",
+				Kind: "CronTab", Data: `{"kind":"CustomResourceDefinition"}`}}, definitions)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestQueryLogURLsByName(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := NewMock()
+			filter := tt.database.getFilter()
+			tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+
+			podBytes, err := os.ReadFile("../testdata/pod-3-containers.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pod corev1.Pod
+			if err = json.Unmarshal(podBytes, &pod); err != nil {
+				t.Fatal(err)
+			}
+			pod.Spec.InitContainers = []corev1.Container{{Name: "prepare"}}
+			newPodBytes, err := json.Marshal(pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Get the pod
+			sb := tt.database.getSelector().ResourceSelector()
+			sb = tt.database.getSorter().CreationTSAndIDSorter(sb)
+			sb.Where(
+				filter.KindApiVersionFilter(sb.Cond, pod.Kind, pod.APIVersion),
+				filter.NamespaceFilter(sb.Cond, pod.Namespace),
+				filter.NameFilter(sb.Cond, pod.Name),
+			)
+			query, args := sb.BuildWithFlavor(tt.database.getFlavor())
+			rows := sqlmock.NewRows([]string{"created_at", "id", "uuid", "data"})
+			rows.AddRow("YYYY-MM-DDTHH:MM:SS+00", "0", string(pod.UID), string(newPodBytes))
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).WillReturnRows(rows)
+
+			// Get the logs of all the containers, in any order
+			sb = tt.database.getSelector().ContainerUrlSelector()
+			sb.Where(filter.UuidFilter(sb.Cond, string(pod.UID)))
+			query, args = sb.BuildWithFlavor(tt.database.getFlavor())
+			rows = sqlmock.NewRows([]string{"container_name", "url", "json_path"})
+			for _, container := range []string{"generate3", "debugger", "generate1", "prepare"} {
+				rows.AddRow(container, "https://logging.example.com/"+container, "$.")
+			}
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).WillReturnRows(rows)
+
+			logURLs, err := tt.database.QueryLogURLsByName(context.Background(), pod.Kind, pod.APIVersion,
+				pod.Namespace, pod.Name)
+			assert.NoError(t, err)
+			containers := make([]string, 0, len(logURLs))
+			for _, logURL := range logURLs {
+				assert.Equal(t, pod.Name, logURL.PodName)
+				assert.Equal(t, "https://logging.example.com/"+logURL.ContainerName, logURL.Url)
+				containers = append(containers, logURL.ContainerName)
+			}
+			// Init containers first, then the containers and last the ones not in the Pod definition
+			assert.Equal(t, []string{"prepare", "generate1", "generate3", "debugger"}, containers)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestQueryLogURLsByUIDs(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podUID := "42422d92-1a72-418d-97cf-97019c2d56e8"
+			missingUID := uuid.New().String()
+			uids := []string{missingUID, podUID}
+
+			db, mock := NewMock()
+			tt.database.setConn(sqlx.NewDb(db, "sqlmock"))
+			filter := tt.database.getFilter()
+			selector := tt.database.getSelector()
+			flavor := tt.database.getFlavor()
+
+			// The Pods and their log urls are queried once
+			sb := selector.ResourceSelector()
+			sb.Where(
+				filter.KindApiVersionFilter(sb.Cond, "Pod", "v1"),
+				filter.NamespaceFilter(sb.Cond, namespace),
+				filter.UuidsFilter(sb.Cond, uids),
+			)
+			query, args := sb.BuildWithFlavor(flavor)
+			rows := sqlmock.NewRows([]string{"created_at", "id", "uuid", "data"})
+			rows.AddRow("YYYY-MM-DDTHH:MM:SS+00", 0, podUID, testPodResource)
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).WillReturnRows(rows)
+
+			sb = selector.PodContainerUrlSelector()
+			sb.Where(filter.UuidsFilter(sb.Cond, uids))
+			query, args = sb.BuildWithFlavor(flavor)
+			rows = sqlmock.NewRows([]string{"uuid", "container_name", "url", "json_path"})
+			rows.AddRow(podUID, "sidecar", "mock-log-url-sidecar", jsonPath)
+			rows.AddRow(podUID, "test-pod", "mock-log-url-test-pod", jsonPath)
+			mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sliceOfAny2sliceOfValue(args)...).WillReturnRows(rows)
+
+			logURLs, err := tt.database.QueryLogURLsByUIDs(context.Background(), namespace, uids)
+			assert.NoError(t, err)
+			assert.Equal(t, []models.ContainerLogURL{
+				{PodName: "test-pod", ContainerName: "test-pod", Url: "mock-log-url-test-pod", JsonPath: jsonPath},
+				{PodName: "test-pod", ContainerName: "sidecar", Url: "mock-log-url-sidecar", JsonPath: jsonPath},
+			}, logURLs)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
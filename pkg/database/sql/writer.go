@@ -4,7 +4,16 @@
 package sql
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/kubearchive/kubearchive/pkg/database/interfaces"
 	"github.com/kubearchive/kubearchive/pkg/database/sql/facade"
+	"github.com/kubearchive/kubearchive/pkg/models"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func (db *sqlDatabaseImpl) getInserter() facade.DBInserter {
@@ -14,3 +23,226 @@ func (db *sqlDatabaseImpl) getInserter() facade.DBInserter {
 func (db *sqlDatabaseImpl) getDeleter() facade.DBDeleter {
 	return db.deleter
 }
+
+// WriteResourceDefinition stores the definition, replacing the one stored for the same group and plural
+func (db *sqlDatabaseImpl) WriteResourceDefinition(ctx context.Context, definition models.ResourceDefinition) error {
+	query, args := db.inserter.ResourceDefinitionInserter(
+		definition.Group, definition.Plural, definition.Kind, []byte(definition.Data),
+	).BuildWithFlavor(db.flavor)
+	if _, err := db.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("could not write the definition of %s.%s: %w", definition.Plural, definition.Group, err)
+	}
+	return nil
+}
+
+// IsEventProcessed returns whether the CloudEvent was recorded with WriteProcessedEvent
+func (db *sqlDatabaseImpl) IsEventProcessed(ctx context.Context, source, id string) (bool, error) {
+	sb := db.selector.ProcessedEventSelector()
+	sb.Where(db.filter.EventFilter(sb.Cond, source, id))
+	count, err := newQueryPerformer[int64](db.db, db.flavor).performSingleRowQuery(ctx, sb)
+	if err != nil {
+		return false, fmt.Errorf("could not query the CloudEvent %s from %s: %w", id, source, err)
+	}
+	return count > 0, nil
+}
+
+// WriteProcessedEvent records that the CloudEvent was processed
+func (db *sqlDatabaseImpl) WriteProcessedEvent(ctx context.Context, source, id string) error {
+	query, args := db.inserter.ProcessedEventInserter(source, id).BuildWithFlavor(db.flavor)
+	if _, err := db.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("could not write the CloudEvent %s from %s: %w", id, source, err)
+	}
+	return nil
+}
+
+// DeleteProcessedEvents deletes the CloudEvents processed before the timestamp and returns how many were deleted
+func (db *sqlDatabaseImpl) DeleteProcessedEvents(ctx context.Context, before time.Time) (int64, error) {
+	delBuilder := db.deleter.ProcessedEventDeleter()
+	delBuilder.Where(db.filter.ProcessedBeforeFilter(delBuilder.Cond, before))
+	query, args := delBuilder.BuildWithFlavor(db.flavor)
+	result, err := db.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("could not delete the processed CloudEvents: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// writeResourceRevision stores the resource as its next revision when the history mode is enabled.
+// It must run in the transaction that writes the resource, as the write locks the resource row
+// and keeps concurrent writes of the same resource from getting the same revision number
+func (db *sqlDatabaseImpl) writeResourceRevision(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	k8sObj *unstructured.Unstructured,
+	data []byte,
+	lastUpdated time.Time,
+) error {
+	if !db.history {
+		return nil
+	}
+
+	uid := string(k8sObj.GetUID())
+	sb := db.selector.LastRevisionSelector()
+	sb.Where(db.filter.UuidFilter(sb.Cond, uid))
+	lastRevision, err := newQueryPerformer[int64](tx, db.flavor).performSingleRowQuery(ctx, sb)
+	if err != nil {
+		return fmt.Errorf("could not retrieve the last revision of resource %s: %w", uid, err)
+	}
+
+	query, args := db.inserter.ResourceRevisionInserter(
+		uid,
+		k8sObj.GetResourceVersion(),
+		lastRevision+1,
+		lastUpdated,
+		models.OptionalTimestamp(k8sObj.GetDeletionTimestamp()),
+		data,
+	).BuildWithFlavor(db.flavor)
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("could not write revision %d of resource %s: %w", lastRevision+1, uid, err)
+	}
+	return nil
+}
+
+// upsertFunc writes the rows with facade.DBInserter.ResourcesInserter and returns the uuids of the resources
+// it inserted or updated, and whether they were inserted
+type upsertFunc func(ctx context.Context, tx *sqlx.Tx, rows []facade.ResourceRow) (map[string]bool, error)
+
+// writeResources writes the resources with a multi-row upsert for each round of writeRounds, the log URLs
+// of the Pods, and the revisions when the history mode is enabled, all in a single transaction
+func (db *sqlDatabaseImpl) writeResources(
+	ctx context.Context,
+	writes []interfaces.ResourceWrite,
+	upsert upsertFunc,
+) ([]interfaces.WriteResourceResult, error) {
+	if len(writes) == 0 {
+		return nil, nil
+	}
+	for _, write := range writes {
+		if write.Object == nil {
+			return nil, errors.New("kubernetes object was 'nil', something went wrong")
+		}
+	}
+
+	tx, err := db.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction for %d resources: %w", len(writes), err)
+	}
+
+	results := make([]interfaces.WriteResourceResult, len(writes))
+	for _, round := range writeRounds(writes) {
+		rows := make([]facade.ResourceRow, 0, len(round))
+		for _, i := range round {
+			obj := writes[i].Object
+			rows = append(rows, facade.ResourceRow{
+				Uuid:             string(obj.GetUID()),
+				ApiVersion:       obj.GetAPIVersion(),
+				Kind:             obj.GetKind(),
+				Name:             obj.GetName(),
+				Namespace:        obj.GetNamespace(),
+				Version:          obj.GetResourceVersion(),
+				ClusterUpdatedTs: writes[i].LastUpdated,
+				ClusterDeletedTs: models.OptionalTimestamp(obj.GetDeletionTimestamp()),
+				Data:             writes[i].Data,
+			})
+		}
+		written, upsertErr := upsert(ctx, tx, rows)
+		if upsertErr != nil {
+			return nil, rollback(tx, fmt.Errorf("write resources to database failed: %w", upsertErr))
+		}
+
+		for _, i := range round {
+			inserted, ok := written[string(writes[i].Object.GetUID())]
+			switch {
+			case !ok:
+				// Stale writes do not change the resource, so they are not a new revision
+				results[i] = interfaces.WriteResourceResultNone
+				continue
+			case inserted:
+				results[i] = interfaces.WriteResourceResultInserted
+			default:
+				results[i] = interfaces.WriteResourceResultUpdated
+			}
+			revisionErr := db.writeResourceRevision(ctx, tx, writes[i].Object, writes[i].Data, writes[i].LastUpdated)
+			if revisionErr != nil {
+				return nil, rollback(tx, revisionErr)
+			}
+		}
+	}
+
+	if err = db.writeLogUrls(ctx, tx, writes); err != nil {
+		return nil, rollback(tx, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, rollback(tx, fmt.Errorf("commit to database failed: %w", err))
+	}
+	return results, nil
+}
+
+// writeRounds splits the indexes of writes in rounds where each resource is written once at most, as a
+// multi-row upsert can not change the same row twice. The writes of the same resource keep their order
+func writeRounds(writes []interfaces.ResourceWrite) [][]int {
+	var rounds [][]int
+	seen := make(map[string]int, len(writes))
+	for i, write := range writes {
+		uid := string(write.Object.GetUID())
+		round := seen[uid]
+		seen[uid] = round + 1
+		if round == len(rounds) {
+			rounds = append(rounds, nil)
+		}
+		rounds[round] = append(rounds[round], i)
+	}
+	return rounds
+}
+
+// writeLogUrls replaces the log URLs of the Pods with the ones of their last write
+func (db *sqlDatabaseImpl) writeLogUrls(ctx context.Context, tx *sqlx.Tx, writes []interfaces.ResourceWrite) error {
+	var uids []string
+	last := make(map[string]interfaces.ResourceWrite)
+	for _, write := range writes {
+		if write.Object.GetKind() != "Pod" {
+			continue
+		}
+		uid := string(write.Object.GetUID())
+		if _, ok := last[uid]; !ok {
+			uids = append(uids, uid)
+		}
+		last[uid] = write
+	}
+	if len(uids) == 0 {
+		return nil
+	}
+
+	delBuilder := db.deleter.UrlDeleter()
+	delBuilder.Where(db.filter.UuidsFilter(delBuilder.Cond, uids))
+	query, args := delBuilder.BuildWithFlavor(db.flavor)
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("delete urls from database failed: %w", err)
+	}
+
+	var rows []facade.UrlRow
+	for _, uid := range uids {
+		for _, log := range last[uid].Logs {
+			rows = append(rows, facade.UrlRow{
+				Uuid: uid, Url: log.Url, ContainerName: log.ContainerName, JsonPath: last[uid].JsonPath,
+			})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	query, args = db.inserter.UrlsInserter(rows).BuildWithFlavor(db.flavor)
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("write urls to database failed: %w", err)
+	}
+	return nil
+}
+
+// rollback rolls back tx after err, adding the rollback error to err if it fails
+func rollback(tx *sqlx.Tx, err error) error {
+	if rollbackErr := tx.Rollback(); rollbackErr != nil {
+		return fmt.Errorf("%w and unable to roll back transaction: %w", err, rollbackErr)
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// fieldPathSegment matches each of the dot separated parts of a field, for example `status` and `phase`
+// in `status.phase`. Field names are restricted because they end up being part of the database query
+var fieldPathSegment = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// FieldFilters encapsulates the database filters for fields in the archived resources
+type FieldFilters struct {
+	Equals    []FieldFilter
+	NotEquals []FieldFilter
+}
+
+// FieldFilter is a single field selector requirement. Path contains the parts of the field,
+// so `status.phase=Failed` is represented as Path ["status", "phase"] and Value "Failed"
+type FieldFilter struct {
+	Path  []string
+	Value string
+}
+
+func NewFieldFilters(fieldRequirements []fields.Requirement) (*FieldFilters, error) {
+	ff := FieldFilters{}
+	for _, r := range fieldRequirements {
+		path := strings.Split(r.Field, ".")
+		for _, segment := range path {
+			if !fieldPathSegment.MatchString(segment) {
+				return nil, fmt.Errorf("unsupported field %q in field selector", r.Field)
+			}
+		}
+
+		switch r.Operator {
+		case selection.Equals, selection.DoubleEquals:
+			ff.Equals = append(ff.Equals, FieldFilter{Path: path, Value: r.Value})
+		case selection.NotEquals:
+			ff.NotEquals = append(ff.NotEquals, FieldFilter{Path: path, Value: r.Value})
+		default:
+			return nil, fmt.Errorf("unsupported field filter %s", r.Operator)
+		}
+	}
+	return &ff, nil
+}
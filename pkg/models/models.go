@@ -44,9 +44,61 @@ type LogTuple struct {
 	Url           string
 }
 
+// ContainerLogURL is the log url of a container of an archived Pod
+type ContainerLogURL struct {
+	PodName       string
+	ContainerName string `db:"container_name"`
+	Url           string `db:"url"`
+	JsonPath      string `db:"json_path"`
+}
+
 type Resource struct {
 	Date string `db:"created_at"`
 	Id   int64  `db:"id"`
 	Uuid string `db:"uuid"`
 	Data string `db:"data"`
 }
+
+// ResourceChange is an archived resource together with the bookkeeping columns
+// needed to turn it into a watch event
+type ResourceChange struct {
+	Resource
+	ArchivedAt string `db:"archived_at"`
+	UpdatedAt  string `db:"updated_at"`
+	Deleted    bool   `db:"deleted"`
+}
+
+// ResourceRevision describes a revision of an archived resource. Revisions are only
+// stored when the history mode of the database is enabled
+type ResourceRevision struct {
+	Revision                int64   `db:"revision" json:"revision"`
+	ResourceVersion         string  `db:"resource_version" json:"resourceVersion"`
+	ClusterUpdatedTimestamp string  `db:"cluster_updated_ts" json:"clusterUpdatedTimestamp"`
+	ClusterDeletedTimestamp *string `db:"cluster_deleted_ts" json:"clusterDeletedTimestamp,omitempty"`
+	ArchivedAt              string  `db:"archived_at" json:"archivedAt"`
+}
+
+// ResourceCount is the number of archived resources of a kind in a namespace. LabelValue is
+// only set when the resources are also grouped by a label and they have that label
+type ResourceCount struct {
+	Kind       string  `db:"kind" json:"kind"`
+	APIVersion string  `db:"api_version" json:"apiVersion"`
+	Namespace  string  `db:"namespace" json:"namespace"`
+	LabelValue *string `db:"label_value" json:"labelValue,omitempty"`
+	Count      int64   `db:"count" json:"count"`
+}
+
+// ArchivedKind is a kind with resources in the archive
+type ArchivedKind struct {
+	Kind       string `db:"kind" json:"kind"`
+	APIVersion string `db:"api_version" json:"apiVersion"`
+}
+
+// ResourceDefinition is the CustomResourceDefinition of archived custom resources, stored as JSON in Data
+// so the resources can be queried after the CustomResourceDefinition is removed from the cluster
+type ResourceDefinition struct {
+	Group  string `db:"api_group"`
+	Plural string `db:"plural"`
+	Kind   string `db:"kind"`
+	Data   string `db:"data"`
+}
@@ -0,0 +1,37 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package models
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ResourceNode is an archived resource in an ownership tree, along with the archived
+// resources that have it in their ownerReferences
+type ResourceNode struct {
+	Kind              string          `json:"kind"`
+	APIVersion        string          `json:"apiVersion"`
+	Name              string          `json:"name"`
+	Namespace         string          `json:"namespace,omitempty"`
+	UID               string          `json:"uid"`
+	CreationTimestamp metav1.Time     `json:"creationTimestamp"`
+	DeletionTimestamp *metav1.Time    `json:"deletionTimestamp,omitempty"`
+	Children          []*ResourceNode `json:"children"`
+	// Truncated is set in the root of a tree with more levels or resources than the ones returned
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func NewResourceNode(resource *unstructured.Unstructured) *ResourceNode {
+	return &ResourceNode{
+		Kind:              resource.GetKind(),
+		APIVersion:        resource.GetAPIVersion(),
+		Name:              resource.GetName(),
+		Namespace:         resource.GetNamespace(),
+		UID:               string(resource.GetUID()),
+		CreationTimestamp: resource.GetCreationTimestamp(),
+		DeletionTimestamp: resource.GetDeletionTimestamp(),
+		Children:          []*ResourceNode{},
+	}
+}
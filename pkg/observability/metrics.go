@@ -12,6 +12,7 @@ import (
 var (
 	CloudEvents metric.Int64Counter
 	Updates     metric.Int64Counter
+	DeadLetters metric.Int64UpDownCounter
 )
 
 type CEResult string
@@ -23,6 +24,9 @@ const (
 	CEResultError           CEResult = "error"
 	CEResultNoMatch         CEResult = "no_match"
 	CEResultNoConfiguration CEResult = "no_conf"
+	CEResultDeadLetter      CEResult = "dead_letter"
+	CEResultDuplicate       CEResult = "duplicate"
+	CEResultInProgress      CEResult = "in_progress"
 )
 
 func NewCEResultFromWriteResourceResult(result interfaces.WriteResourceResult) CEResult {
@@ -59,4 +63,13 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+
+	DeadLetters, err = meter.Int64UpDownCounter(
+		"kubearchive.dead_letters",
+		metric.WithDescription("Number of resources in the dead-letter queue of the sink waiting to be written to the database"),
+		metric.WithUnit("{count}"),
+	)
+	if err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,215 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package transform
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ohler55/ojg/jp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// HashPrefix is prepended to the values replaced by their hash
+const HashPrefix = "hmac-sha256:"
+
+// ErrNoHashKey is returned when the fields of a resource must be hashed and there is no key to hash them
+var ErrNoHashKey = errors.New("the fields can not be hashed without a hash key")
+
+// protectedObject holds the fields KubeArchive needs to archive and query a resource, the paths that select
+// any of them are invalid
+var protectedObject = map[string]any{
+	"apiVersion": "v1",
+	"kind":       "Kind",
+	"metadata": map[string]any{
+		"name":              "name",
+		"namespace":         "namespace",
+		"uid":               "uid",
+		"resourceVersion":   "1",
+		"creationTimestamp": "2025-01-01T00:00:00Z",
+		"deletionTimestamp": "2025-01-01T00:00:00Z",
+		"ownerReferences":   []any{map[string]any{"uid": "uid"}},
+	},
+}
+
+// Rules are the transformations applied to a resource before it is archived
+type Rules struct {
+	// DropManagedFields removes metadata.managedFields
+	DropManagedFields bool
+	// Remove are the JSONPaths of the fields to remove
+	Remove []string
+	// Hash are the JSONPaths of the fields whose value is replaced by its HMAC-SHA256 with HashKey
+	Hash []string
+	// HashKey is the key of the HMAC of the hashed fields, the resources with fields to hash are not
+	// transformed without it
+	HashKey []byte
+	// MaxStatusSize is the maximum size in bytes of the status, zero means no limit
+	MaxStatusSize int
+}
+
+// Pipeline applies the Rules of a kind to its resources. The transformations run in order: managedFields are
+// dropped, then the fields are removed, then hashed, and finally the status is truncated.
+type Pipeline struct {
+	dropManagedFields bool
+	remove            []jp.Expr
+	hash              []jp.Expr
+	hashKey           []byte
+	maxStatusSize     int
+}
+
+func NewPipeline(rules Rules) (*Pipeline, error) {
+	errList := make([]error, 0)
+	pipeline := &Pipeline{
+		dropManagedFields: rules.DropManagedFields,
+		hashKey:           rules.HashKey,
+		maxStatusSize:     rules.MaxStatusSize,
+	}
+	for i, path := range rules.Remove {
+		x, err := ParseRemovePath(path)
+		if err != nil {
+			errList = append(errList, fmt.Errorf("remove[%d]: %w", i, err))
+			continue
+		}
+		pipeline.remove = append(pipeline.remove, x)
+	}
+	for i, path := range rules.Hash {
+		x, err := ParsePath(path)
+		if err != nil {
+			errList = append(errList, fmt.Errorf("hash[%d]: %w", i, err))
+			continue
+		}
+		pipeline.hash = append(pipeline.hash, x)
+	}
+	if rules.MaxStatusSize < 0 {
+		errList = append(errList, errors.New("maxStatusSize must be greater than or equal to 0"))
+	}
+	if len(errList) > 0 {
+		return nil, errors.Join(errList...)
+	}
+	return pipeline, nil
+}
+
+// ParsePath parses a JSONPath that selects the fields of a resource to transform, like
+// "$.spec.containers[*].env[*].value". The path can not select the whole resource nor the fields KubeArchive
+// needs to archive it, like metadata.name or metadata.uid.
+func ParsePath(path string) (jp.Expr, error) {
+	x, err := jp.ParseString(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path '%s': %w", path, err)
+	}
+	if len(x) == 0 || (len(x) == 1 && isRoot(x[0])) {
+		return nil, fmt.Errorf("invalid path '%s': it selects the whole resource", path)
+	}
+	if len(x.Get(protectedObject)) > 0 {
+		return nil, fmt.Errorf("invalid path '%s': it selects fields required to archive the resource", path)
+	}
+	return x, nil
+}
+
+// ParseRemovePath parses a path like ParsePath that must also end in a field, an index or a wildcard, so the
+// fields it selects can be removed
+func ParseRemovePath(path string) (jp.Expr, error) {
+	x, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = x.Remove(map[string]any{}); err != nil {
+		return nil, fmt.Errorf("invalid path '%s': %w", path, err)
+	}
+	return x, nil
+}
+
+// Apply transforms the resource in place. It returns ErrNoHashKey without transforming the resource when it
+// has fields to hash and the pipeline has no hash key.
+func (p *Pipeline) Apply(obj *unstructured.Unstructured) error {
+	if len(p.hash) > 0 && len(p.hashKey) == 0 {
+		return ErrNoHashKey
+	}
+	if p.dropManagedFields {
+		obj.SetManagedFields(nil)
+	}
+	for _, x := range p.remove {
+		if _, err := x.Remove(obj.Object); err != nil {
+			return fmt.Errorf("could not remove '%s': %w", x.String(), err)
+		}
+	}
+	for _, x := range p.hash {
+		if _, err := x.Modify(obj.Object, p.hashValue); err != nil {
+			return fmt.Errorf("could not hash '%s': %w", x.String(), err)
+		}
+	}
+	if p.maxStatusSize > 0 {
+		truncateStatus(obj, p.maxStatusSize)
+	}
+	return nil
+}
+
+// hashValue replaces a value by the HMAC of its string or, for other types, of its JSON encoding. Null values
+// are kept. The key keeps the values that can be guessed, like short passwords, from being found by hashing
+// the candidates.
+func (p *Pipeline) hashValue(element any) (any, bool) {
+	if element == nil {
+		return nil, false
+	}
+	raw, ok := element.(string)
+	if !ok {
+		encoded, err := json.Marshal(element)
+		if err != nil {
+			encoded = []byte(fmt.Sprint(element))
+		}
+		raw = string(encoded)
+	}
+	mac := hmac.New(sha256.New, p.hashKey)
+	mac.Write([]byte(raw))
+	return HashPrefix + hex.EncodeToString(mac.Sum(nil)), true
+}
+
+// truncateStatus removes the largest fields of the status until its JSON encoding fits in maxSize bytes
+func truncateStatus(obj *unstructured.Unstructured, maxSize int) {
+	status, ok := obj.Object["status"].(map[string]any)
+	if !ok || jsonSize(status) <= maxSize {
+		return
+	}
+
+	sizes := make(map[string]int, len(status))
+	fields := make([]string, 0, len(status))
+	for field, value := range status {
+		sizes[field] = jsonSize(value)
+		fields = append(fields, field)
+	}
+	slices.SortFunc(fields, func(a, b string) int {
+		if c := cmp.Compare(sizes[b], sizes[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	for _, field := range fields {
+		delete(status, field)
+		if jsonSize(status) <= maxSize {
+			return
+		}
+	}
+}
+
+func jsonSize(value any) int {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
+
+func isRoot(fragment jp.Frag) bool {
+	switch fragment.(type) {
+	case jp.Root, jp.At:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+// Copyright KubeArchive Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package transform
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newPod() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]any{
+			"name":      "pod",
+			"namespace": "test",
+			"uid":       "1234",
+			"managedFields": []any{
+				map[string]any{"manager": "kubectl", "operation": "Update"},
+			},
+		},
+		"spec": map[string]any{
+			"containers": []any{
+				map[string]any{
+					"name": "main",
+					"env": []any{
+						map[string]any{"name": "TOKEN", "value": "secret"},
+						map[string]any{"name": "PORT", "value": "8080"},
+					},
+				},
+			},
+		},
+		"status": map[string]any{
+			"phase":             "Succeeded",
+			"containerStatuses": []any{map[string]any{"name": "main", "message": "a very long termination message"}},
+		},
+	}}
+}
+
+var testHashKey = []byte("0123456789abcdef0123456789abcdef")
+
+func hashOf(value string) string {
+	mac := hmac.New(sha256.New, testHashKey)
+	mac.Write([]byte(value))
+	return HashPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		remove  bool
+		wantErr string
+	}{
+		{name: "field", path: "$.spec.containers[*].env[*].value"},
+		{name: "map values", path: "$.data.*", remove: true},
+		{name: "without root", path: "spec.nodeName", remove: true},
+		{name: "descent", path: "$..value"},
+		{name: "descent can not be removed", path: "$..value", remove: true, wantErr: "Descent"},
+		{name: "malformed", path: "$.spec[", wantErr: "invalid path"},
+		{name: "root", path: "$", wantErr: "whole resource"},
+		{name: "name", path: "$.metadata.name", wantErr: "required to archive"},
+		{name: "all metadata", path: "$.metadata.*", wantErr: "required to archive"},
+		{name: "any uid", path: "$..uid", wantErr: "required to archive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.remove {
+				_, err = ParseRemovePath(tt.path)
+			} else {
+				_, err = ParsePath(tt.path)
+			}
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewPipelineErrors(t *testing.T) {
+	_, err := NewPipeline(Rules{
+		Remove:        []string{"$.spec.nodeName", "$..env"},
+		Hash:          []string{"$.kind"},
+		MaxStatusSize: -1,
+	})
+	assert.ErrorContains(t, err, "remove[1]")
+	assert.ErrorContains(t, err, "hash[0]")
+	assert.ErrorContains(t, err, "maxStatusSize")
+}
+
+func TestApply(t *testing.T) {
+	pipeline, err := NewPipeline(Rules{
+		DropManagedFields: true,
+		Remove:            []string{"$.spec.containers[*].env[?(@.name == 'PORT')]"},
+		Hash:              []string{"$.spec.containers[*].env[*].value"},
+		HashKey:           testHashKey,
+		MaxStatusSize:     30,
+	})
+	assert.NoError(t, err)
+
+	pod := newPod()
+	assert.NoError(t, pipeline.Apply(pod))
+
+	assert.Nil(t, pod.GetManagedFields())
+	env, _, _ := unstructured.NestedSlice(pod.Object, "spec", "containers")
+	assert.Equal(t, []any{map[string]any{"name": "TOKEN", "value": hashOf("secret")}},
+		env[0].(map[string]any)["env"])
+	status, _, _ := unstructured.NestedMap(pod.Object, "status")
+	assert.Equal(t, map[string]any{"phase": "Succeeded"}, status)
+	assert.Equal(t, "pod", pod.GetName())
+	assert.Equal(t, "1234", string(pod.GetUID()))
+}
+
+func TestApplyWithoutMatches(t *testing.T) {
+	pipeline, err := NewPipeline(Rules{
+		Remove:        []string{"$.data.*"},
+		Hash:          []string{"$.stringData.*"},
+		HashKey:       testHashKey,
+		MaxStatusSize: 1000,
+	})
+	assert.NoError(t, err)
+
+	pod := newPod()
+	assert.NoError(t, pipeline.Apply(pod))
+	assert.Equal(t, newPod(), pod)
+}
+
+func TestApplyWithoutHashKey(t *testing.T) {
+	pipeline, err := NewPipeline(Rules{
+		DropManagedFields: true,
+		Hash:              []string{"$.spec.containers[*].env[*].value"},
+	})
+	assert.NoError(t, err)
+
+	pod := newPod()
+	assert.ErrorIs(t, pipeline.Apply(pod), ErrNoHashKey)
+	assert.Equal(t, newPod(), pod)
+}
+
+func TestHashValue(t *testing.T) {
+	pipeline := &Pipeline{hashKey: testHashKey}
+	hashed, changed := pipeline.hashValue("secret")
+	assert.True(t, changed)
+	assert.Equal(t, hashOf("secret"), hashed)
+
+	hashed, changed = pipeline.hashValue(map[string]any{"user": "admin"})
+	assert.True(t, changed)
+	assert.Equal(t, hashOf(`{"user":"admin"}`), hashed)
+
+	hashed, changed = pipeline.hashValue(nil)
+	assert.False(t, changed)
+	assert.Nil(t, hashed)
+
+	// Another key gives another hash
+	other := &Pipeline{hashKey: []byte("another key of the same length!!")}
+	hashed, _ = other.hashValue("secret")
+	assert.NotEqual(t, hashOf("secret"), hashed)
+}
@@ -354,7 +354,7 @@ func getUrl(t testing.TB, token string, url string, extraHeaders map[string][]st
 
 	if httpResp.StatusCode != http.StatusOK {
 		t.Logf("HTTP status: %d, response: %s", httpResp.StatusCode, string(httpResp.Body))
-		if httpResp.StatusCode == http.StatusUnauthorized {
+		if httpResp.StatusCode == http.StatusUnauthorized || httpResp.StatusCode == http.StatusForbidden {
 			return nil, ErrUnauth
 		}
 		return nil, fmt.Errorf("%d", httpResp.StatusCode)
@@ -418,7 +418,7 @@ func makeGzipRequest(t testing.TB, token string, url string, extraHeaders map[st
 	}
 
 	if httpResp.StatusCode != http.StatusOK {
-		if httpResp.StatusCode == http.StatusUnauthorized {
+		if httpResp.StatusCode == http.StatusUnauthorized || httpResp.StatusCode == http.StatusForbidden {
 			return nil, ErrUnauth
 		}
 		return nil, fmt.Errorf("HTTP %d: %s", httpResp.StatusCode, string(httpResp.Body))